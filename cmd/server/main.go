@@ -1,42 +1,217 @@
 package main
 
 import (
-	"log"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
+	"streaming/internal/apierr"
 	"streaming/internal/config"
 	"streaming/internal/db"
 	httpserver "streaming/internal/http"
+	"streaming/internal/logging"
+	"streaming/internal/notify"
+	"streaming/internal/tracing"
 )
 
+// Использование:
+//
+//	server [-config file.yaml]               — запуск
+//	server [-config file.yaml] config check  — проверить и напечатать конфиг (секреты скрыты)
+//	server smtp-stub [addr]                  — SMTP-заглушка: печатает письма (по умолчанию localhost:2525)
+//	server vapid-keys                        — новая пара ключей для NOTIFY_WEBPUSH_*
+//	server error-codes [file.ts]             — каталог ошибок API для фронтенда (go generate ./internal/apierr)
 func main() {
 	// Загружаем .env (если есть)
 	_ = godotenv.Load()
 
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML/TOML/JSON config file (env overrides it)")
+	flag.Parse()
+
+	if args := flag.Args(); len(args) > 0 {
+		switch {
+		case len(args) == 2 && args[0] == "config" && args[1] == "check":
+			os.Exit(configCheck(*configPath))
+		case len(args) <= 2 && args[0] == "smtp-stub":
+			addr := "localhost:2525"
+			if len(args) == 2 {
+				addr = args[1]
+			}
+			os.Exit(smtpStub(addr))
+		case len(args) == 1 && args[0] == "vapid-keys":
+			os.Exit(vapidKeys())
+		case len(args) <= 2 && args[0] == "error-codes":
+			out := ""
+			if len(args) == 2 {
+				out = args[1]
+			}
+			os.Exit(errorCodes(out))
+		}
+		fmt.Fprintf(os.Stderr, "unknown command: %s\nusage: server [-config file] [config check | smtp-stub [addr] | vapid-keys | error-codes [file.ts]]\n", strings.Join(args, " "))
+		os.Exit(2)
+	}
+
+	// run() вместо log.Fatal в main: defer'ы (пул БД, трассировка) отрабатывают всегда
+	if err := run(*configPath); err != nil {
+		slog.Error("server stopped with error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// configCheck: 0 — конфиг валиден, 1 — ошибки (печатаются все сразу)
+func configCheck(path string) int {
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ invalid config:\n%v\n", err)
+		return 1
+	}
+	if err := cfg.PrintEffective(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println()
+	cfg.PrintSecurityReport(os.Stdout)
+	fmt.Println("\n✅ config OK")
+	return 0
+}
+
+// smtpStub — письма уведомлений для локальной разработки: NOTIFY_SMTP_ADDR=localhost:2525
+func smtpStub(addr string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	stub := &notify.SMTPStub{Addr: addr, Out: os.Stdout}
+	if err := stub.ListenAndServe(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func vapidKeys() int {
+	pub, priv, err := notify.GenerateVAPIDKeys()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("NOTIFY_WEBPUSH_PUBLIC_KEY=%s\nNOTIFY_WEBPUSH_PRIVATE_KEY=%s\n", pub, priv)
+	return 0
+}
+
+// errorCodes — TypeScript-каталог ошибок в файл (или stdout)
+func errorCodes(path string) int {
+	if path == "" {
+		if err := apierr.WriteTS(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	var buf bytes.Buffer
+	if err := apierr.WriteTS(&buf); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func run(configPath string) error {
+	// SIGTERM (deploy) / Ctrl+C — мягкая остановка
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// =========================
 	// 1) Load config
 	// =========================
-	cfg, err := config.Load()
+	cfg, err := config.LoadFile(configPath)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	store := config.NewStore(cfg)
+
+	logging.Setup(cfg.Log.Format, cfg.Log.Level)
+	logSecurityReport(cfg)
+
+	// SIGHUP — перечитать горячие настройки без рестарта
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for range hup {
+			if err := store.Reload(); err != nil {
+				slog.Error("config reload failed, keeping current config", "error", err)
+				continue
+			}
+			logging.SetLevel(store.Get().Log.Level)
+		}
+	}()
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Exporter:     cfg.Tracing.Exporter,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPInsecure: cfg.Tracing.OTLPInsecure,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+		ServiceName:  cfg.Tracing.ServiceName,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("tracing shutdown", "error", err)
+		}
+	}()
 
 	// =========================
 	// 2) Connect database
 	// =========================
 	dbConn, err := db.New(cfg.Database.URL) // ✅ ВАЖНО: Database.URL
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer dbConn.Close()
+	defer func() {
+		if err := dbConn.Close(); err != nil {
+			slog.Warn("database close", "error", err)
+			return
+		}
+		slog.Info("Database pool closed")
+	}()
 
-	log.Println("✅ Database connected")
+	slog.Info("✅ Database connected")
 
 	// =========================
-	// 3) Run HTTP server
+	// 3) Run HTTP server (до сигнала)
 	// =========================
-	if err := httpserver.Run(cfg, dbConn); err != nil {
-		log.Fatal(err)
+	return httpserver.Run(ctx, store, dbConn)
+}
+
+// logSecurityReport — при старте: все проблемы безопасности одним списком
+// (запрещённые в этом окружении сюда не дойдут — LoadFile вернёт ошибку).
+func logSecurityReport(cfg *config.Config) {
+	issues := cfg.SecurityIssues()
+	if len(issues) == 0 {
+		slog.Info("security check passed", "env", cfg.Env)
+		return
+	}
+
+	for _, i := range issues {
+		slog.Warn("security issue", "env", cfg.Env, "key", i.Key, "issue", i.Message, "critical", i.Critical)
 	}
+	slog.Warn("insecure configuration: not allowed with APP_ENV=production (critical ones — with staging too)", "env", cfg.Env, "issues", len(issues))
 }
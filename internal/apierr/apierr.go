@@ -1,38 +1,57 @@
 package apierr
 
 import (
-	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+
+	"streaming/internal/metrics"
+	"streaming/internal/tracing"
 )
 
 type APIError struct {
 	Code    string `json:"code"`
-	Message string `json:"message"`
+	Message string `json:"message"` // на языке клиента (Accept-Language)
+	// TraceID — для обращений в поддержку: по нему находится трасса запроса
+	TraceID string `json:"trace_id,omitempty"`
+	// Details — данные для клиента, если одного кода мало (место в очереди...)
+	Details any `json:"details,omitempty"`
 }
 
 type ErrorResponse struct {
 	Error APIError `json:"error"`
 }
 
-func JSON(c *gin.Context, status int, code, message string) {
-	c.JSON(status, ErrorResponse{
-		Error: APIError{Code: code, Message: message},
-	})
+// Write — ответ с ошибкой каталога
+func Write(c *gin.Context, code *Code) {
+	WriteDetails(c, code, nil)
 }
 
-func BadRequest(c *gin.Context, code, message string) {
-	JSON(c, http.StatusBadRequest, code, message)
+// WriteReason — плюс details.reason: что именно не так в запросе (для разработчика, по-английски).
+// Только свои тексты — не err.Error() из БД и библиотек.
+func WriteReason(c *gin.Context, code *Code, reason string) {
+	WriteDetails(c, code, gin.H{"reason": reason})
 }
 
-func Unauthorized(c *gin.Context, code, message string) {
-	JSON(c, http.StatusUnauthorized, code, message)
-}
-
-func Forbidden(c *gin.Context, code, message string) {
-	JSON(c, http.StatusForbidden, code, message)
+// WriteDetails — плюс error.details
+func WriteDetails(c *gin.Context, code *Code, details any) {
+	metrics.APIErrors.WithLabelValues(code.ID, strconv.Itoa(code.Status)).Inc()
+
+	lang := Lang(c)
+	c.Header("Content-Language", lang)
+	c.Header("Vary", "Accept-Language")
+	c.JSON(code.Status, ErrorResponse{
+		Error: APIError{
+			Code:    code.ID,
+			Message: code.Message(lang),
+			TraceID: tracing.TraceID(c.Request.Context()),
+			Details: details,
+		},
+	})
 }
 
-func Internal(c *gin.Context, code, message string) {
-	JSON(c, http.StatusInternalServerError, code, message)
+// Abort — Write и остановить цепочку (для middleware)
+func Abort(c *gin.Context, code *Code) {
+	Write(c, code)
+	c.Abort()
 }
@@ -0,0 +1,424 @@
+package apierr
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+//go:generate go run ../../cmd/server error-codes ../../web/src/errorCodes.ts
+
+// Code — ошибка из каталога: код для клиента, HTTP-статус и безопасный текст на каждом языке.
+// Внутренние подробности (err.Error() из БД, LiveKit...) клиенту не уходят — только в лог.
+type Code struct {
+	ID     string
+	Status int
+	msg    Messages
+}
+
+// Messages — текст ошибки для клиента (все языки обязательны)
+type Messages struct {
+	EN, RU, TK string
+}
+
+// Message — текст на языке lang (незнакомый язык — по-английски)
+func (c *Code) Message(lang string) string {
+	switch lang {
+	case LangRU:
+		return c.msg.RU
+	case LangTK:
+		return c.msg.TK
+	}
+	return c.msg.EN
+}
+
+var catalog = map[string]*Code{}
+
+// define — новый код каталога; дубль или пропущенный перевод ломают старт, а не ответ клиенту
+func define(id string, status int, msg Messages) *Code {
+	if _, dup := catalog[id]; dup {
+		panic("apierr: duplicate code " + id)
+	}
+	if msg.EN == "" || msg.RU == "" || msg.TK == "" {
+		panic(fmt.Sprintf("apierr: code %s must have en, ru and tk messages", id))
+	}
+	c := &Code{ID: id, Status: status, msg: msg}
+	catalog[id] = c
+	return c
+}
+
+// Codes — весь каталог по ID (генерация кодов для фронтенда)
+func Codes() []*Code {
+	out := make([]*Code, 0, len(catalog))
+	for _, c := range catalog {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// =======================
+// Запрос и доступ
+// =======================
+
+var (
+	InvalidJSON = define("INVALID_JSON", http.StatusBadRequest, Messages{
+		EN: "Invalid JSON body",
+		RU: "Некорректный JSON в запросе",
+		TK: "Haýyşdaky JSON nädogry",
+	})
+	InvalidRequest = define("INVALID_REQUEST", http.StatusBadRequest, Messages{
+		EN: "Invalid request parameters",
+		RU: "Некорректные параметры запроса",
+		TK: "Haýyşyň parametrleri nädogry",
+	})
+	InvalidDate = define("INVALID_DATE", http.StatusBadRequest, Messages{
+		EN: "Invalid date or date range",
+		RU: "Некорректная дата или период",
+		TK: "Sene ýa-da döwür nädogry",
+	})
+	InvalidTimezone = define("INVALID_TIMEZONE", http.StatusBadRequest, Messages{
+		EN: "Unknown time zone",
+		RU: "Неизвестный часовой пояс",
+		TK: "Näbelli sagat guşaklygy",
+	})
+	Unauthorized = define("UNAUTHORIZED", http.StatusUnauthorized, Messages{
+		EN: "Authentication required",
+		RU: "Требуется авторизация",
+		TK: "Ygtyýarlandyrma gerek",
+	})
+	Forbidden = define("FORBIDDEN", http.StatusForbidden, Messages{
+		EN: "Access denied",
+		RU: "Доступ запрещён",
+		TK: "Giriş gadagan",
+	})
+	HostAuthRequired = define("HOST_AUTH_REQUIRED", http.StatusUnauthorized, Messages{
+		EN: "Site login required",
+		RU: "Требуется вход на сайт",
+		TK: "Saýta girmek gerek",
+	})
+	TeacherKeyRequired = define("TEACHER_KEY_REQUIRED", http.StatusForbidden, Messages{
+		EN: "Teacher key required",
+		RU: "Нужен ключ учителя",
+		TK: "Mugallymyň açary gerek",
+	})
+	TeacherOnly = define("TEACHER_ONLY", http.StatusForbidden, Messages{
+		EN: "Only the teacher can do this",
+		RU: "Это может сделать только учитель",
+		TK: "Muny diňe mugallym edip biler",
+	})
+	InvalidToken = define("INVALID_TOKEN", http.StatusUnauthorized, Messages{
+		EN: "Token is invalid or expired, join again",
+		RU: "Токен недействителен или истёк, войдите заново",
+		TK: "Token nädogry ýa-da möhleti geçdi, täzeden giriň",
+	})
+	RateLimited = define("RATE_LIMITED", http.StatusTooManyRequests, Messages{
+		EN: "Too many requests, try again later",
+		RU: "Слишком много запросов, попробуйте позже",
+		TK: "Haýyş gaty köp, soňrak synanyşyň",
+	})
+	DBError = define("DB_ERROR", http.StatusInternalServerError, Messages{
+		EN: "Internal error, try again later",
+		RU: "Внутренняя ошибка, попробуйте позже",
+		TK: "Içki ýalňyşlyk, soňrak synanyşyň",
+	})
+	KeyGenerationFailed = define("KEY_GENERATION_FAILED", http.StatusInternalServerError, Messages{
+		EN: "Failed to generate a key",
+		RU: "Не удалось создать ключ",
+		TK: "Açary döredip bolmady",
+	})
+)
+
+// =======================
+// Школы и классы (админка)
+// =======================
+
+var (
+	TenantNotFound = define("TENANT_NOT_FOUND", http.StatusBadRequest, Messages{
+		EN: "Unknown school",
+		RU: "Неизвестная школа",
+		TK: "Näbelli mekdep",
+	})
+	TenantCreateFailed = define("TENANT_CREATE_FAILED", http.StatusBadRequest, Messages{
+		EN: "School could not be created (slug taken?)",
+		RU: "Не удалось создать школу (slug уже занят?)",
+		TK: "Mekdebi döredip bolmady (slug eýýäm bar?)",
+	})
+	AdminCreateFailed = define("ADMIN_CREATE_FAILED", http.StatusBadRequest, Messages{
+		EN: "Admin could not be created (username taken?)",
+		RU: "Не удалось создать администратора (логин уже занят?)",
+		TK: "Administratory döredip bolmady (login eýýäm bar?)",
+	})
+	ClassCreateFailed = define("CLASS_CREATE_FAILED", http.StatusBadRequest, Messages{
+		EN: "Class could not be created (name taken?)",
+		RU: "Не удалось создать класс (название уже занято?)",
+		TK: "Synpy döredip bolmady (ady eýýäm bar?)",
+	})
+	ClassNotFound = define("CLASS_NOT_FOUND", http.StatusNotFound, Messages{
+		EN: "Class not found",
+		RU: "Класс не найден",
+		TK: "Synp tapylmady",
+	})
+	InvalidCSV = define("INVALID_CSV", http.StatusBadRequest, Messages{
+		EN: "The CSV file has errors",
+		RU: "В CSV-файле есть ошибки",
+		TK: "CSV faýlynda ýalňyşlyklar bar",
+	})
+)
+
+// =======================
+// Комнаты и уроки
+// =======================
+
+var (
+	RoomNotFound = define("ROOM_NOT_FOUND", http.StatusNotFound, Messages{
+		EN: "Room not found, check the link",
+		RU: "Комната не найдена, проверьте ссылку",
+		TK: "Otag tapylmady, salgyny barlaň",
+	})
+	RoomExists = define("ROOM_EXISTS", http.StatusConflict, Messages{
+		EN: "A room with this slug already exists",
+		RU: "Комната с таким slug уже есть",
+		TK: "Şeýle slug bilen otag eýýäm bar",
+	})
+	RoomFull = define("ROOM_FULL", http.StatusConflict, Messages{
+		EN: "The room is full",
+		RU: "В комнате нет свободных мест",
+		TK: "Otagda boş ýer ýok",
+	})
+	NoActiveLesson = define("NO_ACTIVE_LESSON", http.StatusBadRequest, Messages{
+		EN: "The lesson has not started yet",
+		RU: "Урок ещё не начался",
+		TK: "Sapak entek başlamady",
+	})
+	LessonEnded = define("LESSON_ENDED", http.StatusForbidden, Messages{
+		EN: "The lesson has ended",
+		RU: "Урок закончился",
+		TK: "Sapak tamamlandy",
+	})
+	LessonNotFound = define("LESSON_NOT_FOUND", http.StatusNotFound, Messages{
+		EN: "Lesson not found",
+		RU: "Урок не найден",
+		TK: "Sapak tapylmady",
+	})
+	LessonStartFailed = define("LESSON_START_FAILED", http.StatusInternalServerError, Messages{
+		EN: "Failed to start the lesson, try again",
+		RU: "Не удалось начать урок, попробуйте ещё раз",
+		TK: "Sapagy başlap bolmady, täzeden synanyşyň",
+	})
+	ParticipantDenied = define("PARTICIPANT_DENIED", http.StatusForbidden, Messages{
+		EN: "You were removed from this room",
+		RU: "Вас удалили из этой комнаты",
+		TK: "Siz bu otagdan aýryldyňyz",
+	})
+	NotOnRoster = define("NOT_ON_ROSTER", http.StatusForbidden, Messages{
+		EN: "You are not on the class list for this room",
+		RU: "Вас нет в списке класса этой комнаты",
+		TK: "Siz bu otagyň synp sanawynda ýok",
+	})
+	NotAParticipant = define("NOT_A_PARTICIPANT", http.StatusForbidden, Messages{
+		EN: "You are not in this lesson, join again",
+		RU: "Вы не участник этого урока, войдите заново",
+		TK: "Siz bu sapagyň gatnaşyjysy däl, täzeden giriň",
+	})
+	ParticipantNotConnected = define("PARTICIPANT_NOT_CONNECTED", http.StatusNotFound, Messages{
+		EN: "The participant is not in the room",
+		RU: "Участника нет в комнате",
+		TK: "Gatnaşyjy otagda ýok",
+	})
+	MetadataForbidden = define("METADATA_FORBIDDEN", http.StatusForbidden, Messages{
+		EN: "Participants can only update their own data",
+		RU: "Участник может менять только свои данные",
+		TK: "Gatnaşyjy diňe öz maglumatlaryny üýtgedip biler",
+	})
+)
+
+// =======================
+// Видеосервер (LiveKit)
+// =======================
+
+var (
+	LiveKitNoCapacity = define("LIVEKIT_NO_CAPACITY", http.StatusServiceUnavailable, Messages{
+		EN: "All video servers are full, try again later",
+		RU: "Все видеосерверы заняты, попробуйте позже",
+		TK: "Ähli wideo serwerler doly, soňrak synanyşyň",
+	})
+	LiveKitNodeUnavailable = define("LIVEKIT_NODE_UNAVAILABLE", http.StatusServiceUnavailable, Messages{
+		EN: "The video server for this lesson is unavailable",
+		RU: "Видеосервер этого урока недоступен",
+		TK: "Bu sapagyň wideo serweri elýeterli däl",
+	})
+	LiveKitUnavailable = define("LIVEKIT_UNAVAILABLE", http.StatusServiceUnavailable, Messages{
+		EN: "The video server did not respond, try again later",
+		RU: "Видеосервер не ответил, попробуйте позже",
+		TK: "Wideo serwer jogap bermedi, soňrak synanyşyň",
+	})
+	LiveKitTokenError = define("LIVEKIT_TOKEN_ERROR", http.StatusInternalServerError, Messages{
+		EN: "Failed to issue a video token, try again",
+		RU: "Не удалось выдать токен для видео, попробуйте ещё раз",
+		TK: "Wideo tokenini berip bolmady, täzeden synanyşyň",
+	})
+	NoAudioTrack = define("NO_AUDIO_TRACK", http.StatusConflict, Messages{
+		EN: "The teacher's microphone is not on",
+		RU: "Микрофон учителя не включён",
+		TK: "Mugallymyň mikrofony açyk däl",
+	})
+)
+
+// =======================
+// Субтитры, опросы, доска
+// =======================
+
+var (
+	CaptionsDisabled = define("CAPTIONS_DISABLED", http.StatusServiceUnavailable, Messages{
+		EN: "Captions are not available on this server",
+		RU: "Субтитры на этом сервере не настроены",
+		TK: "Bu serwerde subtitrler sazlanmady",
+	})
+	CaptionsRunning = define("CAPTIONS_RUNNING", http.StatusConflict, Messages{
+		EN: "Captions are already on for this lesson",
+		RU: "Субтитры для этого урока уже включены",
+		TK: "Bu sapak üçin subtitrler eýýäm açyk",
+	})
+	CaptionsNotRunning = define("CAPTIONS_NOT_RUNNING", http.StatusNotFound, Messages{
+		EN: "Captions are not on for this lesson",
+		RU: "Субтитры для этого урока не включены",
+		TK: "Bu sapak üçin subtitrler açyk däl",
+	})
+	PollNotFound = define("POLL_NOT_FOUND", http.StatusNotFound, Messages{
+		EN: "Poll not found",
+		RU: "Опрос не найден",
+		TK: "Sorag-jogap tapylmady",
+	})
+	PollClosed = define("POLL_CLOSED", http.StatusConflict, Messages{
+		EN: "The poll is closed",
+		RU: "Опрос уже закрыт",
+		TK: "Sorag-jogap ýapyldy",
+	})
+	InvalidPoll = define("INVALID_POLL", http.StatusBadRequest, Messages{
+		EN: "Invalid poll",
+		RU: "Некорректный опрос",
+		TK: "Sorag-jogap nädogry",
+	})
+	InvalidAnswer = define("INVALID_ANSWER", http.StatusBadRequest, Messages{
+		EN: "Invalid answer",
+		RU: "Некорректный ответ",
+		TK: "Jogap nädogry",
+	})
+	InvalidWhiteboardOp = define("INVALID_WHITEBOARD_OP", http.StatusBadRequest, Messages{
+		EN: "Invalid whiteboard operation",
+		RU: "Некорректное действие на доске",
+		TK: "Tagtadaky hereket nädogry",
+	})
+	WhiteboardForbidden = define("WHITEBOARD_FORBIDDEN", http.StatusForbidden, Messages{
+		EN: "Students can only draw; editing and erasing is for the teacher",
+		RU: "Ученики могут только рисовать; правка и стирание — у учителя",
+		TK: "Okuwçylar diňe çekip bilýär; üýtgetmek we pozmak mugallymda",
+	})
+	RenderFailed = define("RENDER_FAILED", http.StatusInternalServerError, Messages{
+		EN: "Failed to render the whiteboard",
+		RU: "Не удалось нарисовать доску",
+		TK: "Tagtany çekip bolmady",
+	})
+)
+
+// =======================
+// Файлы
+// =======================
+
+var (
+	FileNotFound = define("FILE_NOT_FOUND", http.StatusNotFound, Messages{
+		EN: "File not found",
+		RU: "Файл не найден",
+		TK: "Faýl tapylmady",
+	})
+	FileForbidden = define("FILE_FORBIDDEN", http.StatusForbidden, Messages{
+		EN: "Only the author or the teacher can delete this file",
+		RU: "Удалить файл может только автор или учитель",
+		TK: "Faýly diňe awtor ýa-da mugallym pozup biler",
+	})
+	FileTooLarge = define("FILE_TOO_LARGE", http.StatusRequestEntityTooLarge, Messages{
+		EN: "The file is too large",
+		RU: "Файл слишком большой",
+		TK: "Faýl gaty uly",
+	})
+	FileTypeNotAllowed = define("FILE_TYPE_NOT_ALLOWED", http.StatusUnsupportedMediaType, Messages{
+		EN: "Files of this type are not allowed",
+		RU: "Файлы такого типа загружать нельзя",
+		TK: "Bu görnüşdäki faýllary ýüklemek bolmaýar",
+	})
+	FileInfected = define("FILE_INFECTED", http.StatusUnprocessableEntity, Messages{
+		EN: "The file did not pass the virus check",
+		RU: "Файл не прошёл проверку на вирусы",
+		TK: "Faýl wirus barlagyndan geçmedi",
+	})
+	ScanUnavailable = define("SCAN_UNAVAILABLE", http.StatusServiceUnavailable, Messages{
+		EN: "File check is unavailable, try again later",
+		RU: "Проверка файлов недоступна, попробуйте позже",
+		TK: "Faýl barlagy elýeterli däl, soňrak synanyşyň",
+	})
+	StorageUnavailable = define("STORAGE_UNAVAILABLE", http.StatusServiceUnavailable, Messages{
+		EN: "File storage is unavailable, try again later",
+		RU: "Хранилище файлов недоступно, попробуйте позже",
+		TK: "Faýl ammary elýeterli däl, soňrak synanyşyň",
+	})
+	UploadFailed = define("UPLOAD_FAILED", http.StatusInternalServerError, Messages{
+		EN: "Failed to process the file",
+		RU: "Не удалось обработать файл",
+		TK: "Faýly işläp bolmady",
+	})
+)
+
+// =======================
+// Биллинг
+// =======================
+
+var (
+	BillingPeriodNotFound = define("BILLING_PERIOD_NOT_FOUND", http.StatusNotFound, Messages{
+		EN: "Billing period not found",
+		RU: "Расчётный период не найден",
+		TK: "Hasaplaşyk döwri tapylmady",
+	})
+	BillingPeriodClosed = define("BILLING_PERIOD_CLOSED", http.StatusConflict, Messages{
+		EN: "This month is already closed",
+		RU: "Этот месяц уже закрыт",
+		TK: "Bu aý eýýäm ýapyldy",
+	})
+	BillingPeriodNotOver = define("BILLING_PERIOD_NOT_OVER", http.StatusBadRequest, Messages{
+		EN: "The month is not over yet",
+		RU: "Месяц ещё не закончился",
+		TK: "Aý entek gutarmady",
+	})
+	BillingOpenLessons = define("BILLING_OPEN_LESSONS", http.StatusConflict, Messages{
+		EN: "Some lessons of this month are still open; end them before closing the period",
+		RU: "Часть уроков месяца ещё не закрыта; завершите их до закрытия периода",
+		TK: "Aýyň käbir sapaklary entek açyk; döwri ýapmazdan öň olary tamamlaň",
+	})
+)
+
+// =======================
+// Уведомления
+// =======================
+
+var (
+	ChannelDisabled = define("CHANNEL_DISABLED", http.StatusBadRequest, Messages{
+		EN: "This notification channel is not available on this server",
+		RU: "Этот канал уведомлений на сервере не настроен",
+		TK: "Bu habarnama kanaly serwerde sazlanmady",
+	})
+	WebPushDisabled = define("WEBPUSH_DISABLED", http.StatusNotFound, Messages{
+		EN: "Browser notifications are not available on this server",
+		RU: "Уведомления в браузере на этом сервере не настроены",
+		TK: "Bu serwerde brauzer habarnamalary sazlanmady",
+	})
+	NotificationNotFound = define("NOTIFICATION_NOT_FOUND", http.StatusNotFound, Messages{
+		EN: "Notification not found or already sent",
+		RU: "Уведомление не найдено или уже отправлено",
+		TK: "Habarnama tapylmady ýa-da eýýäm iberildi",
+	})
+	ScheduledLessonNotFound = define("SCHEDULED_LESSON_NOT_FOUND", http.StatusNotFound, Messages{
+		EN: "Scheduled lesson not found",
+		RU: "Урок в расписании не найден",
+		TK: "Meýilnamadaky sapak tapylmady",
+	})
+)
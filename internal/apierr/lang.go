@@ -0,0 +1,53 @@
+package apierr
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Языки сообщений клиенту
+const (
+	LangEN = "en"
+	LangRU = "ru"
+	LangTK = "tk"
+)
+
+// DefaultLang — без Accept-Language (или только с незнакомыми языками)
+const DefaultLang = LangEN
+
+// Lang — язык клиента из Accept-Language
+func Lang(c *gin.Context) string {
+	return ParseLang(c.GetHeader("Accept-Language"))
+}
+
+// ParseLang — самый предпочтительный из поддерживаемых языков:
+// "tk-TM,ru;q=0.8,en;q=0.5" => tk; при равном q — первый в списке.
+func ParseLang(header string) string {
+	best, bestQ := DefaultLang, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		primary, _, _ = strings.Cut(primary, "_")
+
+		switch primary {
+		case LangEN, LangRU, LangTK:
+		default:
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if q > bestQ {
+			best, bestQ = primary, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,45 @@
+package apierr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteTS — каталог для фронтенда (web/src/errorCodes.ts): коды, статусы и тексты
+func WriteTS(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	q := func(s string) string {
+		raw, _ := json.Marshal(s)
+		return string(raw)
+	}
+
+	fmt.Fprintln(b, "// Code generated by `server error-codes`; DO NOT EDIT.")
+	fmt.Fprintln(b, "// Источник: internal/apierr/catalog.go")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "export const ErrorCodes = {")
+	for _, c := range Codes() {
+		fmt.Fprintf(b, "  %s: %d,\n", c.ID, c.Status)
+	}
+	fmt.Fprintln(b, "} as const;")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "export type ErrorCode = keyof typeof ErrorCodes;")
+	fmt.Fprintf(b, "export type ErrorLang = %q | %q | %q;\n", LangEN, LangRU, LangTK)
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "export const ErrorMessages: Record<ErrorCode, Record<ErrorLang, string>> = {")
+	for _, c := range Codes() {
+		fmt.Fprintf(b, "  %s: {\n    en: %s,\n    ru: %s,\n    tk: %s,\n  },\n", c.ID, q(c.msg.EN), q(c.msg.RU), q(c.msg.TK))
+	}
+	fmt.Fprintln(b, "};")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "// тело ответа с ошибкой: { error: ApiErrorBody }")
+	fmt.Fprintln(b, "export type ApiErrorBody = {")
+	fmt.Fprintln(b, "  code: ErrorCode;")
+	fmt.Fprintln(b, "  message: string;")
+	fmt.Fprintln(b, "  trace_id?: string;")
+	fmt.Fprintln(b, "  details?: Record<string, unknown>;")
+	fmt.Fprintln(b, "};")
+
+	return b.Flush()
+}
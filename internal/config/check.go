@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"text/tabwriter"
+)
+
+// Settings — эффективные значения всех ключей (в порядке загрузки).
+func (c *Config) Settings() []Setting {
+	return c.settings
+}
+
+// File — путь к файлу конфигурации ("" если только ENV).
+func (c *Config) File() string {
+	return c.file
+}
+
+// Redacted — значение для вывода: секреты скрыты,
+// у URL с паролем (DATABASE_URL) скрывается только пароль.
+func (s Setting) Redacted() string {
+	if !s.Secret || s.Value == "" {
+		return s.Value
+	}
+	if u, err := url.Parse(s.Value); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Redacted()
+	}
+	return "******"
+}
+
+// PrintEffective печатает итоговую конфигурацию (для `config check`).
+func (c *Config) PrintEffective(w io.Writer) error {
+	file := c.file
+	if file == "" {
+		file = "(none, env only)"
+	}
+	if _, err := fmt.Fprintf(w, "config file: %s\n\n", file); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, s := range c.settings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, s.Redacted(), s.Source)
+	}
+	return tw.Flush()
+}
+
+// PrintSecurityReport — отчёт о безопасности конфигурации (stdout `config check`).
+func (c *Config) PrintSecurityReport(w io.Writer) {
+	issues := c.SecurityIssues()
+	if len(issues) == 0 {
+		fmt.Fprintf(w, "security (%s): no issues\n", c.Env)
+		return
+	}
+
+	fmt.Fprintf(w, "security (%s): %d issue(s)\n", c.Env, len(issues))
+	for _, i := range issues {
+		mark := "⚠️ "
+		if c.Refuses(i) {
+			mark = "❌"
+		}
+		fmt.Fprintf(w, "  %s %s\n", mark, i)
+	}
+}
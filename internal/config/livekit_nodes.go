@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLiveKitNode — id единственного узла, если LIVEKIT_NODES не задан
+// (и значение по умолчанию для lessons.livekit_node).
+const DefaultLiveKitNode = "default"
+
+// LiveKitNode — один LiveKit сервер.
+//
+//	LIVEKIT_NODES='[{"id":"ash-1","region":"ashgabat","public_host":"lk1.school.tm",
+//	                 "port":443,"secure":true,"api_key":"...","api_secret":"...","capacity":200}]'
+type LiveKitNode struct {
+	ID         string `yaml:"id"`
+	Region     string `yaml:"region"`
+	PublicHost string `yaml:"public_host"`
+	Port       int    `yaml:"port"`
+	Secure     bool   `yaml:"secure"`
+	APIKey     string `yaml:"api_key"`
+	APISecret  string `yaml:"api_secret"`
+	// адрес RoomService для сервера (http://livekit:7880); пусто => из public_host/port/secure
+	APIURL string `yaml:"api_url"`
+	// максимум одновременных участников на узле; 0 => без ограничения
+	Capacity int `yaml:"capacity"`
+}
+
+func parseNodes(s *source, raw string) []LiveKitNode {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+
+	var nodes []LiveKitNode
+	// JSON — подмножество YAML, поэтому одного парсера хватает
+	if err := yaml.Unmarshal([]byte(raw), &nodes); err != nil {
+		s.errs = append(s.errs, fmt.Errorf("LIVEKIT_NODES: %w", err))
+		return nil
+	}
+
+	for i := range nodes {
+		n := &nodes[i]
+		n.ID = strings.TrimSpace(n.ID)
+		n.Region = strings.ToLower(strings.TrimSpace(n.Region))
+		n.PublicHost = strings.TrimSpace(n.PublicHost)
+		n.APIURL = strings.TrimRight(strings.TrimSpace(n.APIURL), "/")
+	}
+	return nodes
+}
+
+func validateNodes(c *Config) error {
+	switch c.LiveKit.Placement {
+	case "region", "least_loaded":
+	default:
+		return errors.New("LIVEKIT_PLACEMENT must be region or least_loaded")
+	}
+
+	seen := map[string]bool{}
+	for i, n := range c.LiveKit.Nodes {
+		if n.ID == "" {
+			return fmt.Errorf("LIVEKIT_NODES[%d]: id is required", i)
+		}
+		if seen[n.ID] {
+			return fmt.Errorf("LIVEKIT_NODES: duplicate id %q", n.ID)
+		}
+		seen[n.ID] = true
+
+		if n.PublicHost == "" {
+			return fmt.Errorf("LIVEKIT_NODES[%s]: public_host is required", n.ID)
+		}
+		if n.Port <= 0 || n.Port > 65535 {
+			return fmt.Errorf("LIVEKIT_NODES[%s]: port must be between 1 and 65535", n.ID)
+		}
+		if strings.TrimSpace(n.APIKey) == "" {
+			return fmt.Errorf("LIVEKIT_NODES[%s]: api_key is required", n.ID)
+		}
+		if len(strings.TrimSpace(n.APISecret)) < 32 {
+			return fmt.Errorf("LIVEKIT_NODES[%s]: api_secret must be at least 32 characters", n.ID)
+		}
+		if n.APIURL != "" && !strings.HasPrefix(n.APIURL, "http://") && !strings.HasPrefix(n.APIURL, "https://") {
+			return fmt.Errorf("LIVEKIT_NODES[%s]: api_url must start with http:// or https://", n.ID)
+		}
+		if n.Capacity < 0 {
+			return fmt.Errorf("LIVEKIT_NODES[%s]: capacity must be >= 0", n.ID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package config
+
+import "testing"
+
+func TestDSNParams(t *testing.T) {
+	for _, tc := range []struct {
+		dsn, sslmode, password string
+	}{
+		{"postgres://app:secret@db:5432/classroom?sslmode=disable", "disable", "secret"},
+		{"postgresql://app@db/classroom", "", ""},
+		{"host=db user=app password=postgres dbname=classroom sslmode=disable", "disable", "postgres"},
+		{"host=db  sslmode = require ", "require", ""},
+		{`host=db password='a b\'c' sslmode=verify-full`, "verify-full", "a b'c"},
+	} {
+		params, err := dsnParams(tc.dsn)
+		if err != nil {
+			t.Errorf("%q: %v", tc.dsn, err)
+			continue
+		}
+		if params["sslmode"] != tc.sslmode || params["password"] != tc.password {
+			t.Errorf("%q: sslmode=%q password=%q, want %q %q",
+				tc.dsn, params["sslmode"], params["password"], tc.sslmode, tc.password)
+		}
+	}
+
+	for _, dsn := range []string{"host=db sslmode", "password='open"} {
+		if _, err := dsnParams(dsn); err == nil {
+			t.Errorf("%q: want error", dsn)
+		}
+	}
+}
+
+func TestSecurityIssuesKeyValueDSN(t *testing.T) {
+	c := &Config{}
+	c.Database.URL = "host=db user=app password=postgres sslmode=disable"
+
+	found := map[string]bool{}
+	for _, i := range c.SecurityIssues() {
+		if i.Key == "DATABASE_URL" {
+			found[i.Message] = i.Critical
+		}
+	}
+	if crit, ok := found["sslmode=disable: database traffic is not encrypted"]; !ok || crit {
+		t.Errorf("sslmode=disable not reported as a warning: %v", found)
+	}
+	if crit, ok := found["uses a default database password"]; !ok || !crit {
+		t.Errorf("default password not reported as critical: %v", found)
+	}
+}
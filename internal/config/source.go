@@ -0,0 +1,268 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// =======================
+// Source: defaults < config file < env (KEY_FILE, KEY)
+// =======================
+
+// Setting — итоговое значение ключа и откуда оно пришло (для `config check`).
+type Setting struct {
+	Key    string
+	Value  string
+	Source string // default | file | secret-file | env
+	Secret bool
+}
+
+type source struct {
+	file     map[string]string // ключи файла, нормализованные к ENV_STYLE
+	path     string
+	used     map[string]bool
+	settings []Setting
+	errs     []error
+}
+
+// newSource читает YAML/TOML/JSON файл (если path не пустой).
+// Вложенные секции разворачиваются через "_":
+//
+//	livekit:
+//	  api_key: devkey   =>  LIVEKIT_API_KEY
+func newSource(path string) (*source, error) {
+	s := &source{file: map[string]string{}, path: path, used: map[string]bool{}}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format (use .yaml, .toml or .json)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	flatten("", raw, s.file)
+	return s, nil
+}
+
+func flatten(prefix string, in map[string]any, out map[string]string) {
+	for k, v := range in {
+		key := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(k), "-", "_"))
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch t := v.(type) {
+		case map[string]any:
+			flatten(key, t, out)
+		case []any:
+			// список объектов (livekit.nodes) — сохраняем как JSON
+			if len(t) > 0 {
+				if _, isMap := t[0].(map[string]any); isMap {
+					b, _ := json.Marshal(t)
+					out[key] = string(b)
+					continue
+				}
+			}
+			parts := make([]string, 0, len(t))
+			for _, p := range t {
+				parts = append(parts, fmt.Sprint(p))
+			}
+			out[key] = strings.Join(parts, ",")
+		case nil:
+			out[key] = ""
+		default:
+			out[key] = fmt.Sprint(t)
+		}
+	}
+}
+
+// lookup: env KEY > env KEY_FILE > файл KEY > файл KEY_FILE.
+// Заданная, но пустая env KEY тоже побеждает файл (KEY= в compose "стирает" значение из файла):
+// строки и списки становятся пустыми, числа/флаги/длительности — default.
+func (s *source) lookup(key string) (val, from string, ok bool) {
+	s.used[key] = true
+	s.used[key+"_FILE"] = true
+
+	if v, set := os.LookupEnv(key); set {
+		return strings.TrimSpace(v), "env", true
+	}
+	if p := strings.TrimSpace(os.Getenv(key + "_FILE")); p != "" {
+		return s.readSecret(key, p)
+	}
+	if v := strings.TrimSpace(s.file[key]); v != "" {
+		return v, "file", true
+	}
+	if p := strings.TrimSpace(s.file[key+"_FILE"]); p != "" {
+		return s.readSecret(key, p)
+	}
+
+	return "", "", false
+}
+
+// readSecret — значение из файла секрета (Docker/K8s secrets)
+func (s *source) readSecret(key, path string) (string, string, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s_FILE: %w", key, err))
+		return "", "", false
+	}
+	// такие файлы часто заканчиваются переводом строки
+	return strings.TrimSpace(string(b)), "secret-file", true
+}
+
+func (s *source) record(key, value, from string, secret bool) {
+	if from == "" {
+		from = "default"
+	}
+	s.settings = append(s.settings, Setting{Key: key, Value: value, Source: from, Secret: secret})
+}
+
+func (s *source) fail(key, raw, kind string) {
+	s.errs = append(s.errs, fmt.Errorf("%s: invalid %s %q", key, kind, raw))
+}
+
+// =======================
+// Typed getters (строгие: опечатка => ошибка, а не молчаливый default)
+// =======================
+
+func (s *source) String(key, def string) string {
+	v, from, ok := s.lookup(key)
+	if !ok {
+		v, from = def, ""
+	}
+	s.record(key, v, from, false)
+	return v
+}
+
+// Secret — как String, но значение скрывается в `config check`.
+func (s *source) Secret(key, def string) string {
+	v, from, ok := s.lookup(key)
+	if !ok {
+		v, from = def, ""
+	}
+	s.record(key, v, from, true)
+	return v
+}
+
+func (s *source) Int(key string, def int) int {
+	v, from, ok := s.lookup(key)
+	if !ok || v == "" {
+		s.record(key, strconv.Itoa(def), from, false)
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		s.fail(key, v, "integer")
+		return def
+	}
+	s.record(key, v, from, false)
+	return i
+}
+
+func (s *source) Float(key string, def float64) float64 {
+	v, from, ok := s.lookup(key)
+	if !ok || v == "" {
+		s.record(key, strconv.FormatFloat(def, 'f', -1, 64), from, false)
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		s.fail(key, v, "number")
+		return def
+	}
+	s.record(key, v, from, false)
+	return f
+}
+
+func (s *source) Bool(key string, def bool) bool {
+	v, from, ok := s.lookup(key)
+	if !ok || v == "" {
+		s.record(key, strconv.FormatBool(def), from, false)
+		return def
+	}
+	switch strings.ToLower(v) {
+	case "true", "1", "yes", "y", "on":
+		s.record(key, v, from, false)
+		return true
+	case "false", "0", "no", "n", "off":
+		s.record(key, v, from, false)
+		return false
+	}
+	s.fail(key, v, "boolean")
+	return def
+}
+
+// Duration: "30s", "2m" или просто число секунд
+func (s *source) Duration(key string, def time.Duration) time.Duration {
+	v, from, ok := s.lookup(key)
+	if !ok || v == "" {
+		s.record(key, def.String(), from, false)
+		return def
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		s.record(key, v, from, false)
+		return d
+	}
+	if i, err := strconv.Atoi(v); err == nil {
+		s.record(key, v, from, false)
+		return time.Duration(i) * time.Second
+	}
+	s.fail(key, v, "duration")
+	return def
+}
+
+// List: "a.com, b.com" => ["a.com", "b.com"]
+func (s *source) List(key string) []string {
+	v, from, _ := s.lookup(key)
+	s.record(key, v, from, false)
+
+	var out []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// err — все ошибки разбора + неизвестные ключи файла (опечатки в YAML)
+func (s *source) err() error {
+	var unknown []string
+	for k := range s.file {
+		if !s.used[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		s.errs = append(s.errs, fmt.Errorf("config file %s: unknown key %s", s.path, k))
+	}
+
+	return errors.Join(s.errs...)
+}
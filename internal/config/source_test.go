@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testSource(t *testing.T, yaml string) *source {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := newSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestEmptyEnvOverridesFile(t *testing.T) {
+	s := testSource(t, `
+notify:
+  smtp_password: from-file
+upload:
+  max_mb: 50
+  allowed_types: [pdf, png]
+token:
+  ttl_teacher: 1h
+`)
+	t.Setenv("NOTIFY_SMTP_PASSWORD", "")
+	t.Setenv("UPLOAD_MAX_MB", "")
+	t.Setenv("UPLOAD_ALLOWED_TYPES", "")
+	t.Setenv("TOKEN_TTL_TEACHER", " ")
+
+	if v := s.Secret("NOTIFY_SMTP_PASSWORD", "def"); v != "" {
+		t.Errorf("string = %q, want empty from env", v)
+	}
+	if v := s.Int("UPLOAD_MAX_MB", 20); v != 20 {
+		t.Errorf("int = %d, want default 20", v)
+	}
+	if v := s.List("UPLOAD_ALLOWED_TYPES"); len(v) != 0 {
+		t.Errorf("list = %v, want empty", v)
+	}
+	if v := s.Duration("TOKEN_TTL_TEACHER", 15*time.Minute); v != 15*time.Minute {
+		t.Errorf("duration = %v, want default 15m", v)
+	}
+	if err := s.err(); err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	for _, st := range s.settings {
+		if st.Source != "env" {
+			t.Errorf("%s source = %s, want env", st.Key, st.Source)
+		}
+	}
+}
+
+func TestUnsetEnvFallsBackToFile(t *testing.T) {
+	s := testSource(t, `
+upload:
+  max_mb: 50
+`)
+	t.Setenv("UPLOAD_MAX_MB", "") // восстановится после теста
+	os.Unsetenv("UPLOAD_MAX_MB")
+
+	if v := s.Int("UPLOAD_MAX_MB", 20); v != 50 {
+		t.Errorf("int = %d, want 50 from file", v)
+	}
+}
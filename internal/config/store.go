@@ -0,0 +1,74 @@
+package config
+
+import (
+	"log/slog"
+	"reflect"
+	"sync/atomic"
+)
+
+// Store — текущая конфигурация с перечитыванием по SIGHUP.
+//
+// Перечитываются только "горячие" настройки (ключи учителей, API секрет,
+// admin, rate limits, TTL токенов, LOG_LEVEL). Listener-настройки (адрес, порт, TLS,
+// таймауты, БД, LiveKit) требуют рестарта и остаются прежними.
+type Store struct {
+	path string
+	cur  atomic.Pointer[Config]
+}
+
+func NewStore(c *Config) *Store {
+	s := &Store{path: c.file}
+	s.cur.Store(c)
+	return s
+}
+
+// Get — актуальная конфигурация (не изменять!)
+func (s *Store) Get() *Config {
+	return s.cur.Load()
+}
+
+// Reload читает файл/ENV заново. При ошибке старая конфигурация остаётся.
+func (s *Store) Reload() error {
+	next, err := LoadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	old := s.Get()
+	merged := *old
+
+	// ✅ горячие настройки
+	merged.API = next.API
+	merged.Admin = next.Admin
+	merged.RateLimit = next.RateLimit
+	merged.Rooms = next.Rooms
+	merged.Token = next.Token
+	merged.Billing = next.Billing
+	merged.Upload = next.Upload
+	merged.Log.Level = next.Log.Level
+
+	// ⚠️ остальное — только после рестарта
+	restart := map[string]bool{
+		"APP_LISTEN_IP/APP_PORT": old.ListenIP != next.ListenIP || old.Port != next.Port,
+		"HTTP_*":                 !reflect.DeepEqual(old.HTTP, next.HTTP),
+		"TLS_*":                  !reflect.DeepEqual(old.TLS, next.TLS),
+		"DATABASE_URL":           old.Database != next.Database,
+		"LIVEKIT_*":              !reflect.DeepEqual(old.LiveKit, next.LiveKit),
+		"HOST_*":                 old.HostProtection != next.HostProtection,
+		"LOG_FORMAT":             old.Log.Format != next.Log.Format,
+		"APP_ENV":                old.Env != next.Env,
+		"REAPER_*":               old.Reaper != next.Reaper,
+		"STORAGE_*":              old.Storage != next.Storage,
+		"CAPTIONS_*":             old.Captions != next.Captions,
+		"NOTIFY_*":               old.Notify != next.Notify,
+	}
+	for k, changed := range restart {
+		if changed {
+			slog.Warn("config reload: setting changed but requires restart", "keys", k)
+		}
+	}
+
+	s.cur.Store(&merged)
+	slog.Info("config reloaded", "file", s.path)
+	return nil
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// AnalyticsQuery — параметры /api/admin/analytics
+type AnalyticsQuery struct {
+	TenantID int64 // 0 => все школы (супер-админ)
+
+	From time.Time // включительно
+	To   time.Time // не включительно
+	TZ   string    // IANA: границы дней/недель считаются в этом поясе
+
+	Bucket  string // day | week
+	GroupBy string // teacher | room
+
+	// необязательные фильтры
+	Teacher string
+	Room    string
+
+	// начало позже расписания больше чем на LateGrace => опоздание
+	LateGrace time.Duration
+}
+
+// AnalyticsPoint — один период ряда
+type AnalyticsPoint struct {
+	Period           string  `json:"period"` // YYYY-MM-DD (начало дня/недели в TZ)
+	Lessons          int     `json:"lessons"`
+	Minutes          float64 `json:"minutes"`
+	AvgLessonMinutes float64 `json:"avg_lesson_minutes"`
+	AvgAttendance    float64 `json:"avg_attendance"`
+	PeakAttendance   int     `json:"peak_attendance"`
+	Scheduled        int     `json:"scheduled"` // уроков с расписанием
+	LateStarts       int     `json:"late_starts"`
+	AvgLateMinutes   float64 `json:"avg_late_minutes"`
+
+	// накопительно с начала диапазона и изменение к прошлому периоду
+	MinutesCumulative float64 `json:"minutes_cumulative"`
+	LessonsDelta      int     `json:"lessons_delta"`
+}
+
+// AnalyticsSeries — ряд одного учителя / одной комнаты
+type AnalyticsSeries struct {
+	Key    string           `json:"key"`
+	Points []AnalyticsPoint `json:"points"`
+}
+
+// Analytics — ряды по учителям или комнатам.
+//
+// Посещаемость урока:
+//   - avg_attendance — уникальные участники (в среднем на урок);
+//   - peak_attendance — максимум одновременно присутствующих: бегущая сумма
+//     +1 (вход) / -1 (выход) по времени (оконная функция).
+func Analytics(ctx context.Context, db *sql.DB, q AnalyticsQuery) ([]AnalyticsSeries, error) {
+	ctx, span := startOp(ctx, "Analytics",
+		attribute.Int64("tenant_id", q.TenantID),
+		attribute.String("bucket", q.Bucket),
+		attribute.String("group_by", q.GroupBy),
+	)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		WITH l AS (
+			SELECT id,
+			       CASE WHEN $7 = 'room' THEN room_name ELSE teacher_name END AS key,
+			       started_at,
+			       COALESCE(ended_at, now()) AS ended_at,
+			       scheduled_start,
+			       date_trunc($4, started_at AT TIME ZONE $5) AS period
+			FROM lessons
+			WHERE ($1 = 0 OR tenant_id = $1)
+			  AND started_at >= $2
+			  AND started_at < $3
+			  AND ($8 = '' OR teacher_name = $8)
+			  AND ($9 = '' OR room_name = $9)
+		),
+		ev AS (
+			SELECT lp.lesson_id, lp.joined_at AS t, 1 AS d
+			FROM lesson_participants lp
+			JOIN l ON l.id = lp.lesson_id
+			UNION ALL
+			SELECT lp.lesson_id, LEAST(COALESCE(lp.left_at, l.ended_at), l.ended_at), -1
+			FROM lesson_participants lp
+			JOIN l ON l.id = lp.lesson_id
+		),
+		present AS (
+			-- при равном времени сначала выход (-1), чтобы не завышать пик
+			SELECT lesson_id,
+			       sum(d) OVER (PARTITION BY lesson_id ORDER BY t, d ROWS UNBOUNDED PRECEDING) AS n
+			FROM ev
+		),
+		att AS (
+			SELECT p.lesson_id, max(p.n) AS peak,
+			       (SELECT count(*) FROM lesson_participants lp WHERE lp.lesson_id = p.lesson_id) AS attendees
+			FROM present p
+			GROUP BY p.lesson_id
+		),
+		per_lesson AS (
+			SELECT l.key, l.period,
+			       EXTRACT(EPOCH FROM (l.ended_at - l.started_at)) / 60 AS minutes,
+			       COALESCE(att.attendees, 0) AS attendees,
+			       COALESCE(att.peak, 0) AS peak,
+			       EXTRACT(EPOCH FROM (l.started_at - l.scheduled_start)) / 60 AS late_min
+			FROM l
+			LEFT JOIN att ON att.lesson_id = l.id
+		),
+		buckets AS (
+			SELECT key, period,
+			       count(*) AS lessons,
+			       sum(minutes) AS minutes,
+			       avg(minutes) AS avg_minutes,
+			       avg(attendees) AS avg_attendance,
+			       max(peak) AS peak_attendance,
+			       count(late_min) AS scheduled,
+			       count(*) FILTER (WHERE late_min > $6) AS late_starts,
+			       COALESCE(avg(late_min) FILTER (WHERE late_min > $6), 0) AS avg_late
+			FROM per_lesson
+			GROUP BY key, period
+		)
+		SELECT key, to_char(period, 'YYYY-MM-DD'),
+		       lessons, minutes, avg_minutes, avg_attendance, peak_attendance,
+		       scheduled, late_starts, avg_late,
+		       sum(minutes) OVER w,
+		       lessons - COALESCE(lag(lessons) OVER w, 0)
+		FROM buckets
+		WINDOW w AS (PARTITION BY key ORDER BY period)
+		ORDER BY key, period
+	`,
+		q.TenantID, q.From, q.To, q.Bucket, q.TZ,
+		q.LateGrace.Minutes(), q.GroupBy, q.Teacher, q.Room,
+	)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []AnalyticsSeries{}
+	for rows.Next() {
+		var key string
+		var p AnalyticsPoint
+		if err := rows.Scan(
+			&key, &p.Period,
+			&p.Lessons, &p.Minutes, &p.AvgLessonMinutes, &p.AvgAttendance, &p.PeakAttendance,
+			&p.Scheduled, &p.LateStarts, &p.AvgLateMinutes,
+			&p.MinutesCumulative, &p.LessonsDelta,
+		); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+
+		if n := len(out); n == 0 || out[n-1].Key != key {
+			out = append(out, AnalyticsSeries{Key: key})
+		}
+		last := &out[len(out)-1]
+		last.Points = append(last.Points, p)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// insertLesson — урок с заданным временем (end / scheduled == zero => открыт / без расписания)
+func insertLesson(t *testing.T, conn *sql.DB, tenantID int64, room, teacher string, start, end, scheduled time.Time) int64 {
+	t.Helper()
+	var ended, sched sql.NullTime
+	if !end.IsZero() {
+		ended = sql.NullTime{Time: end, Valid: true}
+	}
+	if !scheduled.IsZero() {
+		sched = sql.NullTime{Time: scheduled, Valid: true}
+	}
+	var id int64
+	if err := conn.QueryRow(`
+		INSERT INTO lessons (tenant_id, room_name, teacher_name, started_at, ended_at, scheduled_start)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, tenantID, room, teacher, start, ended, sched).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// insertPresence — ученик был в уроке с joined до left (участник + сегмент присутствия)
+func insertPresence(t *testing.T, conn *sql.DB, lessonID int64, name string, joined, left time.Time) {
+	t.Helper()
+	if _, err := conn.Exec(`
+		INSERT INTO lesson_participants (lesson_id, participant_name, role, joined_at, left_at)
+		VALUES ($1, $2, 'student', $3, $4)
+		ON CONFLICT (lesson_id, participant_name) DO UPDATE SET left_at = EXCLUDED.left_at
+	`, lessonID, name, joined, left); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(`
+		INSERT INTO participant_sessions (lesson_id, participant_name, role, joined_at, left_at)
+		VALUES ($1, $2, 'student', $3, $4)
+	`, lessonID, name, joined, left); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnalyticsByTeacher(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	other, err := CreateTenant(ctx, conn, "other", "Other school", "other-api-key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 3, day, hour, min, 0, 0, time.UTC) }
+
+	// понедельник: начал на 10 минут позже расписания; bob уходит в 09:20,
+	// carl входит в 09:20 — одновременно в уроке не больше двух
+	monday := insertLesson(t, conn, 1, "math", "alice", at(2, 9, 0), at(2, 9, 40), at(2, 8, 50))
+	insertPresence(t, conn, monday, "ann", at(2, 9, 0), at(2, 9, 40))
+	insertPresence(t, conn, monday, "bob", at(2, 9, 5), at(2, 9, 20))
+	insertPresence(t, conn, monday, "carl", at(2, 9, 20), at(2, 9, 40))
+
+	tuesday := insertLesson(t, conn, 1, "math", "alice", at(3, 9, 0), at(3, 9, 30), at(3, 9, 0))
+	insertPresence(t, conn, tuesday, "ann", at(3, 9, 0), at(3, 9, 30))
+
+	// та же учительница в другой школе и урок вне диапазона — не считаются
+	insertLesson(t, conn, other.ID, "math", "alice", at(2, 12, 0), at(2, 13, 0), time.Time{})
+	insertLesson(t, conn, 1, "math", "alice", at(9, 9, 0), at(9, 10, 0), time.Time{})
+
+	q := AnalyticsQuery{
+		TenantID:  1,
+		From:      at(1, 0, 0),
+		To:        at(8, 0, 0),
+		TZ:        "UTC",
+		Bucket:    "day",
+		GroupBy:   "teacher",
+		LateGrace: 5 * time.Minute,
+	}
+	series, err := Analytics(ctx, conn, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || series[0].Key != "alice" || len(series[0].Points) != 2 {
+		t.Fatalf("series = %+v, want alice with 2 days", series)
+	}
+
+	want := []AnalyticsPoint{
+		{
+			Period: "2026-03-02", Lessons: 1, Minutes: 40, AvgLessonMinutes: 40,
+			AvgAttendance: 3, PeakAttendance: 2, Scheduled: 1, LateStarts: 1, AvgLateMinutes: 10,
+			MinutesCumulative: 40, LessonsDelta: 1,
+		},
+		{
+			Period: "2026-03-03", Lessons: 1, Minutes: 30, AvgLessonMinutes: 30,
+			AvgAttendance: 1, PeakAttendance: 1, Scheduled: 1,
+			MinutesCumulative: 70, LessonsDelta: 0,
+		},
+	}
+	for i, p := range series[0].Points {
+		if p != want[i] {
+			t.Errorf("point %d = %+v\nwant %+v", i, p, want[i])
+		}
+	}
+
+	// неделя с понедельника 2 марта — одна точка на оба урока
+	q.Bucket = "week"
+	series, err = Analytics(ctx, conn, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || len(series[0].Points) != 1 {
+		t.Fatalf("weekly series = %+v, want one point", series)
+	}
+	if p := series[0].Points[0]; p.Period != "2026-03-02" || p.Lessons != 2 || p.Minutes != 70 || p.AvgAttendance != 2 {
+		t.Fatalf("week = %+v, want 2 lessons, 70 minutes, 2 attendees on average", p)
+	}
+
+	// TenantID 0 (супер-админ) видит уроки обеих школ
+	q.TenantID = 0
+	series, err = Analytics(ctx, conn, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || series[0].Points[0].Lessons != 3 {
+		t.Fatalf("all tenants = %+v, want 3 lessons", series)
+	}
+}
@@ -0,0 +1,334 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// ErrBillingPeriodClosed — период за этот месяц уже закрыт
+var ErrBillingPeriodClosed = errors.New("billing period already closed")
+
+// ErrBillingPeriodNotFound — нет такого периода (или он чужой школы)
+var ErrBillingPeriodNotFound = errors.New("billing period not found")
+
+// LessonUsage — сырые данные урока для биллинга (секунды из сегментов присутствия)
+type LessonUsage struct {
+	LessonID  int64
+	Teacher   string
+	Room      string
+	StartedAt time.Time
+	EndedAt   time.Time
+
+	// присутствие учителя урока (teacher_name), обрезанное рамками урока
+	TeacherSeconds int64
+	// все участники, включая учителя (так считает LiveKit)
+	ParticipantSeconds int64
+	// уникальные участники без учителя
+	Participants int
+}
+
+// BillingPeriod — закрытый месяц школы
+type BillingPeriod struct {
+	ID          int64           `json:"id"`
+	TenantID    int64           `json:"tenant_id"`
+	PeriodStart string          `json:"period_start"` // YYYY-MM-DD
+	TZ          string          `json:"tz"`
+	StartsAt    time.Time       `json:"starts_at"`
+	EndsAt      time.Time       `json:"ends_at"`
+	Currency    string          `json:"currency"`
+	Rules       json.RawMessage `json:"rules"`
+	ClosedBy    string          `json:"closed_by"`
+	ClosedAt    time.Time       `json:"closed_at"`
+
+	// итоги по ведомостям
+	Teachers        int   `json:"teachers"`
+	TeacherAmount   int64 `json:"teacher_amount"`
+	ParticipantCost int64 `json:"participant_cost"`
+
+	Statements []BillingStatement `json:"statements,omitempty"`
+}
+
+// BillingStatement — ведомость учителя за период
+type BillingStatement struct {
+	Teacher            string `json:"teacher"`
+	Lessons            int    `json:"lessons"`
+	TeacherMinutes     int64  `json:"teacher_minutes"`
+	BillableMinutes    int64  `json:"billable_minutes"`
+	ParticipantMinutes int64  `json:"participant_minutes"`
+	TeacherAmount      int64  `json:"teacher_amount"`
+	ParticipantCost    int64  `json:"participant_cost"`
+
+	Lines []BillingLine `json:"lines"`
+}
+
+// BillingLine — урок в ведомости
+type BillingLine struct {
+	LessonID           int64     `json:"lesson_id"`
+	Room               string    `json:"room"`
+	StartedAt          time.Time `json:"started_at"`
+	EndedAt            time.Time `json:"ended_at"`
+	TeacherMinutes     int64     `json:"teacher_minutes"`
+	BillableMinutes    int64     `json:"billable_minutes"`
+	ParticipantMinutes int64     `json:"participant_minutes"`
+	Participants       int       `json:"participants"`
+	Amount             int64     `json:"amount"`
+}
+
+// =======================
+// Usage
+// =======================
+
+// LessonUsageFor — завершённые уроки школы, начатые в [from, to)
+func LessonUsageFor(ctx context.Context, db *sql.DB, tenantID int64, from, to time.Time) ([]LessonUsage, error) {
+	ctx, span := startOp(ctx, "LessonUsageFor", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	// сегмент обрезаем рамками урока; незакрытый сегмент — до конца урока
+	rows, err := db.QueryContext(ctx, `
+		WITH seg AS (
+			SELECT l.id AS lesson_id, s.participant_name,
+			       s.participant_name = l.teacher_name AS is_teacher,
+			       GREATEST(0, EXTRACT(EPOCH FROM (
+			           LEAST(COALESCE(s.left_at, l.ended_at), l.ended_at) - GREATEST(s.joined_at, l.started_at)
+			       )))::bigint AS sec
+			FROM lessons l
+			JOIN participant_sessions s ON s.lesson_id = l.id
+			WHERE l.tenant_id = $1
+			  AND l.ended_at IS NOT NULL
+			  AND l.started_at >= $2
+			  AND l.started_at < $3
+		)
+		SELECT l.id, l.teacher_name, l.room_name, l.started_at, l.ended_at,
+		       COALESCE(sum(seg.sec) FILTER (WHERE seg.is_teacher), 0)::bigint,
+		       COALESCE(sum(seg.sec), 0)::bigint,
+		       count(DISTINCT seg.participant_name) FILTER (WHERE NOT seg.is_teacher)
+		FROM lessons l
+		LEFT JOIN seg ON seg.lesson_id = l.id
+		WHERE l.tenant_id = $1
+		  AND l.ended_at IS NOT NULL
+		  AND l.started_at >= $2
+		  AND l.started_at < $3
+		GROUP BY l.id
+		ORDER BY l.teacher_name, l.started_at
+	`, tenantID, from, to)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	var out []LessonUsage
+	for rows.Next() {
+		var u LessonUsage
+		if err := rows.Scan(
+			&u.LessonID, &u.Teacher, &u.Room, &u.StartedAt, &u.EndedAt,
+			&u.TeacherSeconds, &u.ParticipantSeconds, &u.Participants,
+		); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, u)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// CountOpenLessonsIn — незавершённые уроки школы, начатые в [from, to)
+func CountOpenLessonsIn(ctx context.Context, db *sql.DB, tenantID int64, from, to time.Time) (int, error) {
+	ctx, span := startOp(ctx, "CountOpenLessonsIn", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	var n int
+	err := db.QueryRowContext(ctx, `
+		SELECT count(*)
+		FROM lessons
+		WHERE tenant_id = $1
+		  AND ended_at IS NULL
+		  AND started_at >= $2
+		  AND started_at < $3
+	`, tenantID, from, to).Scan(&n)
+
+	return n, tracing.Fail(span, err)
+}
+
+// =======================
+// Periods
+// =======================
+
+// CloseBillingPeriod — сохранить период с ведомостями одной транзакцией.
+// Повторное закрытие того же месяца => ErrBillingPeriodClosed.
+func CloseBillingPeriod(ctx context.Context, db *sql.DB, p *BillingPeriod) (int64, error) {
+	ctx, span := startOp(ctx, "CloseBillingPeriod",
+		attribute.Int64("tenant_id", p.TenantID),
+		attribute.String("period_start", p.PeriodStart),
+	)
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO billing_periods (tenant_id, period_start, tz, starts_at, ends_at, currency, rules, closed_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (tenant_id, period_start) DO NOTHING
+		RETURNING id
+	`, p.TenantID, p.PeriodStart, p.TZ, p.StartsAt, p.EndsAt, p.Currency, []byte(p.Rules), p.ClosedBy).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrBillingPeriodClosed
+	}
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	for _, s := range p.Statements {
+		var stmtID int64
+		if err := tx.QueryRowContext(ctx, `
+			INSERT INTO billing_statements (
+				period_id, teacher_name, lessons, teacher_minutes, billable_minutes,
+				participant_minutes, teacher_amount, participant_cost
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id
+		`, id, s.Teacher, s.Lessons, s.TeacherMinutes, s.BillableMinutes,
+			s.ParticipantMinutes, s.TeacherAmount, s.ParticipantCost,
+		).Scan(&stmtID); err != nil {
+			return 0, tracing.Fail(span, err)
+		}
+
+		for _, l := range s.Lines {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO billing_lines (
+					statement_id, lesson_id, room_name, started_at, ended_at,
+					teacher_minutes, billable_minutes, participant_minutes, participants, amount
+				)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			`, stmtID, l.LessonID, l.Room, l.StartedAt, l.EndedAt,
+				l.TeacherMinutes, l.BillableMinutes, l.ParticipantMinutes, l.Participants, l.Amount,
+			); err != nil {
+				return 0, tracing.Fail(span, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	return id, nil
+}
+
+// ListBillingPeriods — закрытые периоды школы (новые сверху), без ведомостей
+func ListBillingPeriods(ctx context.Context, db *sql.DB, tenantID int64) ([]BillingPeriod, error) {
+	ctx, span := startOp(ctx, "ListBillingPeriods", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, billingPeriodSelect+`
+		WHERE p.tenant_id = $1
+		GROUP BY p.id
+		ORDER BY p.period_start DESC
+	`, tenantID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []BillingPeriod{}
+	for rows.Next() {
+		p, err := scanBillingPeriod(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, *p)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// GetBillingPeriod — период с ведомостями и строками по урокам
+func GetBillingPeriod(ctx context.Context, db *sql.DB, tenantID, periodID int64) (*BillingPeriod, error) {
+	ctx, span := startOp(ctx, "GetBillingPeriod",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("period_id", periodID),
+	)
+	defer span.End()
+
+	p, err := scanBillingPeriod(db.QueryRowContext(ctx, billingPeriodSelect+`
+		WHERE p.tenant_id = $1
+		  AND p.id = $2
+		GROUP BY p.id
+	`, tenantID, periodID))
+	if err == sql.ErrNoRows {
+		return nil, ErrBillingPeriodNotFound
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.teacher_name, s.lessons, s.teacher_minutes, s.billable_minutes,
+		       s.participant_minutes, s.teacher_amount, s.participant_cost,
+		       l.lesson_id, l.room_name, l.started_at, l.ended_at,
+		       l.teacher_minutes, l.billable_minutes, l.participant_minutes, l.participants, l.amount
+		FROM billing_statements s
+		JOIN billing_lines l ON l.statement_id = s.id
+		WHERE s.period_id = $1
+		ORDER BY s.teacher_name, l.started_at
+	`, periodID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	p.Statements = []BillingStatement{}
+	for rows.Next() {
+		var s BillingStatement
+		var l BillingLine
+		if err := rows.Scan(
+			&s.Teacher, &s.Lessons, &s.TeacherMinutes, &s.BillableMinutes,
+			&s.ParticipantMinutes, &s.TeacherAmount, &s.ParticipantCost,
+			&l.LessonID, &l.Room, &l.StartedAt, &l.EndedAt,
+			&l.TeacherMinutes, &l.BillableMinutes, &l.ParticipantMinutes, &l.Participants, &l.Amount,
+		); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+
+		// строки отсортированы по учителю — новая ведомость при смене имени
+		if n := len(p.Statements); n == 0 || p.Statements[n-1].Teacher != s.Teacher {
+			p.Statements = append(p.Statements, s)
+		}
+		last := &p.Statements[len(p.Statements)-1]
+		last.Lines = append(last.Lines, l)
+	}
+
+	return p, tracing.Fail(span, rows.Err())
+}
+
+const billingPeriodSelect = `
+	SELECT p.id, p.tenant_id, to_char(p.period_start, 'YYYY-MM-DD'), p.tz, p.starts_at, p.ends_at,
+	       p.currency, p.rules, p.closed_by, p.closed_at,
+	       count(s.id), COALESCE(sum(s.teacher_amount), 0)::bigint, COALESCE(sum(s.participant_cost), 0)::bigint
+	FROM billing_periods p
+	LEFT JOIN billing_statements s ON s.period_id = p.id
+`
+
+func scanBillingPeriod(row interface{ Scan(...any) error }) (*BillingPeriod, error) {
+	var p BillingPeriod
+	var rules []byte
+	if err := row.Scan(
+		&p.ID, &p.TenantID, &p.PeriodStart, &p.TZ, &p.StartsAt, &p.EndsAt,
+		&p.Currency, &rules, &p.ClosedBy, &p.ClosedAt,
+		&p.Teachers, &p.TeacherAmount, &p.ParticipantCost,
+	); err != nil {
+		return nil, err
+	}
+	p.Rules = rules
+	return &p, nil
+}
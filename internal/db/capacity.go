@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	// ErrRoomFull — мест для этой роли нет (подробности — в *RoomFullError)
+	ErrRoomFull = errors.New("room is full")
+	// ErrNoGuestSeat — ученик не из списка класса, а guest_allowance урока исчерпан
+	ErrNoGuestSeat = errors.New("no guest seats left")
+)
+
+const (
+	// QueuePollInterval — как часто клиенту в очереди повторять join
+	QueuePollInterval = 10 * time.Second
+	// не повторял join дольше — выбывает из очереди
+	queueStaleAfter = time.Minute
+	// учителей в комнате с лимитом только по ученикам (учитель + ассистент / переподключение)
+	liveKitTeacherAllowance = 2
+)
+
+// Capacity — места в комнате (0 => без ограничения)
+type Capacity struct {
+	Total    int
+	Students int
+	Teachers int
+	Queue    bool // мест нет => встать в очередь
+
+	// Guest — входит ученик не из списка класса: место гостя проверяется под той же блокировкой
+	Guest *GuestSeat
+}
+
+// GuestSeat — guest_allowance класса в этом уроке
+type GuestSeat struct {
+	ClassID   int64
+	LessonID  int64
+	Allowance int
+}
+
+func (c Capacity) Unlimited() bool {
+	return c.Total == 0 && c.Students == 0 && c.Teachers == 0
+}
+
+// Limit — места для роли (0 => без ограничения)
+func (c Capacity) Limit(role string) int {
+	if role == "teacher" {
+		return c.Teachers
+	}
+	return c.Students
+}
+
+// LiveKitMax — max_participants комнаты LiveKit: всего, а если не задано — сумма по ролям.
+// Роль без лимита не должна упереться в max_participants: учителей тогда считаем
+// с запасом liveKitTeacherAllowance; без лимита учеников — ограничения нет.
+func (c Capacity) LiveKitMax() int {
+	if c.Total > 0 {
+		return c.Total
+	}
+	if c.Students == 0 {
+		return 0
+	}
+	teachers := c.Teachers
+	if teachers == 0 {
+		teachers = liveKitTeacherAllowance
+	}
+	return c.Students + teachers
+}
+
+// RoomFullError — отказ по местам; Position > 0 — участник стоит в очереди
+type RoomFullError struct {
+	Role     string
+	Limit    int
+	Position int
+}
+
+func (e *RoomFullError) Error() string {
+	if e.Position > 0 {
+		return fmt.Sprintf("room is full (%d %s seats), queue position %d", e.Limit, e.Role, e.Position)
+	}
+	return fmt.Sprintf("room is full (%d %s seats)", e.Limit, e.Role)
+}
+
+func (e *RoomFullError) Is(target error) bool { return target == ErrRoomFull }
+
+// takeSeat — место для name в комнате по живым участникам всех открытых уроков
+// (и место гостя, если он не из списка класса).
+// Блокировка комнаты до конца tx: параллельные входы видят места друг друга.
+// *RoomFullError с Position > 0 — запись в очереди; её надо закоммитить.
+func takeSeat(ctx context.Context, tx *sql.Tx, tenantID int64, room, name, role string, capacity Capacity) error {
+	if capacity.Unlimited() && capacity.Guest == nil {
+		return nil
+	}
+
+	if err := lockRoom(ctx, tx, tenantID, room); err != nil {
+		return err
+	}
+
+	// переподключение гостя не съедает ещё одно место — себя не считаем
+	if g := capacity.Guest; g != nil {
+		guests, err := countActiveGuests(ctx, tx, g.ClassID, g.LessonID, name)
+		if err != nil {
+			return err
+		}
+		if guests >= g.Allowance {
+			return ErrNoGuestSeat
+		}
+	}
+	if capacity.Unlimited() {
+		return nil
+	}
+
+	// переподключение не занимает второе место — себя не считаем.
+	// Открытых уроков в комнате может быть два (учитель начал новый, старый ещё не закрыт):
+	// ученик, записанный в оба, — одно место
+	var students, teachers int
+	err := tx.QueryRowContext(ctx, `
+		SELECT count(DISTINCT p.participant_name) FILTER (WHERE p.role = 'student'),
+		       count(DISTINCT p.participant_name) FILTER (WHERE p.role = 'teacher')
+		FROM lesson_participants p
+		JOIN lessons l ON l.id = p.lesson_id
+		WHERE l.tenant_id = $1
+		  AND l.room_name = $2
+		  AND l.ended_at IS NULL
+		  AND p.left_at IS NULL
+		  AND p.participant_name <> $3
+	`, tenantID, room, name).Scan(&students, &teachers)
+	if err != nil {
+		return err
+	}
+
+	free, limit := math.MaxInt, capacity.Limit(role)
+	if limit > 0 {
+		free = limit - teachers
+		if role != "teacher" {
+			free = limit - students
+		}
+	}
+	if capacity.Total > 0 {
+		free = min(free, capacity.Total-students-teachers)
+		if limit == 0 {
+			limit = capacity.Total
+		}
+	}
+
+	// очередь: место достаётся тому, кто ждёт дольше
+	ahead := 0
+	if capacity.Queue {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM room_queue
+			WHERE tenant_id = $1
+			  AND room_name = $2
+			  AND seen_at < now() - make_interval(secs => $3)
+		`, tenantID, room, queueStaleAfter.Seconds()); err != nil {
+			return err
+		}
+
+		err := tx.QueryRowContext(ctx, `
+			SELECT count(*)
+			FROM room_queue
+			WHERE tenant_id = $1
+			  AND room_name = $2
+			  AND role = $3
+			  AND participant_name <> $4
+			  AND id < COALESCE((
+				SELECT id FROM room_queue
+				WHERE tenant_id = $1 AND room_name = $2 AND participant_name = $4
+			  ), 9223372036854775807)
+		`, tenantID, room, role, name).Scan(&ahead)
+		if err != nil {
+			return err
+		}
+	}
+
+	if free > ahead {
+		if capacity.Queue {
+			_, err := tx.ExecContext(ctx, `
+				DELETE FROM room_queue
+				WHERE tenant_id = $1
+				  AND room_name = $2
+				  AND participant_name = $3
+			`, tenantID, room, name)
+			return err
+		}
+		return nil
+	}
+
+	full := &RoomFullError{Role: role, Limit: limit}
+	if !capacity.Queue {
+		return full
+	}
+
+	// встать в очередь или продлить своё место в ней (id, а значит и порядок, сохраняется)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO room_queue (tenant_id, room_name, participant_name, role)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant_id, room_name, participant_name)
+		DO UPDATE SET role = EXCLUDED.role, seen_at = now()
+	`, tenantID, room, name, role); err != nil {
+		return err
+	}
+	full.Position = ahead + 1
+	return full
+}
+
+// lockRoom — блокировка комнаты до конца tx (повторно в той же tx — не ждёт)
+func lockRoom(ctx context.Context, tx *sql.Tx, tenantID int64, room string) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`,
+		fmt.Sprintf("room:%d:%s", tenantID, room))
+	return err
+}
+
+// commitQueued — отказ по местам с записью в очереди: очередь сохраняем, отказ отдаём
+func commitQueued(tx *sql.Tx, err error) error {
+	var full *RoomFullError
+	if errors.As(err, &full) && full.Position > 0 {
+		if cerr := tx.Commit(); cerr != nil {
+			return cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCapacityLiveKitMax(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		c    Capacity
+		want int
+	}{
+		{"unlimited", Capacity{}, 0},
+		{"total", Capacity{Total: 30, Students: 25, Teachers: 2}, 30},
+		{"both roles", Capacity{Students: 25, Teachers: 3}, 28},
+		{"students only", Capacity{Students: 25}, 25 + liveKitTeacherAllowance},
+		{"teachers only", Capacity{Teachers: 2}, 0},
+	} {
+		if got := tc.c.LiveKitMax(); got != tc.want {
+			t.Errorf("%s: LiveKitMax() = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTakeSeatCountsParticipantOnceAcrossOpenLessons(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+	capacity := Capacity{Students: 2}
+
+	first, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", capacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ann", "bob"} {
+		if err := JoinParticipant(ctx, conn, 1, first.ID, "math", name, "student", capacity); err != nil {
+			t.Fatalf("join %s: %v", name, err)
+		}
+	}
+
+	// второй урок в комнате, первый ещё открыт (его закроет reaper)
+	second, _, err := StartLesson(ctx, conn, 1, "math", "substitute", "node-1", capacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ann", "bob"} {
+		if err := JoinParticipant(ctx, conn, 1, second.ID, "math", name, "student", capacity); err != nil {
+			t.Fatalf("%s moves to the new lesson: %v", name, err)
+		}
+	}
+
+	var full *RoomFullError
+	err = JoinParticipant(ctx, conn, 1, second.ID, "math", "carl", "student", capacity)
+	if !errors.As(err, &full) || full.Limit != 2 || full.Position != 0 {
+		t.Fatalf("third student = %v, want room full (2 seats)", err)
+	}
+}
+
+func TestTakeSeatQueue(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+	capacity := Capacity{Students: 1, Queue: true}
+
+	lesson, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", capacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", "ann", "student", capacity); err != nil {
+		t.Fatal(err)
+	}
+
+	// мест нет — очередь по порядку, повторный join сохраняет место в ней
+	for _, step := range []struct {
+		name     string
+		position int
+	}{{"bob", 1}, {"carl", 2}, {"bob", 1}} {
+		var full *RoomFullError
+		err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", step.name, "student", capacity)
+		if !errors.As(err, &full) || full.Position != step.position {
+			t.Fatalf("%s: %v, want queue position %d", step.name, err, step.position)
+		}
+	}
+
+	// место освободилось — первым входит тот, кто ждёт дольше
+	if err := LeaveParticipant(ctx, conn, 1, lesson.ID, "ann"); err != nil {
+		t.Fatal(err)
+	}
+	var full *RoomFullError
+	if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", "carl", "student", capacity); !errors.As(err, &full) {
+		t.Fatalf("carl jumped the queue: %v", err)
+	}
+	if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", "bob", "student", capacity); err != nil {
+		t.Fatalf("bob (first in queue): %v", err)
+	}
+}
+
+func TestTakeSeatTeacherLimit(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+	capacity := Capacity{Teachers: 1}
+
+	if _, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", capacity); err != nil {
+		t.Fatal(err)
+	}
+	// учитель в очередь не встаёт даже в комнате с очередью
+	capacity.Queue = true
+	if _, _, err := StartLesson(ctx, conn, 1, "math", "other", "node-1", capacity); !errors.Is(err, ErrRoomFull) {
+		t.Fatalf("second teacher = %v, want %v", err, ErrRoomFull)
+	}
+}
@@ -0,0 +1,61 @@
+// Package dbtest — Postgres для тестов пакетов, которые ходят в БД (db, service).
+package dbtest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// Open — Postgres из TEST_DATABASE_URL со всеми миграциями в отдельной схеме
+// (после теста схема удаляется). Без TEST_DATABASE_URL тест пропускается.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// одно соединение — search_path действует на все запросы теста
+	conn.SetMaxOpenConns(1)
+
+	var id [6]byte
+	_, _ = rand.Read(id[:])
+	schema := "test_" + hex.EncodeToString(id[:])
+	if _, err := conn.Exec(`CREATE SCHEMA ` + schema + `; SET search_path TO ` + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = conn.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		conn.Close()
+	})
+
+	// migrations/ — от корня модуля, откуда бы ни запускался тест
+	_, self, _, _ := runtime.Caller(0)
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(self), "..", "..", "..", "migrations", "*.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("migrations: %v (%d files)", err, len(files))
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		sqlText, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec(string(sqlText)); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(f), err)
+		}
+	}
+	return conn
+}
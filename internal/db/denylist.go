@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// DenyParticipant — запретить участнику возвращаться.
+// lessonID.Valid => только в этот урок (kick), иначе — в комнату навсегда (ban).
+func DenyParticipant(
+	ctx context.Context,
+	db *sql.DB,
+	tenantID int64,
+	lessonID sql.NullInt64,
+	room, name, reason, actor string,
+) error {
+	ctx, span := startOp(ctx, "DenyParticipant",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Bool("ban", !lessonID.Valid),
+	)
+	defer span.End()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO participant_denylist
+			(tenant_id, room_name, participant_name, lesson_id, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, tenantID, room, name, lessonID, reason, actor)
+
+	return tracing.Fail(span, err)
+}
+
+// IsDenied — есть ли бан на комнату или kick из этого урока
+func IsDenied(ctx context.Context, db *sql.DB, tenantID, lessonID int64, room, name string) (bool, error) {
+	ctx, span := startOp(ctx, "IsDenied",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("lesson_id", lessonID),
+	)
+	defer span.End()
+
+	var denied bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1
+			FROM participant_denylist
+			WHERE tenant_id = $1
+			  AND room_name = $2
+			  AND participant_name = $3
+			  AND (lesson_id IS NULL OR lesson_id = $4)
+		)
+	`, tenantID, room, name, lessonID).Scan(&denied)
+
+	return denied, tracing.Fail(span, err)
+}
+
+// LiftBan — снять бан на комнату (kick'и уроков остаются как история)
+func LiftBan(ctx context.Context, db *sql.DB, tenantID int64, room, name string) (bool, error) {
+	ctx, span := startOp(ctx, "LiftBan", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM participant_denylist
+		WHERE tenant_id = $1
+		  AND room_name = $2
+		  AND participant_name = $3
+		  AND lesson_id IS NULL
+	`, tenantID, room, name)
+	if err != nil {
+		return false, tracing.Fail(span, err)
+	}
+
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
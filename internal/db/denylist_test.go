@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestDenylistKickAndBan(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	other, err := CreateTenant(ctx, conn, "other", "Other school", "other-api-key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", Capacity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EndLesson(ctx, conn, 1, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", Capacity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	denied := func(tenantID, lessonID int64, room, name string) bool {
+		t.Helper()
+		ok, err := IsDenied(ctx, conn, tenantID, lessonID, room, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	// kick — только из урока, на котором выгнали
+	if err := DenyParticipant(ctx, conn, 1, sql.NullInt64{Int64: first.ID, Valid: true}, "math", "ann", "noise", "teacher"); err != nil {
+		t.Fatal(err)
+	}
+	if !denied(1, first.ID, "math", "ann") || denied(1, second.ID, "math", "ann") {
+		t.Fatal("kick must deny only its own lesson")
+	}
+
+	// ban — на комнату в любом уроке, но не в других комнатах и школах
+	if err := DenyParticipant(ctx, conn, 1, sql.NullInt64{}, "math", "bob", "spam", "teacher"); err != nil {
+		t.Fatal(err)
+	}
+	if !denied(1, first.ID, "math", "bob") || !denied(1, second.ID, "math", "bob") {
+		t.Fatal("ban must deny every lesson of the room")
+	}
+	if denied(1, second.ID, "physics", "bob") || denied(other.ID, second.ID, "math", "bob") {
+		t.Fatal("ban leaks to another room or tenant")
+	}
+
+	// снятие бана не трогает kick'и
+	if lifted, err := LiftBan(ctx, conn, 1, "math", "ann"); err != nil || lifted {
+		t.Fatalf("lift a kick: lifted=%v err=%v, want false", lifted, err)
+	}
+	if lifted, err := LiftBan(ctx, conn, 1, "math", "bob"); err != nil || !lifted {
+		t.Fatalf("lift ban: lifted=%v err=%v", lifted, err)
+	}
+	if denied(1, second.ID, "math", "bob") || !denied(1, first.ID, "math", "ann") {
+		t.Fatal("after lift: bob must be allowed, ann's kick must stay")
+	}
+}
@@ -1,8 +1,14 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/logging"
+	"streaming/internal/tracing"
 )
 
 var allowedEventTypes = map[string]struct{}{
@@ -10,22 +16,40 @@ var allowedEventTypes = map[string]struct{}{
 	"lesson_ended":   {},
 	"join":           {},
 	"leave":          {},
+	"kick":           {},
+	"ban":            {},
 }
 
 // LogEvent — универсальная функция логирования событий урока
-func LogEvent(db *sql.DB, lessonID int64, eventType, actor string) error {
+func LogEvent(ctx context.Context, db *sql.DB, lessonID int64, eventType, actor string) error {
+	ctx, span := startOp(ctx, "LogEvent", attribute.Int64("lesson_id", lessonID))
+	defer span.End()
+
 	if lessonID <= 0 {
-		return errors.New("invalid lessonID")
+		return tracing.Fail(span, errors.New("invalid lessonID"))
 	}
 	if _, ok := allowedEventTypes[eventType]; !ok {
-		return errors.New("invalid event type: " + eventType)
+		return tracing.Fail(span, errors.New("invalid event type: "+eventType))
 	}
 
-	_, err := db.Exec(`
+	_, err := db.ExecContext(ctx, `
 		INSERT INTO lesson_events
 		(lesson_id, event_type, actor_name, occurred_at)
 		VALUES ($1, $2, $3, now())
 	`, lessonID, eventType, actor)
 
-	return err
+	return tracing.Fail(span, err)
+}
+
+// logEvent — LogEvent для вызовов "не ломаем основную операцию":
+// ошибка не возвращается, но и не теряется — пишем её в лог.
+func logEvent(ctx context.Context, db *sql.DB, lessonID int64, eventType, actor string) {
+	if err := LogEvent(ctx, db, lessonID, eventType, actor); err != nil {
+		logging.FromContext(ctx).Warn("lesson event not recorded",
+			"lesson_id", lessonID,
+			"event", eventType,
+			"actor", actor,
+			"error", err,
+		)
+	}
 }
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// ErrFileNotFound — файла нет в этом уроке (или он удалён)
+var ErrFileNotFound = errors.New("file not found")
+
+// LessonFile — метаданные файла урока (содержимое — в хранилище по StorageKey)
+type LessonFile struct {
+	ID           int64     `json:"id"`
+	LessonID     int64     `json:"lesson_id"`
+	Uploader     string    `json:"uploader"`
+	UploaderRole string    `json:"uploader_role"`
+	Kind         string    `json:"kind"` // material | homework
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	StorageKey   string    `json:"-"`
+	ScanStatus   string    `json:"scan_status"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+const lessonFileSelect = `
+	SELECT id, lesson_id, uploader, uploader_role, kind, filename, content_type,
+	       size_bytes, sha256, storage_key, scan_status, created_at
+	FROM lesson_files
+`
+
+// CreateLessonFile — записать загруженный файл (объект уже в хранилище)
+func CreateLessonFile(ctx context.Context, db *sql.DB, f *LessonFile) error {
+	ctx, span := startOp(ctx, "CreateLessonFile",
+		attribute.Int64("lesson_id", f.LessonID),
+		attribute.Int64("size", f.Size),
+	)
+	defer span.End()
+
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO lesson_files (
+			lesson_id, uploader, uploader_role, kind, filename, content_type,
+			size_bytes, sha256, storage_key, scan_status
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`, f.LessonID, f.Uploader, f.UploaderRole, f.Kind, f.Filename, f.ContentType,
+		f.Size, f.SHA256, f.StorageKey, f.ScanStatus,
+	).Scan(&f.ID, &f.CreatedAt)
+
+	return tracing.Fail(span, err)
+}
+
+// ListLessonFiles — файлы урока по времени загрузки.
+// uploader != "" => материалы учителя + файлы этого участника (то, что видит ученик).
+func ListLessonFiles(ctx context.Context, db *sql.DB, lessonID int64, uploader string) ([]LessonFile, error) {
+	ctx, span := startOp(ctx, "ListLessonFiles", attribute.Int64("lesson_id", lessonID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, lessonFileSelect+`
+		WHERE lesson_id = $1
+		  AND deleted_at IS NULL
+		  AND ($2::text = '' OR kind = 'material' OR uploader = $2)
+		ORDER BY created_at, id
+	`, lessonID, uploader)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []LessonFile{}
+	for rows.Next() {
+		f, err := scanLessonFile(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, *f)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// GetLessonFile — файл урока по id
+func GetLessonFile(ctx context.Context, db *sql.DB, lessonID, fileID int64) (*LessonFile, error) {
+	ctx, span := startOp(ctx, "GetLessonFile",
+		attribute.Int64("lesson_id", lessonID),
+		attribute.Int64("file_id", fileID),
+	)
+	defer span.End()
+
+	f, err := scanLessonFile(db.QueryRowContext(ctx, lessonFileSelect+`
+		WHERE lesson_id = $1
+		  AND id = $2
+		  AND deleted_at IS NULL
+	`, lessonID, fileID))
+	if err == sql.ErrNoRows {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return f, nil
+}
+
+// DeleteLessonFile — пометить файл удалённым (запись остаётся для истории)
+func DeleteLessonFile(ctx context.Context, db *sql.DB, lessonID, fileID int64) error {
+	ctx, span := startOp(ctx, "DeleteLessonFile",
+		attribute.Int64("lesson_id", lessonID),
+		attribute.Int64("file_id", fileID),
+	)
+	defer span.End()
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE lesson_files
+		SET deleted_at = now()
+		WHERE lesson_id = $1
+		  AND id = $2
+		  AND deleted_at IS NULL
+	`, lessonID, fileID)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrFileNotFound
+	}
+	return nil
+}
+
+func scanLessonFile(row interface{ Scan(...any) error }) (*LessonFile, error) {
+	var f LessonFile
+	if err := row.Scan(
+		&f.ID, &f.LessonID, &f.Uploader, &f.UploaderRole, &f.Kind, &f.Filename, &f.ContentType,
+		&f.Size, &f.SHA256, &f.StorageKey, &f.ScanStatus, &f.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
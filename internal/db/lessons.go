@@ -1,48 +1,153 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
 )
 
+// ErrNoActiveLesson — в комнате нет открытого урока (не ошибка БД)
+var ErrNoActiveLesson = errors.New("no active lesson")
+
+// Lesson — строка lessons (то, что нужно join-логике)
+type Lesson struct {
+	ID          int64
+	TenantID    int64
+	Room        string
+	Teacher     string
+	LiveKitNode string
+	StartedAt   time.Time
+	EndedAt     *time.Time // только GetLesson
+}
+
 // =======================
 // Start lesson (teacher)
 // =======================
 
-func StartLesson(db *sql.DB, room, teacher string) (int64, error) {
-	var id int64
-
-	err := db.QueryRow(`
-		INSERT INTO lessons (room_name, teacher_name, started_at)
-		VALUES ($1, $2, now())
-		RETURNING id
-	`, room, teacher).Scan(&id)
+// node — LiveKit узел, на котором будет жить комната урока
+// StartLesson — урок учителя в комнате; место учителя занимается в той же транзакции
+// (ErrRoomFull — учителей в комнате уже сколько положено).
+// Открытый урок этого учителя в комнате (переподключение, вторая вкладка) не
+// закрываем и не дублируем — продолжаем его: resumed = true.
+func StartLesson(ctx context.Context, db *sql.DB, tenantID int64, room, teacher, node string, capacity Capacity) (lesson *Lesson, resumed bool, err error) {
+	ctx, span := startOp(ctx, "StartLesson",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.String("room", room),
+		attribute.String("livekit_node", node),
+	)
+	defer span.End()
 
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return nil, false, tracing.Fail(span, err)
+	}
+	defer tx.Rollback()
+
+	// два входа учителя одновременно не откроют два урока
+	if err := lockRoom(ctx, tx, tenantID, room); err != nil {
+		return nil, false, tracing.Fail(span, err)
+	}
+
+	// учитель в очередь не встаёт: без него урока нет
+	capacity.Queue = false
+	if err := takeSeat(ctx, tx, tenantID, room, teacher, "teacher", capacity); err != nil {
+		if errors.Is(err, ErrRoomFull) {
+			return nil, false, err
+		}
+		return nil, false, tracing.Fail(span, err)
+	}
+
+	l := Lesson{TenantID: tenantID, Room: room, Teacher: teacher}
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, livekit_node, started_at
+		FROM lessons
+		WHERE tenant_id = $1
+		  AND room_name = $2
+		  AND teacher_name = $3
+		  AND ended_at IS NULL
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, tenantID, room, teacher).Scan(&l.ID, &l.LiveKitNode, &l.StartedAt)
+	switch {
+	case err == nil:
+		resumed = true
+	case errors.Is(err, sql.ErrNoRows):
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO lessons (tenant_id, room_name, teacher_name, started_at, livekit_node)
+			VALUES ($1, $2, $3, now(), $4)
+			RETURNING id, livekit_node, started_at
+		`, tenantID, room, teacher, node).Scan(&l.ID, &l.LiveKitNode, &l.StartedAt)
+		if err != nil {
+			return nil, false, tracing.Fail(span, err)
+		}
+	default:
+		return nil, false, tracing.Fail(span, err)
+	}
+
+	// место учителя занято сразу: второй учитель не проскочит до JoinParticipant
+	if err := upsertParticipant(ctx, tx, tenantID, l.ID, teacher, "teacher"); err != nil {
+		return nil, false, tracing.Fail(span, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, tracing.Fail(span, err)
 	}
 
 	// лог события (не ломаем урок, если логирование не удалось)
-	_ = LogEvent(db, id, "lesson_started", teacher)
+	if !resumed {
+		logEvent(ctx, db, l.ID, "lesson_started", teacher)
+	}
+
+	return &l, resumed, nil
+}
+
+// SetLessonSchedule — плановое время урока (nil => не менять)
+func SetLessonSchedule(ctx context.Context, db *sql.DB, tenantID, lessonID int64, start, end *time.Time) error {
+	ctx, span := startOp(ctx, "SetLessonSchedule",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("lesson_id", lessonID),
+	)
+	defer span.End()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE lessons
+		SET scheduled_start = COALESCE($3, scheduled_start),
+		    scheduled_end = COALESCE($4, scheduled_end)
+		WHERE id = $1
+		  AND tenant_id = $2
+	`, lessonID, tenantID, start, end)
 
-	return id, nil
+	return tracing.Fail(span, err)
 }
 
 // =======================
 // End lesson
 // =======================
 
-func EndLesson(db *sql.DB, lessonID int64) error {
+func EndLesson(ctx context.Context, db *sql.DB, tenantID, lessonID int64) error {
+	ctx, span := startOp(ctx, "EndLesson",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("lesson_id", lessonID),
+	)
+	defer span.End()
+
 	// ✅ закрываем только если ещё не закрыт
-	res, err := db.Exec(`
+	res, err := db.ExecContext(ctx, `
 		UPDATE lessons
 		SET ended_at = now(),
 		    duration_sec = EXTRACT(EPOCH FROM (now() - started_at))::int
 		WHERE id = $1
+		  AND tenant_id = $2
 		  AND ended_at IS NULL
-	`, lessonID)
+	`, lessonID, tenantID)
 	if err != nil {
-		return err
+		return tracing.Fail(span, err)
 	}
 
 	affected, _ := res.RowsAffected()
@@ -51,7 +156,11 @@ func EndLesson(db *sql.DB, lessonID int64) error {
 		return nil
 	}
 
-	_ = LogEvent(db, lessonID, "lesson_ended", "")
+	if err := closeSessions(ctx, db, lessonID, ""); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	logEvent(ctx, db, lessonID, "lesson_ended", "")
 	return nil
 }
 
@@ -59,24 +168,127 @@ func EndLesson(db *sql.DB, lessonID int64) error {
 // Get active lesson by room
 // =======================
 
-func GetActiveLesson(db *sql.DB, room string) (int64, error) {
-	var id int64
+func GetActiveLesson(ctx context.Context, db *sql.DB, tenantID int64, room string) (*Lesson, error) {
+	ctx, span := startOp(ctx, "GetActiveLesson",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.String("room", room),
+	)
+	defer span.End()
 
-	err := db.QueryRow(`
-		SELECT id
+	var l Lesson
+
+	err := db.QueryRowContext(ctx, `
+		SELECT id, tenant_id, room_name, teacher_name, livekit_node, started_at
 		FROM lessons
-		WHERE room_name = $1
+		WHERE tenant_id = $1
+		  AND room_name = $2
 		  AND ended_at IS NULL
 		ORDER BY started_at DESC
 		LIMIT 1
-	`, room).Scan(&id)
+	`, tenantID, room).Scan(&l.ID, &l.TenantID, &l.Room, &l.Teacher, &l.LiveKitNode, &l.StartedAt)
 
 	if err == sql.ErrNoRows {
-		return 0, errors.New("no active lesson")
+		return nil, ErrNoActiveLesson
 	}
 	if err != nil {
-		return 0, err
+		return nil, tracing.Fail(span, err)
+	}
+
+	return &l, nil
+}
+
+// =======================
+// Get lesson by id
+// =======================
+
+func GetLesson(ctx context.Context, db *sql.DB, tenantID, lessonID int64) (*Lesson, error) {
+	ctx, span := startOp(ctx, "GetLesson",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("lesson_id", lessonID),
+	)
+	defer span.End()
+
+	var l Lesson
+	var endedAt sql.NullTime
+
+	err := db.QueryRowContext(ctx, `
+		SELECT id, tenant_id, room_name, teacher_name, livekit_node, started_at, ended_at
+		FROM lessons
+		WHERE id = $1
+		  AND tenant_id = $2
+	`, lessonID, tenantID).Scan(&l.ID, &l.TenantID, &l.Room, &l.Teacher, &l.LiveKitNode, &l.StartedAt, &endedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrLessonNotFound
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	if endedAt.Valid {
+		l.EndedAt = &endedAt.Time
+	}
+
+	return &l, nil
+}
+
+// =======================
+// Count active lessons (metrics)
+// =======================
+
+func CountActiveLessons(ctx context.Context, db *sql.DB) (int, error) {
+	ctx, span := startOp(ctx, "CountActiveLessons")
+	defer span.End()
+
+	var cnt int
+	err := db.QueryRowContext(ctx, `
+		SELECT count(*)
+		FROM lessons
+		WHERE ended_at IS NULL
+	`).Scan(&cnt)
+
+	return cnt, tracing.Fail(span, err)
+}
+
+// =======================
+// End all open lessons (shutdown)
+// =======================
+
+// EndOpenLessons закрывает все уроки без ended_at и возвращает их количество.
+func EndOpenLessons(ctx context.Context, db *sql.DB) (int, error) {
+	ctx, span := startOp(ctx, "EndOpenLessons")
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		UPDATE lessons
+		SET ended_at = now(),
+		    duration_sec = EXTRACT(EPOCH FROM (now() - started_at))::int
+		WHERE ended_at IS NULL
+		RETURNING id
+	`)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, tracing.Fail(span, err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	for _, id := range ids {
+		if err := closeSessions(ctx, db, id, ""); err != nil {
+			return 0, tracing.Fail(span, err)
+		}
+		logEvent(ctx, db, id, "lesson_ended", "")
 	}
 
-	return id, nil
+	return len(ids), nil
 }
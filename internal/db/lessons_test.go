@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStartLessonResumesTeachersOpenLesson(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	first, resumed, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", Capacity{})
+	if err != nil || resumed {
+		t.Fatalf("start: resumed=%v err=%v", resumed, err)
+	}
+	if err := JoinParticipant(ctx, conn, 1, first.ID, "math", "ann", "student", Capacity{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// учитель переподключился (узел мог выбраться другой) — тот же урок на том же узле
+	again, resumed, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-2", Capacity{})
+	if err != nil || !resumed {
+		t.Fatalf("reconnect: resumed=%v err=%v", resumed, err)
+	}
+	if again.ID != first.ID || again.LiveKitNode != "node-1" || !again.StartedAt.Equal(first.StartedAt) {
+		t.Fatalf("reconnect = %+v, want lesson %+v", again, first)
+	}
+
+	var open, started int
+	if err := conn.QueryRow(`
+		SELECT (SELECT count(*) FROM lessons WHERE room_name = 'math' AND ended_at IS NULL),
+		       (SELECT count(*) FROM lesson_events WHERE event_type = 'lesson_started')
+	`).Scan(&open, &started); err != nil {
+		t.Fatal(err)
+	}
+	if open != 1 || started != 1 {
+		t.Fatalf("open lessons = %d, lesson_started events = %d, want 1 and 1", open, started)
+	}
+
+	// другой учитель — свой урок; другая комната — тоже
+	other, resumed, err := StartLesson(ctx, conn, 1, "math", "substitute", "node-1", Capacity{})
+	if err != nil || resumed || other.ID == first.ID {
+		t.Fatalf("other teacher: %+v resumed=%v err=%v", other, resumed, err)
+	}
+	physics, resumed, err := StartLesson(ctx, conn, 1, "physics", "teacher", "node-1", Capacity{})
+	if err != nil || resumed || physics.ID == first.ID {
+		t.Fatalf("other room: %+v resumed=%v err=%v", physics, resumed, err)
+	}
+
+	// урок закрыт — следующий вход учителя начинает новый
+	if err := EndLesson(ctx, conn, 1, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	next, resumed, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", Capacity{})
+	if err != nil || resumed || next.ID == first.ID {
+		t.Fatalf("after end: %+v resumed=%v err=%v", next, resumed, err)
+	}
+}
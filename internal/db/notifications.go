@@ -0,0 +1,542 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+var (
+	ErrScheduledLessonNotFound = errors.New("scheduled lesson not found")
+	ErrNotificationNotFound    = errors.New("notification not found")
+)
+
+// события уведомлений
+const (
+	EventLessonStarted  = "lesson_started"
+	EventLessonReminder = "lesson_reminder"
+)
+
+// NotificationPrefs — куда и о чём уведомлять ученика
+type NotificationPrefs struct {
+	StudentName      string          `json:"student_name"`
+	Email            string          `json:"email"`
+	TelegramChatID   string          `json:"telegram_chat_id"`
+	PushSubscription json.RawMessage `json:"push_subscription,omitempty"`
+	Channels         []string        `json:"channels"` // email | telegram | webpush
+	LessonStarted    bool            `json:"lesson_started"`
+	Reminders        bool            `json:"reminders"`
+	UpdatedAt        *time.Time      `json:"updated_at,omitempty"`
+}
+
+// Wants — ученик подписан на событие
+func (p *NotificationPrefs) Wants(event string) bool {
+	switch event {
+	case EventLessonStarted:
+		return p.LessonStarted
+	case EventLessonReminder:
+		return p.Reminders
+	}
+	return false
+}
+
+// ScheduledLesson — урок в расписании (для напоминаний)
+type ScheduledLesson struct {
+	ID         int64      `json:"id"`
+	TenantID   int64      `json:"tenant_id"`
+	Room       string     `json:"room"`
+	Title      string     `json:"title"`
+	Teacher    string     `json:"teacher"`
+	StartsAt   time.Time  `json:"starts_at"`
+	EndsAt     *time.Time `json:"ends_at,omitempty"`
+	RemindedAt *time.Time `json:"reminded_at,omitempty"`
+}
+
+// Notification — сообщение в очереди (один получатель, один канал)
+type Notification struct {
+	ID            int64      `json:"id"`
+	TenantID      int64      `json:"tenant_id"`
+	Recipient     string     `json:"recipient"`
+	Channel       string     `json:"channel"`
+	Address       string     `json:"-"` // email, chat id или подписка push — наружу не отдаём
+	Event         string     `json:"event"`
+	Room          string     `json:"room"`
+	Subject       string     `json:"subject"`
+	Body          string     `json:"body"`
+	Link          string     `json:"link"`
+	DedupKey      string     `json:"dedup_key"`
+	Status        string     `json:"status"` // pending | sent | failed
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
+
+// =======================
+// Preferences
+// =======================
+
+const prefsColumns = `student_name, email, telegram_chat_id, push_subscription, channels,
+	lesson_started, reminders, updated_at`
+
+func scanPrefs(row interface{ Scan(...any) error }) (*NotificationPrefs, error) {
+	var (
+		p    NotificationPrefs
+		push []byte
+		upd  time.Time
+	)
+	if err := row.Scan(&p.StudentName, &p.Email, &p.TelegramChatID, &push, pq.Array(&p.Channels),
+		&p.LessonStarted, &p.Reminders, &upd); err != nil {
+		return nil, err
+	}
+	if len(push) > 0 {
+		p.PushSubscription = push
+	}
+	if p.Channels == nil {
+		p.Channels = []string{}
+	}
+	p.UpdatedAt = &upd
+	return &p, nil
+}
+
+// GetNotificationPrefs — настройки ученика; не сохранял — значения по умолчанию (без каналов)
+func GetNotificationPrefs(ctx context.Context, db *sql.DB, tenantID int64, student string) (*NotificationPrefs, error) {
+	ctx, span := startOp(ctx, "GetNotificationPrefs", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	p, err := scanPrefs(db.QueryRowContext(ctx, `
+		SELECT `+prefsColumns+`
+		FROM notification_prefs
+		WHERE tenant_id = $1
+		  AND student_name = $2
+	`, tenantID, student))
+
+	if err == sql.ErrNoRows {
+		return &NotificationPrefs{
+			StudentName:   student,
+			Channels:      []string{},
+			LessonStarted: true,
+			Reminders:     true,
+		}, nil
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return p, nil
+}
+
+// SaveNotificationPrefs — создать или заменить настройки ученика
+func SaveNotificationPrefs(ctx context.Context, db *sql.DB, tenantID int64, p *NotificationPrefs) error {
+	ctx, span := startOp(ctx, "SaveNotificationPrefs", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	var push any
+	if len(p.PushSubscription) > 0 {
+		push = []byte(p.PushSubscription)
+	}
+
+	var upd time.Time
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO notification_prefs
+			(tenant_id, student_name, email, telegram_chat_id, push_subscription, channels, lesson_started, reminders)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (tenant_id, student_name) DO UPDATE
+		SET email = EXCLUDED.email,
+		    telegram_chat_id = EXCLUDED.telegram_chat_id,
+		    push_subscription = EXCLUDED.push_subscription,
+		    channels = EXCLUDED.channels,
+		    lesson_started = EXCLUDED.lesson_started,
+		    reminders = EXCLUDED.reminders,
+		    updated_at = now()
+		RETURNING updated_at
+	`, tenantID, p.StudentName, p.Email, p.TelegramChatID, push, pq.Array(p.Channels),
+		p.LessonStarted, p.Reminders).Scan(&upd)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	p.UpdatedAt = &upd
+	return nil
+}
+
+// ListNotificationPrefs — сохранённые настройки учеников школы
+func ListNotificationPrefs(ctx context.Context, db *sql.DB, tenantID int64) ([]NotificationPrefs, error) {
+	ctx, span := startOp(ctx, "ListNotificationPrefs", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+prefsColumns+`
+		FROM notification_prefs
+		WHERE tenant_id = $1
+		ORDER BY student_name
+	`, tenantID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []NotificationPrefs{}
+	for rows.Next() {
+		p, err := scanPrefs(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, *p)
+	}
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// RoomSubscribers — ученики классов комнаты, подписанные на событие хотя бы одним каналом
+func RoomSubscribers(ctx context.Context, db *sql.DB, tenantID int64, room, event string) ([]NotificationPrefs, error) {
+	ctx, span := startOp(ctx, "RoomSubscribers",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.String("event", event),
+	)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT ON (p.student_name)
+		       p.student_name, p.email, p.telegram_chat_id, p.push_subscription, p.channels,
+		       p.lesson_started, p.reminders, p.updated_at
+		FROM class_rooms r
+		JOIN enrollments e ON e.class_id = r.class_id
+		JOIN notification_prefs p ON p.tenant_id = r.tenant_id AND p.student_name = e.student_name
+		WHERE r.tenant_id = $1
+		  AND r.room_name = $2
+		  AND cardinality(p.channels) > 0
+		ORDER BY p.student_name
+	`, tenantID, room)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []NotificationPrefs{}
+	for rows.Next() {
+		p, err := scanPrefs(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		if p.Wants(event) {
+			out = append(out, *p)
+		}
+	}
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// =======================
+// Schedule
+// =======================
+
+const scheduleColumns = `id, tenant_id, room_name, title, teacher_name, starts_at, ends_at, reminded_at`
+
+func scanScheduled(row interface{ Scan(...any) error }) (*ScheduledLesson, error) {
+	var s ScheduledLesson
+	err := row.Scan(&s.ID, &s.TenantID, &s.Room, &s.Title, &s.Teacher, &s.StartsAt, &s.EndsAt, &s.RemindedAt)
+	return &s, err
+}
+
+func CreateScheduledLesson(ctx context.Context, db *sql.DB, s *ScheduledLesson) error {
+	ctx, span := startOp(ctx, "CreateScheduledLesson", attribute.Int64("tenant_id", s.TenantID))
+	defer span.End()
+
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO scheduled_lessons (tenant_id, room_name, title, teacher_name, starts_at, ends_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, s.TenantID, s.Room, s.Title, s.Teacher, s.StartsAt, s.EndsAt).Scan(&s.ID)
+
+	return tracing.Fail(span, err)
+}
+
+// ListScheduledLessons — предстоящие уроки школы (room == "" => все комнаты)
+func ListScheduledLessons(ctx context.Context, db *sql.DB, tenantID int64, room string, from time.Time) ([]ScheduledLesson, error) {
+	ctx, span := startOp(ctx, "ListScheduledLessons", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM scheduled_lessons
+		WHERE tenant_id = $1
+		  AND ($2::text = '' OR room_name = $2)
+		  AND starts_at >= $3
+		ORDER BY starts_at, id
+		LIMIT 500
+	`, tenantID, room, from)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []ScheduledLesson{}
+	for rows.Next() {
+		s, err := scanScheduled(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, *s)
+	}
+	return out, tracing.Fail(span, rows.Err())
+}
+
+func DeleteScheduledLesson(ctx context.Context, db *sql.DB, tenantID, id int64) error {
+	ctx, span := startOp(ctx, "DeleteScheduledLesson",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("scheduled_id", id),
+	)
+	defer span.End()
+
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM scheduled_lessons
+		WHERE id = $1
+		  AND tenant_id = $2
+	`, id, tenantID)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrScheduledLessonNotFound
+	}
+	return nil
+}
+
+// ClaimDueReminders — уроки, до начала которых осталось меньше before; помечаются
+// как напомненные сразу, чтобы несколько реплик не напомнили дважды
+func ClaimDueReminders(ctx context.Context, db *sql.DB, before time.Duration, limit int) ([]ScheduledLesson, error) {
+	ctx, span := startOp(ctx, "ClaimDueReminders")
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		UPDATE scheduled_lessons
+		SET reminded_at = now()
+		WHERE id IN (
+			SELECT id
+			FROM scheduled_lessons
+			WHERE reminded_at IS NULL
+			  AND starts_at > now()
+			  AND starts_at <= now() + make_interval(secs => $1)
+			ORDER BY starts_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+scheduleColumns+`
+	`, before.Seconds(), limit)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []ScheduledLesson{}
+	for rows.Next() {
+		s, err := scanScheduled(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, *s)
+	}
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// ReleaseReminder — напоминание не поставлено в очередь: вернуть урок следующему проходу
+func ReleaseReminder(ctx context.Context, db *sql.DB, id int64) error {
+	ctx, span := startOp(ctx, "ReleaseReminder", attribute.Int64("scheduled_id", id))
+	defer span.End()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE scheduled_lessons
+		SET reminded_at = NULL
+		WHERE id = $1
+	`, id)
+	return tracing.Fail(span, err)
+}
+
+// =======================
+// Queue
+// =======================
+
+const notificationColumns = `id, tenant_id, recipient, channel, address, event, room_name, subject, body, link,
+	dedup_key, status, attempts, next_attempt_at, last_error, created_at, sent_at`
+
+func scanNotification(row interface{ Scan(...any) error }) (*Notification, error) {
+	var n Notification
+	err := row.Scan(&n.ID, &n.TenantID, &n.Recipient, &n.Channel, &n.Address, &n.Event, &n.Room, &n.Subject,
+		&n.Body, &n.Link, &n.DedupKey, &n.Status, &n.Attempts, &n.NextAttemptAt, &n.LastError,
+		&n.CreatedAt, &n.SentAt)
+	return &n, err
+}
+
+// EnqueueNotifications — поставить сообщения в очередь; уже бывшие (dedup_key) пропускаются,
+// как и те, о которых этому получателю в этот канал писали за последние quiet
+// (то же событие той же комнаты). Возвращает, сколько добавлено.
+func EnqueueNotifications(ctx context.Context, db *sql.DB, list []Notification, quiet time.Duration) (int, error) {
+	ctx, span := startOp(ctx, "EnqueueNotifications", attribute.Int("count", len(list)))
+	defer span.End()
+
+	if len(list) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, n := range list {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO notifications
+				(tenant_id, recipient, channel, address, event, room_name, subject, body, link, dedup_key)
+			SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+			WHERE NOT EXISTS (
+				SELECT 1
+				FROM notifications
+				WHERE tenant_id = $1
+				  AND room_name = $6
+				  AND recipient = $2
+				  AND channel = $3
+				  AND event = $5
+				  AND created_at > now() - make_interval(secs => $11)
+			)
+			ON CONFLICT (dedup_key) DO NOTHING
+		`, n.TenantID, n.Recipient, n.Channel, n.Address, n.Event, n.Room, n.Subject, n.Body, n.Link,
+			n.DedupKey, quiet.Seconds())
+		if err != nil {
+			return 0, tracing.Fail(span, err)
+		}
+		if k, _ := res.RowsAffected(); k > 0 {
+			added++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	return added, nil
+}
+
+// ClaimNotifications — забрать до limit сообщений к отправке.
+// Попытка засчитывается сразу, а next_attempt_at сдвигается на lease: если процесс
+// упадёт посреди отправки, сообщение вернётся в очередь само.
+func ClaimNotifications(ctx context.Context, db *sql.DB, limit int, lease time.Duration) ([]Notification, error) {
+	ctx, span := startOp(ctx, "ClaimNotifications")
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		UPDATE notifications
+		SET attempts = attempts + 1,
+		    next_attempt_at = now() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id
+			FROM notifications
+			WHERE status = 'pending'
+			  AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+notificationColumns+`
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, *n)
+	}
+	return out, tracing.Fail(span, rows.Err())
+}
+
+func MarkNotificationSent(ctx context.Context, db *sql.DB, id int64) error {
+	ctx, span := startOp(ctx, "MarkNotificationSent", attribute.Int64("notification_id", id))
+	defer span.End()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE notifications
+		SET status = 'sent',
+		    sent_at = now(),
+		    last_error = ''
+		WHERE id = $1
+	`, id)
+	return tracing.Fail(span, err)
+}
+
+// MarkNotificationFailed — попытка не удалась: retryAt == nil => больше не пробуем
+func MarkNotificationFailed(ctx context.Context, db *sql.DB, id int64, reason string, retryAt *time.Time) error {
+	ctx, span := startOp(ctx, "MarkNotificationFailed", attribute.Int64("notification_id", id))
+	defer span.End()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE notifications
+		SET status = CASE WHEN $3::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+		    next_attempt_at = COALESCE($3, next_attempt_at),
+		    last_error = $2
+		WHERE id = $1
+	`, id, reason, retryAt)
+	return tracing.Fail(span, err)
+}
+
+// ListNotifications — очередь для админки (tenantID == 0 => все школы, status == "" => любой)
+func ListNotifications(ctx context.Context, db *sql.DB, tenantID int64, status string, limit int) ([]Notification, error) {
+	ctx, span := startOp(ctx, "ListNotifications", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+notificationColumns+`
+		FROM notifications
+		WHERE ($1 = 0 OR tenant_id = $1)
+		  AND ($2::text = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`, tenantID, status, limit)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, *n)
+	}
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// RetryNotification — вернуть неотправленное сообщение в очередь с чистым счётчиком попыток
+func RetryNotification(ctx context.Context, db *sql.DB, tenantID, id int64) error {
+	ctx, span := startOp(ctx, "RetryNotification", attribute.Int64("notification_id", id))
+	defer span.End()
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE notifications
+		SET status = 'pending',
+		    attempts = 0,
+		    next_attempt_at = now()
+		WHERE id = $1
+		  AND ($2 = 0 OR tenant_id = $2)
+		  AND status <> 'sent'
+	`, id, tenantID)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"streaming/internal/db/dbtest"
+)
+
+// testDB — см. dbtest.Open
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	return dbtest.Open(t)
+}
+
+func testNotification(recipient, dedupKey string) Notification {
+	return Notification{
+		TenantID:  1,
+		Recipient: recipient,
+		Channel:   "email",
+		Address:   recipient + "@example.com",
+		Event:     EventLessonStarted,
+		Room:      "math",
+		Subject:   "Lesson started",
+		Body:      "Join now",
+		DedupKey:  dedupKey,
+	}
+}
+
+func TestEnqueueNotificationsDedup(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	added, err := EnqueueNotifications(ctx, conn, []Notification{
+		testNotification("ann", "lesson_started:lesson:10:1:ann:email"),
+		testNotification("bob", "lesson_started:lesson:10:1:bob:email"),
+	}, 30*time.Minute)
+	if err != nil || added != 2 {
+		t.Fatalf("first enqueue: added=%d err=%v", added, err)
+	}
+
+	// тот же dedup_key — второй раз не кладём, даже без quiet
+	added, err = EnqueueNotifications(ctx, conn, []Notification{
+		testNotification("ann", "lesson_started:lesson:10:1:ann:email"),
+	}, 0)
+	if err != nil || added != 0 {
+		t.Fatalf("same dedup key: added=%d err=%v", added, err)
+	}
+
+	// новый урок в той же комнате в пределах quiet — тоже нет
+	added, err = EnqueueNotifications(ctx, conn, []Notification{
+		testNotification("ann", "lesson_started:lesson:11:1:ann:email"),
+	}, 30*time.Minute)
+	if err != nil || added != 0 {
+		t.Fatalf("within quiet period: added=%d err=%v", added, err)
+	}
+
+	// без quiet (напоминания) новый ключ проходит
+	added, err = EnqueueNotifications(ctx, conn, []Notification{
+		testNotification("ann", "lesson_reminder:schedule:5:1:ann:email"),
+	}, 0)
+	if err != nil || added != 1 {
+		t.Fatalf("new key without quiet: added=%d err=%v", added, err)
+	}
+
+	var total int
+	if err := conn.QueryRow(`SELECT count(*) FROM notifications`).Scan(&total); err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 {
+		t.Fatalf("notifications = %d, want 3", total)
+	}
+}
+
+func TestClaimAndMarkNotifications(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	if _, err := EnqueueNotifications(ctx, conn, []Notification{testNotification("ann", "k1")}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	batch, err := ClaimNotifications(ctx, conn, 10, time.Minute)
+	if err != nil || len(batch) != 1 || batch[0].Attempts != 1 {
+		t.Fatalf("claim: %+v err=%v", batch, err)
+	}
+	// взятое сообщение до конца lease никто другой не берёт
+	if again, err := ClaimNotifications(ctx, conn, 10, time.Minute); err != nil || len(again) != 0 {
+		t.Fatalf("claim during lease: %+v err=%v", again, err)
+	}
+
+	// повтор "уже наступил" — сообщение снова в очереди
+	retryAt := time.Now().Add(-time.Second)
+	if err := MarkNotificationFailed(ctx, conn, batch[0].ID, "temporary", &retryAt); err != nil {
+		t.Fatal(err)
+	}
+	batch, err = ClaimNotifications(ctx, conn, 10, time.Minute)
+	if err != nil || len(batch) != 1 || batch[0].Attempts != 2 || batch[0].LastError != "temporary" {
+		t.Fatalf("claim after retry: %+v err=%v", batch, err)
+	}
+
+	if err := MarkNotificationFailed(ctx, conn, batch[0].ID, "permanent", nil); err != nil {
+		t.Fatal(err)
+	}
+	var status string
+	if err := conn.QueryRow(`SELECT status FROM notifications WHERE id = $1`, batch[0].ID).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status != "failed" {
+		t.Fatalf("status = %s, want failed", status)
+	}
+}
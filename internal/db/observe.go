@@ -0,0 +1,35 @@
+package db
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"streaming/internal/metrics"
+	"streaming/internal/tracing"
+)
+
+// op — span функции internal/db, который при End ещё и пишет латентность в метрики.
+type op struct {
+	trace.Span
+	observe func()
+}
+
+func (o op) End(options ...trace.SpanEndOption) {
+	o.observe()
+	o.Span.End(options...)
+}
+
+// startOp открывает span "db.<fn>" и запускает таймер метрики:
+//
+//	ctx, span := startOp(ctx, "StartLesson")
+//	defer span.End()
+func startOp(ctx context.Context, fn string, attrs ...attribute.KeyValue) (context.Context, op) {
+	attrs = append(attrs,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("code.function", fn),
+	)
+	ctx, span := tracing.Start(ctx, "db."+fn, attrs...)
+	return ctx, op{Span: span, observe: metrics.ObserveDB(fn)}
+}
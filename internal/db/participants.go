@@ -1,56 +1,240 @@
 package db
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
 
-func JoinParticipant(db *sql.DB, lessonID int64, name, role string) error {
-	// ✅ если человек переподключился — не создаём дубль,
-	// просто "реанимируем" запись (left_at = NULL)
-	_, err := db.Exec(`
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// ErrLessonNotFound — урока нет (или он другой школы)
+var ErrLessonNotFound = errors.New("lesson not found")
+
+// JoinParticipant — участник вошёл в урок; capacity проверяется по всей комнате
+// (ErrRoomFull / *RoomFullError — мест нет, запись в очереди сохранена; ErrNoGuestSeat — гостевых мест нет)
+func JoinParticipant(ctx context.Context, db *sql.DB, tenantID, lessonID int64, room, name, role string, capacity Capacity) error {
+	ctx, span := startOp(ctx, "JoinParticipant",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("lesson_id", lessonID),
+	)
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	defer tx.Rollback()
+
+	if err := takeSeat(ctx, tx, tenantID, room, name, role, capacity); err != nil {
+		if errors.Is(err, ErrRoomFull) {
+			return commitQueued(tx, err)
+		}
+		if errors.Is(err, ErrNoGuestSeat) {
+			return err
+		}
+		return tracing.Fail(span, err)
+	}
+
+	if err := upsertParticipant(ctx, tx, tenantID, lessonID, name, role); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	// сегмент присутствия: переподключение без leave закрывает предыдущий
+	if err := closeSessions(ctx, tx, lessonID, name); err != nil {
+		return tracing.Fail(span, err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO participant_sessions (lesson_id, participant_name, role, joined_at)
+		VALUES ($1, $2, $3, now())
+	`, lessonID, name, role); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	logEvent(ctx, db, lessonID, "join", name)
+	return nil
+}
+
+// upsertParticipant — запись участника урока школы.
+// ✅ если человек переподключился — не создаём дубль,
+// просто "реанимируем" запись (left_at = NULL)
+func upsertParticipant(ctx context.Context, tx *sql.Tx, tenantID, lessonID int64, name, role string) error {
+	// урок должен принадлежать этой школе
+	res, err := tx.ExecContext(ctx, `
 		INSERT INTO lesson_participants
 			(lesson_id, participant_name, role, joined_at, left_at)
-		VALUES ($1, $2, $3, now(), NULL)
+		SELECT id, $2, $3, now(), NULL
+		FROM lessons
+		WHERE id = $1
+		  AND tenant_id = $4
 		ON CONFLICT (lesson_id, participant_name)
 		DO UPDATE SET
 			role = EXCLUDED.role,
 			joined_at = EXCLUDED.joined_at,
 			left_at = NULL
-	`, lessonID, name, role)
-
+	`, lessonID, name, role, tenantID)
 	if err != nil {
 		return err
 	}
-
-	_ = LogEvent(db, lessonID, "join", name)
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrLessonNotFound
+	}
 	return nil
 }
 
-func LeaveParticipant(db *sql.DB, lessonID int64, name string) error {
-	_, err := db.Exec(`
-		UPDATE lesson_participants
-		SET left_at = now()
-		WHERE lesson_id = $1
-		  AND participant_name = $2
-		  AND left_at IS NULL
-	`, lessonID, name)
+func LeaveParticipant(ctx context.Context, db *sql.DB, tenantID, lessonID int64, name string) error {
+	ctx, span := startOp(ctx, "LeaveParticipant",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("lesson_id", lessonID),
+	)
+	defer span.End()
 
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return tracing.Fail(span, err)
 	}
+	defer tx.Rollback()
 
-	_ = LogEvent(db, lessonID, "leave", name)
+	res, err := tx.ExecContext(ctx, `
+		UPDATE lesson_participants lp
+		SET left_at = now()
+		FROM lessons l
+		WHERE l.id = lp.lesson_id
+		  AND l.tenant_id = $3
+		  AND lp.lesson_id = $1
+		  AND lp.participant_name = $2
+		  AND lp.left_at IS NULL
+	`, lessonID, name, tenantID)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	// урок чужой школы — сегменты не трогаем
+	if n, _ := res.RowsAffected(); n > 0 {
+		if err := closeSessions(ctx, tx, lessonID, name); err != nil {
+			return tracing.Fail(span, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	logEvent(ctx, db, lessonID, "leave", name)
 	return nil
 }
 
+// Participant — запись участника урока
+type Participant struct {
+	Name     string
+	Role     string
+	JoinedAt time.Time
+	LeftAt   sql.NullTime
+}
+
+// ErrParticipantNotFound — человек не заходил в этот урок (не ошибка БД)
+var ErrParticipantNotFound = errors.New("participant not found")
+
+// GetParticipant — участник урока (для продления токена: роль берём из БД, не из токена)
+func GetParticipant(ctx context.Context, db *sql.DB, tenantID, lessonID int64, name string) (*Participant, error) {
+	ctx, span := startOp(ctx, "GetParticipant",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("lesson_id", lessonID),
+	)
+	defer span.End()
+
+	var p Participant
+	err := db.QueryRowContext(ctx, `
+		SELECT lp.participant_name, lp.role, lp.joined_at, lp.left_at
+		FROM lesson_participants lp
+		JOIN lessons l ON l.id = lp.lesson_id
+		WHERE lp.lesson_id = $1
+		  AND l.tenant_id = $2
+		  AND lp.participant_name = $3
+	`, lessonID, tenantID, name).Scan(&p.Name, &p.Role, &p.JoinedAt, &p.LeftAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrParticipantNotFound
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return &p, nil
+}
+
 // ✅ нужно для S2: понять, остался ли активный teacher
-func HasActiveTeacher(dbConn *sql.DB, lessonID int64) (bool, error) {
+func HasActiveTeacher(ctx context.Context, dbConn *sql.DB, tenantID, lessonID int64) (bool, error) {
+	ctx, span := startOp(ctx, "HasActiveTeacher",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("lesson_id", lessonID),
+	)
+	defer span.End()
+
 	var cnt int
-	err := dbConn.QueryRow(`
+	err := dbConn.QueryRowContext(ctx, `
 		SELECT count(*)
-		FROM lesson_participants
-		WHERE lesson_id = $1
-		  AND role = 'teacher'
-		  AND left_at IS NULL
-	`, lessonID).Scan(&cnt)
+		FROM lesson_participants lp
+		JOIN lessons l ON l.id = lp.lesson_id
+		WHERE lp.lesson_id = $1
+		  AND l.tenant_id = $2
+		  AND lp.role = 'teacher'
+		  AND lp.left_at IS NULL
+	`, lessonID, tenantID).Scan(&cnt)
+
+	return cnt > 0, tracing.Fail(span, err)
+}
+
+// ✅ для метрик: сколько людей сейчас в активных уроках
+func CountActiveParticipants(ctx context.Context, dbConn *sql.DB) (int, error) {
+	ctx, span := startOp(ctx, "CountActiveParticipants")
+	defer span.End()
+
+	var cnt int
+	err := dbConn.QueryRowContext(ctx, `
+		SELECT count(*)
+		FROM lesson_participants lp
+		JOIN lessons l ON l.id = lp.lesson_id
+		WHERE l.ended_at IS NULL
+		  AND lp.left_at IS NULL
+	`).Scan(&cnt)
+
+	return cnt, tracing.Fail(span, err)
+}
+
+// ✅ загрузка LiveKit узлов: активные участники по lessons.livekit_node
+func ActiveParticipantsByNode(ctx context.Context, dbConn *sql.DB) (map[string]int, error) {
+	ctx, span := startOp(ctx, "ActiveParticipantsByNode")
+	defer span.End()
+
+	rows, err := dbConn.QueryContext(ctx, `
+		SELECT l.livekit_node, count(*)
+		FROM lesson_participants lp
+		JOIN lessons l ON l.id = lp.lesson_id
+		WHERE l.ended_at IS NULL
+		  AND lp.left_at IS NULL
+		GROUP BY l.livekit_node
+	`)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	load := map[string]int{}
+	for rows.Next() {
+		var node string
+		var cnt int
+		if err := rows.Scan(&node, &cnt); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		load[node] = cnt
+	}
 
-	return cnt > 0, err
+	return load, tracing.Fail(span, rows.Err())
 }
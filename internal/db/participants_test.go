@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestJoinParticipantTwice(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	lesson, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", Capacity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// учитель уже записан StartLesson'ом, ученик переподключается и выходит
+	for _, step := range []struct{ name, role string }{
+		{"teacher", "teacher"},
+		{"ann", "student"},
+		{"ann", "student"},
+	} {
+		if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", step.name, step.role, Capacity{}); err != nil {
+			t.Fatalf("join %s: %v", step.name, err)
+		}
+	}
+	if err := LeaveParticipant(ctx, conn, 1, lesson.ID, "ann"); err != nil {
+		t.Fatal(err)
+	}
+	if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", "ann", "student", Capacity{}); err != nil {
+		t.Fatalf("rejoin after leave: %v", err)
+	}
+
+	var rows, open int
+	if err := conn.QueryRow(`
+		SELECT count(*), count(*) FILTER (WHERE left_at IS NULL)
+		FROM lesson_participants WHERE lesson_id = $1
+	`, lesson.ID).Scan(&rows, &open); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 || open != 2 {
+		t.Fatalf("lesson_participants: %d rows (%d open), want 2 (2 open)", rows, open)
+	}
+
+	// сегменты присутствия: каждый вход — свой, открыт только последний
+	var sessions, openSessions int
+	if err := conn.QueryRow(`
+		SELECT count(*), count(*) FILTER (WHERE left_at IS NULL)
+		FROM participant_sessions WHERE lesson_id = $1 AND participant_name = 'ann'
+	`, lesson.ID).Scan(&sessions, &openSessions); err != nil {
+		t.Fatal(err)
+	}
+	if sessions != 3 || openSessions != 1 {
+		t.Fatalf("ann sessions: %d (%d open), want 3 (1 open)", sessions, openSessions)
+	}
+
+	var joins int
+	if err := conn.QueryRow(`
+		SELECT count(*) FROM lesson_events WHERE lesson_id = $1 AND event_type = 'join'
+	`, lesson.ID).Scan(&joins); err != nil {
+		t.Fatal(err)
+	}
+	if joins != 4 {
+		t.Fatalf("join events = %d, want 4", joins)
+	}
+}
+
+func TestJoinParticipantOtherTenant(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	lesson, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", Capacity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = JoinParticipant(ctx, conn, 2, lesson.ID, "math", "ann", "student", Capacity{})
+	if !errors.Is(err, ErrLessonNotFound) {
+		t.Fatalf("join a lesson of another tenant = %v, want %v", err, ErrLessonNotFound)
+	}
+}
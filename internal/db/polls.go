@@ -0,0 +1,274 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// ErrPollNotFound — нет такого опроса (или он чужой школы)
+var ErrPollNotFound = errors.New("poll not found")
+
+// ErrPollClosed — опрос закрыт, время вышло или урок закончился
+var ErrPollClosed = errors.New("poll closed")
+
+// Poll — опрос урока. Варианты ответа — индексы в Options.
+type Poll struct {
+	ID        int64      `json:"id"`
+	LessonID  int64      `json:"lesson_id"`
+	Room      string     `json:"room"`
+	Question  string     `json:"question"`
+	Kind      string     `json:"kind"` // single | multiple
+	Options   []string   `json:"options"`
+	Correct   []int64    `json:"correct"` // пусто => не оценивается
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ClosesAt  *time.Time `json:"closes_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+
+	// для рассылки: узел и учитель урока
+	LiveKitNode string `json:"-"`
+	Teacher     string `json:"-"`
+}
+
+// Graded — есть правильный ответ
+func (p *Poll) Graded() bool {
+	return len(p.Correct) > 0
+}
+
+// IsCorrect — совпадает ли набор ответов с правильным (порядок не важен)
+func (p *Poll) IsCorrect(choices []int64) bool {
+	if len(choices) != len(p.Correct) {
+		return false
+	}
+	want := make(map[int64]bool, len(p.Correct))
+	for _, c := range p.Correct {
+		want[c] = true
+	}
+	for _, c := range choices {
+		if !want[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// PollAnswer — ответ ученика
+type PollAnswer struct {
+	Name       string    `json:"name"`
+	Choices    []int64   `json:"choices"`
+	IsCorrect  *bool     `json:"is_correct"`
+	AnsweredAt time.Time `json:"answered_at"`
+}
+
+// PollResults — сводка по опросу
+type PollResults struct {
+	Counts   []int        `json:"counts"` // по вариантам
+	Answered int          `json:"answered"`
+	Correct  int          `json:"correct"`
+	Answers  []PollAnswer `json:"answers,omitempty"`
+}
+
+// Results — подсчёт по вариантам из ответов
+func (p *Poll) Results(answers []PollAnswer) PollResults {
+	r := PollResults{Counts: make([]int, len(p.Options)), Answered: len(answers), Answers: answers}
+	for _, a := range answers {
+		for _, c := range a.Choices {
+			if c >= 0 && int(c) < len(r.Counts) {
+				r.Counts[c]++
+			}
+		}
+		if a.IsCorrect != nil && *a.IsCorrect {
+			r.Correct++
+		}
+	}
+	return r
+}
+
+// =======================
+// Polls
+// =======================
+
+// CreatePoll — новый опрос; заполняет ID и CreatedAt
+func CreatePoll(ctx context.Context, db *sql.DB, p *Poll) error {
+	ctx, span := startOp(ctx, "CreatePoll", attribute.Int64("lesson_id", p.LessonID))
+	defer span.End()
+
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO polls (lesson_id, question, kind, options, correct, created_by, closes_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, p.LessonID, p.Question, p.Kind, pq.Array(p.Options), pq.Array(p.Correct), p.CreatedBy, p.ClosesAt,
+	).Scan(&p.ID, &p.CreatedAt)
+
+	return tracing.Fail(span, err)
+}
+
+// GetPoll — опрос школы
+func GetPoll(ctx context.Context, db *sql.DB, tenantID, pollID int64) (*Poll, error) {
+	ctx, span := startOp(ctx, "GetPoll",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("poll_id", pollID),
+	)
+	defer span.End()
+
+	p, err := scanPoll(db.QueryRowContext(ctx, pollSelect+`
+		WHERE l.tenant_id = $1
+		  AND p.id = $2
+	`, tenantID, pollID))
+	if err == sql.ErrNoRows {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return p, nil
+}
+
+// ListPolls — опросы урока по порядку
+func ListPolls(ctx context.Context, db *sql.DB, tenantID, lessonID int64) ([]Poll, error) {
+	ctx, span := startOp(ctx, "ListPolls",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("lesson_id", lessonID),
+	)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, pollSelect+`
+		WHERE l.tenant_id = $1
+		  AND p.lesson_id = $2
+		ORDER BY p.created_at
+	`, tenantID, lessonID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []Poll{}
+	for rows.Next() {
+		p, err := scanPoll(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, *p)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// ClosePoll — закрыть опрос (по таймеру — временем таймера).
+// false => уже был закрыт.
+func ClosePoll(ctx context.Context, db *sql.DB, pollID int64) (bool, error) {
+	ctx, span := startOp(ctx, "ClosePoll", attribute.Int64("poll_id", pollID))
+	defer span.End()
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE polls
+		SET closed_at = LEAST(now(), COALESCE(closes_at, now()))
+		WHERE id = $1
+		  AND closed_at IS NULL
+	`, pollID)
+	if err != nil {
+		return false, tracing.Fail(span, err)
+	}
+
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// =======================
+// Answers
+// =======================
+
+// AnswerPoll — ответ (или новый ответ) ученика, пока опрос открыт и урок идёт.
+// isCorrect == nil — опрос не оценивается.
+func AnswerPoll(ctx context.Context, db *sql.DB, pollID int64, name string, choices []int64, isCorrect *bool) error {
+	ctx, span := startOp(ctx, "AnswerPoll", attribute.Int64("poll_id", pollID))
+	defer span.End()
+
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO poll_answers (poll_id, participant_name, choices, is_correct, answered_at)
+		SELECT p.id, $2, $3, $4, now()
+		FROM polls p
+		JOIN lessons l ON l.id = p.lesson_id
+		WHERE p.id = $1
+		  AND p.closed_at IS NULL
+		  AND (p.closes_at IS NULL OR p.closes_at > now())
+		  AND l.ended_at IS NULL
+		ON CONFLICT (poll_id, participant_name)
+		DO UPDATE SET
+			choices = EXCLUDED.choices,
+			is_correct = EXCLUDED.is_correct,
+			answered_at = EXCLUDED.answered_at
+	`, pollID, name, pq.Array(choices), isCorrect)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPollClosed
+	}
+	return nil
+}
+
+// PollAnswers — ответы на опрос по имени ученика
+func PollAnswers(ctx context.Context, db *sql.DB, pollID int64) ([]PollAnswer, error) {
+	ctx, span := startOp(ctx, "PollAnswers", attribute.Int64("poll_id", pollID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT participant_name, choices, is_correct, answered_at
+		FROM poll_answers
+		WHERE poll_id = $1
+		ORDER BY participant_name
+	`, pollID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []PollAnswer{}
+	for rows.Next() {
+		var a PollAnswer
+		var correct sql.NullBool
+		if err := rows.Scan(&a.Name, pq.Array(&a.Choices), &correct, &a.AnsweredAt); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		if correct.Valid {
+			a.IsCorrect = &correct.Bool
+		}
+		out = append(out, a)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
+
+const pollSelect = `
+	SELECT p.id, p.lesson_id, l.room_name, p.question, p.kind, p.options, p.correct,
+	       p.created_by, p.created_at, p.closes_at, p.closed_at, l.livekit_node, l.teacher_name
+	FROM polls p
+	JOIN lessons l ON l.id = p.lesson_id
+`
+
+func scanPoll(row interface{ Scan(...any) error }) (*Poll, error) {
+	var p Poll
+	var closesAt, closedAt sql.NullTime
+	if err := row.Scan(
+		&p.ID, &p.LessonID, &p.Room, &p.Question, &p.Kind, pq.Array(&p.Options), pq.Array(&p.Correct),
+		&p.CreatedBy, &p.CreatedAt, &closesAt, &closedAt, &p.LiveKitNode, &p.Teacher,
+	); err != nil {
+		return nil, err
+	}
+	if closesAt.Valid {
+		p.ClosesAt = &closesAt.Time
+	}
+	if closedAt.Valid {
+		p.ClosedAt = &closedAt.Time
+	}
+	return &p, nil
+}
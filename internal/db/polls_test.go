@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPollIsCorrectAndResults(t *testing.T) {
+	p := &Poll{Options: []string{"2", "3", "4", "5"}, Correct: []int64{2, 0}}
+	for _, tc := range []struct {
+		choices []int64
+		want    bool
+	}{
+		{[]int64{0, 2}, true},
+		{[]int64{2}, false},
+		{[]int64{0, 1, 2}, false},
+		{[]int64{1, 3}, false},
+	} {
+		if got := p.IsCorrect(tc.choices); got != tc.want {
+			t.Errorf("IsCorrect(%v) = %v, want %v", tc.choices, got, tc.want)
+		}
+	}
+
+	yes, no := true, false
+	res := p.Results([]PollAnswer{
+		{Name: "ann", Choices: []int64{0, 2}, IsCorrect: &yes},
+		{Name: "bob", Choices: []int64{2, 7}, IsCorrect: &no}, // чужой индекс не считаем
+	})
+	if res.Answered != 2 || res.Correct != 1 || res.Counts[0] != 1 || res.Counts[2] != 2 || res.Counts[1] != 0 {
+		t.Fatalf("results = %+v", res)
+	}
+}
+
+func TestPollAnswersLifecycle(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	lesson, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", Capacity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Poll{
+		LessonID:  lesson.ID,
+		Question:  "2 + 2?",
+		Kind:      "single",
+		Options:   []string{"3", "4"},
+		Correct:   []int64{1},
+		CreatedBy: "teacher",
+	}
+	if err := CreatePoll(ctx, conn, p); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetPoll(ctx, conn, 1, p.ID)
+	if err != nil || got.Room != "math" || got.Teacher != "teacher" || got.LiveKitNode != "node-1" ||
+		len(got.Options) != 2 || !got.Graded() || got.ClosedAt != nil {
+		t.Fatalf("get poll = %+v, %v", got, err)
+	}
+	if _, err := GetPoll(ctx, conn, 2, p.ID); !errors.Is(err, ErrPollNotFound) {
+		t.Fatalf("poll of another tenant = %v, want %v", err, ErrPollNotFound)
+	}
+
+	// ученик может передумать, пока опрос открыт
+	wrong, right := false, true
+	if err := AnswerPoll(ctx, conn, p.ID, "ann", []int64{0}, &wrong); err != nil {
+		t.Fatal(err)
+	}
+	if err := AnswerPoll(ctx, conn, p.ID, "ann", []int64{1}, &right); err != nil {
+		t.Fatal(err)
+	}
+	if err := AnswerPoll(ctx, conn, p.ID, "bob", []int64{0}, &wrong); err != nil {
+		t.Fatal(err)
+	}
+
+	answers, err := PollAnswers(ctx, conn, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answers) != 2 || answers[0].Name != "ann" || answers[0].Choices[0] != 1 || !*answers[0].IsCorrect {
+		t.Fatalf("answers = %+v, want ann's second answer kept", answers)
+	}
+
+	closed, err := ClosePoll(ctx, conn, p.ID)
+	if err != nil || !closed {
+		t.Fatalf("close: %v %v", closed, err)
+	}
+	if closed, err := ClosePoll(ctx, conn, p.ID); err != nil || closed {
+		t.Fatalf("close twice: %v %v, want false", closed, err)
+	}
+	if err := AnswerPoll(ctx, conn, p.ID, "carl", []int64{1}, &right); !errors.Is(err, ErrPollClosed) {
+		t.Fatalf("answer after close = %v, want %v", err, ErrPollClosed)
+	}
+}
+
+func TestPollTimerAndLessonEnd(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	lesson, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", Capacity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// таймер истёк: ответ не принимается, закрытие — временем таймера
+	expired := time.Now().Add(-time.Minute)
+	timed := &Poll{LessonID: lesson.ID, Question: "?", Kind: "multiple", Options: []string{"a", "b"}, Correct: []int64{}, ClosesAt: &expired}
+	if err := CreatePoll(ctx, conn, timed); err != nil {
+		t.Fatal(err)
+	}
+	if err := AnswerPoll(ctx, conn, timed.ID, "ann", []int64{0, 1}, nil); !errors.Is(err, ErrPollClosed) {
+		t.Fatalf("answer after timer = %v, want %v", err, ErrPollClosed)
+	}
+	if _, err := ClosePoll(ctx, conn, timed.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetPoll(ctx, conn, 1, timed.ID)
+	if err != nil || got.Graded() || got.ClosedAt == nil || got.ClosedAt.Sub(expired).Abs() > time.Millisecond {
+		t.Fatalf("timed poll = %+v, %v; want closed at %v", got, err, expired)
+	}
+
+	// урок закончился — открытый опрос больше не принимает ответы
+	open := &Poll{LessonID: lesson.ID, Question: "?", Kind: "single", Options: []string{"a", "b"}, Correct: []int64{}}
+	if err := CreatePoll(ctx, conn, open); err != nil {
+		t.Fatal(err)
+	}
+	if err := EndLesson(ctx, conn, 1, lesson.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := AnswerPoll(ctx, conn, open.ID, "ann", []int64{0}, nil); !errors.Is(err, ErrPollClosed) {
+		t.Fatalf("answer after lesson end = %v, want %v", err, ErrPollClosed)
+	}
+
+	list, err := ListPolls(ctx, conn, 1, lesson.ID)
+	if err != nil || len(list) != 2 || list[0].ID != timed.ID || list[1].ID != open.ID {
+		t.Fatalf("polls = %+v, %v; want timed, open", list, err)
+	}
+}
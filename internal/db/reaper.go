@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// OpenLesson — открытый урок для сверки с LiveKit
+type OpenLesson struct {
+	Lesson
+	TenantSlug string
+	// последняя активность: старт, входы/выходы участников
+	LastActivity time.Time
+	// в той же комнате открыт более новый урок — этот точно брошен
+	Superseded bool
+}
+
+// LiveKitRoom — имя комнаты урока в LiveKit
+func (l *OpenLesson) LiveKitRoom() string {
+	t := Tenant{ID: l.TenantID, Slug: l.TenantSlug}
+	return t.LiveKitRoom(l.Room)
+}
+
+// OpenParticipant — участник без left_at
+type OpenParticipant struct {
+	Name     string
+	JoinedAt time.Time
+}
+
+// OpenLessons — все уроки без ended_at (по всем школам)
+func OpenLessons(ctx context.Context, db *sql.DB) ([]OpenLesson, error) {
+	ctx, span := startOp(ctx, "OpenLessons")
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT l.id, l.tenant_id, t.slug, l.room_name, l.teacher_name, l.livekit_node, l.started_at,
+		       GREATEST(
+		           l.started_at,
+		           (SELECT max(GREATEST(s.joined_at, COALESCE(s.left_at, s.joined_at)))
+		            FROM participant_sessions s WHERE s.lesson_id = l.id)
+		       ),
+		       EXISTS (
+		           SELECT 1 FROM lessons n
+		           WHERE n.tenant_id = l.tenant_id
+		             AND n.room_name = l.room_name
+		             AND n.ended_at IS NULL
+		             AND n.started_at > l.started_at
+		       )
+		FROM lessons l
+		JOIN tenants t ON t.id = l.tenant_id
+		WHERE l.ended_at IS NULL
+		ORDER BY l.started_at
+	`)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	var out []OpenLesson
+	for rows.Next() {
+		var l OpenLesson
+		if err := rows.Scan(
+			&l.ID, &l.TenantID, &l.TenantSlug, &l.Room, &l.Teacher, &l.LiveKitNode, &l.StartedAt,
+			&l.LastActivity, &l.Superseded,
+		); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, l)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// OpenParticipants — кто по данным БД ещё в уроке
+func OpenParticipants(ctx context.Context, db *sql.DB, lessonID int64) ([]OpenParticipant, error) {
+	ctx, span := startOp(ctx, "OpenParticipants", attribute.Int64("lesson_id", lessonID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT participant_name, joined_at
+		FROM lesson_participants
+		WHERE lesson_id = $1
+		  AND left_at IS NULL
+	`, lessonID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	var out []OpenParticipant
+	for rows.Next() {
+		var p OpenParticipant
+		if err := rows.Scan(&p.Name, &p.JoinedAt); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, p)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// EndLessonAt — закрыть урок задним числом (время последней активности,
+// а не now()); вместе с ним — участников и сегменты. false => уже закрыт.
+func EndLessonAt(ctx context.Context, db *sql.DB, lessonID int64, at time.Time, actor string) (bool, error) {
+	ctx, span := startOp(ctx, "EndLessonAt", attribute.Int64("lesson_id", lessonID))
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, tracing.Fail(span, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE lessons
+		SET ended_at = GREATEST($2, started_at),
+		    duration_sec = EXTRACT(EPOCH FROM (GREATEST($2, started_at) - started_at))::int
+		WHERE id = $1
+		  AND ended_at IS NULL
+	`, lessonID, at)
+	if err != nil {
+		return false, tracing.Fail(span, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	if err := closeParticipantsAt(ctx, tx, lessonID, "", at); err != nil {
+		return false, tracing.Fail(span, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, tracing.Fail(span, err)
+	}
+
+	logEvent(ctx, db, lessonID, "lesson_ended", actor)
+	return true, nil
+}
+
+// CloseParticipantAt — участник ушёл, не сообщив (нет в комнате LiveKit)
+func CloseParticipantAt(ctx context.Context, db *sql.DB, lessonID int64, name string, at time.Time) error {
+	ctx, span := startOp(ctx, "CloseParticipantAt", attribute.Int64("lesson_id", lessonID))
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	defer tx.Rollback()
+
+	if err := closeParticipantsAt(ctx, tx, lessonID, name, at); err != nil {
+		return tracing.Fail(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	logEvent(ctx, db, lessonID, "leave", name)
+	return nil
+}
+
+// closeParticipantsAt — left_at = at (но не раньше входа); name == "" => всех
+func closeParticipantsAt(ctx context.Context, db execer, lessonID int64, name string, at time.Time) error {
+	if _, err := db.ExecContext(ctx, `
+		UPDATE lesson_participants
+		SET left_at = GREATEST($3, joined_at)
+		WHERE lesson_id = $1
+		  AND ($2 = '' OR participant_name = $2)
+		  AND left_at IS NULL
+	`, lessonID, name, at); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE participant_sessions
+		SET left_at = GREATEST($3, joined_at)
+		WHERE lesson_id = $1
+		  AND ($2 = '' OR participant_name = $2)
+		  AND left_at IS NULL
+	`, lessonID, name, at)
+	return err
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOpenLessonsSupersededAndLastActivity(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	at := func(hour, min int) time.Time { return time.Date(2026, 3, 2, hour, min, 0, 0, time.UTC) }
+
+	// урок без ended_at: ann была до 09:50; в 10:00 в той же комнате начат новый
+	old := insertLesson(t, conn, 1, "math", "alice", at(9, 0), time.Time{}, time.Time{})
+	insertPresence(t, conn, old, "ann", at(9, 5), at(9, 50))
+	newer := insertLesson(t, conn, 1, "math", "bob", at(10, 0), time.Time{}, time.Time{})
+	other := insertLesson(t, conn, 1, "physics", "alice", at(10, 30), time.Time{}, time.Time{})
+	// закрытый урок в списке не нужен и новее не делает
+	insertLesson(t, conn, 1, "physics", "alice", at(11, 0), at(11, 30), time.Time{})
+
+	lessons, err := OpenLessons(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lessons) != 3 {
+		t.Fatalf("open lessons = %+v, want 3", lessons)
+	}
+	byID := map[int64]OpenLesson{}
+	for _, l := range lessons {
+		byID[l.ID] = l
+	}
+	if l := byID[old]; !l.Superseded || !l.LastActivity.Equal(at(9, 50)) || l.LiveKitRoom() != "math" {
+		t.Errorf("old lesson = %+v, want superseded, last activity 09:50", l)
+	}
+	if l := byID[newer]; l.Superseded || !l.LastActivity.Equal(at(10, 0)) {
+		t.Errorf("newer lesson = %+v, want not superseded, last activity = start", l)
+	}
+	if l := byID[other]; l.Superseded {
+		t.Errorf("lesson in another room = %+v, want not superseded", l)
+	}
+}
+
+func TestEndLessonAtBackdates(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	lesson, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", Capacity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", "ann", "student", Capacity{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// активность до начала урока (часы разъехались) — не раньше входа / старта
+	ok, err := EndLessonAt(ctx, conn, lesson.ID, lesson.StartedAt.Add(-time.Hour), "reaper")
+	if err != nil || !ok {
+		t.Fatalf("end: ok=%v err=%v", ok, err)
+	}
+	if ok, err := EndLessonAt(ctx, conn, lesson.ID, time.Now(), "reaper"); err != nil || ok {
+		t.Fatalf("end twice: ok=%v err=%v, want false", ok, err)
+	}
+
+	var duration, openParticipants, openSessions int
+	if err := conn.QueryRow(`
+		SELECT (SELECT duration_sec FROM lessons WHERE id = $1),
+		       (SELECT count(*) FROM lesson_participants WHERE lesson_id = $1 AND left_at IS NULL),
+		       (SELECT count(*) FROM participant_sessions
+		        WHERE lesson_id = $1 AND (left_at IS NULL OR left_at < joined_at))
+	`, lesson.ID).Scan(&duration, &openParticipants, &openSessions); err != nil {
+		t.Fatal(err)
+	}
+	if duration != 0 || openParticipants != 0 || openSessions != 0 {
+		t.Fatalf("duration=%d open participants=%d bad sessions=%d, want 0 0 0", duration, openParticipants, openSessions)
+	}
+}
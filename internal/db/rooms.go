@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+var (
+	ErrRoomNotFound = errors.New("room not found")
+	ErrRoomExists   = errors.New("room already exists")
+)
+
+// Политика записи урока в комнате
+const (
+	RecordingOff     = "off"
+	RecordingAllowed = "allowed" // учитель может включить
+	RecordingAlways  = "always"
+)
+
+// Room — комната школы (slug = room_name в уроках, классах и расписании)
+type Room struct {
+	ID              int64     `json:"id"`
+	TenantID        int64     `json:"tenant_id"`
+	Slug            string    `json:"slug"`
+	Title           string    `json:"title"`
+	OwnerTeacher    string    `json:"owner_teacher"`
+	MaxParticipants int       `json:"max_participants"` // 0 => без ограничения
+	MaxStudents     int       `json:"max_students"`
+	MaxTeachers     int       `json:"max_teachers"`
+	OverflowQueue   bool      `json:"overflow_queue"` // мест нет => очередь вместо ROOM_FULL
+	WaitingRoom     bool      `json:"waiting_room"`
+	Recording       string    `json:"recording"` // off | allowed | always
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+const roomColumns = `id, tenant_id, slug, title, owner_teacher, max_participants, max_students, max_teachers,
+	overflow_queue, waiting_room, recording, created_at, updated_at`
+
+func scanRoom(row interface{ Scan(...any) error }) (*Room, error) {
+	var r Room
+	err := row.Scan(&r.ID, &r.TenantID, &r.Slug, &r.Title, &r.OwnerTeacher, &r.MaxParticipants,
+		&r.MaxStudents, &r.MaxTeachers, &r.OverflowQueue, &r.WaitingRoom, &r.Recording, &r.CreatedAt, &r.UpdatedAt)
+	return &r, err
+}
+
+// Capacity — места комнаты для проверки при входе
+func (r *Room) Capacity() Capacity {
+	return Capacity{
+		Total:    r.MaxParticipants,
+		Students: r.MaxStudents,
+		Teachers: r.MaxTeachers,
+		Queue:    r.OverflowQueue,
+	}
+}
+
+// CreateRoom — новая комната (ErrRoomExists, если slug занят в этой школе)
+func CreateRoom(ctx context.Context, db *sql.DB, r *Room) error {
+	ctx, span := startOp(ctx, "CreateRoom", attribute.Int64("tenant_id", r.TenantID))
+	defer span.End()
+
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO rooms (tenant_id, slug, title, owner_teacher, max_participants, max_students, max_teachers,
+			overflow_queue, waiting_room, recording)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (tenant_id, slug) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`, r.TenantID, r.Slug, r.Title, r.OwnerTeacher, r.MaxParticipants, r.MaxStudents, r.MaxTeachers,
+		r.OverflowQueue, r.WaitingRoom, r.Recording,
+	).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return ErrRoomExists
+	}
+	return tracing.Fail(span, err)
+}
+
+// GetRoom — комната школы по slug (ErrRoomNotFound)
+func GetRoom(ctx context.Context, db *sql.DB, tenantID int64, slug string) (*Room, error) {
+	ctx, span := startOp(ctx, "GetRoom", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	r, err := scanRoom(db.QueryRowContext(ctx, `
+		SELECT `+roomColumns+`
+		FROM rooms
+		WHERE tenant_id = $1
+		  AND slug = $2
+	`, tenantID, slug))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return r, nil
+}
+
+func ListRooms(ctx context.Context, db *sql.DB, tenantID int64) ([]Room, error) {
+	ctx, span := startOp(ctx, "ListRooms", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+roomColumns+`
+		FROM rooms
+		WHERE tenant_id = $1
+		ORDER BY slug
+	`, tenantID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []Room{}
+	for rows.Next() {
+		r, err := scanRoom(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, *r)
+	}
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// UpdateRoom — сохранить настройки комнаты (slug не меняется: на него ссылаются уроки)
+func UpdateRoom(ctx context.Context, db *sql.DB, r *Room) error {
+	ctx, span := startOp(ctx, "UpdateRoom",
+		attribute.Int64("tenant_id", r.TenantID),
+		attribute.Int64("room_id", r.ID),
+	)
+	defer span.End()
+
+	err := db.QueryRowContext(ctx, `
+		UPDATE rooms
+		SET title = $3,
+		    owner_teacher = $4,
+		    max_participants = $5,
+		    max_students = $6,
+		    max_teachers = $7,
+		    overflow_queue = $8,
+		    waiting_room = $9,
+		    recording = $10,
+		    updated_at = now()
+		WHERE id = $1
+		  AND tenant_id = $2
+		RETURNING updated_at
+	`, r.ID, r.TenantID, r.Title, r.OwnerTeacher, r.MaxParticipants, r.MaxStudents, r.MaxTeachers,
+		r.OverflowQueue, r.WaitingRoom, r.Recording,
+	).Scan(&r.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return ErrRoomNotFound
+	}
+	return tracing.Fail(span, err)
+}
+
+// DeleteRoom — удалить комнату; история уроков хранит room_name и не теряется
+func DeleteRoom(ctx context.Context, db *sql.DB, tenantID int64, slug string) error {
+	ctx, span := startOp(ctx, "DeleteRoom", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM rooms
+		WHERE tenant_id = $1
+		  AND slug = $2
+	`, tenantID, slug)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRoomNotFound
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRoomsCRUD(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	other, err := CreateTenant(ctx, conn, "other", "Other school", "other-api-key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	math := &Room{TenantID: 1, Slug: "math", Title: "Math 7A", OwnerTeacher: "alice", MaxStudents: 25, Recording: RecordingOff}
+	if err := CreateRoom(ctx, conn, math); err != nil || math.ID == 0 {
+		t.Fatalf("create: id=%d err=%v", math.ID, err)
+	}
+	if err := CreateRoom(ctx, conn, &Room{TenantID: 1, Slug: "math", Recording: RecordingOff}); !errors.Is(err, ErrRoomExists) {
+		t.Fatalf("duplicate slug = %v, want %v", err, ErrRoomExists)
+	}
+	// тот же slug в другой школе — другая комната
+	if err := CreateRoom(ctx, conn, &Room{TenantID: other.ID, Slug: "math", Recording: RecordingOff}); err != nil {
+		t.Fatalf("same slug in another tenant: %v", err)
+	}
+	if err := CreateRoom(ctx, conn, &Room{TenantID: 1, Slug: "art", Recording: "sometimes"}); err == nil {
+		t.Fatal("unknown recording policy accepted")
+	}
+
+	got, err := GetRoom(ctx, conn, 1, "math")
+	if err != nil || got.ID != math.ID || got.Title != "Math 7A" || got.Capacity() != (Capacity{Students: 25}) {
+		t.Fatalf("get = %+v, %v", got, err)
+	}
+	if _, err := GetRoom(ctx, conn, 1, "physics"); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("missing room = %v, want %v", err, ErrRoomNotFound)
+	}
+
+	got.Title = "Math 7B"
+	got.MaxParticipants = 30
+	got.OverflowQueue = true
+	got.Recording = RecordingAlways
+	if err := UpdateRoom(ctx, conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.UpdatedAt.Before(math.UpdatedAt) {
+		t.Fatalf("updated_at went back: %v < %v", got.UpdatedAt, math.UpdatedAt)
+	}
+	again, err := GetRoom(ctx, conn, 1, "math")
+	if err != nil || again.Title != "Math 7B" || again.Recording != RecordingAlways ||
+		again.Capacity() != (Capacity{Total: 30, Students: 25, Queue: true}) {
+		t.Fatalf("after update = %+v, %v", again, err)
+	}
+
+	// чужая школа не может ни изменить, ни удалить комнату по id / slug
+	foreign := *again
+	foreign.TenantID = other.ID
+	if err := UpdateRoom(ctx, conn, &foreign); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("update from another tenant = %v, want %v", err, ErrRoomNotFound)
+	}
+
+	list, err := ListRooms(ctx, conn, other.ID)
+	if err != nil || len(list) != 1 || list[0].Title != "" {
+		t.Fatalf("rooms of another tenant = %+v, %v", list, err)
+	}
+
+	if err := DeleteRoom(ctx, conn, 1, "math"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteRoom(ctx, conn, 1, "math"); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("delete twice = %v, want %v", err, ErrRoomNotFound)
+	}
+	if _, err := GetRoom(ctx, conn, other.ID, "math"); err != nil {
+		t.Fatalf("room of another tenant deleted: %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// ErrClassNotFound — класса нет (или он другой школы)
+var ErrClassNotFound = errors.New("class not found")
+
+// ErrNoClass — комната не привязана к классу: список не проверяется (не ошибка БД)
+var ErrNoClass = errors.New("room is not linked to a class")
+
+type Class struct {
+	ID             int64    `json:"id"`
+	TenantID       int64    `json:"tenant_id"`
+	Name           string   `json:"name"`
+	GuestAllowance int      `json:"guest_allowance"`
+	Students       int      `json:"students"`
+	Rooms          []string `json:"rooms"`
+}
+
+type Enrollment struct {
+	StudentName string `json:"student_name"`
+	UserID      string `json:"user_id,omitempty"`
+}
+
+// =======================
+// Classes
+// =======================
+
+func CreateClass(ctx context.Context, db *sql.DB, tenantID int64, name string, guestAllowance int) (*Class, error) {
+	ctx, span := startOp(ctx, "CreateClass", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	c := Class{TenantID: tenantID, Name: name, GuestAllowance: guestAllowance, Rooms: []string{}}
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO classes (tenant_id, name, guest_allowance)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, tenantID, name, guestAllowance).Scan(&c.ID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return &c, nil
+}
+
+// GetClass — класс школы (ErrClassNotFound, если чужой)
+func GetClass(ctx context.Context, db *sql.DB, tenantID, classID int64) (*Class, error) {
+	ctx, span := startOp(ctx, "GetClass",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("class_id", classID),
+	)
+	defer span.End()
+
+	c := Class{Rooms: []string{}}
+	err := db.QueryRowContext(ctx, `
+		SELECT c.id, c.tenant_id, c.name, c.guest_allowance,
+		       (SELECT count(*) FROM enrollments e WHERE e.class_id = c.id)
+		FROM classes c
+		WHERE c.id = $1
+		  AND c.tenant_id = $2
+	`, classID, tenantID).Scan(&c.ID, &c.TenantID, &c.Name, &c.GuestAllowance, &c.Students)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrClassNotFound
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return &c, nil
+}
+
+// ListClasses — классы школы с числом учеников и комнатами
+func ListClasses(ctx context.Context, db *sql.DB, tenantID int64) ([]Class, error) {
+	ctx, span := startOp(ctx, "ListClasses", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.id, c.tenant_id, c.name, c.guest_allowance,
+		       (SELECT count(*) FROM enrollments e WHERE e.class_id = c.id),
+		       COALESCE(array_agg(r.room_name ORDER BY r.room_name) FILTER (WHERE r.room_name IS NOT NULL), '{}')
+		FROM classes c
+		LEFT JOIN class_rooms r ON r.class_id = c.id
+		WHERE c.tenant_id = $1
+		GROUP BY c.id
+		ORDER BY c.name
+	`, tenantID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []Class{}
+	for rows.Next() {
+		var c Class
+		if err := rows.Scan(&c.ID, &c.TenantID, &c.Name, &c.GuestAllowance, &c.Students, pq.Array(&c.Rooms)); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, c)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// SetGuestAllowance — сколько гостей (не из списка) пускать на один урок
+func SetGuestAllowance(ctx context.Context, db *sql.DB, tenantID, classID int64, n int) error {
+	ctx, span := startOp(ctx, "SetGuestAllowance", attribute.Int64("class_id", classID))
+	defer span.End()
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE classes SET guest_allowance = $3
+		WHERE id = $1 AND tenant_id = $2
+	`, classID, tenantID, n)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrClassNotFound
+	}
+	return nil
+}
+
+// =======================
+// Rooms <=> class
+// =======================
+
+// LinkRoom — привязать комнату к классу (перепривязывает, если была другая)
+func LinkRoom(ctx context.Context, db *sql.DB, tenantID, classID int64, room string) error {
+	ctx, span := startOp(ctx, "LinkRoom",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("class_id", classID),
+	)
+	defer span.End()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO class_rooms (tenant_id, room_name, class_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tenant_id, room_name)
+		DO UPDATE SET class_id = EXCLUDED.class_id
+	`, tenantID, room, classID)
+
+	return tracing.Fail(span, err)
+}
+
+func UnlinkRoom(ctx context.Context, db *sql.DB, tenantID, classID int64, room string) error {
+	ctx, span := startOp(ctx, "UnlinkRoom",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("class_id", classID),
+	)
+	defer span.End()
+
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM class_rooms
+		WHERE tenant_id = $1 AND room_name = $2 AND class_id = $3
+	`, tenantID, room, classID)
+
+	return tracing.Fail(span, err)
+}
+
+// RoomClass — класс комнаты; ErrNoClass => комната открытая
+func RoomClass(ctx context.Context, db *sql.DB, tenantID int64, room string) (*Class, error) {
+	ctx, span := startOp(ctx, "RoomClass", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	c := Class{Rooms: []string{room}}
+	err := db.QueryRowContext(ctx, `
+		SELECT c.id, c.tenant_id, c.name, c.guest_allowance
+		FROM class_rooms r
+		JOIN classes c ON c.id = r.class_id
+		WHERE r.tenant_id = $1
+		  AND r.room_name = $2
+	`, tenantID, room).Scan(&c.ID, &c.TenantID, &c.Name, &c.GuestAllowance)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNoClass
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return &c, nil
+}
+
+// =======================
+// Roster
+// =======================
+
+func ListEnrollments(ctx context.Context, db *sql.DB, classID int64) ([]Enrollment, error) {
+	ctx, span := startOp(ctx, "ListEnrollments", attribute.Int64("class_id", classID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT student_name, user_id
+		FROM enrollments
+		WHERE class_id = $1
+		ORDER BY student_name
+	`, classID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []Enrollment{}
+	for rows.Next() {
+		var e Enrollment
+		if err := rows.Scan(&e.StudentName, &e.UserID); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, e)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// ImportRoster — загрузить список в одной транзакции.
+// replace => сначала удалить всех, кого нет в новом списке.
+// Возвращает, сколько строк добавлено/обновлено.
+func ImportRoster(ctx context.Context, db *sql.DB, classID int64, entries []Enrollment, replace bool) (int, error) {
+	ctx, span := startOp(ctx, "ImportRoster",
+		attribute.Int64("class_id", classID),
+		attribute.Int("entries", len(entries)),
+		attribute.Bool("replace", replace),
+	)
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	defer tx.Rollback()
+
+	if replace {
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			names = append(names, e.StudentName)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM enrollments
+			WHERE class_id = $1
+			  AND NOT (student_name = ANY($2))
+		`, classID, pq.Array(names)); err != nil {
+			return 0, tracing.Fail(span, err)
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO enrollments (class_id, student_name, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (class_id, student_name)
+		DO UPDATE SET user_id = EXCLUDED.user_id
+	`)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		if _, err := stmt.ExecContext(ctx, classID, e.StudentName, e.UserID); err != nil {
+			return 0, tracing.Fail(span, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	return len(entries), nil
+}
+
+// AddRosterOverride — пропуск для ученика не из списка до until
+func AddRosterOverride(ctx context.Context, db *sql.DB, classID int64, name, actor string, until time.Time) error {
+	ctx, span := startOp(ctx, "AddRosterOverride", attribute.Int64("class_id", classID))
+	defer span.End()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO roster_overrides (class_id, student_name, created_by, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, classID, name, actor, until)
+
+	return tracing.Fail(span, err)
+}
+
+// OnRoster — ученик в списке класса или у него действующий пропуск
+func OnRoster(ctx context.Context, db *sql.DB, classID int64, name string) (bool, error) {
+	ctx, span := startOp(ctx, "OnRoster", attribute.Int64("class_id", classID))
+	defer span.End()
+
+	var ok bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM enrollments
+			WHERE class_id = $1 AND student_name = $2
+		) OR EXISTS (
+			SELECT 1 FROM roster_overrides
+			WHERE class_id = $1 AND student_name = $2 AND expires_at > now()
+		)
+	`, classID, name).Scan(&ok)
+
+	return ok, tracing.Fail(span, err)
+}
+
+// countActiveGuests — сколько учеников не из списка сейчас в уроке (кроме name);
+// вызывается под блокировкой комнаты из takeSeat
+func countActiveGuests(ctx context.Context, tx *sql.Tx, classID, lessonID int64, name string) (int, error) {
+	var cnt int
+	err := tx.QueryRowContext(ctx, `
+		SELECT count(*)
+		FROM lesson_participants lp
+		WHERE lp.lesson_id = $2
+		  AND lp.role = 'student'
+		  AND lp.left_at IS NULL
+		  AND lp.participant_name <> $3
+		  AND NOT EXISTS (
+			SELECT 1 FROM enrollments e
+			WHERE e.class_id = $1 AND e.student_name = lp.participant_name
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM roster_overrides o
+			WHERE o.class_id = $1 AND o.student_name = lp.participant_name AND o.expires_at > now()
+		  )
+	`, classID, lessonID, name).Scan(&cnt)
+	return cnt, err
+}
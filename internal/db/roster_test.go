@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testClass — класс школы 1 с комнатой math и списком ann, bob
+func testClass(t *testing.T, conn *sql.DB, guestAllowance int) *Class {
+	t.Helper()
+	ctx := context.Background()
+
+	c, err := CreateClass(ctx, conn, 1, "7A", guestAllowance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := LinkRoom(ctx, conn, 1, c.ID, "math"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportRoster(ctx, conn, c.ID, []Enrollment{{StudentName: "ann"}, {StudentName: "bob"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRosterImportAndOverrides(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+	class := testClass(t, conn, 0)
+
+	got, err := RoomClass(ctx, conn, 1, "math")
+	if err != nil || got.ID != class.ID {
+		t.Fatalf("room class = %+v, %v", got, err)
+	}
+	if _, err := RoomClass(ctx, conn, 1, "physics"); !errors.Is(err, ErrNoClass) {
+		t.Fatalf("unlinked room = %v, want %v", err, ErrNoClass)
+	}
+
+	// replace: bob выбывает, carl добавляется, ann остаётся (user_id обновляется)
+	n, err := ImportRoster(ctx, conn, class.ID, []Enrollment{
+		{StudentName: "ann", UserID: "u-1"},
+		{StudentName: "carl"},
+	}, true)
+	if err != nil || n != 2 {
+		t.Fatalf("import: n=%d err=%v", n, err)
+	}
+	list, err := ListEnrollments(ctx, conn, class.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0] != (Enrollment{StudentName: "ann", UserID: "u-1"}) || list[1].StudentName != "carl" {
+		t.Fatalf("roster = %+v, want ann(u-1), carl", list)
+	}
+
+	for _, tc := range []struct {
+		name string
+		want bool
+	}{{"ann", true}, {"bob", false}, {"dave", false}} {
+		if ok, err := OnRoster(ctx, conn, class.ID, tc.name); err != nil || ok != tc.want {
+			t.Fatalf("OnRoster(%s) = %v, %v; want %v", tc.name, ok, err, tc.want)
+		}
+	}
+
+	// пропуск действует до expires_at
+	if err := AddRosterOverride(ctx, conn, class.ID, "bob", "admin", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := OnRoster(ctx, conn, class.ID, "bob"); ok {
+		t.Fatal("expired override lets bob in")
+	}
+	if err := AddRosterOverride(ctx, conn, class.ID, "bob", "admin", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := OnRoster(ctx, conn, class.ID, "bob"); !ok {
+		t.Fatal("active override does not let bob in")
+	}
+}
+
+func TestGuestAllowance(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+	class := testClass(t, conn, 1)
+
+	lesson, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", Capacity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	guest := Capacity{Guest: &GuestSeat{ClassID: class.ID, LessonID: lesson.ID, Allowance: class.GuestAllowance}}
+
+	// ученики из списка гостевые места не занимают
+	if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", "ann", "student", Capacity{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", "dave", "student", guest); err != nil {
+		t.Fatalf("first guest: %v", err)
+	}
+	// переподключение гостя — то же место
+	if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", "dave", "student", guest); err != nil {
+		t.Fatalf("guest reconnect: %v", err)
+	}
+	if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", "eve", "student", guest); !errors.Is(err, ErrNoGuestSeat) {
+		t.Fatalf("second guest = %v, want %v", err, ErrNoGuestSeat)
+	}
+
+	// гость с действующим пропуском — уже не гость
+	if err := AddRosterOverride(ctx, conn, class.ID, "dave", "admin", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", "eve", "student", guest); err != nil {
+		t.Fatalf("guest after override freed the seat: %v", err)
+	}
+
+	// гость вышел — место освободилось
+	if err := LeaveParticipant(ctx, conn, 1, lesson.ID, "eve"); err != nil {
+		t.Fatal(err)
+	}
+	if err := JoinParticipant(ctx, conn, 1, lesson.ID, "math", "frank", "student", guest); err != nil {
+		t.Fatalf("guest after leave: %v", err)
+	}
+}
+
+func TestClassTenantIsolation(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+	class := testClass(t, conn, 0)
+
+	other, err := CreateTenant(ctx, conn, "other", "Other school", "other-api-key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetClass(ctx, conn, other.ID, class.ID); !errors.Is(err, ErrClassNotFound) {
+		t.Fatalf("get class of another tenant = %v, want %v", err, ErrClassNotFound)
+	}
+	if err := SetGuestAllowance(ctx, conn, other.ID, class.ID, 5); !errors.Is(err, ErrClassNotFound) {
+		t.Fatalf("set guest allowance of another tenant = %v, want %v", err, ErrClassNotFound)
+	}
+	// одноимённая комната другой школы к классу не привязана
+	if _, err := RoomClass(ctx, conn, other.ID, "math"); !errors.Is(err, ErrNoClass) {
+		t.Fatalf("room of another tenant = %v, want %v", err, ErrNoClass)
+	}
+	if list, err := ListClasses(ctx, conn, other.ID); err != nil || len(list) != 0 {
+		t.Fatalf("classes of another tenant = %+v, %v", list, err)
+	}
+
+	got, err := GetClass(ctx, conn, 1, class.ID)
+	if err != nil || got.Students != 2 || got.GuestAllowance != 0 {
+		t.Fatalf("own class = %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// execer — *sql.DB или *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// closeSessions закрывает открытые сегменты участника (name == "" => всех в уроке)
+func closeSessions(ctx context.Context, db execer, lessonID int64, name string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE participant_sessions
+		SET left_at = now()
+		WHERE lesson_id = $1
+		  AND ($2 = '' OR participant_name = $2)
+		  AND left_at IS NULL
+	`, lessonID, name)
+	return err
+}
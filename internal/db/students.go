@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// StudentSummary — вовлечённость ученика класса за период
+type StudentSummary struct {
+	StudentName     string     `json:"student_name"`
+	UserID          string     `json:"user_id,omitempty"`
+	LessonsHeld     int        `json:"lessons_held"` // уроков класса за период после записи ученика
+	LessonsAttended int        `json:"lessons_attended"`
+	AttendanceRate  float64    `json:"attendance_rate"` // 0..1
+	MinutesAttended float64    `json:"minutes_attended"`
+	CurrentStreak   int        `json:"current_streak"` // подряд, включая последний урок класса
+	LongestStreak   int        `json:"longest_streak"`
+	LastSeen        *time.Time `json:"last_seen,omitempty"` // за всё время, в уроках школы
+}
+
+// StudentSummaries — все ученики из списка класса против уроков в комнатах класса
+// (каждому — только уроки с момента его записи).
+//
+// Серии (streaks) — "gaps and islands": разность двух row_number() постоянна
+// внутри подряд идущих посещённых уроков.
+func StudentSummaries(ctx context.Context, db *sql.DB, tenantID, classID int64, from, to time.Time) ([]StudentSummary, error) {
+	ctx, span := startOp(ctx, "StudentSummaries",
+		attribute.Int64("tenant_id", tenantID),
+		attribute.Int64("class_id", classID),
+	)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		WITH cl AS (
+			SELECT l.id, l.started_at, COALESCE(l.ended_at, now()) AS ended_at
+			FROM lessons l
+			JOIN class_rooms r ON r.tenant_id = l.tenant_id AND r.room_name = l.room_name
+			JOIN classes c ON c.id = r.class_id
+			WHERE r.class_id = $1
+			  AND c.tenant_id = $2
+			  AND l.started_at >= $3
+			  AND l.started_at < $4
+		),
+		st AS (
+			SELECT student_name, user_id, COALESCE(created_at, '-infinity') AS enrolled_at
+			FROM enrollments WHERE class_id = $1
+		),
+		grid AS (
+			SELECT st.student_name, cl.started_at,
+			       EXISTS (
+			           SELECT 1 FROM participant_sessions s
+			           WHERE s.lesson_id = cl.id AND s.participant_name = st.student_name
+			       ) AS attended
+			-- уроки до записи в класс ученику не засчитываются ни в пропуски, ни в серии
+			FROM st JOIN cl ON cl.started_at >= st.enrolled_at
+		),
+		isl AS (
+			SELECT student_name, started_at, attended,
+			       row_number() OVER (PARTITION BY student_name ORDER BY started_at)
+			     - row_number() OVER (PARTITION BY student_name, attended ORDER BY started_at) AS grp
+			FROM grid
+		),
+		streaks AS (
+			SELECT student_name, count(*) AS len, max(started_at) AS last_at
+			FROM isl
+			WHERE attended
+			GROUP BY student_name, grp
+		),
+		mins AS (
+			SELECT s.participant_name,
+			       sum(EXTRACT(EPOCH FROM (LEAST(COALESCE(s.left_at, cl.ended_at), cl.ended_at) - s.joined_at)) / 60) AS minutes
+			FROM participant_sessions s
+			JOIN cl ON cl.id = s.lesson_id
+			GROUP BY s.participant_name
+		)
+		SELECT st.student_name, st.user_id,
+		       (SELECT count(*) FROM grid g WHERE g.student_name = st.student_name),
+		       (SELECT count(*) FROM grid g WHERE g.student_name = st.student_name AND g.attended),
+		       COALESCE(m.minutes, 0),
+		       COALESCE((SELECT x.len FROM streaks x
+		                 WHERE x.student_name = st.student_name
+		                   AND x.last_at = (SELECT max(g.started_at) FROM grid g
+		                                    WHERE g.student_name = st.student_name)), 0),
+		       COALESCE((SELECT max(x.len) FROM streaks x WHERE x.student_name = st.student_name), 0),
+		       (SELECT max(COALESCE(s.left_at, now()))
+		        FROM participant_sessions s
+		        JOIN lessons l ON l.id = s.lesson_id
+		        WHERE l.tenant_id = $2 AND s.participant_name = st.student_name)
+		FROM st
+		LEFT JOIN mins m ON m.participant_name = st.student_name
+		ORDER BY st.student_name
+	`, classID, tenantID, from, to)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []StudentSummary{}
+	for rows.Next() {
+		var s StudentSummary
+		var lastSeen sql.NullTime
+		if err := rows.Scan(
+			&s.StudentName, &s.UserID,
+			&s.LessonsHeld, &s.LessonsAttended, &s.MinutesAttended,
+			&s.CurrentStreak, &s.LongestStreak, &lastSeen,
+		); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		if s.LessonsHeld > 0 {
+			s.AttendanceRate = float64(s.LessonsAttended) / float64(s.LessonsHeld)
+		}
+		if lastSeen.Valid {
+			s.LastSeen = &lastSeen.Time
+		}
+		out = append(out, s)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// StudentLesson — один посещённый урок в истории ученика
+type StudentLesson struct {
+	LessonID  int64      `json:"lesson_id"`
+	Room      string     `json:"room"`
+	Teacher   string     `json:"teacher"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Minutes   float64    `json:"minutes"`  // сумма сегментов присутствия
+	Segments  int        `json:"segments"` // входов (переподключения => > 1)
+	FirstJoin time.Time  `json:"first_join"`
+	LastLeave *time.Time `json:"last_leave,omitempty"`
+}
+
+// StudentHistory — уроки школы, где был ученик, новые сверху
+func StudentHistory(ctx context.Context, db *sql.DB, tenantID int64, name string, from, to time.Time) ([]StudentLesson, error) {
+	ctx, span := startOp(ctx, "StudentHistory", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT l.id, l.room_name, l.teacher_name, l.started_at, l.ended_at,
+		       sum(EXTRACT(EPOCH FROM (
+		           LEAST(COALESCE(s.left_at, l.ended_at, now()), COALESCE(l.ended_at, now())) - s.joined_at
+		       )) / 60),
+		       count(*),
+		       min(s.joined_at),
+		       max(s.left_at) FILTER (WHERE NOT EXISTS (
+		           SELECT 1 FROM participant_sessions o
+		           WHERE o.lesson_id = l.id AND o.participant_name = $2 AND o.left_at IS NULL
+		       ))
+		FROM participant_sessions s
+		JOIN lessons l ON l.id = s.lesson_id
+		WHERE l.tenant_id = $1
+		  AND s.participant_name = $2
+		  AND l.started_at >= $3
+		  AND l.started_at < $4
+		GROUP BY l.id
+		ORDER BY l.started_at DESC
+	`, tenantID, name, from, to)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []StudentLesson{}
+	for rows.Next() {
+		var sl StudentLesson
+		var ended, lastLeave sql.NullTime
+		if err := rows.Scan(
+			&sl.LessonID, &sl.Room, &sl.Teacher, &sl.StartedAt, &ended,
+			&sl.Minutes, &sl.Segments, &sl.FirstJoin, &lastLeave,
+		); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		if ended.Valid {
+			sl.EndedAt = &ended.Time
+		}
+		if lastLeave.Valid {
+			sl.LastLeave = &lastLeave.Time
+		}
+		out = append(out, sl)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
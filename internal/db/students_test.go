@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStudentSummariesFromEnrollment(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+	class := testClass(t, conn, 0)
+
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 3, day, hour, min, 0, 0, time.UTC) }
+
+	// ann в классе с 1 марта, bob записан после первого урока
+	if _, err := conn.Exec(`
+		UPDATE enrollments SET created_at = CASE student_name WHEN 'ann' THEN $2::timestamptz ELSE $3::timestamptz END
+		WHERE class_id = $1
+	`, class.ID, at(1, 0, 0), at(2, 12, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	var lessons []int64
+	for day := 2; day <= 5; day++ {
+		lessons = append(lessons, insertLesson(t, conn, 1, "math", "alice", at(day, 9, 0), at(day, 9, 45), time.Time{}))
+	}
+	// урок в комнате без класса — не в счёт
+	insertLesson(t, conn, 1, "physics", "alice", at(4, 11, 0), at(4, 11, 45), time.Time{})
+
+	// ann: 1, 2, -, 4 (на втором уроке переподключалась)
+	insertPresence(t, conn, lessons[0], "ann", at(2, 9, 0), at(2, 9, 30))
+	insertPresence(t, conn, lessons[1], "ann", at(3, 9, 0), at(3, 9, 10))
+	insertPresence(t, conn, lessons[1], "ann", at(3, 9, 15), at(3, 9, 35))
+	insertPresence(t, conn, lessons[3], "ann", at(5, 9, 0), at(5, 9, 30))
+	// bob: (до записи), 2, 3, -
+	insertPresence(t, conn, lessons[1], "bob", at(3, 9, 0), at(3, 9, 20))
+	insertPresence(t, conn, lessons[2], "bob", at(4, 9, 0), at(4, 9, 20))
+
+	list, err := StudentSummaries(ctx, conn, 1, class.ID, at(1, 0, 0), at(8, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("summaries = %+v, want ann and bob", list)
+	}
+
+	ann, bob := list[0], list[1]
+	if ann.StudentName != "ann" || ann.LessonsHeld != 4 || ann.LessonsAttended != 3 || ann.AttendanceRate != 0.75 ||
+		ann.MinutesAttended != 90 || ann.CurrentStreak != 1 || ann.LongestStreak != 2 {
+		t.Errorf("ann = %+v, want 3 of 4 lessons, 90 minutes, streak 1 (longest 2)", ann)
+	}
+	if ann.LastSeen == nil || !ann.LastSeen.Equal(at(5, 9, 30)) {
+		t.Errorf("ann last seen = %v, want %v", ann.LastSeen, at(5, 9, 30))
+	}
+	// уроки до записи не пропуски
+	if bob.StudentName != "bob" || bob.LessonsHeld != 3 || bob.LessonsAttended != 2 ||
+		bob.MinutesAttended != 40 || bob.CurrentStreak != 0 || bob.LongestStreak != 2 {
+		t.Errorf("bob = %+v, want 2 of 3 lessons, 40 minutes, streak 0 (longest 2)", bob)
+	}
+
+	history, err := StudentHistory(ctx, conn, 1, "ann", at(1, 0, 0), at(8, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].LessonID != lessons[3] || history[2].LessonID != lessons[0] {
+		t.Fatalf("ann history = %+v, want lessons 4, 2, 1 (newest first)", history)
+	}
+	if h := history[1]; h.Segments != 2 || h.Minutes != 30 || !h.FirstJoin.Equal(at(3, 9, 0)) ||
+		h.LastLeave == nil || !h.LastLeave.Equal(at(3, 9, 35)) {
+		t.Errorf("reconnect lesson = %+v, want 2 segments, 30 minutes, 09:00-09:35", h)
+	}
+
+	// история другой школы пустая
+	other, err := CreateTenant(ctx, conn, "other", "Other school", "other-api-key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, err := StudentHistory(ctx, conn, other.ID, "ann", at(1, 0, 0), at(8, 0, 0)); err != nil || len(h) != 0 {
+		t.Fatalf("ann history in another tenant = %+v, %v", h, err)
+	}
+}
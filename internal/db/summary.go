@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+type TeacherLessons struct {
+	Teacher string `json:"teacher"`
+	Lessons int    `json:"lessons"`
+}
+
+type Summary struct {
+	TotalLessons int              `json:"total_lessons"`
+	TotalMinutes int              `json:"total_minutes"`
+	Teachers     []TeacherLessons `json:"teachers"`
+}
+
+// LessonSummary — итоги по школе; tenantID == 0 => по всем школам (супер-админ).
+func LessonSummary(ctx context.Context, db *sql.DB, tenantID int64) (*Summary, error) {
+	ctx, span := startOp(ctx, "LessonSummary", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	s := Summary{Teachers: []TeacherLessons{}}
+
+	err := db.QueryRowContext(ctx, `
+		SELECT count(*), COALESCE(sum(duration_sec)/60,0)
+		FROM lessons
+		WHERE ($1 = 0 OR tenant_id = $1)
+	`, tenantID).Scan(&s.TotalLessons, &s.TotalMinutes)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT teacher_name, count(*)
+		FROM lessons
+		WHERE ($1 = 0 OR tenant_id = $1)
+		GROUP BY teacher_name
+	`, tenantID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t TeacherLessons
+		if err := rows.Scan(&t.Teacher, &t.Lessons); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		s.Teachers = append(s.Teachers, t)
+	}
+
+	return &s, tracing.Fail(span, rows.Err())
+}
+
+type TenantSummary struct {
+	TenantID      int64  `json:"tenant_id"`
+	Slug          string `json:"slug"`
+	Name          string `json:"name"`
+	TotalLessons  int    `json:"total_lessons"`
+	TotalMinutes  int    `json:"total_minutes"`
+	ActiveLessons int    `json:"active_lessons"`
+}
+
+// TenantSummaries — сводка по всем школам (супер-админ)
+func TenantSummaries(ctx context.Context, db *sql.DB) ([]TenantSummary, error) {
+	ctx, span := startOp(ctx, "TenantSummaries")
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT t.id, t.slug, t.name,
+		       count(l.id),
+		       COALESCE(sum(l.duration_sec)/60,0),
+		       count(l.id) FILTER (WHERE l.id IS NOT NULL AND l.ended_at IS NULL)
+		FROM tenants t
+		LEFT JOIN lessons l ON l.tenant_id = t.id
+		GROUP BY t.id
+		ORDER BY t.id
+	`)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []TenantSummary{}
+	for rows.Next() {
+		var s TenantSummary
+		if err := rows.Scan(&s.TenantID, &s.Slug, &s.Name, &s.TotalLessons, &s.TotalMinutes, &s.ActiveLessons); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, s)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
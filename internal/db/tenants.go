@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/crypto/bcrypt"
+
+	"streaming/internal/tracing"
+)
+
+// DefaultTenantID — школа по умолчанию (глобальный API_KEY_SECRET, старые уроки)
+const DefaultTenantID int64 = 1
+
+// ErrTenantNotFound — неизвестный ключ / slug / логин
+var ErrTenantNotFound = errors.New("tenant not found")
+
+type Tenant struct {
+	ID             int64
+	Slug           string
+	Name           string
+	TeacherKeyHash sql.NullString
+}
+
+// IsDefault — tenant без префикса в именах комнат LiveKit
+func (t *Tenant) IsDefault() bool {
+	return t.ID == DefaultTenantID
+}
+
+// LiveKitRoom — имя комнаты в LiveKit: "<slug>__<room>",
+// чтобы "math" двух школ не оказались одной комнатой.
+// Школа по умолчанию — без префикса (совместимость со старыми комнатами).
+func (t *Tenant) LiveKitRoom(room string) string {
+	if t.IsDefault() {
+		return room
+	}
+	return t.Slug + "__" + room
+}
+
+// TeacherKeyOK — ключ учителя школы (если задан); ok=false => использовать глобальный.
+func (t *Tenant) TeacherKeyOK(key string) (match, ok bool) {
+	if !t.TeacherKeyHash.Valid || t.TeacherKeyHash.String == "" {
+		return false, false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashKey(key)), []byte(t.TeacherKeyHash.String)) == 1, true
+}
+
+// TeacherAllowed — ключ учителя школы; глобальный API_TEACHER_KEY
+// действует только для школы по умолчанию.
+func (t *Tenant) TeacherAllowed(globalKey, key string) bool {
+	if key == "" {
+		return false
+	}
+	if match, ok := t.TeacherKeyOK(key); ok {
+		return match
+	}
+	return t.IsDefault() && globalKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(globalKey)) == 1
+}
+
+// HashKey — sha256 hex (API ключи и ключи учителей в БД только так)
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewKey — случайный ключ для выдачи школе (показывается один раз)
+func NewKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+const tenantColumns = `id, slug, name, teacher_key_hash`
+
+func scanTenant(row interface{ Scan(...any) error }) (*Tenant, error) {
+	var t Tenant
+	if err := row.Scan(&t.ID, &t.Slug, &t.Name, &t.TeacherKeyHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// =======================
+// Lookup
+// =======================
+
+func GetTenant(ctx context.Context, db *sql.DB, id int64) (*Tenant, error) {
+	ctx, span := startOp(ctx, "GetTenant", attribute.Int64("tenant_id", id))
+	defer span.End()
+
+	t, err := scanTenant(db.QueryRowContext(ctx,
+		`SELECT `+tenantColumns+` FROM tenants WHERE id = $1`, id))
+	if err != nil && !errors.Is(err, ErrTenantNotFound) {
+		return nil, tracing.Fail(span, err)
+	}
+	return t, err
+}
+
+func GetTenantBySlug(ctx context.Context, db *sql.DB, slug string) (*Tenant, error) {
+	ctx, span := startOp(ctx, "GetTenantBySlug")
+	defer span.End()
+
+	t, err := scanTenant(db.QueryRowContext(ctx,
+		`SELECT `+tenantColumns+` FROM tenants WHERE slug = $1`, slug))
+	if err != nil && !errors.Is(err, ErrTenantNotFound) {
+		return nil, tracing.Fail(span, err)
+	}
+	return t, err
+}
+
+// TenantByAPIKey — школа по её API ключу
+func TenantByAPIKey(ctx context.Context, db *sql.DB, key string) (*Tenant, error) {
+	ctx, span := startOp(ctx, "TenantByAPIKey")
+	defer span.End()
+
+	t, err := scanTenant(db.QueryRowContext(ctx,
+		`SELECT `+tenantColumns+` FROM tenants WHERE api_key_hash = $1`, HashKey(key)))
+	if err != nil && !errors.Is(err, ErrTenantNotFound) {
+		return nil, tracing.Fail(span, err)
+	}
+	return t, err
+}
+
+// TenantByAdmin — школа администратора (логин + bcrypt пароль)
+func TenantByAdmin(ctx context.Context, db *sql.DB, username, password string) (*Tenant, error) {
+	ctx, span := startOp(ctx, "TenantByAdmin")
+	defer span.End()
+
+	var hash string
+	var tenantID int64
+	err := db.QueryRowContext(ctx, `
+		SELECT tenant_id, password_hash
+		FROM tenant_admins
+		WHERE username = $1
+	`, username).Scan(&tenantID, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTenantNotFound
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, ErrTenantNotFound
+	}
+
+	return GetTenant(ctx, db, tenantID)
+}
+
+// =======================
+// Management (super-admin)
+// =======================
+
+// ListTenants — все школы
+func ListTenants(ctx context.Context, db *sql.DB) ([]Tenant, error) {
+	ctx, span := startOp(ctx, "ListTenants")
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `SELECT `+tenantColumns+` FROM tenants ORDER BY id`)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	var out []Tenant
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, *t)
+	}
+	return out, tracing.Fail(span, rows.Err())
+}
+
+// CreateTenant создаёт школу; apiKey/teacherKey сохраняются только хэшем.
+// teacherKey == "" => у школы нет своего ключа учителя (teacher запрещён).
+func CreateTenant(ctx context.Context, db *sql.DB, slug, name, apiKey, teacherKey string) (*Tenant, error) {
+	ctx, span := startOp(ctx, "CreateTenant")
+	defer span.End()
+
+	var teacherHash sql.NullString
+	if teacherKey != "" {
+		teacherHash = sql.NullString{String: HashKey(teacherKey), Valid: true}
+	}
+
+	t, err := scanTenant(db.QueryRowContext(ctx, `
+		INSERT INTO tenants (slug, name, api_key_hash, teacher_key_hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+tenantColumns,
+		slug, name, HashKey(apiKey), teacherHash))
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return t, nil
+}
+
+// AddTenantAdmin — администратор школы (пароль хранится bcrypt)
+func AddTenantAdmin(ctx context.Context, db *sql.DB, tenantID int64, username, password string) error {
+	ctx, span := startOp(ctx, "AddTenantAdmin", attribute.Int64("tenant_id", tenantID))
+	defer span.End()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO tenant_admins (tenant_id, username, password_hash)
+		VALUES ($1, $2, $3)
+	`, tenantID, username, string(hash))
+
+	return tracing.Fail(span, err)
+}
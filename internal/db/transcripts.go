@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// TranscriptSegment — фраза расшифровки урока
+type TranscriptSegment struct {
+	ID        int64     `json:"id"`
+	LessonID  int64     `json:"lesson_id"`
+	Speaker   string    `json:"speaker"`
+	Language  string    `json:"language"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Text      string    `json:"text"`
+}
+
+// AddTranscriptSegment — дописать финальный фрагмент субтитров
+func AddTranscriptSegment(ctx context.Context, db *sql.DB, s *TranscriptSegment) error {
+	ctx, span := startOp(ctx, "AddTranscriptSegment", attribute.Int64("lesson_id", s.LessonID))
+	defer span.End()
+
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO transcript_segments (lesson_id, speaker, language, started_at, ended_at, text)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, s.LessonID, s.Speaker, s.Language, s.StartedAt, s.EndedAt, s.Text).Scan(&s.ID)
+
+	return tracing.Fail(span, err)
+}
+
+// LessonTranscript — расшифровка урока по времени
+func LessonTranscript(ctx context.Context, db *sql.DB, lessonID int64) ([]TranscriptSegment, error) {
+	ctx, span := startOp(ctx, "LessonTranscript", attribute.Int64("lesson_id", lessonID))
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, lesson_id, speaker, language, started_at, ended_at, text
+		FROM transcript_segments
+		WHERE lesson_id = $1
+		ORDER BY started_at, id
+	`, lessonID)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	out := []TranscriptSegment{}
+	for rows.Next() {
+		var s TranscriptSegment
+		if err := rows.Scan(&s.ID, &s.LessonID, &s.Speaker, &s.Language, &s.StartedAt, &s.EndedAt, &s.Text); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		out = append(out, s)
+	}
+
+	return out, tracing.Fail(span, rows.Err())
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// WhiteboardOp — операция доски в журнале (op — JSON, формат знает service)
+type WhiteboardOp struct {
+	Seq    int64           `json:"seq"`
+	Author string          `json:"author"`
+	Op     json.RawMessage `json:"op"`
+}
+
+// WhiteboardState — снимок + операции после него
+type WhiteboardState struct {
+	Snapshot    json.RawMessage
+	SnapshotSeq int64
+	HeadSeq     int64
+	Ops         []WhiteboardOp
+}
+
+// AppendWhiteboardOps — дописать операции в журнал урока; возвращает seq первой.
+// Порядок гарантирует блокировка строки whiteboards.
+func AppendWhiteboardOps(ctx context.Context, db *sql.DB, lessonID int64, author string, ops []json.RawMessage) (int64, error) {
+	ctx, span := startOp(ctx, "AppendWhiteboardOps",
+		attribute.Int64("lesson_id", lessonID),
+		attribute.Int("ops", len(ops)),
+	)
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO whiteboards (lesson_id)
+		VALUES ($1)
+		ON CONFLICT (lesson_id) DO NOTHING
+	`, lessonID); err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	var head int64
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE whiteboards
+		SET head_seq = head_seq + $2,
+		    updated_at = now()
+		WHERE lesson_id = $1
+		RETURNING head_seq
+	`, lessonID, len(ops)).Scan(&head); err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	first := head - int64(len(ops)) + 1
+	for i, op := range ops {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO whiteboard_ops (lesson_id, seq, author, op)
+			VALUES ($1, $2, $3, $4)
+		`, lessonID, first+int64(i), author, []byte(op)); err != nil {
+			return 0, tracing.Fail(span, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	return first, nil
+}
+
+// GetWhiteboard — снимок и операции после него (since > snapshot_seq => только новее since).
+// Доски ещё нет — пустое состояние.
+func GetWhiteboard(ctx context.Context, db *sql.DB, lessonID, since int64) (*WhiteboardState, error) {
+	ctx, span := startOp(ctx, "GetWhiteboard", attribute.Int64("lesson_id", lessonID))
+	defer span.End()
+
+	st := WhiteboardState{Ops: []WhiteboardOp{}}
+	var snapshot []byte
+	err := db.QueryRowContext(ctx, `
+		SELECT snapshot, snapshot_seq, head_seq
+		FROM whiteboards
+		WHERE lesson_id = $1
+	`, lessonID).Scan(&snapshot, &st.SnapshotSeq, &st.HeadSeq)
+	if err == sql.ErrNoRows {
+		return &st, nil
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	st.Snapshot = snapshot
+
+	from := st.SnapshotSeq
+	if since > from {
+		from = since
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT seq, author, op
+		FROM whiteboard_ops
+		WHERE lesson_id = $1
+		  AND seq > $2
+		ORDER BY seq
+	`, lessonID, from)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var op WhiteboardOp
+		var raw []byte
+		if err := rows.Scan(&op.Seq, &op.Author, &raw); err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		op.Op = raw
+		st.Ops = append(st.Ops, op)
+	}
+
+	return &st, tracing.Fail(span, rows.Err())
+}
+
+// CompactWhiteboard — сохранить снимок на seq и удалить операции до него.
+// Более старый снимок (параллельное сжатие) не затирает новый.
+func CompactWhiteboard(ctx context.Context, db *sql.DB, lessonID, seq int64, snapshot json.RawMessage) error {
+	ctx, span := startOp(ctx, "CompactWhiteboard",
+		attribute.Int64("lesson_id", lessonID),
+		attribute.Int64("seq", seq),
+	)
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE whiteboards
+		SET snapshot = $3,
+		    snapshot_seq = $2,
+		    updated_at = now()
+		WHERE lesson_id = $1
+		  AND snapshot_seq < $2
+	`, lessonID, seq, []byte(snapshot))
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM whiteboard_ops
+		WHERE lesson_id = $1
+		  AND seq <= $2
+	`, lessonID, seq); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	return tracing.Fail(span, tx.Commit())
+}
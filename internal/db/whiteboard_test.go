@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func ops(raw ...string) []json.RawMessage {
+	out := make([]json.RawMessage, len(raw))
+	for i, r := range raw {
+		out[i] = json.RawMessage(r)
+	}
+	return out
+}
+
+// sameJSON — jsonb переформатирует документ, сравниваем по значению
+func sameJSON(t *testing.T, got json.RawMessage, want string) bool {
+	t.Helper()
+	var a, b any
+	if err := json.Unmarshal(got, &a); err != nil {
+		t.Fatalf("%s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &b); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func TestWhiteboardOpLogAndCompaction(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	lesson, _, err := StartLesson(ctx, conn, 1, "math", "teacher", "node-1", Capacity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// доски ещё нет — пустое состояние, а не ошибка
+	st, err := GetWhiteboard(ctx, conn, lesson.ID, 0)
+	if err != nil || st.HeadSeq != 0 || len(st.Ops) != 0 {
+		t.Fatalf("empty board = %+v, %v", st, err)
+	}
+
+	first, err := AppendWhiteboardOps(ctx, conn, lesson.ID, "teacher", ops(`{"type":"stroke","id":"a"}`, `{"type":"stroke","id":"b"}`))
+	if err != nil || first != 1 {
+		t.Fatalf("append: first=%d err=%v", first, err)
+	}
+	first, err = AppendWhiteboardOps(ctx, conn, lesson.ID, "ann", ops(`{"type":"erase","id":"a"}`))
+	if err != nil || first != 3 {
+		t.Fatalf("second append: first=%d err=%v, want 3", first, err)
+	}
+
+	st, err = GetWhiteboard(ctx, conn, lesson.ID, 0)
+	if err != nil || st.HeadSeq != 3 || len(st.Ops) != 3 {
+		t.Fatalf("board = %+v, %v; want 3 ops", st, err)
+	}
+	if op := st.Ops[2]; op.Seq != 3 || op.Author != "ann" || !sameJSON(t, op.Op, `{"type":"erase","id":"a"}`) {
+		t.Fatalf("third op = %+v", op)
+	}
+
+	// догоняющий клиент получает только новее since
+	st, err = GetWhiteboard(ctx, conn, lesson.ID, 2)
+	if err != nil || len(st.Ops) != 1 || st.Ops[0].Seq != 3 {
+		t.Fatalf("since 2 = %+v, %v; want op 3", st, err)
+	}
+
+	// снимок на seq 2: операции до него удалены, late joiner — снимок + хвост
+	if err := CompactWhiteboard(ctx, conn, lesson.ID, 2, json.RawMessage(`{"strokes":["a","b"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	// запоздавшее сжатие на более старом seq снимок не затирает
+	if err := CompactWhiteboard(ctx, conn, lesson.ID, 1, json.RawMessage(`{"strokes":["a"]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err = GetWhiteboard(ctx, conn, lesson.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.SnapshotSeq != 2 || st.HeadSeq != 3 || !sameJSON(t, st.Snapshot, `{"strokes":["a","b"]}`) {
+		t.Fatalf("compacted board = %+v, want snapshot at 2", st)
+	}
+	if len(st.Ops) != 1 || st.Ops[0].Seq != 3 {
+		t.Fatalf("ops after compaction = %+v, want only op 3", st.Ops)
+	}
+
+	var stored int
+	if err := conn.QueryRow(`SELECT count(*) FROM whiteboard_ops WHERE lesson_id = $1`, lesson.ID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != 1 {
+		t.Fatalf("stored ops = %d, want 1", stored)
+	}
+
+	// seq продолжается после сжатия
+	if first, err := AppendWhiteboardOps(ctx, conn, lesson.ID, "teacher", ops(`{"type":"clear"}`)); err != nil || first != 4 {
+		t.Fatalf("append after compaction: first=%d err=%v, want 4", first, err)
+	}
+}
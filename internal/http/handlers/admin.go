@@ -2,45 +2,183 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
+	"regexp"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/middleware"
 )
 
-func AdminSummary(db *sql.DB) gin.HandlerFunc {
+// AdminSummary — итоги школы администратора.
+// Супер-админ: ?tenant=<slug> для конкретной школы, без параметра — по всем.
+func AdminSummary(dbConn *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		tenantID, ok := adminTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+
+		s, err := db.LessonSummary(ctx, dbConn, tenantID)
+		if err != nil {
+			logging.FromContext(ctx).Error("admin summary", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, s)
+	}
+}
+
+// adminTenantID — школа для админ-запроса: своя для админа школы,
+// ?tenant=<slug> или 0 (все школы) для супер-админа.
+// ok=false — ответ с ошибкой уже отправлен.
+func adminTenantID(c *gin.Context, dbConn *sql.DB) (int64, bool) {
+	scope := middleware.GetAdminScope(c)
+	if !scope.Super {
+		return scope.Tenant.ID, true
+	}
+
+	slug := strings.TrimSpace(c.Query("tenant"))
+	if slug == "" {
+		return 0, true
+	}
+
+	t, err := db.GetTenantBySlug(c.Request.Context(), dbConn, slug)
+	if err != nil {
+		if errors.Is(err, db.ErrTenantNotFound) {
+			apierr.Write(c, apierr.TenantNotFound)
+			return 0, false
+		}
+		logging.FromContext(c.Request.Context()).Error("tenant lookup", "error", err)
+		apierr.Write(c, apierr.DBError)
+		return 0, false
+	}
+	return t.ID, true
+}
+
+// =======================
+// Super-admin: tenants
+// =======================
+
+// AdminTenants — GET /api/admin/tenants: сводка по всем школам
+func AdminTenants(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		list, err := db.TenantSummaries(ctx, dbConn)
+		if err != nil {
+			logging.FromContext(ctx).Error("tenant summaries", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"tenants": list})
+	}
+}
+
+type CreateTenantRequest struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+var tenantSlugRe = regexp.MustCompile(`^[a-z0-9-]{2,32}$`)
+
+// AdminCreateTenant — POST /api/admin/tenants.
+// API ключ и ключ учителя возвращаются ОДИН раз (в БД только хэши).
+func AdminCreateTenant(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req CreateTenantRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+		req.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+		req.Name = strings.TrimSpace(req.Name)
+
+		if !tenantSlugRe.MatchString(req.Slug) || req.Name == "" {
+			apierr.WriteReason(c, apierr.InvalidRequest, "slug (a-z, 0-9, -, 2..32 chars) and name are required")
+			return
+		}
+
+		apiKey, err := db.NewKey()
+		if err != nil {
+			apierr.Write(c, apierr.KeyGenerationFailed)
+			return
+		}
+		teacherKey, err := db.NewKey()
+		if err != nil {
+			apierr.Write(c, apierr.KeyGenerationFailed)
+			return
+		}
+
+		t, err := db.CreateTenant(ctx, dbConn, req.Slug, req.Name, apiKey, teacherKey)
+		if err != nil {
+			logging.FromContext(ctx).Error("create tenant", "error", err)
+			apierr.Write(c, apierr.TenantCreateFailed)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"tenant_id":   t.ID,
+			"slug":        t.Slug,
+			"name":        t.Name,
+			"api_key":     apiKey,
+			"teacher_key": teacherKey,
+		})
+	}
+}
+
+type CreateTenantAdminRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AdminCreateTenantAdmin — POST /api/admin/tenants/:slug/admins
+func AdminCreateTenantAdmin(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req CreateTenantAdminRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+		req.Username = strings.TrimSpace(req.Username)
+		if req.Username == "" || len(req.Password) < 12 {
+			apierr.WriteReason(c, apierr.InvalidRequest, "username and password (min 12 chars) are required")
+			return
+		}
+
+		t, err := db.GetTenantBySlug(ctx, dbConn, c.Param("slug"))
+		if err != nil {
+			if errors.Is(err, db.ErrTenantNotFound) {
+				apierr.Write(c, apierr.TenantNotFound)
+				return
+			}
+			logging.FromContext(ctx).Error("tenant lookup", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		if err := db.AddTenantAdmin(ctx, dbConn, t.ID, req.Username, req.Password); err != nil {
+			logging.FromContext(ctx).Error("add tenant admin", "error", err)
+			apierr.Write(c, apierr.AdminCreateFailed)
+			return
+		}
 
-		var totalLessons int
-		_ = db.QueryRow(`SELECT count(*) FROM lessons`).Scan(&totalLessons)
-
-		var totalMinutes int
-		_ = db.QueryRow(`
-			SELECT COALESCE(sum(duration_sec)/60,0)
-			FROM lessons
-		`).Scan(&totalMinutes)
-
-		rows, _ := db.Query(`
-			SELECT teacher_name, count(*)
-			FROM lessons
-			GROUP BY teacher_name
-		`)
-		defer rows.Close()
-
-		teachers := []gin.H{}
-		for rows.Next() {
-			var name string
-			var cnt int
-			rows.Scan(&name, &cnt)
-			teachers = append(teachers, gin.H{
-				"teacher": name,
-				"lessons": cnt,
-			})
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"total_lessons": totalLessons,
-			"total_minutes": totalMinutes,
-			"teachers":      teachers,
+		c.JSON(http.StatusCreated, gin.H{
+			"tenant":   t.Slug,
+			"username": req.Username,
 		})
 	}
 }
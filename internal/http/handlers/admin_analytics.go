@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+)
+
+const (
+	analyticsDateLayout   = "2006-01-02"
+	analyticsDefaultDays  = 30
+	analyticsMaxRangeDays = 366
+)
+
+// AdminAnalytics — GET /api/admin/analytics
+//
+//	?from=2026-01-01&to=2026-01-31   даты включительно (по умолчанию — последние 30 дней)
+//	&tz=Asia/Ashgabat                пояс для границ дней/недель (по умолчанию UTC)
+//	&bucket=day|week                 (day)
+//	&group=teacher|room              (teacher)
+//	&teacher=...&room=...            фильтры
+//	&late_grace=5                    минут до "опоздания" относительно расписания
+func AdminAnalytics(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		q, ok := parseAnalyticsQuery(c)
+		if !ok {
+			return
+		}
+
+		tenantID, ok := adminTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+		q.TenantID = tenantID
+
+		series, err := db.Analytics(ctx, dbConn, q)
+		if err != nil {
+			logging.FromContext(ctx).Error("analytics", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":       q.From.Format(analyticsDateLayout),
+			"to":         q.To.AddDate(0, 0, -1).Format(analyticsDateLayout),
+			"tz":         q.TZ,
+			"bucket":     q.Bucket,
+			"group":      q.GroupBy,
+			"late_grace": q.LateGrace.Minutes(),
+			"series":     series,
+		})
+	}
+}
+
+// parseAnalyticsQuery — ok=false: ответ с ошибкой уже отправлен
+func parseAnalyticsQuery(c *gin.Context) (db.AnalyticsQuery, bool) {
+	q := db.AnalyticsQuery{
+		TZ:        strings.TrimSpace(c.DefaultQuery("tz", "UTC")),
+		Bucket:    strings.ToLower(c.DefaultQuery("bucket", "day")),
+		GroupBy:   strings.ToLower(c.DefaultQuery("group", "teacher")),
+		Teacher:   strings.TrimSpace(c.Query("teacher")),
+		Room:      strings.TrimSpace(c.Query("room")),
+		LateGrace: 5 * time.Minute,
+	}
+
+	from, to, ok := parseDateRange(c, q.TZ)
+	if !ok {
+		return q, false
+	}
+	q.From, q.To = from, to
+
+	if q.Bucket != "day" && q.Bucket != "week" {
+		apierr.WriteReason(c, apierr.InvalidRequest, "bucket must be day or week")
+		return q, false
+	}
+	if q.GroupBy != "teacher" && q.GroupBy != "room" {
+		apierr.WriteReason(c, apierr.InvalidRequest, "group must be teacher or room")
+		return q, false
+	}
+
+	if v := c.Query("late_grace"); v != "" {
+		m, err := strconv.Atoi(v)
+		if err != nil || m < 0 || m > 240 {
+			apierr.WriteReason(c, apierr.InvalidRequest, "late_grace must be 0..240 minutes")
+			return q, false
+		}
+		q.LateGrace = time.Duration(m) * time.Minute
+	}
+
+	return q, true
+}
+
+// parseDateRange — ?from=&to= (YYYY-MM-DD, включительно) в поясе tz.
+// Возвращает [from 00:00, to+1 00:00); по умолчанию — последние 30 дней.
+// ok=false — ответ с ошибкой уже отправлен.
+func parseDateRange(c *gin.Context, tz string) (from, to time.Time, ok bool) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		apierr.WriteReason(c, apierr.InvalidTimezone, "tz must be an IANA time zone, e.g. Asia/Ashgabat")
+		return from, to, false
+	}
+
+	// даты — полночь в поясе tz; to включительно => верхняя граница to+1 день
+	now := time.Now().In(loc)
+	to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if v := c.Query("to"); v != "" {
+		if to, err = time.ParseInLocation(analyticsDateLayout, v, loc); err != nil {
+			apierr.WriteReason(c, apierr.InvalidDate, "to must be YYYY-MM-DD")
+			return from, to, false
+		}
+	}
+	from = to.AddDate(0, 0, -(analyticsDefaultDays - 1))
+	if v := c.Query("from"); v != "" {
+		if from, err = time.ParseInLocation(analyticsDateLayout, v, loc); err != nil {
+			apierr.WriteReason(c, apierr.InvalidDate, "from must be YYYY-MM-DD")
+			return from, to, false
+		}
+	}
+	to = to.AddDate(0, 0, 1)
+
+	if !from.Before(to) {
+		apierr.WriteReason(c, apierr.InvalidDate, "from must not be after to")
+		return from, to, false
+	}
+	if to.Sub(from) > analyticsMaxRangeDays*24*time.Hour {
+		apierr.WriteReason(c, apierr.InvalidDate, "date range must not exceed 366 days")
+		return from, to, false
+	}
+
+	return from, to, true
+}
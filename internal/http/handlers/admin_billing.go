@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/service"
+)
+
+const billingMonthLayout = "2006-01"
+
+// BillingPeriodRange — границы расчётного месяца
+type BillingPeriodRange struct {
+	Month string
+	TZ    string
+	From  time.Time
+	To    time.Time // не включительно
+}
+
+// AdminBillingPreview — GET /api/admin/billing/preview?month=2026-09&tz=Asia/Ashgabat
+// Ведомости по текущим правилам без сохранения (по умолчанию — прошлый месяц).
+func AdminBillingPreview(dbConn *sql.DB, rules func() service.BillingRules) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+		pr, ok := parseBillingMonth(c, c.Query("month"), c.DefaultQuery("tz", "UTC"))
+		if !ok {
+			return
+		}
+
+		usage, err := db.LessonUsageFor(ctx, dbConn, tenantID, pr.From, pr.To)
+		if err != nil {
+			logging.FromContext(ctx).Error("billing usage", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+		open, err := db.CountOpenLessonsIn(ctx, dbConn, tenantID, pr.From, pr.To)
+		if err != nil {
+			logging.FromContext(ctx).Error("billing open lessons", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		r := rules()
+		c.JSON(http.StatusOK, gin.H{
+			"month":        pr.Month,
+			"tz":           pr.TZ,
+			"currency":     r.Currency,
+			"rules":        r.JSON(),
+			"open_lessons": open, // ещё идут — в период не попадут
+			"statements":   r.Statements(usage),
+		})
+	}
+}
+
+// AdminBillingPeriods — GET /api/admin/billing/periods
+func AdminBillingPeriods(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+
+		list, err := db.ListBillingPeriods(ctx, dbConn, tenantID)
+		if err != nil {
+			logging.FromContext(ctx).Error("list billing periods", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"periods": list})
+	}
+}
+
+type CloseBillingPeriodRequest struct {
+	Month string `json:"month"` // YYYY-MM
+	TZ    string `json:"tz"`
+}
+
+// AdminCloseBillingPeriod — POST /api/admin/billing/periods
+// Считает ведомости по текущим правилам и фиксирует их; месяц закрывается один раз.
+func AdminCloseBillingPeriod(dbConn *sql.DB, rules func() service.BillingRules) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req CloseBillingPeriodRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+		if strings.TrimSpace(req.TZ) == "" {
+			req.TZ = "UTC"
+		}
+
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+		pr, ok := parseBillingMonth(c, req.Month, req.TZ)
+		if !ok {
+			return
+		}
+		if pr.To.After(time.Now()) {
+			apierr.Write(c, apierr.BillingPeriodNotOver)
+			return
+		}
+
+		open, err := db.CountOpenLessonsIn(ctx, dbConn, tenantID, pr.From, pr.To)
+		if err != nil {
+			logging.FromContext(ctx).Error("billing open lessons", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+		// незавершённый урок не попал бы ни в этот период, ни в следующий — не закрываем
+		if open > 0 {
+			apierr.WriteDetails(c, apierr.BillingOpenLessons, gin.H{"open_lessons": open})
+			return
+		}
+
+		usage, err := db.LessonUsageFor(ctx, dbConn, tenantID, pr.From, pr.To)
+		if err != nil {
+			logging.FromContext(ctx).Error("billing usage", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		r := rules()
+		actor, _, _ := c.Request.BasicAuth()
+		p := &db.BillingPeriod{
+			TenantID:    tenantID,
+			PeriodStart: pr.From.Format(analyticsDateLayout),
+			TZ:          pr.TZ,
+			StartsAt:    pr.From,
+			EndsAt:      pr.To,
+			Currency:    r.Currency,
+			Rules:       r.JSON(),
+			ClosedBy:    actor,
+			Statements:  r.Statements(usage),
+		}
+
+		id, err := db.CloseBillingPeriod(ctx, dbConn, p)
+		if errors.Is(err, db.ErrBillingPeriodClosed) {
+			apierr.Write(c, apierr.BillingPeriodClosed)
+			return
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("close billing period", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		logging.FromContext(ctx).Info("billing period closed",
+			"period_id", id,
+			"month", pr.Month,
+			"teachers", len(p.Statements),
+		)
+
+		saved, err := db.GetBillingPeriod(ctx, dbConn, tenantID, id)
+		if err != nil {
+			logging.FromContext(ctx).Error("get billing period", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+		c.JSON(http.StatusCreated, saved)
+	}
+}
+
+// AdminBillingPeriod — GET /api/admin/billing/periods/:id
+func AdminBillingPeriod(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := adminBillingPeriod(c, dbConn)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+// AdminBillingExport — GET /api/admin/billing/periods/:id/export.csv
+//
+//	?detail=teacher (по умолчанию) — строка на учителя
+//	?detail=lesson                  — строка на урок
+func AdminBillingExport(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		detail := strings.ToLower(c.DefaultQuery("detail", "teacher"))
+		if detail != "teacher" && detail != "lesson" {
+			apierr.WriteReason(c, apierr.InvalidRequest, "detail must be teacher or lesson")
+			return
+		}
+
+		p, ok := adminBillingPeriod(c, dbConn)
+		if !ok {
+			return
+		}
+
+		writeBillingCSV(c, p, detail)
+	}
+}
+
+// =======================
+// Helpers
+// =======================
+
+// parseBillingMonth — YYYY-MM в поясе tz; пусто => прошлый месяц.
+// ok=false — ответ с ошибкой уже отправлен.
+func parseBillingMonth(c *gin.Context, month, tz string) (BillingPeriodRange, bool) {
+	pr := BillingPeriodRange{TZ: strings.TrimSpace(tz)}
+
+	loc, err := time.LoadLocation(pr.TZ)
+	if err != nil {
+		apierr.WriteReason(c, apierr.InvalidTimezone, "tz must be an IANA time zone, e.g. Asia/Ashgabat")
+		return pr, false
+	}
+
+	month = strings.TrimSpace(month)
+	if month == "" {
+		now := time.Now().In(loc)
+		pr.From = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc).AddDate(0, -1, 0)
+	} else if pr.From, err = time.ParseInLocation(billingMonthLayout, month, loc); err != nil {
+		apierr.WriteReason(c, apierr.InvalidDate, "month must be YYYY-MM")
+		return pr, false
+	}
+	pr.To = pr.From.AddDate(0, 1, 0)
+	pr.Month = pr.From.Format(billingMonthLayout)
+
+	return pr, true
+}
+
+// adminBillingPeriod — период из :id, доступный этому админу
+func adminBillingPeriod(c *gin.Context, dbConn *sql.DB) (*db.BillingPeriod, bool) {
+	ctx := c.Request.Context()
+
+	periodID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.WriteReason(c, apierr.InvalidRequest, "invalid billing period id")
+		return nil, false
+	}
+
+	tenantID, ok := adminWriteTenantID(c, dbConn)
+	if !ok {
+		return nil, false
+	}
+
+	p, err := db.GetBillingPeriod(ctx, dbConn, tenantID, periodID)
+	if err != nil {
+		if errors.Is(err, db.ErrBillingPeriodNotFound) {
+			apierr.Write(c, apierr.BillingPeriodNotFound)
+			return nil, false
+		}
+		logging.FromContext(ctx).Error("get billing period", "error", err)
+		apierr.Write(c, apierr.DBError)
+		return nil, false
+	}
+	return p, true
+}
+
+func writeBillingCSV(c *gin.Context, p *db.BillingPeriod, detail string) {
+	filename := fmt.Sprintf("billing-%s-%s.csv", p.PeriodStart[:7], detail)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	// BOM — чтобы Excel открыл UTF-8 без кракозябр
+	_, _ = c.Writer.WriteString("\ufeff")
+
+	loc, err := time.LoadLocation(p.TZ)
+	if err != nil {
+		loc = time.UTC
+	}
+	w := csv.NewWriter(c.Writer)
+
+	if detail == "lesson" {
+		_ = w.Write([]string{
+			"teacher_name", "lesson_id", "room", "started_at", "ended_at", "teacher_minutes",
+			"billable_minutes", "participant_minutes", "participants", "amount", "currency",
+		})
+		for _, s := range p.Statements {
+			for _, l := range s.Lines {
+				_ = w.Write([]string{
+					s.Teacher,
+					strconv.FormatInt(l.LessonID, 10),
+					l.Room,
+					l.StartedAt.In(loc).Format(time.RFC3339),
+					l.EndedAt.In(loc).Format(time.RFC3339),
+					strconv.FormatInt(l.TeacherMinutes, 10),
+					strconv.FormatInt(l.BillableMinutes, 10),
+					strconv.FormatInt(l.ParticipantMinutes, 10),
+					strconv.Itoa(l.Participants),
+					formatMinor(l.Amount),
+					p.Currency,
+				})
+			}
+		}
+	} else {
+		_ = w.Write([]string{
+			"teacher_name", "lessons", "teacher_minutes", "billable_minutes",
+			"participant_minutes", "teacher_amount", "participant_cost", "currency",
+		})
+		for _, s := range p.Statements {
+			_ = w.Write([]string{
+				s.Teacher,
+				strconv.Itoa(s.Lessons),
+				strconv.FormatInt(s.TeacherMinutes, 10),
+				strconv.FormatInt(s.BillableMinutes, 10),
+				strconv.FormatInt(s.ParticipantMinutes, 10),
+				formatMinor(s.TeacherAmount),
+				formatMinor(s.ParticipantCost),
+				p.Currency,
+			})
+		}
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("billing csv write", "error", err)
+	}
+}
+
+// formatMinor — минорные единицы => "12.50"
+func formatMinor(v int64) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
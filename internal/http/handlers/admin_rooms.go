@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+)
+
+// slug комнаты — часть ссылки; "__" занят разделителем школы в имени комнаты LiveKit
+var roomSlugRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// maxRoomParticipants — больше в одной комнате LiveKit всё равно не потянет
+const maxRoomParticipants = 1000
+
+type RoomRequest struct {
+	Slug            string  `json:"slug"` // только при создании
+	Title           *string `json:"title"`
+	OwnerTeacher    *string `json:"owner_teacher"`
+	MaxParticipants *int    `json:"max_participants"` // всего
+	MaxStudents     *int    `json:"max_students"`
+	MaxTeachers     *int    `json:"max_teachers"`
+	OverflowQueue   *bool   `json:"overflow_queue"` // мест нет => очередь вместо ROOM_FULL
+	WaitingRoom     *bool   `json:"waiting_room"`
+	Recording       *string `json:"recording"` // off | allowed | always
+}
+
+// apply — заполненные поля запроса поверх r (PATCH); false — ответ с ошибкой уже отправлен
+func (req *RoomRequest) apply(c *gin.Context, r *db.Room) bool {
+	if req.Title != nil {
+		r.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.OwnerTeacher != nil {
+		r.OwnerTeacher = strings.TrimSpace(*req.OwnerTeacher)
+	}
+	if req.MaxParticipants != nil {
+		r.MaxParticipants = *req.MaxParticipants
+	}
+	if req.MaxStudents != nil {
+		r.MaxStudents = *req.MaxStudents
+	}
+	if req.MaxTeachers != nil {
+		r.MaxTeachers = *req.MaxTeachers
+	}
+	if req.OverflowQueue != nil {
+		r.OverflowQueue = *req.OverflowQueue
+	}
+	if req.WaitingRoom != nil {
+		r.WaitingRoom = *req.WaitingRoom
+	}
+	if req.Recording != nil {
+		r.Recording = strings.ToLower(strings.TrimSpace(*req.Recording))
+	}
+
+	for _, n := range []int{r.MaxParticipants, r.MaxStudents, r.MaxTeachers} {
+		if n < 0 || n > maxRoomParticipants {
+			apierr.WriteReason(c, apierr.InvalidRequest, "max_participants, max_students and max_teachers must be 0..1000 (0 = unlimited)")
+			return false
+		}
+	}
+	if r.MaxParticipants > 0 && (r.MaxStudents > r.MaxParticipants || r.MaxTeachers > r.MaxParticipants) {
+		apierr.WriteReason(c, apierr.InvalidRequest, "max_students and max_teachers cannot exceed max_participants")
+		return false
+	}
+	if r.Recording != db.RecordingOff && r.Recording != db.RecordingAllowed && r.Recording != db.RecordingAlways {
+		apierr.WriteReason(c, apierr.InvalidRequest, "recording must be off, allowed or always")
+		return false
+	}
+	return true
+}
+
+// AdminRooms — GET /api/admin/rooms
+func AdminRooms(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+
+		list, err := db.ListRooms(ctx, dbConn, tenantID)
+		if err != nil {
+			logging.FromContext(ctx).Error("list rooms", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"rooms": list})
+	}
+}
+
+// AdminCreateRoom — POST /api/admin/rooms
+func AdminCreateRoom(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req RoomRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+		slug := strings.TrimSpace(req.Slug)
+		if !roomSlugRe.MatchString(slug) || !roomNameOK(slug) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "slug must be 1-63 chars: a-z, 0-9, '-', '_' (no '__')")
+			return
+		}
+
+		room := db.Room{Slug: slug, Recording: db.RecordingOff}
+		if !req.apply(c, &room) {
+			return
+		}
+
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+		room.TenantID = tenantID
+
+		if err := db.CreateRoom(ctx, dbConn, &room); err != nil {
+			if errors.Is(err, db.ErrRoomExists) {
+				apierr.Write(c, apierr.RoomExists)
+				return
+			}
+			logging.FromContext(ctx).Error("create room", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		logging.FromContext(ctx).Info("room created", "room", room.Slug, "tenant_id", room.TenantID)
+
+		c.JSON(http.StatusCreated, gin.H{"room": room})
+	}
+}
+
+// AdminRoom — GET /api/admin/rooms/:slug
+func AdminRoom(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		room, ok := adminRoom(c, dbConn)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"room": room})
+	}
+}
+
+// AdminUpdateRoom — PATCH /api/admin/rooms/:slug (только переданные поля)
+func AdminUpdateRoom(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req RoomRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+		if req.Slug != "" && req.Slug != c.Param("slug") {
+			apierr.WriteReason(c, apierr.InvalidRequest, "slug cannot be changed: lessons and classes refer to it")
+			return
+		}
+
+		room, ok := adminRoom(c, dbConn)
+		if !ok {
+			return
+		}
+		if !req.apply(c, room) {
+			return
+		}
+
+		if err := db.UpdateRoom(ctx, dbConn, room); err != nil {
+			if errors.Is(err, db.ErrRoomNotFound) {
+				apierr.Write(c, apierr.RoomNotFound)
+				return
+			}
+			logging.FromContext(ctx).Error("update room", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"room": room})
+	}
+}
+
+// AdminDeleteRoom — DELETE /api/admin/rooms/:slug
+// Идущий урок не прерывается; с ROOMS_ADHOC=false в комнату больше не войти.
+func AdminDeleteRoom(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+		slug := c.Param("slug")
+
+		if err := db.DeleteRoom(ctx, dbConn, tenantID, slug); err != nil {
+			if errors.Is(err, db.ErrRoomNotFound) {
+				apierr.Write(c, apierr.RoomNotFound)
+				return
+			}
+			logging.FromContext(ctx).Error("delete room", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		logging.FromContext(ctx).Info("room deleted", "room", slug, "tenant_id", tenantID)
+
+		c.JSON(http.StatusOK, gin.H{"deleted": true})
+	}
+}
+
+// adminRoom — комната из :slug, доступная этому админу
+func adminRoom(c *gin.Context, dbConn *sql.DB) (*db.Room, bool) {
+	ctx := c.Request.Context()
+
+	tenantID, ok := adminWriteTenantID(c, dbConn)
+	if !ok {
+		return nil, false
+	}
+
+	room, err := db.GetRoom(ctx, dbConn, tenantID, c.Param("slug"))
+	if err != nil {
+		if errors.Is(err, db.ErrRoomNotFound) {
+			apierr.Write(c, apierr.RoomNotFound)
+			return nil, false
+		}
+		logging.FromContext(ctx).Error("get room", "error", err)
+		apierr.Write(c, apierr.DBError)
+		return nil, false
+	}
+	return room, true
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/middleware"
+)
+
+// maxRosterCSV — больше 1 МБ списков класса не бывает
+const maxRosterCSV = 1 << 20
+
+// =======================
+// Classes
+// =======================
+
+// AdminClasses — GET /api/admin/classes
+func AdminClasses(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+
+		list, err := db.ListClasses(ctx, dbConn, tenantID)
+		if err != nil {
+			logging.FromContext(ctx).Error("list classes", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"classes": list})
+	}
+}
+
+type CreateClassRequest struct {
+	Name           string `json:"name"`
+	GuestAllowance int    `json:"guest_allowance"`
+}
+
+// AdminCreateClass — POST /api/admin/classes
+func AdminCreateClass(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req CreateClassRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || req.GuestAllowance < 0 {
+			apierr.WriteReason(c, apierr.InvalidRequest, "name is required and guest_allowance must be >= 0")
+			return
+		}
+
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+
+		class, err := db.CreateClass(ctx, dbConn, tenantID, req.Name, req.GuestAllowance)
+		if err != nil {
+			logging.FromContext(ctx).Error("create class", "error", err)
+			apierr.Write(c, apierr.ClassCreateFailed)
+			return
+		}
+
+		c.JSON(http.StatusCreated, class)
+	}
+}
+
+type UpdateClassRequest struct {
+	GuestAllowance int `json:"guest_allowance"`
+}
+
+// AdminUpdateClass — PATCH /api/admin/classes/:id (гостевые места)
+func AdminUpdateClass(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req UpdateClassRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.GuestAllowance < 0 {
+			apierr.WriteReason(c, apierr.InvalidRequest, "guest_allowance must be >= 0")
+			return
+		}
+
+		class, ok := adminClass(c, dbConn)
+		if !ok {
+			return
+		}
+
+		if err := db.SetGuestAllowance(ctx, dbConn, class.TenantID, class.ID, req.GuestAllowance); err != nil {
+			logging.FromContext(ctx).Error("update class", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		class.GuestAllowance = req.GuestAllowance
+		c.JSON(http.StatusOK, class)
+	}
+}
+
+// AdminLinkRoom — PUT /api/admin/classes/:id/rooms/:room
+func AdminLinkRoom(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		class, ok := adminClass(c, dbConn)
+		if !ok {
+			return
+		}
+		room := strings.TrimSpace(c.Param("room"))
+		if !roomNameOK(room) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "room must not contain '__'")
+			return
+		}
+
+		if err := db.LinkRoom(ctx, dbConn, class.TenantID, class.ID, room); err != nil {
+			logging.FromContext(ctx).Error("link room", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"class_id": class.ID, "room": room})
+	}
+}
+
+// AdminUnlinkRoom — DELETE /api/admin/classes/:id/rooms/:room
+func AdminUnlinkRoom(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		class, ok := adminClass(c, dbConn)
+		if !ok {
+			return
+		}
+		room := strings.TrimSpace(c.Param("room"))
+		if !roomNameOK(room) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "room must not contain '__'")
+			return
+		}
+
+		if err := db.UnlinkRoom(ctx, dbConn, class.TenantID, class.ID, room); err != nil {
+			logging.FromContext(ctx).Error("unlink room", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// =======================
+// Roster
+// =======================
+
+// AdminRoster — GET /api/admin/classes/:id/roster
+func AdminRoster(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		class, ok := adminClass(c, dbConn)
+		if !ok {
+			return
+		}
+
+		list, err := db.ListEnrollments(ctx, dbConn, class.ID)
+		if err != nil {
+			logging.FromContext(ctx).Error("list roster", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"class": class, "students": list})
+	}
+}
+
+type RosterLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// AdminImportRoster — POST /api/admin/classes/:id/roster/import[?replace=true]
+//
+// CSV (multipart поле "file" или тело text/csv): name[,user_id], заголовок необязателен.
+// replace=true — ученики, которых нет в файле, удаляются из списка;
+// в этом режиме файл с ошибками не применяется целиком.
+func AdminImportRoster(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		class, ok := adminClass(c, dbConn)
+		if !ok {
+			return
+		}
+		replace, _ := strconv.ParseBool(c.Query("replace"))
+
+		body, err := rosterUpload(c)
+		if err != nil {
+			apierr.WriteReason(c, apierr.InvalidCSV, err.Error())
+			return
+		}
+		defer body.Close()
+
+		entries, lineErrs, err := parseRosterCSV(body)
+		if err != nil {
+			// ошибка чтения тела (обрыв, больше maxRosterCSV) — текст не для клиента
+			logging.FromContext(ctx).Warn("roster csv read", "error", err)
+			apierr.WriteReason(c, apierr.InvalidCSV, "file could not be read (too large or upload interrupted)")
+			return
+		}
+		if replace && len(lineErrs) > 0 {
+			// ничего не заменено: список строк с ошибками — в details
+			apierr.WriteDetails(c, apierr.InvalidCSV, gin.H{"errors": lineErrs})
+			return
+		}
+
+		n, err := db.ImportRoster(ctx, dbConn, class.ID, entries, replace)
+		if err != nil {
+			logging.FromContext(ctx).Error("import roster", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		logging.FromContext(ctx).Info("roster imported",
+			"class_id", class.ID,
+			"imported", n,
+			"skipped", len(lineErrs),
+			"replace", replace,
+		)
+
+		c.JSON(http.StatusOK, gin.H{
+			"class_id": class.ID,
+			"imported": n,
+			"skipped":  len(lineErrs),
+			"errors":   lineErrs,
+		})
+	}
+}
+
+type RosterOverrideRequest struct {
+	Name  string `json:"name"`
+	Hours int    `json:"hours"` // 0 => 24
+}
+
+// AdminRosterOverride — POST /api/admin/classes/:id/overrides:
+// пустить ученика не из списка на ограниченное время
+func AdminRosterOverride(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req RosterOverrideRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Hours == 0 {
+			req.Hours = 24
+		}
+		if req.Name == "" || req.Hours < 0 || req.Hours > 24*30 {
+			apierr.WriteReason(c, apierr.InvalidRequest, "name is required, hours must be 1..720")
+			return
+		}
+
+		class, ok := adminClass(c, dbConn)
+		if !ok {
+			return
+		}
+
+		actor, _, _ := c.Request.BasicAuth()
+		until := time.Now().Add(time.Duration(req.Hours) * time.Hour)
+		if err := db.AddRosterOverride(ctx, dbConn, class.ID, req.Name, actor, until); err != nil {
+			logging.FromContext(ctx).Error("roster override", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"class_id":   class.ID,
+			"name":       req.Name,
+			"expires_at": until.UTC(),
+		})
+	}
+}
+
+// =======================
+// Helpers
+// =======================
+
+// adminWriteTenantID — школа для изменений: своя у админа школы,
+// ?tenant=<slug> или школа по умолчанию у супер-админа.
+func adminWriteTenantID(c *gin.Context, dbConn *sql.DB) (int64, bool) {
+	id, ok := adminTenantID(c, dbConn)
+	if ok && id == 0 {
+		id = db.DefaultTenantID
+	}
+	return id, ok
+}
+
+// adminClass — класс из :id, доступный этому админу
+func adminClass(c *gin.Context, dbConn *sql.DB) (*db.Class, bool) {
+	ctx := c.Request.Context()
+
+	classID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.WriteReason(c, apierr.InvalidRequest, "invalid class id")
+		return nil, false
+	}
+
+	tenantID, ok := adminWriteTenantID(c, dbConn)
+	if !ok {
+		return nil, false
+	}
+
+	class, err := db.GetClass(ctx, dbConn, tenantID, classID)
+	if err != nil {
+		if errors.Is(err, db.ErrClassNotFound) {
+			apierr.Write(c, apierr.ClassNotFound)
+			return nil, false
+		}
+		logging.FromContext(ctx).Error("get class", "error", err)
+		apierr.Write(c, apierr.DBError)
+		return nil, false
+	}
+
+	middleware.LogWith(c, "class_id", class.ID)
+	return class, true
+}
+
+// rosterUpload — CSV из multipart "file" или из тела запроса
+func rosterUpload(c *gin.Context) (io.ReadCloser, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRosterCSV)
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, errors.New(`multipart field "file" is required`)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, errors.New("uploaded file could not be read")
+		}
+		return f, nil
+	}
+	return c.Request.Body, nil
+}
+
+// parseRosterCSV: name[,user_id]. Ошибки строк не прерывают разбор —
+// они возвращаются списком, чтобы админ исправил файл.
+func parseRosterCSV(r io.Reader) ([]db.Enrollment, []RosterLineError, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	entries := []db.Enrollment{}
+	lineErrs := []RosterLineError{}
+	seen := map[string]int{}
+
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				lineErrs = append(lineErrs, RosterLineError{Line: pe.Line, Error: pe.Err.Error()})
+				continue
+			}
+			return nil, nil, err
+		}
+
+		if len(rec) == 0 {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		// Excel сохраняет UTF-8 CSV с BOM
+		name := strings.TrimSpace(strings.TrimPrefix(rec[0], "\ufeff"))
+
+		// заголовок
+		if first {
+			switch strings.ToLower(name) {
+			case "name", "student", "student_name":
+				continue
+			}
+		}
+
+		switch {
+		case name == "":
+			lineErrs = append(lineErrs, RosterLineError{Line: line, Error: "name is empty"})
+			continue
+		case len(name) > 100:
+			lineErrs = append(lineErrs, RosterLineError{Line: line, Error: "name is longer than 100 characters"})
+			continue
+		}
+		if prev, dup := seen[name]; dup {
+			lineErrs = append(lineErrs, RosterLineError{Line: line, Error: fmt.Sprintf("duplicate of line %d", prev)})
+			continue
+		}
+		seen[name] = line
+
+		e := db.Enrollment{StudentName: name}
+		if len(rec) > 1 {
+			e.UserID = strings.TrimSpace(rec[1])
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) == 0 && len(lineErrs) == 0 {
+		return nil, nil, errors.New("file is empty")
+	}
+	return entries, lineErrs, nil
+}
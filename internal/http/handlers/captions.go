@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/middleware"
+	"streaming/internal/service"
+)
+
+const (
+	// egress шлёт звук непрерывно; при выключенном микрофоне — только {"muted":true}
+	captionIngestIdle   = 5 * time.Minute
+	captionMaxFrameSize = 1 << 20
+)
+
+var captionLanguage = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z]{2,4})?$`)
+
+var captionUpgrader = websocket.Upgrader{
+	ReadBufferSize: 32 << 10,
+	// подключается egress-сервис, не браузер
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+type CaptionsRequest struct {
+	Room       string `json:"room"`
+	TeacherKey string `json:"teacherKey"`
+	Language   string `json:"language"` // optional: CAPTIONS_LANGUAGE
+}
+
+// CaptionsStart — POST /api/v1/captions/start (только учитель): субтитры речи учителя.
+// Egress подписывается на его микрофон; фрагменты идут в комнату (topic "captions").
+func CaptionsStart(
+	nodes *service.LiveKitNodes,
+	teacherKey func() string,
+	dbConn *sql.DB,
+	captions *service.Captions,
+	defaultLanguage string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CaptionsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+		if captions == nil {
+			apierr.Write(c, apierr.CaptionsDisabled)
+			return
+		}
+
+		req.Room = strings.TrimSpace(req.Room)
+		lang := strings.TrimSpace(req.Language)
+		if lang == "" {
+			lang = defaultLanguage
+		}
+		if !roomNameOK(req.Room) || !captionLanguage.MatchString(lang) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "room (without '__') is required; language must look like ru, tk or en-US")
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		if !teacherAllowed(tenant, teacherKey(), strings.TrimSpace(req.TeacherKey)) {
+			apierr.Write(c, apierr.TeacherOnly)
+			return
+		}
+		middleware.LogWith(c, "room", req.Room)
+
+		lesson, node, ok := lessonNode(c, nodes, dbConn, tenant, req.Room)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		s, err := captions.Start(ctx, node, lesson.ID, tenant.LiveKitRoom(req.Room), lesson.Teacher, lang)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrCaptionsRunning):
+				apierr.Write(c, apierr.CaptionsRunning)
+			case errors.Is(err, service.ErrNoAudioTrack):
+				apierr.Write(c, apierr.NoAudioTrack)
+			case errors.Is(err, service.ErrCaptionsNotRunning):
+				// остановили, пока egress запускался
+				apierr.Write(c, apierr.CaptionsNotRunning)
+			default:
+				roomServiceError(c, err)
+			}
+			return
+		}
+
+		logging.FromContext(ctx).Info("captions started", "lesson_id", lesson.ID, "egress_id", s.EgressID, "language", lang)
+
+		c.JSON(http.StatusCreated, gin.H{"captions": s})
+	}
+}
+
+// CaptionsStop — POST /api/v1/captions/stop (только учитель)
+func CaptionsStop(
+	nodes *service.LiveKitNodes,
+	teacherKey func() string,
+	dbConn *sql.DB,
+	captions *service.Captions,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CaptionsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+		if captions == nil {
+			apierr.Write(c, apierr.CaptionsDisabled)
+			return
+		}
+
+		req.Room = strings.TrimSpace(req.Room)
+		if !roomNameOK(req.Room) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "room is required and must not contain '__'")
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		if !teacherAllowed(tenant, teacherKey(), strings.TrimSpace(req.TeacherKey)) {
+			apierr.Write(c, apierr.TeacherOnly)
+			return
+		}
+		middleware.LogWith(c, "room", req.Room)
+
+		lesson, _, ok := lessonNode(c, nodes, dbConn, tenant, req.Room)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		if err := captions.Stop(ctx, lesson.ID); err != nil {
+			if errors.Is(err, service.ErrCaptionsNotRunning) {
+				apierr.Write(c, apierr.CaptionsNotRunning)
+				return
+			}
+			roomServiceError(c, err)
+			return
+		}
+
+		logging.FromContext(ctx).Info("captions stopped", "lesson_id", lesson.ID)
+
+		c.JSON(http.StatusOK, gin.H{"stopped": true})
+	}
+}
+
+// CaptionsIngest — GET /api/captions/ingest/:session (websocket от LiveKit Egress).
+// Без API ключа: id сессии — одноразовый секрет, выданный egress'у в CaptionsStart.
+func CaptionsIngest(captions *service.Captions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if captions == nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		id := c.Param("session")
+		if !captions.Pending(id) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		conn, err := captionUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// ответ уже отправил Upgrade
+			return
+		}
+		defer conn.Close()
+
+		conn.SetReadLimit(captionMaxFrameSize)
+		// дедлайны http.Server (WriteTimeout) на долгое соединение не распространяем
+		_ = conn.NetConn().SetWriteDeadline(time.Time{})
+
+		ctx := c.Request.Context()
+		if err := captions.Ingest(ctx, id, &egressAudio{conn: conn}); err != nil && !errors.Is(err, service.ErrCaptionSession) {
+			logging.FromContext(ctx).Warn("captions ingest ended with error", "error", err)
+		}
+	}
+}
+
+// LessonTranscript — GET /api/v1/lessons/:id/transcript[?format=vtt|txt]
+// Расшифровка для участников урока (учитель — X-Teacher-Key, ученик — X-LiveKit-Token).
+func LessonTranscript(nodes *service.LiveKitNodes, teacherKey func() string, dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "vtt" && format != "txt" {
+			apierr.WriteReason(c, apierr.InvalidRequest, "format must be json, vtt or txt")
+			return
+		}
+
+		lesson, _, ok := lessonMember(c, nodes, teacherKey, dbConn)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		segments, err := db.LessonTranscript(ctx, dbConn, lesson.ID)
+		if err != nil {
+			logging.FromContext(ctx).Error("load transcript", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		if format == "json" {
+			c.JSON(http.StatusOK, gin.H{
+				"lesson_id":  lesson.ID,
+				"started_at": lesson.StartedAt,
+				"segments":   segments,
+			})
+			return
+		}
+
+		var b strings.Builder
+		filename := fmt.Sprintf("transcript-lesson-%d.%s", lesson.ID, format)
+		contentType := "text/plain; charset=utf-8"
+
+		if format == "vtt" {
+			contentType = "text/vtt; charset=utf-8"
+			b.WriteString("WEBVTT\n")
+			for i, s := range segments {
+				fmt.Fprintf(&b, "\n%d\n%s --> %s\n<v %s>%s\n",
+					i+1,
+					vttTime(s.StartedAt.Sub(lesson.StartedAt)),
+					vttTime(s.EndedAt.Sub(lesson.StartedAt)),
+					vttEscape(s.Speaker), vttEscape(s.Text),
+				)
+			}
+		} else {
+			for _, s := range segments {
+				fmt.Fprintf(&b, "[%s] %s: %s\n", s.StartedAt.Format("15:04:05"), s.Speaker, s.Text)
+			}
+		}
+
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Data(http.StatusOK, contentType, []byte(b.String()))
+	}
+}
+
+// =======================
+// Helpers
+// =======================
+
+// egressAudio — websocket egress'а как источник звука:
+// бинарные сообщения — PCM, текстовые ({"muted":...}) пропускаем
+type egressAudio struct {
+	conn *websocket.Conn
+}
+
+func (a *egressAudio) ReadAudio() ([]byte, error) {
+	for {
+		_ = a.conn.SetReadDeadline(time.Now().Add(captionIngestIdle))
+
+		kind, data, err := a.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		if kind == websocket.BinaryMessage && len(data) > 0 {
+			return data, nil
+		}
+	}
+}
+
+// vttTime — 00:01:02.345 (фрагменты до начала урока — с нуля)
+func vttTime(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// vttEscape — в тексте реплики нельзя "<", "&" и "-->"
+func vttEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\n", " ").Replace(s)
+}
@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/metrics"
+	"streaming/internal/middleware"
+	"streaming/internal/service"
+	"streaming/internal/storage"
+)
+
+const maxFilenameLen = 200
+
+const (
+	// медленный, но живой клиент: ~1 Мбит/с (школьный Wi-Fi, мобильный интернет)
+	minTransferRate = 128 << 10 // байт/с
+	transferSlack   = time.Minute
+)
+
+var (
+	errNoUploadFile  = errors.New("multipart field \"file\" is required")
+	errUploadTooBig  = errors.New("file is too large")
+	errUploadIsEmpty = errors.New("file is empty")
+)
+
+// LessonFileUpload — POST /api/v1/lessons/:id/files (multipart, поле "file").
+// Учитель (X-Teacher-Key или свой токен) выкладывает материалы, ученик — домашнее задание.
+// Тип определяется по содержимому, а не по имени/заголовку клиента.
+func LessonFileUpload(
+	nodes *service.LiveKitNodes,
+	teacherKey func() string,
+	dbConn *sql.DB,
+	files storage.Storage,
+	scanner storage.Scanner,
+	policy func() storage.Policy,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lesson, who, ok := lessonMember(c, nodes, teacherKey, dbConn)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		tenant := middleware.GetTenant(c)
+		p := policy()
+
+		// ---------- RECEIVE ----------
+		extendDeadlines(c, p.MaxSize)
+		// запас 1 MB на заголовки multipart и прочие поля
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, p.MaxSize+1<<20)
+
+		up, err := receiveUpload(c.Request, p.MaxSize)
+		if up != nil {
+			defer up.remove()
+		}
+		if err != nil {
+			var tooBig *http.MaxBytesError
+			switch {
+			case errors.Is(err, errUploadTooBig) || errors.As(err, &tooBig):
+				metrics.Uploads.WithLabelValues("too_large").Inc()
+				apierr.WriteDetails(c, apierr.FileTooLarge, gin.H{"max_mb": p.MaxSize >> 20})
+			case errors.Is(err, errNoUploadFile) || errors.Is(err, errUploadIsEmpty):
+				metrics.Uploads.WithLabelValues("invalid").Inc()
+				apierr.WriteReason(c, apierr.InvalidRequest, err.Error())
+			default:
+				metrics.Uploads.WithLabelValues("invalid").Inc()
+				apierr.WriteReason(c, apierr.InvalidRequest, "invalid multipart body")
+			}
+			return
+		}
+
+		// ---------- TYPE ----------
+		if _, err := up.file.Seek(0, io.SeekStart); err != nil {
+			uploadFailed(c, err)
+			return
+		}
+		mt, err := mimetype.DetectReader(up.file)
+		if err != nil {
+			uploadFailed(c, err)
+			return
+		}
+		contentType := mt.String()
+		if !p.Allowed(contentType) {
+			metrics.Uploads.WithLabelValues("type_rejected").Inc()
+			apierr.WriteDetails(c, apierr.FileTypeNotAllowed, gin.H{"type": contentType})
+			return
+		}
+
+		// ---------- SCAN ----------
+		if _, err := up.file.Seek(0, io.SeekStart); err != nil {
+			uploadFailed(c, err)
+			return
+		}
+		verdict, err := scanner.Scan(ctx, up.file)
+		if err != nil {
+			// не смогли проверить — не принимаем
+			metrics.Uploads.WithLabelValues("scan_error").Inc()
+			logging.FromContext(ctx).Error("virus scan failed", "error", err)
+			apierr.Write(c, apierr.ScanUnavailable)
+			return
+		}
+		if verdict.Status == storage.ScanInfected {
+			metrics.Uploads.WithLabelValues("infected").Inc()
+			logging.FromContext(ctx).Warn("infected upload rejected",
+				"lesson_id", lesson.ID,
+				"uploader", who.identity,
+				"threat", verdict.Threat,
+			)
+			apierr.Write(c, apierr.FileInfected)
+			return
+		}
+
+		// ---------- STORE ----------
+		if _, err := up.file.Seek(0, io.SeekStart); err != nil {
+			uploadFailed(c, err)
+			return
+		}
+		key := fmt.Sprintf("t%d/l%d/%s", tenant.ID, lesson.ID, uuid.NewString())
+		if err := files.Put(ctx, key, up.file, up.size, contentType); err != nil {
+			metrics.Uploads.WithLabelValues("storage_error").Inc()
+			logging.FromContext(ctx).Error("store upload", "error", err)
+			apierr.Write(c, apierr.StorageUnavailable)
+			return
+		}
+
+		f := db.LessonFile{
+			LessonID:     lesson.ID,
+			Uploader:     who.identity,
+			UploaderRole: who.role,
+			Kind:         "homework",
+			Filename:     up.name,
+			ContentType:  contentType,
+			Size:         up.size,
+			SHA256:       up.sha256,
+			StorageKey:   key,
+			ScanStatus:   verdict.Status,
+		}
+		if who.role == "teacher" {
+			f.Kind = "material"
+		}
+		if err := db.CreateLessonFile(ctx, dbConn, &f); err != nil {
+			// без записи объект никто не найдёт — убираем
+			if derr := files.Delete(ctx, key); derr != nil {
+				logging.FromContext(ctx).Warn("delete orphaned upload", "key", key, "error", derr)
+			}
+			metrics.Uploads.WithLabelValues("db_error").Inc()
+			logging.FromContext(ctx).Error("create lesson file", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		metrics.Uploads.WithLabelValues("ok").Inc()
+		metrics.UploadBytes.Add(float64(f.Size))
+		logging.FromContext(ctx).Info("file uploaded",
+			"lesson_id", lesson.ID,
+			"file_id", f.ID,
+			"kind", f.Kind,
+			"size", f.Size,
+			"content_type", f.ContentType,
+		)
+
+		c.JSON(http.StatusCreated, f)
+	}
+}
+
+// LessonFiles — GET /api/v1/lessons/:id/files
+// Учитель видит всё; ученик — материалы учителя и свои работы.
+func LessonFiles(nodes *service.LiveKitNodes, teacherKey func() string, dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lesson, who, ok := lessonMember(c, nodes, teacherKey, dbConn)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		uploader := who.identity
+		if who.role == "teacher" {
+			uploader = ""
+		}
+
+		list, err := db.ListLessonFiles(ctx, dbConn, lesson.ID, uploader)
+		if err != nil {
+			logging.FromContext(ctx).Error("list lesson files", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"lesson_id": lesson.ID,
+			"files":     list,
+		})
+	}
+}
+
+// LessonFileDownload — GET /api/v1/lessons/:id/files/:file_id
+func LessonFileDownload(
+	nodes *service.LiveKitNodes,
+	teacherKey func() string,
+	dbConn *sql.DB,
+	files storage.Storage,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lesson, who, ok := lessonMember(c, nodes, teacherKey, dbConn)
+		if !ok {
+			return
+		}
+		f, ok := visibleLessonFile(c, dbConn, lesson.ID, who)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		rc, err := files.Open(ctx, f.StorageKey)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				logging.FromContext(ctx).Error("lesson file missing in storage", "file_id", f.ID, "key", f.StorageKey)
+				apierr.Write(c, apierr.FileNotFound)
+				return
+			}
+			logging.FromContext(ctx).Error("open lesson file", "error", err)
+			apierr.Write(c, apierr.StorageUnavailable)
+			return
+		}
+		defer rc.Close()
+
+		extendDeadlines(c, f.Size)
+		// файлы пользователей: браузер не должен угадывать тип и исполнять их на нашем домене
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Cache-Control", "private, no-cache")
+		c.DataFromReader(http.StatusOK, f.Size, f.ContentType, rc, map[string]string{
+			"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": f.Filename}),
+		})
+	}
+}
+
+// LessonFileDelete — DELETE /api/v1/lessons/:id/files/:file_id (автор или учитель)
+func LessonFileDelete(
+	nodes *service.LiveKitNodes,
+	teacherKey func() string,
+	dbConn *sql.DB,
+	files storage.Storage,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lesson, who, ok := lessonMember(c, nodes, teacherKey, dbConn)
+		if !ok {
+			return
+		}
+		f, ok := visibleLessonFile(c, dbConn, lesson.ID, who)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		if who.role != "teacher" && f.Uploader != who.identity {
+			apierr.Write(c, apierr.FileForbidden)
+			return
+		}
+
+		if err := db.DeleteLessonFile(ctx, dbConn, lesson.ID, f.ID); err != nil {
+			if errors.Is(err, db.ErrFileNotFound) {
+				apierr.Write(c, apierr.FileNotFound)
+				return
+			}
+			logging.FromContext(ctx).Error("delete lesson file", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+		// запись уже скрыта; объект, который не удалось стереть, — только мусор в хранилище
+		if err := files.Delete(ctx, f.StorageKey); err != nil {
+			logging.FromContext(ctx).Warn("delete lesson file object", "key", f.StorageKey, "error", err)
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// =======================
+// Helpers
+// =======================
+
+// extendDeadlines — HTTP_READ_TIMEOUT / HTTP_WRITE_TIMEOUT рассчитаны на JSON;
+// файл в size байт на медленном канале идёт дольше — дедлайны соединения по размеру
+func extendDeadlines(c *gin.Context, size int64) {
+	deadline := time.Now().Add(transferSlack + time.Duration(size/minTransferRate)*time.Second)
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("extend read deadline", "error", err)
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("extend write deadline", "error", err)
+	}
+}
+
+// lessonMember — урок из :id и кто пришёл (файлы, расшифровка): учитель по X-Teacher-Key
+// или участник урока по LiveKit токену (X-LiveKit-Token) этой комнаты.
+// Урок может быть уже закончен — домашние задания сдают после него.
+func lessonMember(
+	c *gin.Context,
+	nodes *service.LiveKitNodes,
+	teacherKey func() string,
+	dbConn *sql.DB,
+) (*db.Lesson, roomUser, bool) {
+	ctx := c.Request.Context()
+
+	lessonID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.WriteReason(c, apierr.InvalidRequest, "invalid lesson id")
+		return nil, roomUser{}, false
+	}
+
+	tenant := middleware.GetTenant(c)
+	middleware.LogWith(c, "lesson_id", lessonID)
+
+	lesson, err := db.GetLesson(ctx, dbConn, tenant.ID, lessonID)
+	if err != nil {
+		if errors.Is(err, db.ErrLessonNotFound) {
+			apierr.Write(c, apierr.LessonNotFound)
+			return nil, roomUser{}, false
+		}
+		logging.FromContext(ctx).Error("get lesson", "error", err)
+		apierr.Write(c, apierr.DBError)
+		return nil, roomUser{}, false
+	}
+
+	if teacherAllowed(tenant, teacherKey(), strings.TrimSpace(c.GetHeader(middleware.TeacherHeader))) {
+		return lesson, roomUser{identity: lesson.Teacher, role: "teacher"}, true
+	}
+
+	node, ok := nodes.Get(lesson.LiveKitNode)
+	if !ok {
+		logging.FromContext(ctx).Error("lesson pinned to unknown livekit node", "livekit_node", lesson.LiveKitNode)
+		apierr.Write(c, apierr.LiveKitNodeUnavailable)
+		return nil, roomUser{}, false
+	}
+	who, ok := roomParticipant(c, node, tenant, lesson.Room, c.GetHeader(LiveKitTokenHeader))
+	if !ok {
+		return nil, roomUser{}, false
+	}
+
+	// токен выдан в комнату, а урок в ней мог быть другой — нужен именно этот урок
+	if _, err := db.GetParticipant(ctx, dbConn, tenant.ID, lesson.ID, who.identity); err != nil {
+		if !errors.Is(err, db.ErrParticipantNotFound) {
+			logging.FromContext(ctx).Error("get participant failed", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return nil, roomUser{}, false
+		}
+		apierr.Write(c, apierr.NotAParticipant)
+		return nil, roomUser{}, false
+	}
+
+	return lesson, who, true
+}
+
+// visibleLessonFile — файл из :file_id, если пришедший может его видеть
+// (чужие домашние задания для ученика — "не найден")
+func visibleLessonFile(c *gin.Context, dbConn *sql.DB, lessonID int64, who roomUser) (*db.LessonFile, bool) {
+	ctx := c.Request.Context()
+
+	fileID, err := strconv.ParseInt(c.Param("file_id"), 10, 64)
+	if err != nil {
+		apierr.WriteReason(c, apierr.InvalidRequest, "invalid file id")
+		return nil, false
+	}
+
+	f, err := db.GetLessonFile(ctx, dbConn, lessonID, fileID)
+	if err == nil && who.role != "teacher" && f.Kind != "material" && f.Uploader != who.identity {
+		err = db.ErrFileNotFound
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrFileNotFound) {
+			apierr.Write(c, apierr.FileNotFound)
+			return nil, false
+		}
+		logging.FromContext(ctx).Error("get lesson file", "error", err)
+		apierr.Write(c, apierr.DBError)
+		return nil, false
+	}
+	return f, true
+}
+
+// receivedUpload — файл из multipart во временном файле (размер и sha256 посчитаны по пути)
+type receivedUpload struct {
+	file   *os.File
+	name   string
+	size   int64
+	sha256 string
+}
+
+func (u *receivedUpload) remove() {
+	u.file.Close()
+	os.Remove(u.file.Name())
+}
+
+// receiveUpload — читает multipart потоком (без ParseMultipartForm и буфера в памяти)
+// до поля "file"; остальные поля пропускает.
+func receiveUpload(r *http.Request, maxSize int64) (*receivedUpload, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errNoUploadFile
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		tmp, err := os.CreateTemp("", "upload-*")
+		if err != nil {
+			return nil, err
+		}
+		up := &receivedUpload{file: tmp, name: cleanFilename(part.FileName())}
+
+		h := sha256.New()
+		n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(part, maxSize+1))
+		part.Close()
+		switch {
+		case err != nil:
+			return up, err
+		case n > maxSize:
+			return up, errUploadTooBig
+		case n == 0:
+			return up, errUploadIsEmpty
+		}
+
+		up.size = n
+		up.sha256 = hex.EncodeToString(h.Sum(nil))
+		return up, nil
+	}
+}
+
+// cleanFilename — имя для списка и Content-Disposition: без пути и управляющих символов
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+
+	if name == "" || name == "." || name == "/" || name == ".." {
+		return "file"
+	}
+	if r := []rune(name); len(r) > maxFilenameLen {
+		name = string(r[:maxFilenameLen])
+	}
+	return name
+}
+
+func uploadFailed(c *gin.Context, err error) {
+	metrics.Uploads.WithLabelValues("error").Inc()
+	logging.FromContext(c.Request.Context()).Error("process upload", "error", err)
+	apierr.Write(c, apierr.UploadFailed)
+}
@@ -1,34 +1,59 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"streaming/internal/apierr"
 	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/metrics"
+	"streaming/internal/middleware"
 	"streaming/internal/service"
 )
 
+// notifyEnqueueTimeout — постановка уведомлений о начале урока в очередь
+const notifyEnqueueTimeout = 10 * time.Second
+
 type LiveKitJoinRequest struct {
 	Room       string `json:"room"`
 	Name       string `json:"name"`
 	Role       string `json:"role"`       // teacher | student
 	TeacherKey string `json:"teacherKey"` // optional
+	Region     string `json:"region"`     // optional: подсказка для выбора LiveKit узла
+
+	// optional: попадают в participant.metadata
+	UserID    string `json:"userId"`
+	AvatarURL string `json:"avatarUrl"`
+	Locale    string `json:"locale"`
+
+	// optional, только учитель: room.metadata урока
+	Title          string     `json:"title"`
+	ScheduledStart *time.Time `json:"scheduledStart"`
+	ScheduledEnd   *time.Time `json:"scheduledEnd"`
 }
 
 func LiveKitJoin(
-	lk *service.LiveKitService,
-	teacherKey string,
+	nodes *service.LiveKitNodes,
+	teacherKey func() string, // перечитывается по SIGHUP
+	tokenTTL func(role string) time.Duration,
+	adHocRoom func() *db.Room, // ROOMS_ADHOC / ROOMS_MAX_*: nil => выключено; перечитывается по SIGHUP
 	dbConn *sql.DB,
+	notifier *service.Notifier, // nil => уведомления выключены
 ) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		var req LiveKitJoinRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			apierr.BadRequest(c, "INVALID_JSON", "invalid JSON body")
+			metrics.JoinRequests.WithLabelValues("invalid_request", "unknown").Inc()
+			apierr.Write(c, apierr.InvalidJSON)
 			return
 		}
 
@@ -37,9 +62,11 @@ func LiveKitJoin(
 		req.Name = strings.TrimSpace(req.Name)
 		req.Role = strings.ToLower(strings.TrimSpace(req.Role))
 		req.TeacherKey = strings.TrimSpace(req.TeacherKey)
+		req.Title = strings.TrimSpace(req.Title)
 
-		if req.Room == "" || req.Name == "" {
-			apierr.BadRequest(c, "INVALID_REQUEST", "room and name are required")
+		if !roomNameOK(req.Room) || req.Name == "" {
+			metrics.JoinRequests.WithLabelValues("invalid_request", "unknown").Inc()
+			apierr.WriteReason(c, apierr.InvalidRequest, "room (without '__') and name are required")
 			return
 		}
 
@@ -50,50 +77,318 @@ func LiveKitJoin(
 			req.Role = "student"
 		}
 
+		tenant := middleware.GetTenant(c)
+
 		// ---------- ROLE CHECK ----------
-		if req.Role == "teacher" {
-			if teacherKey == "" || req.TeacherKey != teacherKey {
-				req.Role = "student"
+		if req.Role == "teacher" && !teacherAllowed(tenant, teacherKey(), req.TeacherKey) {
+			req.Role = "student"
+		}
+
+		middleware.LogWith(c, "room", req.Room, "identity", req.Name, "role", req.Role)
+		ctx := c.Request.Context()
+
+		// ---------- ROOM ----------
+		// комнаты нет в rooms: опечатка в ссылке или комната "на лету" (ROOMS_ADHOC)
+		room, err := db.GetRoom(ctx, dbConn, tenant.ID, req.Room)
+		if err != nil {
+			if !errors.Is(err, db.ErrRoomNotFound) {
+				metrics.JoinRequests.WithLabelValues("room_error", req.Role).Inc()
+				logging.FromContext(ctx).Error("get room failed", "error", err)
+				apierr.Write(c, apierr.DBError)
+				return
+			}
+			if room = adHocRoom(); room == nil {
+				metrics.JoinRequests.WithLabelValues("unknown_room", req.Role).Inc()
+				logging.FromContext(ctx).Info("join rejected: unknown room")
+				apierr.Write(c, apierr.RoomNotFound)
+				return
 			}
 		}
+		capacity := room.Capacity()
+		if req.Title == "" {
+			req.Title = room.Title
+		}
 
 		// ---------- LESSON LOGIC ----------
 		var lessonID int64
+		var node *service.LiveKitNode
 		if req.Role == "teacher" {
-			id, err := db.StartLesson(dbConn, req.Room, req.Name)
+			n, err := pinNode(ctx, nodes, dbConn, tenant.ID, req.Room, req.Region)
 			if err != nil {
-				apierr.Internal(c, "LESSON_START_FAILED", err.Error())
+				if errors.Is(err, service.ErrNoCapacity) {
+					metrics.JoinRequests.WithLabelValues("no_capacity", req.Role).Inc()
+					apierr.Write(c, apierr.LiveKitNoCapacity)
+					return
+				}
+				metrics.JoinRequests.WithLabelValues("lesson_start_failed", req.Role).Inc()
+				logging.FromContext(ctx).Error("livekit placement failed", "error", err)
+				apierr.Write(c, apierr.LessonStartFailed)
 				return
 			}
+			node = n
+
+			// переподключение учителя — тот же урок: ученики остаются в нём, биллинг не дробится
+			lesson, resumed, err := db.StartLesson(ctx, dbConn, tenant.ID, req.Room, req.Name, node.ID, capacity)
+			if err != nil {
+				if errors.Is(err, db.ErrRoomFull) {
+					roomFull(c, req.Role, err)
+					return
+				}
+				metrics.JoinRequests.WithLabelValues("lesson_start_failed", req.Role).Inc()
+				logging.FromContext(ctx).Error("start lesson failed", "error", err)
+				apierr.Write(c, apierr.LessonStartFailed)
+				return
+			}
+			id := lesson.ID
 			lessonID = id
+			if n, ok := nodes.Get(lesson.LiveKitNode); ok {
+				node = n
+			}
+			if resumed {
+				middleware.LogWith(c, "resumed", true)
+				ctx = c.Request.Context()
+			}
+
+			// ---------- NOTIFY STUDENTS ----------
+			// очередь пишем вне запроса: вход учителя не ждёт рассылки; урок продолжается — не пишем
+			if notifier != nil && !resumed {
+				log := logging.FromContext(ctx)
+				nctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyEnqueueTimeout)
+				go func() {
+					defer cancel()
+					n, err := notifier.LessonStarted(nctx, tenant.ID, id, req.Room, req.Title, req.Name)
+					if err != nil {
+						log.Error("lesson started notifications not queued", "error", err)
+						return
+					}
+					if n > 0 {
+						log.Info("lesson started notifications queued", "count", n)
+					}
+				}()
+			}
+
+			// ---------- ROOM METADATA ----------
+			// комнату создаём заранее с данными урока; LiveKit недоступен — урок всё равно идёт
+			roomMD := service.RoomMetadata{
+				Title:          req.Title,
+				Teacher:        req.Name,
+				LessonID:       id,
+				Tenant:         tenant.Slug,
+				StartedAt:      lesson.StartedAt.UTC(),
+				ScheduledStart: req.ScheduledStart,
+				ScheduledEnd:   req.ScheduledEnd,
+
+				MaxParticipants: capacity.LiveKitMax(),
+				MaxStudents:     capacity.Students,
+				MaxTeachers:     capacity.Teachers,
+				WaitingRoom:     room.WaitingRoom,
+				Recording:       room.Recording,
+			}
+			if err := node.CreateRoom(ctx, tenant.LiveKitRoom(req.Room), roomMD); err != nil {
+				logging.FromContext(ctx).Warn("livekit room metadata not set", "error", err)
+			}
+			if req.ScheduledStart != nil || req.ScheduledEnd != nil {
+				if err := db.SetLessonSchedule(ctx, dbConn, tenant.ID, id, req.ScheduledStart, req.ScheduledEnd); err != nil {
+					logging.FromContext(ctx).Warn("lesson schedule not saved", "error", err)
+				}
+			}
 		} else {
-			id, err := db.GetActiveLesson(dbConn, req.Room)
+			lesson, err := db.GetActiveLesson(ctx, dbConn, tenant.ID, req.Room)
 			if err != nil {
-				apierr.BadRequest(c, "NO_ACTIVE_LESSON", "lesson not started yet")
+				if !errors.Is(err, db.ErrNoActiveLesson) {
+					logging.FromContext(ctx).Error("get active lesson failed", "error", err)
+				}
+				metrics.JoinRequests.WithLabelValues("no_active_lesson", req.Role).Inc()
+				apierr.Write(c, apierr.NoActiveLesson)
 				return
 			}
-			lessonID = id
+			lessonID = lesson.ID
+
+			// ✅ kick / ban: обратно не пускаем
+			denied, err := db.IsDenied(ctx, dbConn, tenant.ID, lesson.ID, req.Room, req.Name)
+			if err != nil {
+				metrics.JoinRequests.WithLabelValues("denylist_error", req.Role).Inc()
+				logging.FromContext(ctx).Error("denylist check failed", "error", err)
+				apierr.Write(c, apierr.DBError)
+				return
+			}
+			if denied {
+				metrics.JoinRequests.WithLabelValues("denied", req.Role).Inc()
+				logging.FromContext(ctx).Info("join denied")
+				apierr.Write(c, apierr.ParticipantDenied)
+				return
+			}
+
+			// ✅ список класса (если комната привязана к классу); гостю — место по guest_allowance
+			guest, err := rosterGuest(ctx, dbConn, tenant.ID, req.Room, lesson.ID, req.Name)
+			if err != nil {
+				metrics.JoinRequests.WithLabelValues("roster_error", req.Role).Inc()
+				logging.FromContext(ctx).Error("roster check failed", "error", err)
+				apierr.Write(c, apierr.DBError)
+				return
+			}
+			if guest != nil {
+				capacity.Guest = guest
+				middleware.LogWith(c, "guest", true)
+				ctx = c.Request.Context()
+			}
+
+			n, ok := nodes.Get(lesson.LiveKitNode)
+			if !ok {
+				// узел убрали из конфига, а урок ещё открыт
+				metrics.JoinRequests.WithLabelValues("node_unavailable", req.Role).Inc()
+				logging.FromContext(ctx).Error("lesson pinned to unknown livekit node", "livekit_node", lesson.LiveKitNode)
+				apierr.Write(c, apierr.LiveKitNodeUnavailable)
+				return
+			}
+			node = n
 		}
 
+		middleware.LogWith(c, "lesson_id", lessonID, "livekit_node", node.ID)
+		ctx = c.Request.Context()
+
 		// ---------- PARTICIPANT ----------
-		_ = db.JoinParticipant(dbConn, lessonID, req.Name, req.Role)
+		// мест нет — отказ (или очередь). Участник не записан — места не посчитаны,
+		// токен не выдаём: иначе при сбое БД лимиты комнаты не работают
+		if err := db.JoinParticipant(ctx, dbConn, tenant.ID, lessonID, req.Room, req.Name, req.Role, capacity); err != nil {
+			if errors.Is(err, db.ErrRoomFull) {
+				roomFull(c, req.Role, err)
+				return
+			}
+			if errors.Is(err, db.ErrNoGuestSeat) {
+				metrics.JoinRequests.WithLabelValues("not_on_roster", req.Role).Inc()
+				logging.FromContext(ctx).Info("join rejected: not on roster, no guest seats")
+				apierr.Write(c, apierr.NotOnRoster)
+				return
+			}
+			metrics.JoinRequests.WithLabelValues("participant_error", req.Role).Inc()
+			logging.FromContext(ctx).Error("participant not recorded", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
 
 		// ---------- LIVEKIT TOKEN ----------
-		token, err := lk.JoinToken(req.Room, req.Name, req.Name, req.Role)
+		// комната в LiveKit — с префиксом школы, клиенту отдаём оба имени
+		lkRoom := tenant.LiveKitRoom(req.Room)
+		ttl := tokenTTL(req.Role)
+		md := service.ParticipantMetadata{
+			Role:      req.Role,
+			UserID:    strings.TrimSpace(req.UserID),
+			LessonID:  lessonID,
+			AvatarURL: strings.TrimSpace(req.AvatarURL),
+			Locale:    strings.TrimSpace(req.Locale),
+			Tenant:    tenant.Slug,
+		}
+		token, err := node.JoinToken(ctx, lkRoom, req.Name, req.Name, md, ttl)
 		if err != nil {
-			apierr.Internal(c, "LIVEKIT_TOKEN_ERROR", err.Error())
+			metrics.JoinRequests.WithLabelValues("token_error", req.Role).Inc()
+			logging.FromContext(ctx).Error("livekit token failed", "error", err)
+			apierr.Write(c, apierr.LiveKitTokenError)
 			return
 		}
 
-		wsURL := lk.WSURLFromRequestHost(c.Request.Host)
+		wsURL := node.WSURLFromRequestHost(c.Request.Host)
+
+		metrics.JoinRequests.WithLabelValues("ok", req.Role).Inc()
+		logging.FromContext(ctx).Info("participant joined")
 
 		c.JSON(http.StatusOK, gin.H{
-			"room":      req.Room,
-			"name":      req.Name,
-			"role":      req.Role,
-			"lesson_id": lessonID,
-			"token":     token,
-			"wsUrl":     wsURL,
+			"room":         req.Room,
+			"livekit_room": lkRoom,
+			"name":         req.Name,
+			"role":         req.Role,
+			"lesson_id":    lessonID,
+			"token":        token,
+			"expires_at":   time.Now().Add(ttl).UTC(),
+			"wsUrl":        wsURL,
+			"region":       node.Region,
 		})
 	}
 }
+
+// roomFull — ROOM_FULL: 409; в очереди — позиция и когда повторить join
+func roomFull(c *gin.Context, role string, err error) {
+	ctx := c.Request.Context()
+
+	var full *db.RoomFullError
+	if !errors.As(err, &full) {
+		full = &db.RoomFullError{Role: role}
+	}
+
+	if full.Position == 0 {
+		metrics.JoinRequests.WithLabelValues("room_full", role).Inc()
+		logging.FromContext(ctx).Info("join rejected: room full", "limit", full.Limit)
+		apierr.WriteDetails(c, apierr.RoomFull, gin.H{"role": full.Role, "limit": full.Limit})
+		return
+	}
+
+	retry := int(db.QueuePollInterval.Seconds())
+	metrics.JoinRequests.WithLabelValues("queued", role).Inc()
+	logging.FromContext(ctx).Info("join queued: room full", "limit", full.Limit, "position", full.Position)
+	c.Header("Retry-After", strconv.Itoa(retry))
+	apierr.WriteDetails(c, apierr.RoomFull,
+		gin.H{"role": full.Role, "limit": full.Limit, "queue_position": full.Position, "retry_after_sec": retry})
+}
+
+// pinNode — узел для урока учителя: если в комнате уже идёт урок (переподключение),
+// остаёмся на его узле, иначе выбираем по региону / загрузке.
+func pinNode(
+	ctx context.Context,
+	nodes *service.LiveKitNodes,
+	dbConn *sql.DB,
+	tenantID int64,
+	room, region string,
+) (*service.LiveKitNode, error) {
+	active, err := db.GetActiveLesson(ctx, dbConn, tenantID, room)
+	switch {
+	case err == nil:
+		if n, ok := nodes.Get(active.LiveKitNode); ok {
+			return n, nil
+		}
+	case !errors.Is(err, db.ErrNoActiveLesson):
+		return nil, err
+	}
+
+	load, err := db.ActiveParticipantsByNode(ctx, dbConn)
+	if err != nil {
+		return nil, err
+	}
+	return nodes.Place(region, load)
+}
+
+// roomNameOK — непустое имя без "__": "__" отделяет школу в имени комнаты LiveKit,
+// и "school__math" из школы по умолчанию (без префикса) попал бы в комнату чужой школы
+func roomNameOK(room string) bool {
+	return room != "" && !strings.Contains(room, "__")
+}
+
+// teacherAllowed — см. db.Tenant.TeacherAllowed
+func teacherAllowed(tenant *db.Tenant, globalKey, key string) bool {
+	return tenant.TeacherAllowed(globalKey, key)
+}
+
+// rosterGuest — ученик в комнате класса не из списка (и без пропуска админа) => место гостя,
+// его guest_allowance проверит JoinParticipant под блокировкой комнаты.
+// nil — из списка или комната без класса (открыта всем).
+func rosterGuest(
+	ctx context.Context,
+	dbConn *sql.DB,
+	tenantID int64,
+	room string,
+	lessonID int64,
+	name string,
+) (*db.GuestSeat, error) {
+	class, err := db.RoomClass(ctx, dbConn, tenantID, room)
+	if errors.Is(err, db.ErrNoClass) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	on, err := db.OnRoster(ctx, dbConn, class.ID, name)
+	if err != nil || on {
+		return nil, err
+	}
+	return &db.GuestSeat{ClassID: class.ID, LessonID: lessonID, Allowance: class.GuestAllowance}, nil
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/middleware"
+	"streaming/internal/service"
+)
+
+// =======================
+// Participant metadata (live, через RoomService)
+// =======================
+
+type ParticipantMetadataRequest struct {
+	Room string `json:"room"`
+	Name string `json:"name"` // чьи метаданные меняем
+
+	// кто меняет: сам участник (token) или учитель (teacherKey)
+	Token      string `json:"token"`
+	TeacherKey string `json:"teacherKey"`
+
+	// nil => не менять
+	HandRaised *bool   `json:"hand_raised"`
+	AvatarURL  *string `json:"avatar_url"`
+	Locale     *string `json:"locale"`
+}
+
+// LiveKitParticipantMetadata — POST /api/v1/livekit/participant/metadata.
+// Участник меняет свои поля (поднять руку, аватар, язык), учитель — любого
+// (например, опустить руку). role / lesson_id / tenant меняет только сервер.
+func LiveKitParticipantMetadata(
+	nodes *service.LiveKitNodes,
+	teacherKey func() string,
+	dbConn *sql.DB,
+) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var req ParticipantMetadataRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+
+		req.Room = strings.TrimSpace(req.Room)
+		req.Name = strings.TrimSpace(req.Name)
+		if !roomNameOK(req.Room) || req.Name == "" {
+			apierr.WriteReason(c, apierr.InvalidRequest, "room (without '__') and name are required")
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		middleware.LogWith(c, "room", req.Room, "identity", req.Name)
+
+		lesson, node, ok := lessonNode(c, nodes, dbConn, tenant, req.Room)
+		if !ok {
+			return
+		}
+		lkRoom := tenant.LiveKitRoom(req.Room)
+
+		// ---------- WHO ----------
+		if !teacherAllowed(tenant, teacherKey(), strings.TrimSpace(req.TeacherKey)) {
+			grants, err := node.VerifyToken(strings.TrimSpace(req.Token))
+			if err != nil || grants.Video.Room != lkRoom || grants.Identity != req.Name {
+				apierr.Write(c, apierr.MetadataForbidden)
+				return
+			}
+		}
+
+		ctx := c.Request.Context()
+
+		// ---------- READ / PATCH / WRITE ----------
+		md, err := node.ParticipantMetadata(ctx, lkRoom, req.Name)
+		if err != nil {
+			roomServiceError(c, err)
+			return
+		}
+
+		if req.HandRaised != nil {
+			md.HandRaised = *req.HandRaised
+		}
+		if req.AvatarURL != nil {
+			md.AvatarURL = strings.TrimSpace(*req.AvatarURL)
+		}
+		if req.Locale != nil {
+			md.Locale = strings.TrimSpace(*req.Locale)
+		}
+		// старые токены (до typed metadata) могли не содержать этих полей
+		md.LessonID = lesson.ID
+		md.Tenant = tenant.Slug
+
+		if err := node.UpdateParticipantMetadata(ctx, lkRoom, req.Name, md); err != nil {
+			roomServiceError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"room":     req.Room,
+			"name":     req.Name,
+			"metadata": md,
+		})
+	}
+}
+
+// =======================
+// Room metadata
+// =======================
+
+type RoomMetadataRequest struct {
+	Room       string `json:"room"`
+	TeacherKey string `json:"teacherKey"`
+
+	// nil => не менять
+	Title          *string    `json:"title"`
+	ScheduledStart *time.Time `json:"scheduled_start"`
+	ScheduledEnd   *time.Time `json:"scheduled_end"`
+}
+
+// LiveKitRoomMetadata — POST /api/v1/livekit/room/metadata (только учитель)
+func LiveKitRoomMetadata(
+	nodes *service.LiveKitNodes,
+	teacherKey func() string,
+	dbConn *sql.DB,
+) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var req RoomMetadataRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+
+		req.Room = strings.TrimSpace(req.Room)
+		if !roomNameOK(req.Room) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "room is required and must not contain '__'")
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		if !teacherAllowed(tenant, teacherKey(), strings.TrimSpace(req.TeacherKey)) {
+			apierr.Write(c, apierr.TeacherOnly)
+			return
+		}
+
+		middleware.LogWith(c, "room", req.Room)
+
+		lesson, node, ok := lessonNode(c, nodes, dbConn, tenant, req.Room)
+		if !ok {
+			return
+		}
+		lkRoom := tenant.LiveKitRoom(req.Room)
+		ctx := c.Request.Context()
+
+		md, err := node.RoomMetadata(ctx, lkRoom)
+		if err != nil {
+			roomServiceError(c, err)
+			return
+		}
+
+		if req.Title != nil {
+			md.Title = strings.TrimSpace(*req.Title)
+		}
+		if req.ScheduledStart != nil {
+			md.ScheduledStart = req.ScheduledStart
+		}
+		if req.ScheduledEnd != nil {
+			md.ScheduledEnd = req.ScheduledEnd
+		}
+		// комнату могли создать без нас (клиент подключился раньше)
+		md.LessonID = lesson.ID
+		md.Teacher = lesson.Teacher
+		md.Tenant = tenant.Slug
+		if md.StartedAt.IsZero() {
+			md.StartedAt = lesson.StartedAt.UTC()
+		}
+
+		if err := node.UpdateRoomMetadata(ctx, lkRoom, md); err != nil {
+			roomServiceError(c, err)
+			return
+		}
+
+		// расписание нужно и аналитике (опоздания)
+		if req.ScheduledStart != nil || req.ScheduledEnd != nil {
+			if err := db.SetLessonSchedule(ctx, dbConn, tenant.ID, lesson.ID, req.ScheduledStart, req.ScheduledEnd); err != nil {
+				logging.FromContext(ctx).Warn("lesson schedule not saved", "error", err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"room":     req.Room,
+			"metadata": md,
+		})
+	}
+}
+
+// =======================
+// Helpers
+// =======================
+
+// lessonNode — открытый урок комнаты и его LiveKit узел.
+// ok=false — ответ с ошибкой уже отправлен.
+func lessonNode(
+	c *gin.Context,
+	nodes *service.LiveKitNodes,
+	dbConn *sql.DB,
+	tenant *db.Tenant,
+	room string,
+) (*db.Lesson, *service.LiveKitNode, bool) {
+	ctx := c.Request.Context()
+
+	lesson, err := db.GetActiveLesson(ctx, dbConn, tenant.ID, room)
+	if err != nil {
+		if !errors.Is(err, db.ErrNoActiveLesson) {
+			logging.FromContext(ctx).Error("get active lesson failed", "error", err)
+		}
+		apierr.Write(c, apierr.NoActiveLesson)
+		return nil, nil, false
+	}
+
+	node, ok := nodes.Get(lesson.LiveKitNode)
+	if !ok {
+		logging.FromContext(ctx).Error("lesson pinned to unknown livekit node", "livekit_node", lesson.LiveKitNode)
+		apierr.Write(c, apierr.LiveKitNodeUnavailable)
+		return nil, nil, false
+	}
+
+	return lesson, node, true
+}
+
+func roomServiceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrParticipantNotConnected):
+		apierr.Write(c, apierr.ParticipantNotConnected)
+	case errors.Is(err, service.ErrRoomNotFound):
+		apierr.Write(c, apierr.RoomNotFound)
+	default:
+		logging.FromContext(c.Request.Context()).Error("livekit room service failed", "error", err)
+		apierr.Write(c, apierr.LiveKitUnavailable)
+	}
+}
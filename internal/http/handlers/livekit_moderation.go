@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/middleware"
+	"streaming/internal/service"
+)
+
+type LiveKitKickRequest struct {
+	Room       string `json:"room"`
+	Name       string `json:"name"`
+	TeacherKey string `json:"teacherKey"`
+	Ban        bool   `json:"ban"`    // true => в комнату больше нельзя, false => только в этот урок
+	Reason     string `json:"reason"` // optional
+}
+
+// LiveKitKick — POST /api/v1/livekit/kick (только учитель).
+// Участник попадает в denylist (join и refresh ему больше не выдают токен)
+// и сразу отключается от LiveKit: сам токен подключённого клиента LiveKit продлевает.
+// Уже выданный токен kick не отзывает — до истечения (TOKEN_TTL_*) с ним можно
+// подключиться к LiveKit напрямую, мимо denylist; поэтому TTL по умолчанию короткий.
+func LiveKitKick(nodes *service.LiveKitNodes, teacherKey func() string, dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LiveKitKickRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+
+		req.Room = strings.TrimSpace(req.Room)
+		req.Name = strings.TrimSpace(req.Name)
+		req.Reason = strings.TrimSpace(req.Reason)
+		if !roomNameOK(req.Room) || req.Name == "" {
+			apierr.WriteReason(c, apierr.InvalidRequest, "room (without '__') and name are required")
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		if !teacherAllowed(tenant, teacherKey(), strings.TrimSpace(req.TeacherKey)) {
+			apierr.Write(c, apierr.TeacherOnly)
+			return
+		}
+
+		middleware.LogWith(c, "room", req.Room, "identity", req.Name, "ban", req.Ban)
+		ctx := c.Request.Context()
+
+		// бан на комнату возможен и без урока; kick — только из идущего урока
+		lesson, err := db.GetActiveLesson(ctx, dbConn, tenant.ID, req.Room)
+		switch {
+		case err == nil:
+		case errors.Is(err, db.ErrNoActiveLesson) && req.Ban:
+			lesson = nil
+		case errors.Is(err, db.ErrNoActiveLesson):
+			apierr.Write(c, apierr.NoActiveLesson)
+			return
+		default:
+			logging.FromContext(ctx).Error("get active lesson failed", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		var lessonID sql.NullInt64
+		actor := "teacher"
+		if lesson != nil {
+			actor = lesson.Teacher
+			lessonID = sql.NullInt64{Int64: lesson.ID, Valid: !req.Ban}
+		}
+
+		if err := db.DenyParticipant(ctx, dbConn, tenant.ID, lessonID, req.Room, req.Name, req.Reason, actor); err != nil {
+			logging.FromContext(ctx).Error("deny participant failed", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		if lesson != nil {
+			// ✅ отключаем сейчас; не вышло — повтор безопасен (denylist уже записан)
+			if err := disconnectParticipant(ctx, nodes, lesson, tenant.LiveKitRoom(req.Room), req.Name); err != nil {
+				logging.FromContext(ctx).Error("participant not disconnected", "error", err)
+				apierr.Write(c, apierr.LiveKitUnavailable)
+				return
+			}
+
+			if err := db.LeaveParticipant(ctx, dbConn, tenant.ID, lesson.ID, req.Name); err != nil {
+				logging.FromContext(ctx).Error("participant leave not recorded", "error", err)
+			}
+
+			event := "kick"
+			if req.Ban {
+				event = "ban"
+			}
+			if err := db.LogEvent(ctx, dbConn, lesson.ID, event, req.Name); err != nil {
+				logging.FromContext(ctx).Warn("lesson event not recorded", "event", event, "error", err)
+			}
+		}
+
+		logging.FromContext(ctx).Info("participant removed")
+
+		c.JSON(http.StatusOK, gin.H{
+			"room":   req.Room,
+			"name":   req.Name,
+			"banned": req.Ban,
+		})
+	}
+}
+
+// disconnectParticipant — убрать участника из комнаты урока; кого уже нет — не ошибка
+func disconnectParticipant(ctx context.Context, nodes *service.LiveKitNodes, lesson *db.Lesson, lkRoom, name string) error {
+	node, ok := nodes.Get(lesson.LiveKitNode)
+	if !ok {
+		return fmt.Errorf("lesson pinned to unknown livekit node %q", lesson.LiveKitNode)
+	}
+	err := node.RemoveParticipant(ctx, lkRoom, name)
+	if errors.Is(err, service.ErrParticipantNotConnected) || errors.Is(err, service.ErrRoomNotFound) {
+		return nil
+	}
+	return err
+}
+
+type LiveKitUnbanRequest struct {
+	Room       string `json:"room"`
+	Name       string `json:"name"`
+	TeacherKey string `json:"teacherKey"`
+}
+
+// LiveKitUnban — POST /api/v1/livekit/unban (только учитель): снять бан на комнату
+func LiveKitUnban(teacherKey func() string, dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LiveKitUnbanRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+
+		req.Room = strings.TrimSpace(req.Room)
+		req.Name = strings.TrimSpace(req.Name)
+		if !roomNameOK(req.Room) || req.Name == "" {
+			apierr.WriteReason(c, apierr.InvalidRequest, "room (without '__') and name are required")
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		if !teacherAllowed(tenant, teacherKey(), strings.TrimSpace(req.TeacherKey)) {
+			apierr.Write(c, apierr.TeacherOnly)
+			return
+		}
+
+		middleware.LogWith(c, "room", req.Room, "identity", req.Name)
+		ctx := c.Request.Context()
+
+		lifted, err := db.LiftBan(ctx, dbConn, tenant.ID, req.Room, req.Name)
+		if err != nil {
+			logging.FromContext(ctx).Error("lift ban failed", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"room":   req.Room,
+			"name":   req.Name,
+			"lifted": lifted,
+		})
+	}
+}
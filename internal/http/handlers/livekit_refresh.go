@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/metrics"
+	"streaming/internal/middleware"
+	"streaming/internal/service"
+)
+
+type LiveKitRefreshRequest struct {
+	Room  string `json:"room"`
+	Token string `json:"token"` // текущий (ещё действующий) LiveKit токен
+}
+
+// LiveKitRefresh — POST /api/v1/livekit/refresh: новый токен вместо истекающего.
+// Выдаётся только тем, кто всё ещё участник открытого урока и не в denylist.
+// Роль берётся из БД, а не из старого токена.
+func LiveKitRefresh(
+	nodes *service.LiveKitNodes,
+	tokenTTL func(role string) time.Duration, // перечитывается по SIGHUP
+	dbConn *sql.DB,
+) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		var req LiveKitRefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			metrics.TokenRefreshes.WithLabelValues("invalid_request").Inc()
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+
+		req.Room = strings.TrimSpace(req.Room)
+		req.Token = strings.TrimSpace(req.Token)
+		if !roomNameOK(req.Room) || req.Token == "" {
+			metrics.TokenRefreshes.WithLabelValues("invalid_request").Inc()
+			apierr.WriteReason(c, apierr.InvalidRequest, "room (without '__') and token are required")
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		middleware.LogWith(c, "room", req.Room)
+		ctx := c.Request.Context()
+
+		// ---------- LESSON ----------
+		lesson, err := db.GetActiveLesson(ctx, dbConn, tenant.ID, req.Room)
+		if err != nil {
+			if !errors.Is(err, db.ErrNoActiveLesson) {
+				logging.FromContext(ctx).Error("get active lesson failed", "error", err)
+			}
+			metrics.TokenRefreshes.WithLabelValues("no_active_lesson").Inc()
+			apierr.Write(c, apierr.LessonEnded)
+			return
+		}
+
+		node, ok := nodes.Get(lesson.LiveKitNode)
+		if !ok {
+			metrics.TokenRefreshes.WithLabelValues("node_unavailable").Inc()
+			logging.FromContext(ctx).Error("lesson pinned to unknown livekit node", "livekit_node", lesson.LiveKitNode)
+			apierr.Write(c, apierr.LiveKitNodeUnavailable)
+			return
+		}
+
+		// ---------- OLD TOKEN ----------
+		// подписан узлом урока и выдан именно в эту комнату этой школы
+		lkRoom := tenant.LiveKitRoom(req.Room)
+		grants, err := node.VerifyToken(req.Token)
+		if err != nil || grants.Video.Room != lkRoom {
+			metrics.TokenRefreshes.WithLabelValues("invalid_token").Inc()
+			apierr.Write(c, apierr.InvalidToken)
+			return
+		}
+
+		middleware.LogWith(c, "lesson_id", lesson.ID, "identity", grants.Identity)
+		ctx = c.Request.Context()
+
+		// ---------- STILL ALLOWED? ----------
+		p, err := db.GetParticipant(ctx, dbConn, tenant.ID, lesson.ID, grants.Identity)
+		if err != nil {
+			if !errors.Is(err, db.ErrParticipantNotFound) {
+				logging.FromContext(ctx).Error("get participant failed", "error", err)
+				apierr.Write(c, apierr.DBError)
+				return
+			}
+			metrics.TokenRefreshes.WithLabelValues("not_participant").Inc()
+			apierr.Write(c, apierr.NotAParticipant)
+			return
+		}
+		if p.LeftAt.Valid {
+			metrics.TokenRefreshes.WithLabelValues("not_participant").Inc()
+			apierr.Write(c, apierr.NotAParticipant)
+			return
+		}
+
+		denied, err := db.IsDenied(ctx, dbConn, tenant.ID, lesson.ID, req.Room, p.Name)
+		if err != nil {
+			// ⚠️ не можем проверить denylist — токен не выдаём
+			logging.FromContext(ctx).Error("denylist check failed", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+		if denied {
+			metrics.TokenRefreshes.WithLabelValues("denied").Inc()
+			logging.FromContext(ctx).Info("token refresh denied")
+			apierr.Write(c, apierr.ParticipantDenied)
+			return
+		}
+
+		// ---------- NEW TOKEN ----------
+		// профиль (аватар, язык...) переносим из старого токена, роль/урок/школу — с сервера
+		md, err := service.ParseParticipantMetadata(grants.Metadata)
+		if err != nil {
+			logging.FromContext(ctx).Warn("old token metadata ignored", "error", err)
+			md = service.ParticipantMetadata{}
+		}
+		md.Role = p.Role
+		md.LessonID = lesson.ID
+		md.Tenant = tenant.Slug
+
+		ttl := tokenTTL(p.Role)
+		token, err := node.JoinToken(ctx, lkRoom, p.Name, grants.Name, md, ttl)
+		if err != nil {
+			metrics.TokenRefreshes.WithLabelValues("token_error").Inc()
+			logging.FromContext(ctx).Error("livekit token failed", "error", err)
+			apierr.Write(c, apierr.LiveKitTokenError)
+			return
+		}
+
+		metrics.TokenRefreshes.WithLabelValues("ok").Inc()
+		logging.FromContext(ctx).Debug("token refreshed", "role", p.Role)
+
+		c.JSON(http.StatusOK, gin.H{
+			"room":       req.Room,
+			"role":       p.Role,
+			"lesson_id":  lesson.ID,
+			"token":      token,
+			"expires_at": time.Now().Add(ttl).UTC(),
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/middleware"
+	"streaming/internal/notify"
+	"streaming/internal/service"
+)
+
+// chat id пользователя / группы (-100...) или @username канала
+var telegramChatID = regexp.MustCompile(`^(-?\d{1,20}|@[A-Za-z0-9_]{5,32})$`)
+
+type NotificationPrefsRequest struct {
+	Email            string          `json:"email"`
+	TelegramChatID   string          `json:"telegram_chat_id"`
+	PushSubscription json.RawMessage `json:"push_subscription"`
+	Channels         []string        `json:"channels"`
+	LessonStarted    *bool           `json:"lesson_started"` // optional: true
+	Reminders        *bool           `json:"reminders"`      // optional: true
+}
+
+// =======================
+// Student
+// =======================
+
+// NotificationPrefs — GET /api/v1/notifications/prefs (X-LiveKit-Token ученика)
+func NotificationPrefs(nodes *service.LiveKitNodes, dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := tokenIdentity(c, nodes)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		p, err := db.GetNotificationPrefs(ctx, dbConn, middleware.GetTenant(c).ID, name)
+		if err != nil {
+			logging.FromContext(ctx).Error("get notification prefs", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"prefs": p})
+	}
+}
+
+// SaveNotificationPrefs — PUT /api/v1/notifications/prefs: ученик включает каналы и события
+func SaveNotificationPrefs(nodes *service.LiveKitNodes, dbConn *sql.DB, notifier *service.Notifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := tokenIdentity(c, nodes)
+		if !ok {
+			return
+		}
+		p, ok := bindNotificationPrefs(c, notifier, name)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		if err := db.SaveNotificationPrefs(ctx, dbConn, middleware.GetTenant(c).ID, p); err != nil {
+			logging.FromContext(ctx).Error("save notification prefs", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"prefs": p})
+	}
+}
+
+// NotificationVAPIDKey — GET /api/v1/notifications/vapid-key: applicationServerKey для подписки браузера
+func NotificationVAPIDKey(notifier *service.Notifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := ""
+		if notifier != nil {
+			key = notifier.PushKey()
+		}
+		if key == "" {
+			apierr.Write(c, apierr.WebPushDisabled)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"public_key": key})
+	}
+}
+
+// =======================
+// Teacher: schedule (напоминания)
+// =======================
+
+type ScheduleLessonRequest struct {
+	Room     string     `json:"room"`
+	Title    string     `json:"title"`
+	Teacher  string     `json:"teacher"`
+	StartsAt time.Time  `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+// TeacherScheduleLesson — POST /api/v1/teacher/schedule: ученикам класса придёт напоминание
+func TeacherScheduleLesson(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req ScheduleLessonRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.WriteReason(c, apierr.InvalidJSON, "invalid JSON body (starts_at must be RFC 3339)")
+			return
+		}
+		req.Room = strings.TrimSpace(req.Room)
+		req.Title = strings.TrimSpace(req.Title)
+		req.Teacher = strings.TrimSpace(req.Teacher)
+
+		if !roomNameOK(req.Room) || req.StartsAt.IsZero() {
+			apierr.WriteReason(c, apierr.InvalidRequest, "room (without '__') and starts_at are required")
+			return
+		}
+		if !req.StartsAt.After(time.Now()) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "starts_at must be in the future")
+			return
+		}
+		if req.EndsAt != nil && !req.EndsAt.After(req.StartsAt) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "ends_at must be after starts_at")
+			return
+		}
+
+		s := db.ScheduledLesson{
+			TenantID: middleware.GetTenant(c).ID,
+			Room:     req.Room,
+			Title:    req.Title,
+			Teacher:  req.Teacher,
+			StartsAt: req.StartsAt.UTC(),
+			EndsAt:   req.EndsAt,
+		}
+		if err := db.CreateScheduledLesson(ctx, dbConn, &s); err != nil {
+			logging.FromContext(ctx).Error("schedule lesson", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		logging.FromContext(ctx).Info("lesson scheduled", "scheduled_id", s.ID, "room", s.Room, "starts_at", s.StartsAt)
+
+		c.JSON(http.StatusCreated, gin.H{"lesson": s})
+	}
+}
+
+// TeacherSchedule — GET /api/v1/teacher/schedule[?room=]: предстоящие уроки
+func TeacherSchedule(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		list, err := db.ListScheduledLessons(ctx, dbConn, middleware.GetTenant(c).ID,
+			strings.TrimSpace(c.Query("room")), time.Now())
+		if err != nil {
+			logging.FromContext(ctx).Error("list scheduled lessons", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"lessons": list})
+	}
+}
+
+// TeacherUnscheduleLesson — DELETE /api/v1/teacher/schedule/:id
+func TeacherUnscheduleLesson(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			apierr.WriteReason(c, apierr.InvalidRequest, "invalid scheduled lesson id")
+			return
+		}
+
+		if err := db.DeleteScheduledLesson(ctx, dbConn, middleware.GetTenant(c).ID, id); err != nil {
+			if errors.Is(err, db.ErrScheduledLessonNotFound) {
+				apierr.Write(c, apierr.ScheduledLessonNotFound)
+				return
+			}
+			logging.FromContext(ctx).Error("delete scheduled lesson", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"deleted": true})
+	}
+}
+
+// =======================
+// Admin
+// =======================
+
+// AdminNotificationPrefs — GET /api/admin/notifications/prefs
+func AdminNotificationPrefs(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+
+		list, err := db.ListNotificationPrefs(ctx, dbConn, tenantID)
+		if err != nil {
+			logging.FromContext(ctx).Error("list notification prefs", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"prefs": list})
+	}
+}
+
+// AdminSaveNotificationPrefs — PUT /api/admin/notifications/prefs/:name (за ученика)
+func AdminSaveNotificationPrefs(dbConn *sql.DB, notifier *service.Notifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		name := strings.TrimSpace(c.Param("name"))
+		if name == "" {
+			apierr.WriteReason(c, apierr.InvalidRequest, "student name is required")
+			return
+		}
+		p, ok := bindNotificationPrefs(c, notifier, name)
+		if !ok {
+			return
+		}
+
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+
+		if err := db.SaveNotificationPrefs(ctx, dbConn, tenantID, p); err != nil {
+			logging.FromContext(ctx).Error("save notification prefs", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"prefs": p})
+	}
+}
+
+// AdminNotifications — GET /api/admin/notifications?status=pending|sent|failed&limit=
+func AdminNotifications(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		status := c.Query("status")
+		if status != "" && status != "pending" && status != "sent" && status != "failed" {
+			apierr.WriteReason(c, apierr.InvalidRequest, "status must be pending, sent or failed")
+			return
+		}
+		limit := 100
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 1000 {
+				apierr.WriteReason(c, apierr.InvalidRequest, "limit must be 1..1000")
+				return
+			}
+			limit = n
+		}
+
+		tenantID, ok := adminTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+
+		list, err := db.ListNotifications(ctx, dbConn, tenantID, status, limit)
+		if err != nil {
+			logging.FromContext(ctx).Error("list notifications", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"notifications": list})
+	}
+}
+
+// AdminRetryNotification — POST /api/admin/notifications/:id/retry: снова в очередь
+func AdminRetryNotification(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			apierr.WriteReason(c, apierr.InvalidRequest, "invalid notification id")
+			return
+		}
+
+		tenantID, ok := adminTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+
+		if err := db.RetryNotification(ctx, dbConn, tenantID, id); err != nil {
+			if errors.Is(err, db.ErrNotificationNotFound) {
+				apierr.Write(c, apierr.NotificationNotFound)
+				return
+			}
+			logging.FromContext(ctx).Error("retry notification", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"queued": true})
+	}
+}
+
+// =======================
+// Helpers
+// =======================
+
+// tokenIdentity — ученик по LiveKit токену любого урока этой школы (X-LiveKit-Token):
+// отдельных аккаунтов нет, identity = имя в списке класса
+func tokenIdentity(c *gin.Context, nodes *service.LiveKitNodes) (string, bool) {
+	token := strings.TrimSpace(c.GetHeader(LiveKitTokenHeader))
+	tenant := middleware.GetTenant(c)
+
+	for _, node := range nodes.All() {
+		grants, err := node.VerifyToken(token)
+		if err != nil {
+			continue
+		}
+		if !tenantRoom(tenant, grants.Video.Room) {
+			break
+		}
+		middleware.LogWith(c, "identity", grants.Identity)
+		return grants.Identity, true
+	}
+
+	apierr.Write(c, apierr.InvalidToken)
+	return "", false
+}
+
+// tenantRoom — комната LiveKit принадлежит школе (см. Tenant.LiveKitRoom)
+func tenantRoom(tenant *db.Tenant, lkRoom string) bool {
+	if tenant.IsDefault() {
+		return !strings.Contains(lkRoom, "__")
+	}
+	return strings.HasPrefix(lkRoom, tenant.Slug+"__")
+}
+
+// bindNotificationPrefs — настройки из тела; включённому каналу нужен адрес
+func bindNotificationPrefs(c *gin.Context, notifier *service.Notifier, name string) (*db.NotificationPrefs, bool) {
+	var req NotificationPrefsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierr.Write(c, apierr.InvalidJSON)
+		return nil, false
+	}
+
+	p := &db.NotificationPrefs{
+		StudentName:    name,
+		Email:          strings.TrimSpace(req.Email),
+		TelegramChatID: strings.TrimSpace(req.TelegramChatID),
+		Channels:       []string{},
+		LessonStarted:  req.LessonStarted == nil || *req.LessonStarted,
+		Reminders:      req.Reminders == nil || *req.Reminders,
+	}
+
+	if p.Email != "" {
+		addr, err := mail.ParseAddress(p.Email)
+		if err != nil {
+			apierr.WriteReason(c, apierr.InvalidRequest, "email is not a valid address")
+			return nil, false
+		}
+		p.Email = addr.Address
+	}
+	if p.TelegramChatID != "" && !telegramChatID.MatchString(p.TelegramChatID) {
+		apierr.WriteReason(c, apierr.InvalidRequest, "telegram_chat_id must be a numeric chat id or @channel")
+		return nil, false
+	}
+	if len(req.PushSubscription) > 0 && string(req.PushSubscription) != "null" {
+		if _, err := notify.ParsePushSubscription(req.PushSubscription); err != nil {
+			apierr.WriteReason(c, apierr.InvalidRequest, err.Error())
+			return nil, false
+		}
+		p.PushSubscription = req.PushSubscription
+	}
+
+	for _, ch := range req.Channels {
+		ch = strings.ToLower(strings.TrimSpace(ch))
+		if !notify.KnownChannel(ch) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "channels must be email, telegram or webpush")
+			return nil, false
+		}
+		if slices.Contains(p.Channels, ch) {
+			continue
+		}
+		if notifier == nil || !notifier.Enabled(ch) {
+			apierr.WriteDetails(c, apierr.ChannelDisabled, gin.H{"channel": ch})
+			return nil, false
+		}
+		if (ch == notify.ChannelEmail && p.Email == "") ||
+			(ch == notify.ChannelTelegram && p.TelegramChatID == "") ||
+			(ch == notify.ChannelWebPush && p.PushSubscription == nil) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "channel "+ch+" is enabled but its address is empty")
+			return nil, false
+		}
+		p.Channels = append(p.Channels, ch)
+	}
+	return p, true
+}
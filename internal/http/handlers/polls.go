@@ -0,0 +1,505 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/middleware"
+	"streaming/internal/service"
+)
+
+const (
+	pollMaxOptions     = 10
+	pollMaxQuestionLen = 500
+	pollMaxOptionLen   = 200
+	pollMaxDuration    = time.Hour
+)
+
+// pollBroadcastTimeout — рассылка итогов по таймеру идёт вне HTTP-запроса
+const pollBroadcastTimeout = 10 * time.Second
+
+type CreatePollRequest struct {
+	Room       string   `json:"room"`
+	TeacherKey string   `json:"teacherKey"`
+	Question   string   `json:"question"`
+	Kind       string   `json:"kind"` // single | multiple (single)
+	Options    []string `json:"options"`
+	Correct    []int64  `json:"correct"`      // optional: индексы правильных вариантов
+	Duration   int      `json:"duration_sec"` // optional: таймер; 0 => до закрытия учителем
+}
+
+// PollCreate — POST /api/v1/polls (только учитель): опрос в идущем уроке.
+// Вопрос рассылается всем в комнате data-сообщением (topic "poll").
+func PollCreate(nodes *service.LiveKitNodes, teacherKey func() string, dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreatePollRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+
+		req.Room = strings.TrimSpace(req.Room)
+		if !roomNameOK(req.Room) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "room is required and must not contain '__'")
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		if !teacherAllowed(tenant, teacherKey(), strings.TrimSpace(req.TeacherKey)) {
+			apierr.Write(c, apierr.TeacherOnly)
+			return
+		}
+
+		p, msg := newPoll(req)
+		if msg != "" {
+			apierr.WriteReason(c, apierr.InvalidPoll, msg)
+			return
+		}
+
+		middleware.LogWith(c, "room", req.Room)
+
+		lesson, node, ok := lessonNode(c, nodes, dbConn, tenant, req.Room)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		p.LessonID = lesson.ID
+		p.Room = lesson.Room
+		p.CreatedBy = lesson.Teacher
+		p.LiveKitNode = lesson.LiveKitNode
+		p.Teacher = lesson.Teacher
+		if err := db.CreatePoll(ctx, dbConn, p); err != nil {
+			logging.FromContext(ctx).Error("create poll", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		middleware.LogWith(c, "poll_id", p.ID)
+		ctx = c.Request.Context()
+
+		// опрос уже сохранён: если рассылка не прошла, клиент учителя может показать его сам
+		lkRoom := tenant.LiveKitRoom(req.Room)
+		broadcast := true
+		if err := node.SendPoll(ctx, lkRoom, service.NewPollMessage(service.PollStarted, p, nil)); err != nil {
+			broadcast = false
+			logging.FromContext(ctx).Warn("poll broadcast failed", "error", err)
+		}
+
+		if p.ClosesAt != nil {
+			pollID := p.ID
+			time.AfterFunc(time.Until(*p.ClosesAt), func() {
+				ctx, cancel := context.WithTimeout(context.Background(), pollBroadcastTimeout)
+				defer cancel()
+				if _, _, err := finishPoll(ctx, node, dbConn, tenant, pollID); err != nil {
+					logging.FromContext(ctx).Error("poll timer close failed", "poll_id", pollID, "error", err)
+				}
+			})
+		}
+
+		logging.FromContext(ctx).Info("poll started", "kind", p.Kind, "options", len(p.Options))
+
+		c.JSON(http.StatusCreated, gin.H{
+			"poll":      p,
+			"broadcast": broadcast,
+		})
+	}
+}
+
+type PollAnswerRequest struct {
+	Token   string  `json:"token"` // LiveKit токен ученика (кто отвечает)
+	Choices []int64 `json:"choices"`
+}
+
+// PollAnswer — POST /api/v1/polls/:id/answers: ответ ученика.
+// Пока опрос открыт, ответ можно поменять; учитель получает живые итоги.
+func PollAnswer(nodes *service.LiveKitNodes, dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PollAnswerRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		p, ok := apiPoll(c, dbConn, tenant)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		node, ok := nodes.Get(p.LiveKitNode)
+		if !ok {
+			logging.FromContext(ctx).Error("lesson pinned to unknown livekit node", "livekit_node", p.LiveKitNode)
+			apierr.Write(c, apierr.LiveKitNodeUnavailable)
+			return
+		}
+
+		// ---------- WHO ----------
+		lkRoom := tenant.LiveKitRoom(p.Room)
+		grants, err := node.VerifyToken(strings.TrimSpace(req.Token))
+		if err != nil || grants.Video.Room != lkRoom {
+			apierr.Write(c, apierr.InvalidToken)
+			return
+		}
+		middleware.LogWith(c, "identity", grants.Identity)
+		ctx = c.Request.Context()
+
+		// ---------- WHAT ----------
+		choices, msg := pollChoices(p, req.Choices)
+		if msg != "" {
+			apierr.WriteReason(c, apierr.InvalidAnswer, msg)
+			return
+		}
+		var correct *bool
+		if p.Graded() {
+			ok := p.IsCorrect(choices)
+			correct = &ok
+		}
+
+		if err := db.AnswerPoll(ctx, dbConn, p.ID, grants.Identity, choices, correct); err != nil {
+			if errors.Is(err, db.ErrPollClosed) {
+				apierr.Write(c, apierr.PollClosed)
+				return
+			}
+			logging.FromContext(ctx).Error("answer poll", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		// ---------- LIVE RESULTS (учителю) ----------
+		if answers, err := db.PollAnswers(ctx, dbConn, p.ID); err != nil {
+			logging.FromContext(ctx).Warn("poll results not loaded", "error", err)
+		} else {
+			res := p.Results(answers)
+			m := service.NewPollMessage(service.PollResults, p, &res)
+			if err := node.SendPoll(ctx, lkRoom, m, p.Teacher); err != nil {
+				logging.FromContext(ctx).Warn("poll results broadcast failed", "error", err)
+			}
+		}
+
+		// правильность не раскрываем до закрытия опроса
+		c.JSON(http.StatusOK, gin.H{
+			"poll_id": p.ID,
+			"choices": choices,
+		})
+	}
+}
+
+type ClosePollRequest struct {
+	TeacherKey string `json:"teacherKey"`
+}
+
+// PollClose — POST /api/v1/polls/:id/close (только учитель):
+// всем рассылаются итоги и правильный ответ.
+func PollClose(nodes *service.LiveKitNodes, teacherKey func() string, dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ClosePollRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Write(c, apierr.InvalidJSON)
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		if !teacherAllowed(tenant, teacherKey(), strings.TrimSpace(req.TeacherKey)) {
+			apierr.Write(c, apierr.TeacherOnly)
+			return
+		}
+
+		p, ok := apiPoll(c, dbConn, tenant)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		node, ok := nodes.Get(p.LiveKitNode)
+		if !ok {
+			logging.FromContext(ctx).Error("lesson pinned to unknown livekit node", "livekit_node", p.LiveKitNode)
+			apierr.Write(c, apierr.LiveKitNodeUnavailable)
+			return
+		}
+
+		res, closed, err := finishPoll(ctx, node, dbConn, tenant, p.ID)
+		if err != nil {
+			logging.FromContext(ctx).Error("close poll", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+		if !closed {
+			apierr.Write(c, apierr.PollClosed)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"poll_id": p.ID,
+			"results": res,
+			"correct": p.Correct,
+		})
+	}
+}
+
+// =======================
+// Teacher: results / export (X-Teacher-Key)
+// =======================
+
+// TeacherPolls — GET /api/v1/teacher/polls?lesson_id=: опросы урока с итогами
+func TeacherPolls(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		lessonID, err := strconv.ParseInt(c.Query("lesson_id"), 10, 64)
+		if err != nil {
+			apierr.WriteReason(c, apierr.InvalidRequest, "lesson_id is required")
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		polls, err := db.ListPolls(ctx, dbConn, tenant.ID, lessonID)
+		if err != nil {
+			logging.FromContext(ctx).Error("list polls", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		type pollWithResults struct {
+			db.Poll
+			Results db.PollResults `json:"results"`
+		}
+		out := make([]pollWithResults, 0, len(polls))
+		for i := range polls {
+			answers, err := db.PollAnswers(ctx, dbConn, polls[i].ID)
+			if err != nil {
+				logging.FromContext(ctx).Error("poll answers", "error", err)
+				apierr.Write(c, apierr.DBError)
+				return
+			}
+			out = append(out, pollWithResults{Poll: polls[i], Results: polls[i].Results(answers)})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"lesson_id": lessonID, "polls": out})
+	}
+}
+
+// TeacherPoll — GET /api/v1/teacher/polls/:id: итоги и ответы по ученикам
+func TeacherPoll(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, answers, ok := pollWithAnswers(c, dbConn)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"poll":    p,
+			"results": p.Results(answers),
+		})
+	}
+}
+
+// TeacherPollCSV — GET /api/v1/teacher/polls/:id/answers.csv (для оценок)
+func TeacherPollCSV(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, answers, ok := pollWithAnswers(c, dbConn)
+		if !ok {
+			return
+		}
+
+		filename := fmt.Sprintf("poll-%d-lesson-%d.csv", p.ID, p.LessonID)
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Status(http.StatusOK)
+
+		// BOM — чтобы Excel открыл UTF-8 без кракозябр
+		_, _ = c.Writer.WriteString("\ufeff")
+
+		w := csv.NewWriter(c.Writer)
+		_ = w.Write([]string{"student_name", "choices", "answer", "is_correct", "answered_at"})
+		for _, a := range answers {
+			idx := make([]string, 0, len(a.Choices))
+			text := make([]string, 0, len(a.Choices))
+			for _, ch := range a.Choices {
+				idx = append(idx, strconv.FormatInt(ch, 10))
+				if ch >= 0 && int(ch) < len(p.Options) {
+					text = append(text, p.Options[ch])
+				}
+			}
+			correct := ""
+			if a.IsCorrect != nil {
+				correct = strconv.FormatBool(*a.IsCorrect)
+			}
+			_ = w.Write([]string{
+				a.Name,
+				strings.Join(idx, ";"),
+				strings.Join(text, "; "),
+				correct,
+				a.AnsweredAt.UTC().Format(time.RFC3339),
+			})
+		}
+		w.Flush()
+
+		if err := w.Error(); err != nil {
+			logging.FromContext(c.Request.Context()).Warn("poll csv write", "error", err)
+		}
+	}
+}
+
+// =======================
+// Helpers
+// =======================
+
+// newPoll — проверка запроса учителя; msg != "" — что не так
+func newPoll(req CreatePollRequest) (*db.Poll, string) {
+	p := &db.Poll{
+		Question: strings.TrimSpace(req.Question),
+		Kind:     strings.ToLower(strings.TrimSpace(req.Kind)),
+		Correct:  []int64{},
+	}
+	if p.Kind == "" {
+		p.Kind = "single"
+	}
+	if p.Kind != "single" && p.Kind != "multiple" {
+		return nil, "kind must be single or multiple"
+	}
+	if p.Question == "" || len([]rune(p.Question)) > pollMaxQuestionLen {
+		return nil, fmt.Sprintf("question is required (max %d characters)", pollMaxQuestionLen)
+	}
+
+	for _, o := range req.Options {
+		o = strings.TrimSpace(o)
+		if o == "" || len([]rune(o)) > pollMaxOptionLen {
+			return nil, fmt.Sprintf("options must be non-empty (max %d characters)", pollMaxOptionLen)
+		}
+		p.Options = append(p.Options, o)
+	}
+	if len(p.Options) < 2 || len(p.Options) > pollMaxOptions {
+		return nil, fmt.Sprintf("a poll needs 2..%d options", pollMaxOptions)
+	}
+
+	if len(req.Correct) > 0 {
+		correct, msg := pollChoices(p, req.Correct)
+		if msg != "" {
+			return nil, "correct: " + msg
+		}
+		p.Correct = correct
+	}
+
+	if req.Duration < 0 || time.Duration(req.Duration)*time.Second > pollMaxDuration {
+		return nil, "duration_sec must be 0..3600"
+	}
+	if req.Duration > 0 {
+		at := time.Now().Add(time.Duration(req.Duration) * time.Second).UTC()
+		p.ClosesAt = &at
+	}
+
+	return p, ""
+}
+
+// pollChoices — проверка индексов ответа (без повторов, в пределах вариантов)
+func pollChoices(p *db.Poll, choices []int64) ([]int64, string) {
+	if len(choices) == 0 {
+		return nil, "choose at least one option"
+	}
+	if p.Kind == "single" && len(choices) != 1 {
+		return nil, "single choice poll takes exactly one option"
+	}
+
+	seen := make(map[int64]bool, len(choices))
+	out := make([]int64, 0, len(choices))
+	for _, ch := range choices {
+		if ch < 0 || int(ch) >= len(p.Options) {
+			return nil, "unknown option"
+		}
+		if !seen[ch] {
+			seen[ch] = true
+			out = append(out, ch)
+		}
+	}
+	return out, ""
+}
+
+// apiPoll — опрос из :id (школа из APIAuth)
+func apiPoll(c *gin.Context, dbConn *sql.DB, tenant *db.Tenant) (*db.Poll, bool) {
+	ctx := c.Request.Context()
+
+	pollID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.WriteReason(c, apierr.InvalidRequest, "invalid poll id")
+		return nil, false
+	}
+
+	p, err := db.GetPoll(ctx, dbConn, tenant.ID, pollID)
+	if err != nil {
+		if errors.Is(err, db.ErrPollNotFound) {
+			apierr.Write(c, apierr.PollNotFound)
+			return nil, false
+		}
+		logging.FromContext(ctx).Error("get poll", "error", err)
+		apierr.Write(c, apierr.DBError)
+		return nil, false
+	}
+
+	middleware.LogWith(c, "poll_id", p.ID, "lesson_id", p.LessonID, "room", p.Room)
+	return p, true
+}
+
+func pollWithAnswers(c *gin.Context, dbConn *sql.DB) (*db.Poll, []db.PollAnswer, bool) {
+	p, ok := apiPoll(c, dbConn, middleware.GetTenant(c))
+	if !ok {
+		return nil, nil, false
+	}
+
+	ctx := c.Request.Context()
+	answers, err := db.PollAnswers(ctx, dbConn, p.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("poll answers", "error", err)
+		apierr.Write(c, apierr.DBError)
+		return nil, nil, false
+	}
+	return p, answers, true
+}
+
+// finishPoll — закрыть опрос и разослать итоги всем в комнате.
+// closed=false — опрос уже был закрыт (ничего не рассылаем повторно).
+func finishPoll(
+	ctx context.Context,
+	node *service.LiveKitNode,
+	dbConn *sql.DB,
+	tenant *db.Tenant,
+	pollID int64,
+) (res db.PollResults, closed bool, err error) {
+	closed, err = db.ClosePoll(ctx, dbConn, pollID)
+	if err != nil || !closed {
+		return res, false, err
+	}
+
+	p, err := db.GetPoll(ctx, dbConn, tenant.ID, pollID)
+	if err != nil {
+		return res, true, err
+	}
+	answers, err := db.PollAnswers(ctx, dbConn, pollID)
+	if err != nil {
+		return res, true, err
+	}
+	res = p.Results(answers)
+
+	// ученикам — только итоги, без ответов по именам
+	summary := res
+	summary.Answers = nil
+	log := logging.FromContext(ctx).With("poll_id", pollID)
+	if err := node.SendPoll(ctx, tenant.LiveKitRoom(p.Room), service.NewPollMessage(service.PollClosed, p, &summary)); err != nil {
+		log.Warn("poll close broadcast failed", "error", err)
+	}
+
+	log.Info("poll closed", "answered", res.Answered, "correct", res.Correct)
+	return res, true, nil
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/middleware"
+)
+
+// =======================
+// Admin
+// =======================
+
+// AdminStudents — GET /api/admin/students?class_id=&from=&to=&tz=
+func AdminStudents(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+		if r, ok := studentReport(c, dbConn, tenantID); ok {
+			c.JSON(http.StatusOK, r)
+		}
+	}
+}
+
+// AdminStudentsCSV — GET /api/admin/students.csv (те же параметры)
+func AdminStudentsCSV(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+		if r, ok := studentReport(c, dbConn, tenantID); ok {
+			writeStudentsCSV(c, r)
+		}
+	}
+}
+
+// AdminStudentHistory — GET /api/admin/students/:name?from=&to=&tz=
+func AdminStudentHistory(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		tenantID, ok := adminWriteTenantID(c, dbConn)
+		if !ok {
+			return
+		}
+
+		name := strings.TrimSpace(c.Param("name"))
+		from, to, ok := parseDateRange(c, strings.TrimSpace(c.DefaultQuery("tz", "UTC")))
+		if !ok {
+			return
+		}
+
+		lessons, err := db.StudentHistory(ctx, dbConn, tenantID, name, from, to)
+		if err != nil {
+			logging.FromContext(ctx).Error("student history", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		var minutes float64
+		for _, l := range lessons {
+			minutes += l.Minutes
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"student_name":     name,
+			"from":             from.Format(analyticsDateLayout),
+			"to":               to.AddDate(0, 0, -1).Format(analyticsDateLayout),
+			"lessons_attended": len(lessons),
+			"minutes_attended": minutes,
+			"lessons":          lessons,
+		})
+	}
+}
+
+// =======================
+// Teacher (API key + X-Teacher-Key)
+// =======================
+
+// TeacherStudents — GET /api/v1/teacher/students?class_id=...
+func TeacherStudents(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r, ok := studentReport(c, dbConn, middleware.GetTenant(c).ID); ok {
+			c.JSON(http.StatusOK, r)
+		}
+	}
+}
+
+// TeacherStudentsCSV — GET /api/v1/teacher/students.csv?class_id=...
+func TeacherStudentsCSV(dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r, ok := studentReport(c, dbConn, middleware.GetTenant(c).ID); ok {
+			writeStudentsCSV(c, r)
+		}
+	}
+}
+
+// =======================
+// Helpers
+// =======================
+
+type StudentReport struct {
+	Class    *db.Class           `json:"class"`
+	From     string              `json:"from"`
+	To       string              `json:"to"`
+	TZ       string              `json:"tz"`
+	Students []db.StudentSummary `json:"students"`
+}
+
+// studentReport — сводка по ученикам класса ?class_id= школы tenantID.
+// ok=false — ответ с ошибкой уже отправлен.
+func studentReport(c *gin.Context, dbConn *sql.DB, tenantID int64) (*StudentReport, bool) {
+	ctx := c.Request.Context()
+
+	classID, err := strconv.ParseInt(c.Query("class_id"), 10, 64)
+	if err != nil {
+		apierr.WriteReason(c, apierr.InvalidRequest, "class_id is required")
+		return nil, false
+	}
+
+	tz := strings.TrimSpace(c.DefaultQuery("tz", "UTC"))
+	from, to, ok := parseDateRange(c, tz)
+	if !ok {
+		return nil, false
+	}
+
+	class, err := db.GetClass(ctx, dbConn, tenantID, classID)
+	if err != nil {
+		if errors.Is(err, db.ErrClassNotFound) {
+			apierr.Write(c, apierr.ClassNotFound)
+			return nil, false
+		}
+		logging.FromContext(ctx).Error("get class", "error", err)
+		apierr.Write(c, apierr.DBError)
+		return nil, false
+	}
+
+	list, err := db.StudentSummaries(ctx, dbConn, tenantID, class.ID, from, to)
+	if err != nil {
+		logging.FromContext(ctx).Error("student summaries", "error", err)
+		apierr.Write(c, apierr.DBError)
+		return nil, false
+	}
+
+	return &StudentReport{
+		Class:    class,
+		From:     from.Format(analyticsDateLayout),
+		To:       to.AddDate(0, 0, -1).Format(analyticsDateLayout),
+		TZ:       tz,
+		Students: list,
+	}, true
+}
+
+func writeStudentsCSV(c *gin.Context, r *StudentReport) {
+	filename := fmt.Sprintf("students-%d-%s-%s.csv", r.Class.ID, r.From, r.To)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	// BOM — чтобы Excel открыл UTF-8 (кириллица / туркменские буквы) без кракозябр
+	_, _ = c.Writer.WriteString("\ufeff")
+
+	loc, _ := time.LoadLocation(r.TZ)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{
+		"student_name", "user_id", "lessons_held", "lessons_attended", "attendance_rate",
+		"minutes_attended", "current_streak", "longest_streak", "last_seen",
+	})
+	for _, s := range r.Students {
+		lastSeen := ""
+		if s.LastSeen != nil {
+			lastSeen = s.LastSeen.In(loc).Format(time.RFC3339)
+		}
+		_ = w.Write([]string{
+			s.StudentName,
+			s.UserID,
+			strconv.Itoa(s.LessonsHeld),
+			strconv.Itoa(s.LessonsAttended),
+			strconv.FormatFloat(s.AttendanceRate, 'f', 2, 64),
+			strconv.FormatFloat(s.MinutesAttended, 'f', 0, 64),
+			strconv.Itoa(s.CurrentStreak),
+			strconv.Itoa(s.LongestStreak),
+			lastSeen,
+		})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("students csv write", "error", err)
+	}
+}
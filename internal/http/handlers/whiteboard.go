@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
+	"streaming/internal/middleware"
+	"streaming/internal/service"
+)
+
+// LiveKitTokenHeader — LiveKit токен участника для GET-маршрутов (доска)
+const LiveKitTokenHeader = "X-LiveKit-Token"
+
+const (
+	maxWhiteboardBody  = 256 << 10
+	maxWhiteboardBatch = 100
+)
+
+// WhiteboardState — GET /api/v1/whiteboard?room=&since=<seq>
+// Токен участника — в X-LiveKit-Token. Опоздавший получает всю доску (board + seq),
+// переподключившийся с since — только операции после since (если журнал ещё их хранит).
+func WhiteboardState(nodes *service.LiveKitNodes, dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		room := strings.TrimSpace(c.Query("room"))
+		if !roomNameOK(room) {
+			apierr.WriteReason(c, apierr.InvalidRequest, "room is required and must not contain '__'")
+			return
+		}
+		var since int64
+		if v := c.Query("since"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				apierr.WriteReason(c, apierr.InvalidRequest, "since must be a sequence number")
+				return
+			}
+			since = n
+		}
+
+		tenant := middleware.GetTenant(c)
+		middleware.LogWith(c, "room", room)
+
+		lesson, node, ok := lessonNode(c, nodes, dbConn, tenant, room)
+		if !ok {
+			return
+		}
+		if _, ok := roomParticipant(c, node, tenant, room, c.GetHeader(LiveKitTokenHeader)); !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		if since > 0 {
+			st, err := db.GetWhiteboard(ctx, dbConn, lesson.ID, since)
+			if err != nil {
+				logging.FromContext(ctx).Error("get whiteboard", "error", err)
+				apierr.Write(c, apierr.DBError)
+				return
+			}
+			// операции до since уже сжаты в снимок — отдаём доску целиком
+			if since >= st.SnapshotSeq {
+				c.JSON(http.StatusOK, gin.H{
+					"lesson_id": lesson.ID,
+					"seq":       st.HeadSeq,
+					"ops":       st.Ops,
+				})
+				return
+			}
+		}
+
+		doc, seq, err := service.LoadWhiteboard(ctx, dbConn, lesson.ID, false)
+		if err != nil {
+			logging.FromContext(ctx).Error("load whiteboard", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"lesson_id": lesson.ID,
+			"seq":       seq,
+			"board":     doc,
+		})
+	}
+}
+
+type WhiteboardOpsRequest struct {
+	Room  string                 `json:"room"`
+	Token string                 `json:"token"`
+	Ops   []service.WhiteboardOp `json:"ops"`
+}
+
+// WhiteboardOps — POST /api/v1/whiteboard/ops: дописать операции и разослать их в комнату.
+// Ученики могут только рисовать (add); правка, стирание и очистка — учитель.
+func WhiteboardOps(nodes *service.LiveKitNodes, dbConn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxWhiteboardBody)
+
+		var req WhiteboardOpsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.WriteReason(c, apierr.InvalidJSON, "invalid JSON body (max 256 KB)")
+			return
+		}
+
+		req.Room = strings.TrimSpace(req.Room)
+		if !roomNameOK(req.Room) || len(req.Ops) == 0 || len(req.Ops) > maxWhiteboardBatch {
+			apierr.WriteReason(c, apierr.InvalidRequest, fmt.Sprintf("room (without '__') and 1..%d ops are required", maxWhiteboardBatch))
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		middleware.LogWith(c, "room", req.Room)
+
+		lesson, node, ok := lessonNode(c, nodes, dbConn, tenant, req.Room)
+		if !ok {
+			return
+		}
+		who, ok := roomParticipant(c, node, tenant, req.Room, req.Token)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		// ---------- VALIDATE ----------
+		raw := make([]json.RawMessage, 0, len(req.Ops))
+		for i := range req.Ops {
+			op := &req.Ops[i]
+			if err := op.Validate(); err != nil {
+				apierr.WriteReason(c, apierr.InvalidWhiteboardOp, fmt.Sprintf("op %d: %v", i, err))
+				return
+			}
+			if who.role != "teacher" && op.Type != service.WhiteboardAdd {
+				apierr.Write(c, apierr.WhiteboardForbidden)
+				return
+			}
+			// автора ставит сервер (см. WhiteboardDoc.Apply), клиентскому не верим
+			if op.Element != nil {
+				op.Element.Author = ""
+			}
+
+			b, err := json.Marshal(op)
+			if err != nil {
+				apierr.WriteReason(c, apierr.InvalidWhiteboardOp, fmt.Sprintf("op %d: %v", i, err))
+				return
+			}
+			raw = append(raw, b)
+		}
+
+		// ---------- STORE ----------
+		first, err := db.AppendWhiteboardOps(ctx, dbConn, lesson.ID, who.identity, raw)
+		if err != nil {
+			logging.FromContext(ctx).Error("append whiteboard ops", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+		last := first + int64(len(raw)) - 1
+
+		// ---------- RELAY ----------
+		msg := service.WhiteboardMessage{Type: "ops", LessonID: lesson.ID}
+		for i, b := range raw {
+			msg.Ops = append(msg.Ops, db.WhiteboardOp{Seq: first + int64(i), Author: who.identity, Op: b})
+		}
+		// не дошло — клиенты догонят через GET ?since=
+		if err := node.SendWhiteboard(ctx, tenant.LiveKitRoom(req.Room), msg); err != nil {
+			logging.FromContext(ctx).Warn("whiteboard relay failed", "error", err)
+		}
+
+		if err := service.CompactWhiteboardIfDue(ctx, dbConn, lesson.ID, first, last); err != nil {
+			logging.FromContext(ctx).Warn("whiteboard compaction failed", "error", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"lesson_id": lesson.ID,
+			"first_seq": first,
+			"seq":       last,
+		})
+	}
+}
+
+// TeacherWhiteboardExport — GET /api/v1/teacher/lessons/:id/whiteboard.svg | whiteboard.png
+// Выгрузка доски урока (обычно после его окончания); журнал при этом сжимается в снимок.
+func TeacherWhiteboardExport(dbConn *sql.DB, format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		lessonID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			apierr.WriteReason(c, apierr.InvalidRequest, "invalid lesson id")
+			return
+		}
+
+		tenant := middleware.GetTenant(c)
+		lesson, err := db.GetLesson(ctx, dbConn, tenant.ID, lessonID)
+		if err != nil {
+			if errors.Is(err, db.ErrLessonNotFound) {
+				apierr.Write(c, apierr.LessonNotFound)
+				return
+			}
+			logging.FromContext(ctx).Error("get lesson", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		doc, _, err := service.LoadWhiteboard(ctx, dbConn, lesson.ID, true)
+		if err != nil {
+			logging.FromContext(ctx).Error("load whiteboard", "error", err)
+			apierr.Write(c, apierr.DBError)
+			return
+		}
+
+		filename := fmt.Sprintf("whiteboard-lesson-%d.%s", lesson.ID, format)
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+		if format == "svg" {
+			c.Data(http.StatusOK, "image/svg+xml", doc.RenderSVG())
+			return
+		}
+
+		var b bytes.Buffer
+		if err := doc.RenderPNG(&b); err != nil {
+			logging.FromContext(ctx).Error("render whiteboard png", "error", err)
+			apierr.Write(c, apierr.RenderFailed)
+			return
+		}
+		c.Data(http.StatusOK, "image/png", b.Bytes())
+	}
+}
+
+// =======================
+// Helpers
+// =======================
+
+type roomUser struct {
+	identity string
+	role     string
+}
+
+// roomParticipant — кто пришёл: токен узла урока, выданный в эту комнату (доска, файлы).
+// Роль — из метаданных токена (их подписывает сервер при join).
+func roomParticipant(
+	c *gin.Context,
+	node *service.LiveKitNode,
+	tenant *db.Tenant,
+	room, token string,
+) (roomUser, bool) {
+	grants, err := node.VerifyToken(strings.TrimSpace(token))
+	if err != nil || grants.Video.Room != tenant.LiveKitRoom(room) {
+		apierr.Write(c, apierr.InvalidToken)
+		return roomUser{}, false
+	}
+
+	md, err := service.ParseParticipantMetadata(grants.Metadata)
+	if err != nil {
+		md = service.ParticipantMetadata{Role: "student"}
+	}
+
+	middleware.LogWith(c, "identity", grants.Identity)
+	return roomUser{identity: grants.Identity, role: md.Role}, true
+}
@@ -1,66 +1,250 @@
 package http
 
 import (
+	"context"
 	"database/sql"
+	"log/slog"
 	nethttp "net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"streaming/internal/config"
+	dbpkg "streaming/internal/db"
 	"streaming/internal/http/handlers"
+	"streaming/internal/logging"
+	"streaming/internal/metrics"
 	"streaming/internal/middleware"
+	"streaming/internal/notify"
 	"streaming/internal/service"
+	"streaming/internal/storage"
 )
 
 func RegisterRoutes(
 	r *gin.Engine,
-	cfg *config.Config,
+	store *config.Store,
 	db *sql.DB,
+	lkNodes *service.LiveKitNodes,
+	files storage.Storage,
+	scanner storage.Scanner,
+	captions *service.Captions,
+	notifier *service.Notifier,
 ) {
-	// ✅ безопасность
-	_ = r.SetTrustedProxies(nil)
+	// cfg — снимок на момент старта (listener/LiveKit/host настройки);
+	// горячие значения (ключи, лимиты) читаются через store.Get() на каждый запрос
+	cfg := store.Get()
+
+	// ✅ безопасность: X-Forwarded-For только от HTTP_TRUSTED_PROXIES (пусто => ни от кого)
+	if err := r.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		slog.Warn("set trusted proxies", "error", err)
+	}
+
+	// ================================
+	// HOST PROTECTION (весь сайт: frontend + API)
+	// ================================
+	if cfg.HostProtection.Protected {
+		hostAuth := middleware.NewHostAuth(
+			cfg.HostProtection.Username,
+			cfg.HostProtection.Password,
+			cfg.HostProtection.SessionTTL,
+			cfg.HostProtection.AllowIP,
+		)
+		// звук субтитров шлёт LiveKit Egress (без сессии сайта; id сессии — секрет);
+		// /metrics закрыт своим Basic Auth (супер-админ) в том же заголовке
+		r.Use(hostAuth.Check("/healthz", "/metrics", service.CaptionsIngestPath))
+
+		r.GET(middleware.HostLoginPath, hostAuth.LoginPage())
+		// перебор пароля: HOST_LOGIN_PER_MIN попыток в минуту с IP
+		loginLimit := func() int { return cfg.HostProtection.LoginPerMinute }
+		r.POST(middleware.HostLoginPath, middleware.RateLimit(loginLimit), hostAuth.Login(cfg.TLS.Enabled))
+	}
 
 	// ================================
 	// ADMIN (protected)
 	// ================================
 	admin := r.Group("/api/admin")
-	admin.Use(middleware.AdminBasicAuth(cfg.Admin.Username, cfg.Admin.Password))
+	admin.Use(middleware.AdminBasicAuth(func() (string, string) {
+		a := store.Get().Admin
+		return a.Username, a.Password
+	}, db))
 	{
 		admin.GET("/summary", handlers.AdminSummary(db))
+		admin.GET("/analytics", handlers.AdminAnalytics(db))
+
+		// классы и списки учеников (админ школы — своей, супер-админ — ?tenant=<slug>)
+		admin.GET("/classes", handlers.AdminClasses(db))
+		admin.POST("/classes", handlers.AdminCreateClass(db))
+		admin.PATCH("/classes/:id", handlers.AdminUpdateClass(db))
+		admin.PUT("/classes/:id/rooms/:room", handlers.AdminLinkRoom(db))
+		admin.DELETE("/classes/:id/rooms/:room", handlers.AdminUnlinkRoom(db))
+		admin.GET("/classes/:id/roster", handlers.AdminRoster(db))
+		admin.POST("/classes/:id/roster/import", handlers.AdminImportRoster(db))
+		admin.POST("/classes/:id/overrides", handlers.AdminRosterOverride(db))
+
+		// комнаты: slug, название, владелец и настройки по умолчанию
+		admin.GET("/rooms", handlers.AdminRooms(db))
+		admin.POST("/rooms", handlers.AdminCreateRoom(db))
+		admin.GET("/rooms/:slug", handlers.AdminRoom(db))
+		admin.PATCH("/rooms/:slug", handlers.AdminUpdateRoom(db))
+		admin.DELETE("/rooms/:slug", handlers.AdminDeleteRoom(db))
+
+		// вовлечённость учеников
+		admin.GET("/students", handlers.AdminStudents(db))
+		admin.GET("/students.csv", handlers.AdminStudentsCSV(db))
+		admin.GET("/students/:name", handlers.AdminStudentHistory(db))
+
+		// биллинг: ведомости учителей по месяцам
+		billingRules := func() service.BillingRules {
+			b := store.Get().Billing
+			return service.BillingRules{
+				Currency:               b.Currency,
+				TeacherRate:            b.TeacherRate,
+				ParticipantRatePer1000: b.ParticipantRatePer1000,
+				RoundTo:                b.RoundTo,
+				Rounding:               b.Rounding,
+				MinLesson:              b.MinLesson,
+				SkipUnder:              b.SkipUnder,
+			}
+		}
+		admin.GET("/billing/preview", handlers.AdminBillingPreview(db, billingRules))
+		admin.GET("/billing/periods", handlers.AdminBillingPeriods(db))
+		admin.POST("/billing/periods", handlers.AdminCloseBillingPeriod(db, billingRules))
+		admin.GET("/billing/periods/:id", handlers.AdminBillingPeriod(db))
+		admin.GET("/billing/periods/:id/export.csv", handlers.AdminBillingExport(db))
+
+		// уведомления: настройки учеников и очередь отправки
+		admin.GET("/notifications", handlers.AdminNotifications(db))
+		admin.POST("/notifications/:id/retry", handlers.AdminRetryNotification(db))
+		admin.GET("/notifications/prefs", handlers.AdminNotificationPrefs(db))
+		admin.PUT("/notifications/prefs/:name", handlers.AdminSaveNotificationPrefs(db, notifier))
+
+		// школы — только супер-админ (ADMIN_USERNAME / ADMIN_PASSWORD)
+		tenants := admin.Group("/tenants", middleware.SuperAdminOnly())
+		tenants.GET("", handlers.AdminTenants(db))
+		tenants.POST("", handlers.AdminCreateTenant(db))
+		tenants.POST("/:slug/admins", handlers.AdminCreateTenantAdmin(db))
 	}
 
 	// ================================
 	// API (protected)
 	// ================================
 	api := r.Group("/api/v1")
-	api.Use(middleware.APIAuth(cfg.API.KeySecret))
+	api.Use(middleware.APIAuth(func() string { return store.Get().API.KeySecret }, db))
+
+	teacherKey := func() string { return store.Get().API.TeacherKey }
+	tokenTTL := func(role string) time.Duration { return store.Get().TokenTTL(role) }
+	joinLimit := func() int { return store.Get().RateLimit.JoinPerMinute }
+	adHocRoom := func() *dbpkg.Room {
+		r := store.Get().Rooms
+		if !r.AdHoc {
+			return nil
+		}
+		return &dbpkg.Room{Recording: dbpkg.RecordingOff, MaxStudents: r.MaxStudents, MaxTeachers: r.MaxTeachers}
+	}
 
 	api.POST("/livekit/join",
-		handlers.LiveKitJoin(
-			service.NewLiveKitService(
-				cfg.LiveKit.APIKey,
-				cfg.LiveKit.APISecret,
-				cfg.LiveKit.Port,
-				cfg.LiveKit.Secure,
-				cfg.LiveKit.PublicHost,
-			),
-			cfg.API.TeacherKey,
-			db,
-		),
+		middleware.RateLimit(joinLimit),
+		handlers.LiveKitJoin(lkNodes, teacherKey, tokenTTL, adHocRoom, db, notifier),
+	)
+	// отдельный счётчик: продления не должны съедать лимит входов
+	api.POST("/livekit/refresh",
+		middleware.RateLimit(joinLimit),
+		handlers.LiveKitRefresh(lkNodes, tokenTTL, db),
 	)
 
+	// модерация (teacherKey в теле): kick / ban и снятие бана
+	api.POST("/livekit/kick", handlers.LiveKitKick(lkNodes, teacherKey, db))
+	api.POST("/livekit/unban", handlers.LiveKitUnban(teacherKey, db))
+
+	// отчёты учителя (ключ учителя в X-Teacher-Key)
+	teacher := api.Group("/teacher", middleware.TeacherOnly(teacherKey))
+	teacher.GET("/students", handlers.TeacherStudents(db))
+	teacher.GET("/students.csv", handlers.TeacherStudentsCSV(db))
+	teacher.GET("/polls", handlers.TeacherPolls(db))
+	teacher.GET("/polls/:id", handlers.TeacherPoll(db))
+	teacher.GET("/polls/:id/answers.csv", handlers.TeacherPollCSV(db))
+	teacher.GET("/lessons/:id/whiteboard.svg", handlers.TeacherWhiteboardExport(db, "svg"))
+	teacher.GET("/lessons/:id/whiteboard.png", handlers.TeacherWhiteboardExport(db, "png"))
+	teacher.GET("/schedule", handlers.TeacherSchedule(db))
+	teacher.POST("/schedule", handlers.TeacherScheduleLesson(db))
+	teacher.DELETE("/schedule/:id", handlers.TeacherUnscheduleLesson(db))
+
+	// уведомления ученику (X-LiveKit-Token): каналы, события, ключ для web push
+	api.GET("/notifications/prefs", handlers.NotificationPrefs(lkNodes, db))
+	api.PUT("/notifications/prefs", handlers.SaveNotificationPrefs(lkNodes, db, notifier))
+	api.GET("/notifications/vapid-key", handlers.NotificationVAPIDKey(notifier))
+
+	// опросы: вопрос и итоги идут в комнату data-сообщениями (topic "poll")
+	api.POST("/polls", handlers.PollCreate(lkNodes, teacherKey, db))
+	api.POST("/polls/:id/answers", handlers.PollAnswer(lkNodes, db))
+	api.POST("/polls/:id/close", handlers.PollClose(lkNodes, teacherKey, db))
+
+	// доска урока: журнал операций + снимок; операции идут в комнату data-сообщениями (topic "whiteboard")
+	api.GET("/whiteboard", handlers.WhiteboardState(lkNodes, db))
+	api.POST("/whiteboard/ops", handlers.WhiteboardOps(lkNodes, db))
+
+	// файлы урока: материалы учителя и домашние задания (X-Teacher-Key или X-LiveKit-Token)
+	uploadPolicy := func() storage.Policy {
+		u := store.Get().Upload
+		return storage.Policy{MaxSize: u.MaxSize, AllowedTypes: u.AllowedTypes}
+	}
+	api.POST("/lessons/:id/files", handlers.LessonFileUpload(lkNodes, teacherKey, db, files, scanner, uploadPolicy))
+	api.GET("/lessons/:id/files", handlers.LessonFiles(lkNodes, teacherKey, db))
+	api.GET("/lessons/:id/files/:file_id", handlers.LessonFileDownload(lkNodes, teacherKey, db, files))
+	api.DELETE("/lessons/:id/files/:file_id", handlers.LessonFileDelete(lkNodes, teacherKey, db, files))
+
+	// живые субтитры: egress -> STT -> комната (topic "captions") + расшифровка урока
+	api.POST("/captions/start", handlers.CaptionsStart(lkNodes, teacherKey, db, captions, cfg.Captions.Language))
+	api.POST("/captions/stop", handlers.CaptionsStop(lkNodes, teacherKey, db, captions))
+	api.GET("/lessons/:id/transcript", handlers.LessonTranscript(lkNodes, teacherKey, db))
+	r.GET(service.CaptionsIngestPath+":session", handlers.CaptionsIngest(captions))
+
+	// метаданные (RoomService): рука, аватар, язык; название/расписание урока
+	api.POST("/livekit/participant/metadata", handlers.LiveKitParticipantMetadata(lkNodes, teacherKey, db))
+	api.POST("/livekit/room/metadata", handlers.LiveKitRoomMetadata(lkNodes, teacherKey, db))
+
 	// ================================
 	// Health
 	// ================================
 	r.GET("/healthz", func(c *gin.Context) {
-		if err := db.Ping(); err != nil {
+		if err := db.PingContext(c.Request.Context()); err != nil {
+			logging.FromContext(c.Request.Context()).Error("healthz: db ping", "error", err)
 			c.String(nethttp.StatusServiceUnavailable, "db error")
 			return
 		}
 		c.String(nethttp.StatusOK, "ok")
 	})
 
+	// ================================
+	// Metrics (Prometheus)
+	// ================================
+	metrics.RegisterGauges(
+		func() float64 {
+			n, err := dbpkg.CountActiveLessons(context.Background(), db)
+			if err != nil {
+				slog.Warn("metrics: count active lessons", "error", err)
+			}
+			return float64(n)
+		},
+		func() float64 {
+			n, err := dbpkg.CountActiveParticipants(context.Background(), db)
+			if err != nil {
+				slog.Warn("metrics: count active participants", "error", err)
+			}
+			return float64(n)
+		},
+	)
+	// метрики по всем школам — только супер-админ (basic_auth в scrape_config Prometheus)
+	r.GET("/metrics",
+		middleware.AdminBasicAuth(func() (string, string) {
+			a := store.Get().Admin
+			return a.Username, a.Password
+		}, db),
+		middleware.SuperAdminOnly(),
+		gin.WrapH(metrics.Handler()),
+	)
+
 	// ================================
 	// Frontend (Vite)
 	// ================================
@@ -82,3 +266,101 @@ func RegisterRoutes(
 		c.File("./web/dist/index.html")
 	})
 }
+
+// newLiveKitNodes — LiveKit узлы (регионы / несколько серверов): общие для API и reaper'а
+func newLiveKitNodes(cfg *config.Config) *service.LiveKitNodes {
+	nodes := make([]*service.LiveKitNode, 0, len(cfg.LiveKit.Nodes))
+	for _, n := range cfg.LiveKit.Nodes {
+		nodes = append(nodes, &service.LiveKitNode{
+			ID:       n.ID,
+			Region:   n.Region,
+			Capacity: n.Capacity,
+			LiveKitService: service.NewLiveKitService(
+				n.APIKey,
+				n.APISecret,
+				n.Port,
+				n.Secure,
+				n.PublicHost,
+				n.APIURL,
+			),
+		})
+	}
+	return service.NewLiveKitNodes(nodes, cfg.LiveKit.Placement)
+}
+
+// newFileStorage — хранилище файлов уроков и антивирус по STORAGE_*
+func newFileStorage(cfg *config.Config) (storage.Storage, storage.Scanner, error) {
+	var scanner storage.Scanner = storage.NoopScanner{}
+	if cfg.Storage.Scanner == "clamav" {
+		scanner = storage.ClamAV{Addr: cfg.Storage.ClamAVAddr, Timeout: cfg.Storage.ScanTimeout}
+	}
+
+	if cfg.Storage.Backend == "s3" {
+		s3 := cfg.Storage.S3
+		files, err := storage.NewS3(storage.S3Config{
+			Endpoint:  s3.Endpoint,
+			Region:    s3.Region,
+			Bucket:    s3.Bucket,
+			AccessKey: s3.AccessKey,
+			SecretKey: s3.SecretKey,
+			PathStyle: s3.PathStyle,
+		})
+		return files, scanner, err
+	}
+
+	files, err := storage.NewLocal(cfg.Storage.LocalDir)
+	return files, scanner, err
+}
+
+// newCaptions — живые субтитры по CAPTIONS_*; nil => выключены
+func newCaptions(cfg *config.Config, db *sql.DB) *service.Captions {
+	var provider service.STTProvider
+	switch cfg.Captions.Provider {
+	case "fake":
+		provider = service.FakeSTT{}
+	default:
+		return nil
+	}
+	return service.NewCaptions(db, provider, cfg.Captions.IngestURL)
+}
+
+// newNotifier — уведомления по NOTIFY_*; nil => выключены
+func newNotifier(cfg *config.Config, db *sql.DB) (*service.Notifier, error) {
+	n := cfg.Notify
+	if n.WorkerInterval <= 0 {
+		return nil, nil
+	}
+
+	templates, err := notify.LoadTemplates(n.TemplatesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var channels []notify.Channel
+	if n.SMTP.Addr != "" {
+		channels = append(channels, notify.SMTP{
+			Addr:     n.SMTP.Addr,
+			From:     n.SMTP.From,
+			Username: n.SMTP.Username,
+			Password: n.SMTP.Password,
+		})
+	}
+	if n.Telegram.Token != "" {
+		channels = append(channels, notify.Telegram{Token: n.Telegram.Token, API: n.Telegram.API})
+	}
+	if n.WebPush.PrivateKey != "" {
+		wp, err := notify.NewWebPush(n.WebPush.PublicKey, n.WebPush.PrivateKey, n.WebPush.Subject)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, wp)
+	}
+
+	return service.NewNotifier(db, channels, templates, service.NotifierOptions{
+		JoinURL:        n.JoinURL,
+		Interval:       n.WorkerInterval,
+		ReminderBefore: n.ReminderBefore,
+		MaxAttempts:    n.MaxAttempts,
+		RetryBase:      n.RetryBase,
+	}), nil
+}
@@ -1,12 +1,26 @@
 package http
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	nethttp "net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	"streaming/internal/config"
+	dbpkg "streaming/internal/db"
+	"streaming/internal/metrics"
+	"streaming/internal/middleware"
+	"streaming/internal/service"
+	"streaming/internal/storage"
 )
 
 // =======================
@@ -14,12 +28,30 @@ import (
 // =======================
 
 func NewRouter(
-	cfg *config.Config,
+	store *config.Store,
 	db *sql.DB,
+	lkNodes *service.LiveKitNodes,
+	files storage.Storage,
+	scanner storage.Scanner,
+	captions *service.Captions,
+	notifier *service.Notifier,
 ) *gin.Engine {
-	r := gin.Default()
+	cfg := store.Get()
 
-	RegisterRoutes(r, cfg, db)
+	// gin.New вместо gin.Default: текстовый логгер gin заменён на slog access log
+	r := gin.New()
+	r.Use(
+		otelgin.Middleware(cfg.Tracing.ServiceName, otelgin.WithFilter(func(req *nethttp.Request) bool {
+			// scrape'ы и health-check'и не засоряют трассы
+			return req.URL.Path != "/metrics" && req.URL.Path != "/healthz"
+		})),
+		middleware.RequestID(slog.Default()),
+		middleware.AccessLog(),
+		gin.Recovery(),
+		metrics.HTTP(),
+	)
+
+	RegisterRoutes(r, store, db, lkNodes, files, scanner, captions, notifier)
 
 	return r
 }
@@ -28,14 +60,213 @@ func NewRouter(
 // HTTP server
 // =======================
 
+// NewServer — http.Server с таймаутами из конфига (r.Run их не ставит).
+func NewServer(cfg *config.Config, h nethttp.Handler) *nethttp.Server {
+	return &nethttp.Server{
+		Addr:              cfg.ListenAddr(),
+		Handler:           h,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+}
+
+// Run слушает до отмены ctx (SIGTERM/SIGINT в main), затем
+// аккуратно останавливает сервер через Shutdown.
+// При TLS_ENABLED основной listener — HTTPS, плюс опциональный
+// plain-HTTP listener для redirect'а (и ACME HTTP-01).
 func Run(
-	cfg *config.Config,
+	ctx context.Context,
+	store *config.Store,
 	db *sql.DB,
 ) error {
-	r := NewRouter(cfg, db)
+	cfg := store.Get()
+	lkNodes := newLiveKitNodes(cfg)
+	files, scanner, err := newFileStorage(cfg)
+	if err != nil {
+		return err
+	}
+	captions := newCaptions(cfg, db)
+	notifier, err := newNotifier(cfg, db)
+	if err != nil {
+		return err
+	}
+	srv := NewServer(cfg, NewRouter(store, db, lkNodes, files, scanner, captions, notifier))
+	servers := []*nethttp.Server{srv}
+
+	errCh := make(chan error, 2)
+	w := startWorkers(ctx)
+
+	// сверка открытых уроков с LiveKit; останавливается в Shutdown
+	if cfg.Reaper.Interval > 0 {
+		w.run(service.NewReaper(db, lkNodes, cfg.Reaper.Interval, cfg.Reaper.Grace).Run)
+	}
+
+	// очередь уведомлений и напоминания по расписанию
+	if notifier != nil {
+		w.run(notifier.Run)
+	}
+
+	if cfg.TLS.Enabled {
+		ts, err := newTLSSetup(ctx, cfg)
+		if err != nil {
+			_ = w.stop(context.Background())
+			return err
+		}
+		srv.TLSConfig = ts.config
+
+		go func() {
+			slog.Info("HTTPS listening", "addr", srv.Addr, "mode", cfg.TLS.Mode)
+			// сертификаты берутся из TLSConfig (GetCertificate)
+			errCh <- srv.ListenAndServeTLS("", "")
+		}()
+
+		if cfg.TLS.RedirectPort > 0 {
+			redirect := &nethttp.Server{
+				Addr:              net.JoinHostPort(cfg.ListenIP, strconv.Itoa(cfg.TLS.RedirectPort)),
+				Handler:           ts.httpHandler,
+				ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+				ReadTimeout:       cfg.HTTP.ReadTimeout,
+				WriteTimeout:      cfg.HTTP.WriteTimeout,
+				IdleTimeout:       cfg.HTTP.IdleTimeout,
+			}
+			servers = append(servers, redirect)
+
+			go func() {
+				slog.Info("HTTP redirect listening", "addr", redirect.Addr)
+				errCh <- redirect.ListenAndServe()
+			}()
+		}
+	} else {
+		go func() {
+			slog.Info("HTTP listening", "addr", srv.Addr)
+			errCh <- srv.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, nethttp.ErrServerClosed) {
+			return nil
+		}
+		// один listener упал (порт занят и т.п.) — гасим остальные
+		_ = Shutdown(cfg, db, w.stop, servers...)
+		return err
+	case <-ctx.Done():
+	}
+
+	return Shutdown(cfg, db, w.stop, servers...)
+}
+
+// =======================
+// Background workers
+// =======================
+
+// workers — фоновые циклы (reaper, уведомления) со своим ctx:
+// Shutdown отменяет его и ждёт выхода, чтобы main не закрыл пул БД посреди запроса
+type workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func startWorkers(parent context.Context) *workers {
+	ctx, cancel := context.WithCancel(parent)
+	return &workers{ctx: ctx, cancel: cancel}
+}
+
+func (w *workers) run(fn func(context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		fn(w.ctx)
+	}()
+}
+
+// stop отменяет ctx и ждёт все циклы (не дольше ctx)
+func (w *workers) stop(ctx context.Context) error {
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeLessonsTimeout — своё время на закрытие уроков: SHUTDOWN_TIMEOUT мог уйти целиком на drain
+const closeLessonsTimeout = 10 * time.Second
+
+// stopWorkersTimeout — сколько ждём выхода фоновых циклов (текущий запрос к БД / отправка письма)
+const stopWorkersTimeout = 10 * time.Second
+
+// Shutdown — последовательность остановки:
+//  1. перестаём принимать соединения и ждём текущие запросы (join'ы)
+//  2. останавливаем фоновые циклы и ждём их выхода
+//  3. (опционально) закрываем открытые уроки — даже если drain не уложился
+//
+// Пул БД закрывает вызывающий (main), уже после Shutdown.
+func Shutdown(cfg *config.Config, db *sql.DB, stopWorkers func(context.Context) error, servers ...*nethttp.Server) error {
+	var closeLessons func(context.Context) (int, error)
+	if cfg.Shutdown.CloseLessons {
+		closeLessons = func(ctx context.Context) (int, error) { return dbpkg.EndOpenLessons(ctx, db) }
+	}
+	return shutdown(cfg.Shutdown.Timeout, stopWorkers, closeLessons, servers...)
+}
+
+// shutdown — Shutdown без конфига и БД (stopWorkers / closeLessons == nil => пропускаем шаг)
+func shutdown(
+	timeout time.Duration,
+	stopWorkers func(context.Context) error,
+	closeLessons func(context.Context) (int, error),
+	servers ...*nethttp.Server,
+) error {
+	slog.Info("HTTP shutting down", "timeout", timeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			// не дождались — рвём оставшиеся соединения
+			_ = srv.Close()
+			errs = append(errs, fmt.Errorf("http shutdown %s: %w", srv.Addr, err))
+		}
+	}
+
+	if stopWorkers != nil {
+		wctx, cancel := context.WithTimeout(context.Background(), stopWorkersTimeout)
+		defer cancel()
+
+		if err := stopWorkers(wctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop background workers: %w", err))
+		}
+	}
+
+	if closeLessons != nil {
+		lctx, cancel := context.WithTimeout(context.Background(), closeLessonsTimeout)
+		defer cancel()
 
-	addr := cfg.ListenAddr()
-	log.Printf("HTTP listening on %s\n", addr)
+		n, err := closeLessons(lctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("close open lessons: %w", err))
+		} else {
+			slog.Info("open lessons closed on shutdown", "count", n)
+		}
+	}
 
-	return r.Run(addr)
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	slog.Info("HTTP stopped")
+	return nil
 }
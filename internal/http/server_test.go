@@ -0,0 +1,179 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	nethttp "net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startServer — сервер на свободном порту с handler'ом
+func startServer(t *testing.T, h nethttp.HandlerFunc) (*nethttp.Server, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &nethttp.Server{Handler: h}
+	go func() { _ = srv.Serve(ln) }()
+	return srv, "http://" + ln.Addr().String()
+}
+
+// steps — порядок шагов остановки
+type steps struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (s *steps) add(step string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.list = append(s.list, step)
+}
+
+func (s *steps) get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.list, ",")
+}
+
+func TestShutdownDrainsThenClosesLessons(t *testing.T) {
+	var order steps
+	started := make(chan struct{})
+	srv, url := startServer(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		order.add("request")
+		w.WriteHeader(nethttp.StatusOK)
+	})
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := nethttp.Get(url)
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-started
+
+	err := shutdown(2*time.Second, nil, func(ctx context.Context) (int, error) {
+		if _, ok := ctx.Deadline(); !ok || ctx.Err() != nil {
+			t.Errorf("close lessons ctx: deadline=%v err=%v", ok, ctx.Err())
+		}
+		order.add("lessons")
+		return 3, nil
+	}, srv)
+	if err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if got := order.get(); got != "request,lessons" {
+		t.Fatalf("order = %q, want request,lessons", got)
+	}
+	if code := <-status; code != nethttp.StatusOK {
+		t.Fatalf("in-flight request status = %d, want 200", code)
+	}
+	if _, err := nethttp.Get(url); err == nil {
+		t.Fatal("server still accepts connections after shutdown")
+	}
+}
+
+func TestShutdownClosesLessonsAfterDrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{})
+	srv, url := startServer(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		close(started)
+		<-release
+	})
+	go func() {
+		if resp, err := nethttp.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	called := false
+	err := shutdown(50*time.Millisecond, nil, func(ctx context.Context) (int, error) {
+		called = true
+		// drain съел весь SHUTDOWN_TIMEOUT — у уроков должно остаться своё время
+		if ctx.Err() != nil {
+			t.Errorf("close lessons got an expired ctx: %v", ctx.Err())
+		}
+		return 1, nil
+	}, srv)
+
+	if !called {
+		t.Fatal("lessons were not closed after a drain timeout")
+	}
+	if err == nil || !strings.Contains(err.Error(), "http shutdown") {
+		t.Fatalf("err = %v, want http shutdown error", err)
+	}
+}
+
+func TestShutdownReportsCloseLessonsError(t *testing.T) {
+	srv, _ := startServer(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {})
+
+	boom := errors.New("db down")
+	err := shutdown(time.Second, nil, func(context.Context) (int, error) { return 0, boom }, srv)
+	if !errors.Is(err, boom) {
+		t.Fatalf("err = %v, want %v", err, boom)
+	}
+}
+
+func TestShutdownWithoutClosingLessons(t *testing.T) {
+	srv, _ := startServer(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {})
+
+	if err := shutdown(time.Second, nil, nil, srv); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+func TestShutdownWaitsForWorkersBeforeClosingLessons(t *testing.T) {
+	srv, _ := startServer(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {})
+
+	var order steps
+	w := startWorkers(context.Background())
+	running := make(chan struct{})
+	w.run(func(ctx context.Context) {
+		close(running)
+		<-ctx.Done()
+		// "запрос к БД" дорабатывает после отмены
+		time.Sleep(100 * time.Millisecond)
+		order.add("worker")
+	})
+	<-running
+
+	err := shutdown(time.Second, w.stop, func(context.Context) (int, error) {
+		order.add("lessons")
+		return 0, nil
+	}, srv)
+	if err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if got := order.get(); got != "worker,lessons" {
+		t.Fatalf("order = %q, want worker,lessons", got)
+	}
+}
+
+func TestWorkersStopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	w := startWorkers(context.Background())
+	w.run(func(context.Context) { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := w.stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("stop = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
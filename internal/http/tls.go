@@ -0,0 +1,208 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	nethttp "net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"streaming/internal/config"
+)
+
+// =======================
+// Certificate files (hot reload)
+// =======================
+
+// certReloader отдаёт сертификат из файлов и перечитывает их,
+// когда меняется mtime (certbot renew, k8s secret update и т.п.).
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS key pair: %w", err)
+	}
+
+	mt, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = mt
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range []string{r.certFile, r.keyFile} {
+		st, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if st.ModTime().After(latest) {
+			latest = st.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// watch проверяет файлы каждые interval до отмены ctx.
+// Битый/недописанный файл не роняет сервер: остаётся старый сертификат.
+func (r *certReloader) watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		mt, err := r.latestModTime()
+		if err != nil {
+			slog.Warn("tls: stat certificate", "error", err)
+			continue
+		}
+
+		r.mu.RLock()
+		changed := mt.After(r.modTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := r.load(); err != nil {
+			slog.Error("tls: certificate reload failed, keeping old one", "error", err)
+			continue
+		}
+		slog.Info("tls: certificate reloaded", "cert_file", r.certFile)
+	}
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// =======================
+// TLS setup
+// =======================
+
+// tlsSetup — tls.Config для основного сервера и (опционально)
+// обработчик для plain-HTTP listener'а (ACME HTTP-01 + redirect).
+type tlsSetup struct {
+	config      *tls.Config
+	httpHandler nethttp.Handler
+}
+
+func newTLSSetup(ctx context.Context, cfg *config.Config) (*tlsSetup, error) {
+	redirect := redirectHandler(cfg.Port)
+
+	if cfg.TLS.Mode == "acme" {
+		m, err := newACMEManager(cfg)
+		if err != nil {
+			return nil, err
+		}
+		tc := m.TLSConfig()
+		tc.MinVersion = tls.VersionTLS12
+		return &tlsSetup{
+			config:      tc,
+			httpHandler: m.HTTPHandler(redirect),
+		}, nil
+	}
+
+	reloader, err := newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	go reloader.watch(ctx, cfg.TLS.ReloadInterval)
+
+	return &tlsSetup{
+		config: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		},
+		httpHandler: redirect,
+	}, nil
+}
+
+func newACMEManager(cfg *config.Config) (*autocert.Manager, error) {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.TLS.ACME.Domains...),
+		Cache:      autocert.DirCache(cfg.TLS.ACME.CacheDir),
+		Email:      cfg.TLS.ACME.Email,
+	}
+
+	// локальный тестовый CA (pebble, step-ca): свой directory URL
+	// и, как правило, самоподписанный корень, которому надо доверять
+	if cfg.TLS.ACME.DirectoryURL != "" {
+		client := &acme.Client{DirectoryURL: cfg.TLS.ACME.DirectoryURL}
+
+		if cfg.TLS.ACME.CARootFile != "" {
+			pem, err := os.ReadFile(cfg.TLS.ACME.CARootFile)
+			if err != nil {
+				return nil, fmt.Errorf("read ACME CA root: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New("ACME CA root: no certificates found")
+			}
+			client.HTTPClient = &nethttp.Client{
+				Timeout: 30 * time.Second,
+				Transport: &nethttp.Transport{
+					TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+				},
+			}
+		}
+
+		m.Client = client
+	}
+
+	return m, nil
+}
+
+// redirectHandler: http://host[:80]/path → https://host:<port>/path
+func redirectHandler(httpsPort int) nethttp.Handler {
+	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+
+		nethttp.Redirect(w, r, "https://"+host+r.URL.RequestURI(), nethttp.StatusMovedPermanently)
+	})
+}
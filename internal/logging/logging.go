@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type ctxKey struct{}
+
+// level — общий для всех логгеров; меняется на лету (SIGHUP)
+var level = new(slog.LevelVar)
+
+// New создаёт логгер: format "json" (по умолчанию) или "text",
+// level: debug | info | warn | error.
+func New(w io.Writer, format, lvl string) *slog.Logger {
+	SetLevel(lvl)
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	if strings.EqualFold(strings.TrimSpace(format), "text") {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.New(h)
+}
+
+// Setup — New + slog.SetDefault (stdlib log.Printf тоже уходит в slog).
+func Setup(format, lvl string) *slog.Logger {
+	l := New(os.Stdout, format, lvl)
+	slog.SetDefault(l)
+	return l
+}
+
+// SetLevel меняет уровень логирования без пересоздания логгеров.
+func SetLevel(lvl string) {
+	level.Set(parseLevel(lvl))
+}
+
+func parseLevel(s string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// =======================
+// Context propagation
+// =======================
+
+// FromContext — логгер запроса (с request_id и т.д.) или slog.Default().
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+			return l
+		}
+	}
+	return slog.Default()
+}
+
+// WithLogger кладёт логгер в контекст.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// With добавляет поля (room, lesson_id, identity, role...) к логгеру в контексте.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
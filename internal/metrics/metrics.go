@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ⚠️ Метки должны быть ограниченными: никаких room/name/identity,
+// иначе каждая новая комната создаёт новый временной ряд.
+
+const namespace = "classroom"
+
+// Registry — собственный реестр (не глобальный default), чтобы
+// /metrics отдавал только то, что мы явно зарегистрировали.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// =======================
+	// HTTP
+	// =======================
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status.",
+	}, []string{"route", "method", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// =======================
+	// Join / LiveKit
+	// =======================
+	JoinRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "join_requests_total",
+		Help:      "Join requests by outcome and granted role.",
+	}, []string{"outcome", "role"})
+
+	TokenRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_refreshes_total",
+		Help:      "LiveKit token refresh requests by outcome.",
+	}, []string{"outcome"})
+
+	TokenIssueDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "livekit_token_issue_duration_seconds",
+		Help:      "Time spent signing LiveKit access tokens.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1},
+	})
+
+	// =======================
+	// Reaper
+	// =======================
+	ReaperRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reaper_runs_total",
+		Help:      "Reconciliation passes of open lessons against LiveKit by outcome.",
+	}, []string{"outcome"})
+
+	ReaperFixes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reaper_fixes_total",
+		Help:      "Orphaned lessons and participants closed by the reaper, by kind.",
+	}, []string{"kind"})
+
+	// =======================
+	// Files
+	// =======================
+	Uploads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "uploads_total",
+		Help:      "Lesson file uploads by outcome.",
+	}, []string{"outcome"})
+
+	UploadBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes of accepted lesson file uploads.",
+	})
+
+	// =======================
+	// Captions
+	// =======================
+	CaptionSessions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "caption_sessions_total",
+		Help:      "Live caption sessions by lifecycle event (started, ended, failures).",
+	}, []string{"outcome"})
+
+	CaptionSegments = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "caption_segments_total",
+		Help:      "Final caption segments recognized and stored in lesson transcripts.",
+	})
+
+	// =======================
+	// Notifications
+	// =======================
+	Notifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "Notification delivery attempts by channel and outcome (sent, retry, failed).",
+	}, []string{"channel", "outcome"})
+
+	// =======================
+	// Database
+	// =======================
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of internal/db functions.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"func"})
+
+	// =======================
+	// API errors
+	// =======================
+	APIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_errors_total",
+		Help:      "apierr responses by error code and HTTP status.",
+	}, []string{"code", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		JoinRequests,
+		TokenRefreshes,
+		TokenIssueDuration,
+		ReaperRuns,
+		ReaperFixes,
+		Uploads,
+		UploadBytes,
+		CaptionSessions,
+		CaptionSegments,
+		Notifications,
+		DBQueryDuration,
+		APIErrors,
+	)
+}
+
+// RegisterGauges регистрирует gauge'и, значения которых считаются
+// в момент scrape (например, из БД), поэтому не "дрейфуют" после рестарта.
+// Повторный вызов (второй роутер, тесты) не паникует: gauge'и переключаются на новые функции.
+func RegisterGauges(activeLessons, activeParticipants func() float64) {
+	replace(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_lessons",
+		Help:      "Lessons that are started and not ended.",
+	}, activeLessons))
+	replace(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_participants",
+		Help:      "Participants currently in an active lesson.",
+	}, activeParticipants))
+}
+
+// replace — Register, а уже зарегистрированный collector с тем же именем заменяется
+func replace(c prometheus.Collector) {
+	err := Registry.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		Registry.Unregister(are.ExistingCollector)
+		err = Registry.Register(c)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Handler — http.Handler для /metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveDB возвращает функцию, которую нужно вызвать по завершении запроса:
+//
+//	defer metrics.ObserveDB("StartLesson")()
+func ObserveDB(fn string) func() {
+	start := time.Now()
+	return func() {
+		DBQueryDuration.WithLabelValues(fn).Observe(time.Since(start).Seconds())
+	}
+}
+
+// HTTP — middleware: считает запросы по шаблону маршрута (c.FullPath),
+// а не по реальному пути, чтобы /join/<room> не раздувал кардинальность.
+func HTTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := normalizeMethod(c.Request.Method)
+
+		HTTPRequests.WithLabelValues(route, method, strconv.Itoa(c.Writer.Status())).Inc()
+		HTTPDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	}
+}
+
+// normalizeMethod — метод приходит от клиента как есть ("FOO", "GET2"...):
+// всё кроме стандартных — в одну метку OTHER
+func normalizeMethod(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	}
+	return "OTHER"
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return w.Body.String()
+}
+
+func TestRegisterGaugesTwice(t *testing.T) {
+	RegisterGauges(func() float64 { return 1 }, func() float64 { return 2 })
+	// второй вызов не паникует, scrape видит новые функции
+	RegisterGauges(func() float64 { return 3 }, func() float64 { return 4 })
+
+	body := scrape(t)
+	for _, want := range []string{"classroom_active_lessons 3", "classroom_active_participants 4"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape has no %q", want)
+		}
+	}
+}
+
+func TestNormalizeMethod(t *testing.T) {
+	for m, want := range map[string]string{
+		"GET":      "GET",
+		"OPTIONS":  "OPTIONS",
+		"get":      "OTHER",
+		"PROPFIND": "OTHER",
+		"":         "OTHER",
+	} {
+		if got := normalizeMethod(m); got != want {
+			t.Errorf("normalizeMethod(%q) = %q, want %q", m, got, want)
+		}
+	}
+}
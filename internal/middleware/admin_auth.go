@@ -1,19 +1,85 @@
 package middleware
 
 import (
-	"net/http"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
 
 	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
 )
 
-func AdminBasicAuth(username, password string) gin.HandlerFunc {
+const adminScopeKey = "admin_scope"
+
+// AdminScope — кто вошёл в /api/admin:
+// супер-админ (ADMIN_USERNAME из конфига) видит все школы,
+// администратор школы (tenant_admins) — только свою.
+type AdminScope struct {
+	Super  bool
+	Tenant *db.Tenant // nil для супер-админа
+}
+
+// creds читаются на каждый запрос (перечитываются по SIGHUP)
+func AdminBasicAuth(creds func() (username, password string), dbConn *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		username, password := creds()
 		u, p, ok := c.Request.BasicAuth()
-		if !ok || u != username || p != password {
-			c.Header("WWW-Authenticate", `Basic realm="Admin Area"`)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "unauthorized",
-			})
+		if !ok {
+			adminUnauthorized(c)
+			return
+		}
+
+		if superAdminOK(u, p, username, password) {
+			c.Set(adminScopeKey, AdminScope{Super: true})
+			c.Next()
+			return
+		}
+
+		t, err := db.TenantByAdmin(c.Request.Context(), dbConn, u, p)
+		if err != nil {
+			if !errors.Is(err, db.ErrTenantNotFound) {
+				logging.FromContext(c.Request.Context()).Error("tenant admin lookup", "error", err)
+			}
+			adminUnauthorized(c)
+			return
+		}
+
+		c.Set(adminScopeKey, AdminScope{Tenant: t})
+		SetTenant(c, t)
+		c.Next()
+	}
+}
+
+// superAdminOK — оба поля сравниваются всегда и за постоянное время (как HostAuth.credentialsOK)
+func superAdminOK(u, p, username, password string) bool {
+	uOK := subtle.ConstantTimeCompare([]byte(u), []byte(username)) == 1
+	pOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+	return uOK && pOK
+}
+
+func adminUnauthorized(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Basic realm="Admin Area"`)
+	apierr.Abort(c, apierr.Unauthorized)
+}
+
+// GetAdminScope — scope текущего админ-запроса
+func GetAdminScope(c *gin.Context) AdminScope {
+	if v, ok := c.Get(adminScopeKey); ok {
+		if s, ok := v.(AdminScope); ok {
+			return s
+		}
+	}
+	return AdminScope{}
+}
+
+// SuperAdminOnly — маршруты только для супер-админа
+func SuperAdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !GetAdminScope(c).Super {
+			apierr.Abort(c, apierr.Forbidden)
 			return
 		}
 		c.Next()
@@ -1,19 +1,44 @@
 package middleware
 
 import (
-	"net/http"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
 
 	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+	"streaming/internal/db"
+	"streaming/internal/logging"
 )
 
-func APIAuth(secret string) gin.HandlerFunc {
+// APIAuth: Authorization = глобальный API_KEY_SECRET (школа по умолчанию)
+// или API ключ конкретной школы (tenants.api_key_hash).
+// secret читается на каждый запрос (перечитывается по SIGHUP)
+func APIAuth(secret func() string, dbConn *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
-		if auth == "" || auth != secret {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-			c.Abort()
+		if auth == "" {
+			apierr.Abort(c, apierr.Unauthorized)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(auth), []byte(secret())) == 1 {
+			SetTenant(c, defaultTenant)
+			c.Next()
 			return
 		}
+
+		t, err := db.TenantByAPIKey(c.Request.Context(), dbConn, auth)
+		if err != nil {
+			if !errors.Is(err, db.ErrTenantNotFound) {
+				logging.FromContext(c.Request.Context()).Error("tenant api key lookup", "error", err)
+			}
+			apierr.Abort(c, apierr.Unauthorized)
+			return
+		}
+
+		SetTenant(c, t)
 		c.Next()
 	}
 }
@@ -1,18 +1,247 @@
 package middleware
 
-import "sync"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+)
+
+// HostSessionCookie — cookie, которую выдаёт страница входа
+const HostSessionCookie = "host_session"
+
+// HostLoginPath — страница входа (GET форма, POST проверка)
+const HostLoginPath = "/host/login"
+
+// HostAuth — "ворота" всего сайта (HOST_PROTECTED).
+// Пускаем, если есть одно из:
+//   - валидный Basic Auth (HOST_USERNAME / HOST_PASSWORD)
+//   - cookie сессии после входа через форму
+//   - IP клиента, авторизованный входом (не истёк) — только с allowIP (HOST_ALLOW_IP):
+//     за NAT / прокси один вход пускает всех с того же адреса
 type HostAuth struct {
-	mu  sync.RWMutex
-	ips map[string]bool
+	username string
+	password string
+	ttl      time.Duration
+	allowIP  bool
+
+	mu       sync.RWMutex
+	ips      map[string]time.Time // ip => expires
+	sessions map[string]time.Time // token => expires
 }
 
-func NewHostAuth() *HostAuth {
-	return &HostAuth{ips: make(map[string]bool)}
+func NewHostAuth(username, password string, ttl time.Duration, allowIP bool) *HostAuth {
+	return &HostAuth{
+		username: username,
+		password: password,
+		ttl:      ttl,
+		allowIP:  allowIP,
+		ips:      make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+	}
 }
 
+// Authorize пускает IP на ttl
 func (h *HostAuth) Authorize(ip string) {
 	h.mu.Lock()
-	h.ips[ip] = true
+	h.ips[ip] = time.Now().Add(h.ttl)
+	h.purgeLocked()
+	h.mu.Unlock()
+}
+
+// NewSession создаёт токен сессии на ttl
+func (h *HostAuth) NewSession() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	h.mu.Lock()
+	h.sessions[token] = time.Now().Add(h.ttl)
+	h.purgeLocked()
 	h.mu.Unlock()
+
+	return token, nil
+}
+
+func (h *HostAuth) ipAllowed(ip string) bool {
+	if !h.allowIP {
+		return false
+	}
+	h.mu.RLock()
+	exp, ok := h.ips[ip]
+	h.mu.RUnlock()
+	return ok && time.Now().Before(exp)
+}
+
+func (h *HostAuth) sessionValid(token string) bool {
+	if token == "" {
+		return false
+	}
+	h.mu.RLock()
+	exp, ok := h.sessions[token]
+	h.mu.RUnlock()
+	return ok && time.Now().Before(exp)
+}
+
+// purgeLocked — чистим истёкшие записи, чтобы map не рос бесконечно
+func (h *HostAuth) purgeLocked() {
+	now := time.Now()
+	for k, exp := range h.ips {
+		if now.After(exp) {
+			delete(h.ips, k)
+		}
+	}
+	for k, exp := range h.sessions {
+		if now.After(exp) {
+			delete(h.sessions, k)
+		}
+	}
+}
+
+func (h *HostAuth) credentialsOK(u, p string) bool {
+	uOK := subtle.ConstantTimeCompare([]byte(u), []byte(h.username)) == 1
+	pOK := subtle.ConstantTimeCompare([]byte(p), []byte(h.password)) == 1
+	return uOK && pOK
+}
+
+// =======================
+// Check middleware
+// =======================
+
+// Check — middleware на весь сайт (frontend + API).
+// exempt — пути без проверки (health-check и т.п.).
+func (h *HostAuth) Check(exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := c.Request.URL.Path
+
+		if p == HostLoginPath {
+			c.Next()
+			return
+		}
+		// "/x/" в exempt — весь префикс (путь с параметром)
+		for _, e := range exempt {
+			if p == e || (strings.HasSuffix(e, "/") && strings.HasPrefix(p, e)) {
+				c.Next()
+				return
+			}
+		}
+
+		if u, pw, ok := c.Request.BasicAuth(); ok && h.credentialsOK(u, pw) {
+			c.Next()
+			return
+		}
+
+		// /api/admin использует свой Basic Auth в том же заголовке —
+		// для него достаточно сессии/IP
+		if token, err := c.Cookie(HostSessionCookie); err == nil && h.sessionValid(token) {
+			c.Next()
+			return
+		}
+		if h.ipAllowed(c.ClientIP()) {
+			c.Next()
+			return
+		}
+
+		// API-клиентам — 401, браузеру — страница входа
+		if strings.HasPrefix(p, "/api/") {
+			c.Header("WWW-Authenticate", `Basic realm="Classroom"`)
+			apierr.Write(c, apierr.HostAuthRequired)
+			c.Abort()
+			return
+		}
+
+		c.Redirect(http.StatusFound, HostLoginPath+"?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+		c.Abort()
+	}
+}
+
+// =======================
+// Login page
+// =======================
+
+var hostLoginTmpl = template.Must(template.New("login").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Login</title>
+<style>
+body{font-family:system-ui,sans-serif;background:#0f172a;color:#e2e8f0;display:flex;align-items:center;justify-content:center;height:100vh;margin:0}
+form{background:#1e293b;padding:24px;border-radius:12px;display:flex;flex-direction:column;gap:12px;min-width:260px}
+input,button{padding:10px;border-radius:8px;border:1px solid #334155;font-size:15px}
+button{background:#2563eb;color:#fff;border:none;cursor:pointer}
+.err{color:#f87171}
+</style>
+</head>
+<body>
+<form method="post" action="{{.Action}}">
+<h3>🔒 Classroom</h3>
+{{if .Error}}<div class="err">{{.Error}}</div>{{end}}
+<input name="username" placeholder="Username" autocomplete="username" required>
+<input name="password" type="password" placeholder="Password" autocomplete="current-password" required>
+<input type="hidden" name="next" value="{{.Next}}">
+<button type="submit">Login</button>
+</form>
+</body>
+</html>`))
+
+func (h *HostAuth) renderLogin(c *gin.Context, status int, next, errMsg string) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	_ = hostLoginTmpl.Execute(c.Writer, gin.H{
+		"Action": HostLoginPath,
+		"Next":   next,
+		"Error":  errMsg,
+	})
+}
+
+// LoginPage — GET /host/login
+func (h *HostAuth) LoginPage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.renderLogin(c, http.StatusOK, safeNext(c.Query("next")), "")
+	}
+}
+
+// Login — POST /host/login: проверка, cookie сессии (+ IP при allowIP) на ttl
+func (h *HostAuth) Login(secureCookie bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		next := safeNext(c.PostForm("next"))
+
+		if !h.credentialsOK(c.PostForm("username"), c.PostForm("password")) {
+			h.renderLogin(c, http.StatusUnauthorized, next, "Invalid username or password")
+			return
+		}
+
+		token, err := h.NewSession()
+		if err != nil {
+			h.renderLogin(c, http.StatusInternalServerError, next, "Login failed, try again")
+			return
+		}
+		if h.allowIP {
+			h.Authorize(c.ClientIP())
+		}
+
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(HostSessionCookie, token, int(h.ttl.Seconds()), "/", "", secureCookie, true)
+		c.Redirect(http.StatusSeeOther, next)
+	}
+}
+
+// safeNext — только локальные пути (без open redirect на чужой домен)
+func safeNext(next string) string {
+	if next == "" || !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
 }
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func hostRouter(h *HostAuth, loginPerMinute int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(h.Check())
+	r.POST(HostLoginPath, RateLimit(func() int { return loginPerMinute }), h.Login(false))
+	r.GET("/api/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	return r
+}
+
+func hostLogin(r *gin.Engine, password string) *httptest.ResponseRecorder {
+	form := url.Values{"username": {"host"}, "password": {password}}
+	req := httptest.NewRequest(http.MethodPost, HostLoginPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func ping(r *gin.Engine, cookies ...*http.Cookie) int {
+	req := httptest.NewRequest(http.MethodGet, "/api/ping", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestHostLoginDoesNotAuthorizeIPByDefault(t *testing.T) {
+	r := hostRouter(NewHostAuth("host", "secret", time.Hour, false), 0)
+
+	w := hostLogin(r, "secret")
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("login = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	// httptest шлёт всё с одного адреса: без cookie — не пускаем
+	if code := ping(r); code != http.StatusUnauthorized {
+		t.Fatalf("same IP without cookie = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := ping(r, w.Result().Cookies()...); code != http.StatusOK {
+		t.Fatalf("with session cookie = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestHostLoginAuthorizesIPWhenAllowed(t *testing.T) {
+	r := hostRouter(NewHostAuth("host", "secret", time.Hour, true), 0)
+
+	if w := hostLogin(r, "secret"); w.Code != http.StatusSeeOther {
+		t.Fatalf("login = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	if code := ping(r); code != http.StatusOK {
+		t.Fatalf("same IP with HOST_ALLOW_IP = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestHostLoginRateLimited(t *testing.T) {
+	r := hostRouter(NewHostAuth("host", "secret", time.Hour, false), 3)
+
+	for i := 0; i < 3; i++ {
+		if w := hostLogin(r, "wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d = %d, want %d", i+1, w.Code, http.StatusUnauthorized)
+		}
+	}
+	// лимит исчерпан — даже верный пароль не проверяем
+	if w := hostLogin(r, "secret"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("attempt over the limit = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
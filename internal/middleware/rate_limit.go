@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+)
+
+// RateLimit — простое окно в 1 минуту на IP клиента.
+// limit читается на каждый запрос, поэтому меняется на лету (SIGHUP).
+// limit() <= 0 => без ограничения.
+func RateLimit(limit func() int) gin.HandlerFunc {
+	type window struct {
+		start time.Time
+		count int
+	}
+
+	var (
+		mu      sync.Mutex
+		windows = map[string]*window{}
+		sweep   = time.Now()
+	)
+
+	return func(c *gin.Context) {
+		max := limit()
+		if max <= 0 {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		ip := c.ClientIP()
+
+		mu.Lock()
+		// раз в минуту выкидываем старые окна
+		if now.Sub(sweep) > time.Minute {
+			for k, w := range windows {
+				if now.Sub(w.start) > time.Minute {
+					delete(windows, k)
+				}
+			}
+			sweep = now
+		}
+
+		w, ok := windows[ip]
+		if !ok || now.Sub(w.start) > time.Minute {
+			w = &window{start: now}
+			windows[ip] = w
+		}
+		w.count++
+		over := w.count > max
+		retry := time.Minute - now.Sub(w.start)
+		mu.Unlock()
+
+		if over {
+			c.Header("Retry-After", strconv.Itoa(int(retry.Seconds())+1))
+			apierr.Write(c, apierr.RateLimited)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
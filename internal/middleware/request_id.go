@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"streaming/internal/logging"
+	"streaming/internal/tracing"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+)
+
+// RequestID берёт X-Request-ID от прокси (если разумной длины) или генерирует новый,
+// отдаёт его в ответе и кладёт логгер с request_id в context запроса.
+func RequestID(base *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := strings.TrimSpace(c.GetHeader(RequestIDHeader))
+		if id == "" || len(id) > 64 {
+			id = uuid.NewString()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+
+		l := base.With("request_id", id)
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			l = l.With("trace_id", traceID)
+		}
+
+		ctx := logging.WithLogger(c.Request.Context(), l)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// GetRequestID — id текущего запроса ("" если middleware не подключен)
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// LogWith добавляет поля к логгеру запроса (их увидят и DB-слой, и access log).
+func LogWith(c *gin.Context, args ...any) {
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), args...))
+}
+
+// AccessLog — замена текстового логгера gin.Default()
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		l := logging.FromContext(c.Request.Context())
+		l.Log(c.Request.Context(), level, "http request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+
+		for _, e := range c.Errors {
+			l.Error("handler error", "error", e.Err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/apierr"
+)
+
+// TeacherHeader — ключ учителя для GET-маршрутов (отчёты, выгрузки)
+const TeacherHeader = "X-Teacher-Key"
+
+// TeacherOnly — только с ключом учителя школы (после APIAuth).
+// globalKey перечитывается по SIGHUP.
+func TeacherOnly(globalKey func() string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(TeacherHeader))
+		if !GetTenant(c).TeacherAllowed(globalKey(), key) {
+			apierr.Abort(c, apierr.TeacherKeyRequired)
+			return
+		}
+		c.Next()
+	}
+}
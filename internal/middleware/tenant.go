@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"streaming/internal/db"
+)
+
+const tenantKey = "tenant"
+
+// defaultTenant — школа для глобального API_KEY_SECRET (без похода в БД)
+var defaultTenant = &db.Tenant{ID: db.DefaultTenantID, Slug: "default", Name: "Default"}
+
+func SetTenant(c *gin.Context, t *db.Tenant) {
+	c.Set(tenantKey, t)
+	LogWith(c, "tenant", t.Slug)
+}
+
+// GetTenant — школа текущего запроса (ставит APIAuth).
+// Без APIAuth — школа по умолчанию.
+func GetTenant(c *gin.Context) *db.Tenant {
+	if v, ok := c.Get(tenantKey); ok {
+		if t, ok := v.(*db.Tenant); ok {
+			return t
+		}
+	}
+	return defaultTenant
+}
@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Каналы доставки (notification_prefs.channels, notifications.channel)
+const (
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+	ChannelWebPush  = "webpush"
+)
+
+// ErrPermanent — повтор не поможет: адрес неверный, чат удалён, подписка отозвана
+var ErrPermanent = errors.New("permanent delivery failure")
+
+// Message — одно сообщение одному получателю
+type Message struct {
+	To      string // email / chat id / подписка Web Push (JSON)
+	Subject string
+	Text    string
+	Link    string // вход в урок
+}
+
+// Channel — способ доставки. Ошибка с ErrPermanent — сообщение больше не пробуем,
+// любая другая — повторим позже.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, m Message) error
+}
+
+// KnownChannel — имя канала из списка выше
+func KnownChannel(name string) bool {
+	return name == ChannelEmail || name == ChannelTelegram || name == ChannelWebPush
+}
+
+func permanent(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrPermanent, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// SMTP — письма через SMTP-сервер (STARTTLS, если сервер его предлагает)
+type SMTP struct {
+	Addr     string // host:port
+	From     string
+	Username string // пусто => без AUTH
+	Password string
+	Timeout  time.Duration
+}
+
+func (s SMTP) Name() string { return ChannelEmail }
+
+func (s SMTP) Send(ctx context.Context, m Message) error {
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return permanent("invalid email address %q", m.To)
+	}
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("smtp: invalid sender %q: %w", s.From, err)
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	host, _, _ := net.SplitHostPort(s.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if s.Username != "" {
+		// PlainAuth сам откажется слать пароль без TLS (кроме localhost)
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return smtpError(err)
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return smtpError(err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return smtpError(err)
+	}
+	if _, err := w.Write(buildMail(from, to, m)); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return smtpError(err)
+	}
+	return c.Quit()
+}
+
+// smtpError — 5xx: адрес отвергнут, повтор не поможет; 4xx — временно
+func smtpError(err error) error {
+	var te *textproto.Error
+	if errors.As(err, &te) && te.Code >= 500 {
+		return permanent("smtp %d %s", te.Code, te.Msg)
+	}
+	return fmt.Errorf("smtp: %w", err)
+}
+
+// buildMail — text/plain UTF-8; переводы строк DATA-writer превращает в CRLF
+func buildMail(from, to *mail.Address, m Message) []byte {
+	var id [12]byte
+	_, _ = rand.Read(id[:])
+	domain := from.Address[strings.LastIndexByte(from.Address, '@')+1:]
+
+	// перевод строки в теме — это уже чужой заголовок
+	subject := strings.Join(strings.Fields(m.Subject), " ")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\n", from.String())
+	fmt.Fprintf(&b, "To: %s\n", to.String())
+	fmt.Fprintf(&b, "Subject: %s\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\n", hex.EncodeToString(id[:]), domain)
+	b.WriteString("MIME-Version: 1.0\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\n\n")
+	b.WriteString(m.Text)
+	if m.Link != "" && !strings.Contains(m.Text, m.Link) {
+		b.WriteString("\n\n" + m.Link)
+	}
+	b.WriteString("\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMTPStub — SMTP-заглушка для локальной разработки и тестов: принимает любые
+// письма (и любой AUTH) и печатает их в Out. Не для production.
+type SMTPStub struct {
+	Addr string
+	Out  io.Writer
+
+	mu sync.Mutex // письма из разных соединений не перемешиваем
+}
+
+// ListenAndServe — до отмены ctx
+func (s *SMTPStub) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	slog.Info("SMTP stub listening", "addr", ln.Addr().String())
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.serve(conn)
+	}
+}
+
+func (s *SMTPStub) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	reply := func(line string) bool {
+		_ = conn.SetWriteDeadline(time.Now().Add(time.Minute))
+		w.WriteString(line + "\r\n")
+		return w.Flush() == nil
+	}
+	readLine := func() (string, error) {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		line, err := r.ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	var from string
+	var rcpt []string
+
+	if !reply("220 localhost smtp-stub") {
+		return
+	}
+	for {
+		line, err := readLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-localhost\r\n250-8BITMIME\r\n250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			reply("235 2.7.0 accepted")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			from, rcpt = strings.TrimSpace(line[len("MAIL FROM:"):]), nil
+			reply("250 2.1.0 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			rcpt = append(rcpt, strings.TrimSpace(line[len("RCPT TO:"):]))
+			reply("250 2.1.5 ok")
+		case cmd == "DATA":
+			if len(rcpt) == 0 {
+				reply("503 5.5.1 need RCPT")
+				continue
+			}
+			reply("354 end with <CRLF>.<CRLF>")
+			body, err := readData(readLine)
+			if err != nil {
+				return
+			}
+			s.print(from, rcpt, body)
+			reply("250 2.0.0 queued")
+			from, rcpt = "", nil
+		case cmd == "RSET":
+			from, rcpt = "", nil
+			reply("250 ok")
+		case cmd == "NOOP":
+			reply("250 ok")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 5.5.2 not implemented")
+		}
+	}
+}
+
+func readData(readLine func() (string, error)) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := readLine()
+		if err != nil {
+			return "", err
+		}
+		if line == "." {
+			return b.String(), nil
+		}
+		// dot-stuffing (RFC 5321 4.5.2)
+		line = strings.TrimPrefix(line, ".")
+		b.WriteString(line + "\n")
+	}
+}
+
+func (s *SMTPStub) print(from string, rcpt []string, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := s.Out
+	if out == nil {
+		return
+	}
+	fmt.Fprintf(out, "==================== %s\nMAIL FROM: %s\nRCPT TO: %s\n\n%s\n",
+		time.Now().Format(time.RFC3339), from, strings.Join(rcpt, ", "), body)
+}
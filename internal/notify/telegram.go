@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Telegram — сообщение от бота (Bot API sendMessage) с кнопкой входа в урок.
+// Ученик сначала пишет боту /start, его chat id сохраняется в настройках.
+type Telegram struct {
+	Token  string
+	API    string // https://api.telegram.org (или свой Bot API сервер)
+	Client *http.Client
+}
+
+func (t Telegram) Name() string { return ChannelTelegram }
+
+type telegramButton struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+type telegramMessage struct {
+	ChatID      string `json:"chat_id"`
+	Text        string `json:"text"`
+	ReplyMarkup *struct {
+		InlineKeyboard [][]telegramButton `json:"inline_keyboard"`
+	} `json:"reply_markup,omitempty"`
+}
+
+func (t Telegram) Send(ctx context.Context, m Message) error {
+	if strings.TrimSpace(m.To) == "" {
+		return permanent("empty telegram chat id")
+	}
+
+	msg := telegramMessage{ChatID: m.To, Text: m.Text}
+	if m.Subject != "" {
+		msg.Text = m.Subject + "\n\n" + m.Text
+	}
+	if m.Link != "" {
+		msg.ReplyMarkup = &struct {
+			InlineKeyboard [][]telegramButton `json:"inline_keyboard"`
+		}{InlineKeyboard: [][]telegramButton{{{Text: "Войти в урок", URL: m.Link}}}}
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.API+"/bot"+t.Token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return errors.New("telegram: invalid api url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := t.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// в URL — токен бота: в ошибку (и в last_error) его не пускаем
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return fmt.Errorf("telegram: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&out)
+	if resp.StatusCode == http.StatusOK && out.OK {
+		return nil
+	}
+
+	switch {
+	// чат не найден, бот заблокирован, неверная кнопка — повтор не поможет
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusForbidden:
+		return permanent("telegram %d: %s", resp.StatusCode, out.Description)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusNotFound:
+		// токен неверный — чинит админ, сообщения подождут в очереди
+		return fmt.Errorf("telegram %d: bot token rejected", resp.StatusCode)
+	default:
+		return fmt.Errorf("telegram %d: %s", resp.StatusCode, out.Description)
+	}
+}
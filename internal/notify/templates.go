@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData — что доступно в шаблоне сообщения
+type TemplateData struct {
+	Event    string
+	Student  string
+	Room     string
+	Title    string // название урока (или комната, если не задано)
+	Teacher  string
+	StartsAt time.Time
+	Link     string // вход в урок
+}
+
+// встроенные шаблоны: <event> -> тема, текст
+var defaultTemplates = map[string][2]string{
+	"lesson_started": {
+		`Урок начался: {{.Title}}`,
+		`Здравствуйте, {{.Student}}!
+
+{{if .Teacher}}{{.Teacher}} начинает{{else}}Начинается{{end}} урок «{{.Title}}».
+{{if .Link}}Подключиться: {{.Link}}{{end}}`,
+	},
+	"lesson_reminder": {
+		`Скоро урок: {{.Title}}`,
+		`Здравствуйте, {{.Student}}!
+
+Урок «{{.Title}}»{{if .Teacher}} ({{.Teacher}}){{end}} начнётся {{.StartsAt.Format "02.01.2006 в 15:04 MST"}}.
+{{if .Link}}Ссылка для входа: {{.Link}}{{end}}`,
+	},
+}
+
+// Templates — шаблоны сообщений по событиям
+type Templates struct {
+	subject map[string]*template.Template
+	text    map[string]*template.Template
+}
+
+// LoadTemplates — встроенные шаблоны; из dir (если задан) их заменяют
+// <event>.subject.tmpl и <event>.txt.tmpl
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{
+		subject: map[string]*template.Template{},
+		text:    map[string]*template.Template{},
+	}
+
+	for event, def := range defaultTemplates {
+		subject, text := def[0], def[1]
+		if dir != "" {
+			var err error
+			if subject, err = readTemplate(dir, event+".subject.tmpl", subject); err != nil {
+				return nil, err
+			}
+			if text, err = readTemplate(dir, event+".txt.tmpl", text); err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		if t.subject[event], err = template.New(event + ".subject").Parse(subject); err != nil {
+			return nil, fmt.Errorf("notify template %s subject: %w", event, err)
+		}
+		if t.text[event], err = template.New(event + ".txt").Parse(text); err != nil {
+			return nil, fmt.Errorf("notify template %s text: %w", event, err)
+		}
+	}
+	return t, nil
+}
+
+func readTemplate(dir, name, def string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return def, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("notify template: %w", err)
+	}
+	return string(b), nil
+}
+
+// Render — тема и текст сообщения о событии
+func (t *Templates) Render(d TemplateData) (subject, text string, err error) {
+	st, ok := t.subject[d.Event]
+	if !ok {
+		return "", "", fmt.Errorf("no template for event %q", d.Event)
+	}
+	if d.Title == "" {
+		d.Title = d.Room
+	}
+
+	var b strings.Builder
+	if err := st.Execute(&b, d); err != nil {
+		return "", "", err
+	}
+	subject = strings.TrimSpace(b.String())
+
+	b.Reset()
+	if err := t.text[d.Event].Execute(&b, d); err != nil {
+		return "", "", err
+	}
+	return subject, strings.TrimSpace(b.String()), nil
+}
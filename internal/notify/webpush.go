@@ -0,0 +1,277 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// push-сервис хранит сообщение, пока браузер офлайн; урок через сутки уже не нужен
+const webPushTTL = 12 * time.Hour
+
+// PushSubscription — PushManager.subscribe() из браузера (toJSON())
+type PushSubscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// ParsePushSubscription — подписка из настроек ученика (проверяем до сохранения)
+func ParsePushSubscription(raw []byte) (*PushSubscription, error) {
+	var s PushSubscription
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, errors.New("push subscription must be a JSON object")
+	}
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, errors.New("push subscription endpoint must be an https URL")
+	}
+	if k, err := decodeB64(s.Keys.P256dh); err != nil || len(k) != 65 {
+		return nil, errors.New("push subscription keys.p256dh must be a P-256 public key")
+	}
+	if a, err := decodeB64(s.Keys.Auth); err != nil || len(a) != 16 {
+		return nil, errors.New("push subscription keys.auth must be 16 bytes")
+	}
+	return &s, nil
+}
+
+// WebPush — уведомления браузера: VAPID (RFC 8292) + шифрование aes128gcm (RFC 8291)
+type WebPush struct {
+	publicKey string // base64url, уходит в заголовок и в браузер (applicationServerKey)
+	key       *ecdsa.PrivateKey
+	subject   string
+	client    *http.Client
+}
+
+// NewWebPush — ключи VAPID в base64url: публичный (65 байт) и закрытый (32 байта)
+func NewWebPush(publicKey, privateKey, subject string) (*WebPush, error) {
+	d, err := decodeB64(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: private key: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), d)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: private key: %w", err)
+	}
+	pub, err := key.PublicKey.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("webpush: %w", err)
+	}
+	given, err := decodeB64(publicKey)
+	if err != nil || !bytes.Equal(given, pub) {
+		return nil, errors.New("webpush: public key does not match the private key")
+	}
+
+	return &WebPush{
+		publicKey: base64.RawURLEncoding.EncodeToString(pub),
+		key:       key,
+		subject:   subject,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// GenerateVAPIDKeys — новая пара ключей (base64url) для NOTIFY_WEBPUSH_*
+func GenerateVAPIDKeys() (public, private string, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	pub, err := key.PublicKey.Bytes()
+	if err != nil {
+		return "", "", err
+	}
+	d, err := key.Bytes()
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(pub), base64.RawURLEncoding.EncodeToString(d), nil
+}
+
+// PublicKey — applicationServerKey для PushManager.subscribe()
+func (w *WebPush) PublicKey() string { return w.publicKey }
+
+func (w *WebPush) Name() string { return ChannelWebPush }
+
+func (w *WebPush) Send(ctx context.Context, m Message) error {
+	sub, err := ParsePushSubscription([]byte(m.To))
+	if err != nil {
+		return permanent("%v", err)
+	}
+
+	// показывает service worker клиента: title/body — в уведомление, url — по клику
+	payload, err := json.Marshal(map[string]string{
+		"title": m.Subject,
+		"body":  m.Text,
+		"url":   m.Link,
+	})
+	if err != nil {
+		return err
+	}
+
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	body, err := encryptPush(payload, sub, asKey, salt)
+	if err != nil {
+		return permanent("%v", err)
+	}
+
+	auth, err := w.vapid(sub.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return permanent("invalid push endpoint")
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(webPushTTL.Seconds())))
+	req.Header.Set("Urgency", "high")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	// 404/410 — подписка отозвана; 400/403/413 — запрос не примут и в следующий раз
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusGone,
+		resp.StatusCode == http.StatusBadRequest, resp.StatusCode == http.StatusForbidden,
+		resp.StatusCode == http.StatusRequestEntityTooLarge:
+		return permanent("webpush %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	default:
+		return fmt.Errorf("webpush %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+}
+
+// vapid — "vapid t=<JWT ES256>, k=<публичный ключ>" для origin'а push-сервиса
+func (w *WebPush) vapid(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", permanent("invalid push endpoint")
+	}
+
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(webPushTTL).Unix(),
+		"sub": w.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + enc.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, w.key, hash[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS: r||s по 32 байта, не ASN.1
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	return "vapid t=" + unsigned + "." + enc.EncodeToString(sig) + ", k=" + w.publicKey, nil
+}
+
+// encryptPush — тело aes128gcm (RFC 8188) одной записью, ключи по RFC 8291
+func encryptPush(payload []byte, sub *PushSubscription, asKey *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	uaRaw, err := decodeB64(sub.Keys.P256dh)
+	if err != nil {
+		return nil, err
+	}
+	uaPub, err := ecdh.P256().NewPublicKey(uaRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := decodeB64(sub.Keys.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := asKey.ECDH(uaPub)
+	if err != nil {
+		return nil, err
+	}
+	asPub := asKey.PublicKey().Bytes()
+
+	keyInfo := "WebPush: info\x00" + string(uaRaw) + string(asPub)
+	ikm, err := hkdf.Key(sha256.New, shared, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 0x02 — разделитель последней (и единственной) записи
+	plain := append(append([]byte{}, payload...), 0x02)
+	const recordSize = 4096
+	if len(plain)+gcm.Overhead() > recordSize {
+		return nil, errors.New("push payload is too large")
+	}
+
+	out := make([]byte, 0, 16+4+1+len(asPub)+len(plain)+gcm.Overhead())
+	out = append(out, salt...)
+	out = binary.BigEndian.AppendUint32(out, recordSize)
+	out = append(out, byte(len(asPub)))
+	out = append(out, asPub...)
+	return gcm.Seal(out, nonce, plain, nil), nil
+}
+
+// decodeB64 — браузеры отдают base64url без "=", но встречается и обычный base64
+func decodeB64(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	if strings.ContainsAny(s, "+/") {
+		return base64.RawStdEncoding.DecodeString(s)
+	}
+	return base64.RawURLEncoding.DecodeString(s)
+}
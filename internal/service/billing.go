@@ -0,0 +1,117 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"streaming/internal/db"
+)
+
+// BillingRules — ставки и правила округления (из конфига; сохраняются с периодом)
+type BillingRules struct {
+	Currency               string        `json:"currency"`
+	TeacherRate            int64         `json:"teacher_rate"`
+	ParticipantRatePer1000 int64         `json:"participant_rate_per_1000"`
+	RoundTo                time.Duration `json:"-"`
+	Rounding               string        `json:"rounding"`
+	MinLesson              time.Duration `json:"-"`
+	SkipUnder              time.Duration `json:"-"`
+}
+
+// JSON — правила для billing_periods.rules (длительности — в минутах)
+func (r BillingRules) JSON() json.RawMessage {
+	raw, _ := json.Marshal(struct {
+		BillingRules
+		RoundToMin   int64 `json:"round_to_min"`
+		MinLessonMin int64 `json:"min_lesson_min"`
+		SkipUnderMin int64 `json:"skip_under_min"`
+	}{
+		BillingRules: r,
+		RoundToMin:   int64(r.RoundTo / time.Minute),
+		MinLessonMin: int64(r.MinLesson / time.Minute),
+		SkipUnderMin: int64(r.SkipUnder / time.Minute),
+	})
+	return raw
+}
+
+// TeacherMinutes — минуты урока после округления до шага RoundTo
+func (r BillingRules) TeacherMinutes(sec int64) int64 {
+	return roundMinutes(sec, r.RoundTo, r.Rounding)
+}
+
+// BillableMinutes — к оплате: короче SkipUnder — 0, иначе не меньше MinLesson
+func (r BillingRules) BillableMinutes(sec int64) int64 {
+	if time.Duration(sec)*time.Second < r.SkipUnder {
+		return 0
+	}
+	m := r.TeacherMinutes(sec)
+	if floor := int64(r.MinLesson / time.Minute); m < floor {
+		m = floor
+	}
+	return m
+}
+
+// ParticipantMinutes — участнико-минуты урока (LiveKit считает поминутно, шаг 1m)
+func (r BillingRules) ParticipantMinutes(sec int64) int64 {
+	return roundMinutes(sec, time.Minute, r.Rounding)
+}
+
+// ParticipantCost — стоимость участнико-минут; доли минорной единицы — вверх
+func (r BillingRules) ParticipantCost(minutes int64) int64 {
+	return (minutes*r.ParticipantRatePer1000 + 999) / 1000
+}
+
+// Statements — ведомости по учителям из использования уроков.
+// usage отсортирован по учителю (см. db.LessonUsageFor).
+func (r BillingRules) Statements(usage []db.LessonUsage) []db.BillingStatement {
+	out := []db.BillingStatement{}
+	for _, u := range usage {
+		line := db.BillingLine{
+			LessonID:           u.LessonID,
+			Room:               u.Room,
+			StartedAt:          u.StartedAt,
+			EndedAt:            u.EndedAt,
+			TeacherMinutes:     r.TeacherMinutes(u.TeacherSeconds),
+			BillableMinutes:    r.BillableMinutes(u.TeacherSeconds),
+			ParticipantMinutes: r.ParticipantMinutes(u.ParticipantSeconds),
+			Participants:       u.Participants,
+		}
+		line.Amount = line.BillableMinutes * r.TeacherRate
+
+		if n := len(out); n == 0 || out[n-1].Teacher != u.Teacher {
+			out = append(out, db.BillingStatement{Teacher: u.Teacher})
+		}
+		s := &out[len(out)-1]
+		s.Lessons++
+		s.TeacherMinutes += line.TeacherMinutes
+		s.BillableMinutes += line.BillableMinutes
+		s.ParticipantMinutes += line.ParticipantMinutes
+		s.TeacherAmount += line.Amount
+		s.Lines = append(s.Lines, line)
+	}
+
+	// стоимость LiveKit — от суммы за период, чтобы не копить округления по урокам
+	for i := range out {
+		out[i].ParticipantCost = r.ParticipantCost(out[i].ParticipantMinutes)
+	}
+	return out
+}
+
+// roundMinutes — секунды => минуты, кратные step, по правилу up | down | nearest
+func roundMinutes(sec int64, step time.Duration, mode string) int64 {
+	if sec <= 0 {
+		return 0
+	}
+	stepSec := int64(step / time.Second)
+
+	var n int64
+	switch mode {
+	case "up":
+		n = (sec + stepSec - 1) / stepSec
+	case "down":
+		n = sec / stepSec
+	default: // nearest, половина шага — вверх
+		n = (sec + stepSec/2) / stepSec
+	}
+	return n * stepSec / 60
+}
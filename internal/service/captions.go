@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"streaming/internal/db"
+	"streaming/internal/metrics"
+)
+
+// CaptionsTopic — topic data-сообщений субтитров
+const CaptionsTopic = "captions"
+
+// CaptionsIngestPath — куда egress присылает звук (+ id сессии)
+const CaptionsIngestPath = "/api/captions/ingest/"
+
+const (
+	// egress не подключился за это время — сессию забываем (учитель может запустить заново)
+	captionConnectTimeout = 30 * time.Second
+	// рассылка / запись фрагмента идёт вне HTTP-запроса
+	captionPublishTimeout = 5 * time.Second
+	// остановка egress по таймауту подключения — тоже вне запроса
+	captionStopTimeout = 10 * time.Second
+)
+
+// EgressAudioFormat — что Track Egress шлёт на websocket: PCM s16le 48 kHz моно
+var EgressAudioFormat = AudioFormat{SampleRate: 48000, Channels: 1}
+
+var (
+	ErrCaptionsRunning    = errors.New("captions are already running for this lesson")
+	ErrCaptionsNotRunning = errors.New("captions are not running for this lesson")
+	// ErrCaptionSession — нет такой сессии (или к ней уже подключились)
+	ErrCaptionSession = errors.New("unknown caption session")
+)
+
+// AudioSource — звук от egress (websocket); io.EOF — дорожка закончилась
+type AudioSource interface {
+	ReadAudio() ([]byte, error)
+}
+
+// CaptionNode — что субтитрам нужно от узла LiveKit (*LiveKitNode)
+type CaptionNode interface {
+	AudioTrack(ctx context.Context, room, identity string) (string, error)
+	StartTrackWebsocket(ctx context.Context, room, trackID, wsURL string) (string, error)
+	StopEgress(ctx context.Context, room, egressID string) error
+	SendData(ctx context.Context, room, topic string, data []byte, identities ...string) error
+}
+
+// CaptionMessage — data-сообщение субтитров.
+// Промежуточные (final=false) клиент показывает вместо предыдущего промежуточного.
+type CaptionMessage struct {
+	Type      string    `json:"type"` // caption
+	LessonID  int64     `json:"lesson_id"`
+	Speaker   string    `json:"speaker"`
+	Language  string    `json:"language"`
+	Text      string    `json:"text"`
+	Final     bool      `json:"final"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+}
+
+// CaptionSession — субтитры одного урока: дорожка учителя -> egress -> STT -> комната
+type CaptionSession struct {
+	ID        string    `json:"-"` // секрет в URL для egress
+	LessonID  int64     `json:"lesson_id"`
+	Speaker   string    `json:"speaker"`
+	Language  string    `json:"language"`
+	EgressID  string    `json:"egress_id"`
+	StartedAt time.Time `json:"started_at"`
+
+	room      string // имя комнаты в LiveKit
+	node      CaptionNode
+	connected bool
+}
+
+// Captions — живые субтитры: сессии по урокам (в памяти процесса)
+type Captions struct {
+	provider       STTProvider
+	ingestURL      string // ws(s)://host:port, по которому egress достаёт до этого сервера
+	connectTimeout time.Duration
+
+	// save — финальный фрагмент в расшифровку урока
+	save func(ctx context.Context, seg *db.TranscriptSegment) error
+
+	mu       sync.Mutex
+	sessions map[string]*CaptionSession
+	lessons  map[int64]*CaptionSession
+}
+
+func NewCaptions(dbConn *sql.DB, provider STTProvider, ingestURL string) *Captions {
+	return &Captions{
+		provider:       provider,
+		ingestURL:      strings.TrimRight(ingestURL, "/"),
+		connectTimeout: captionConnectTimeout,
+		save: func(ctx context.Context, seg *db.TranscriptSegment) error {
+			return db.AddTranscriptSegment(ctx, dbConn, seg)
+		},
+		sessions: map[string]*CaptionSession{},
+		lessons:  map[int64]*CaptionSession{},
+	}
+}
+
+// Start — подписаться на микрофон speaker'а через Track Egress.
+// Звук придёт позже — в Ingest, когда egress подключится к websocket.
+func (c *Captions) Start(
+	ctx context.Context,
+	node CaptionNode,
+	lessonID int64,
+	room, speaker, language string,
+) (*CaptionSession, error) {
+	trackID, err := node.AudioTrack(ctx, room, speaker)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &CaptionSession{
+		ID:        uuid.NewString(),
+		LessonID:  lessonID,
+		Speaker:   speaker,
+		Language:  language,
+		StartedAt: time.Now().UTC(),
+		room:      room,
+		node:      node,
+	}
+
+	c.mu.Lock()
+	if _, ok := c.lessons[lessonID]; ok {
+		c.mu.Unlock()
+		return nil, ErrCaptionsRunning
+	}
+	c.sessions[s.ID] = s
+	c.lessons[lessonID] = s
+	c.mu.Unlock()
+
+	egressID, err := node.StartTrackWebsocket(ctx, room, trackID, c.ingestURL+CaptionsIngestPath+s.ID)
+	if err != nil {
+		c.drop(s)
+		metrics.CaptionSessions.WithLabelValues("start_failed").Inc()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	s.EgressID = egressID
+	out := *s
+	stopped := c.sessions[s.ID] != s
+	c.mu.Unlock()
+
+	// Stop пришёл, пока egress запускался: сессии уже нет, egress — наш, останавливаем
+	if stopped {
+		metrics.CaptionSessions.WithLabelValues("stopped_while_starting").Inc()
+		if err := node.StopEgress(ctx, room, egressID); err != nil {
+			slog.Warn("captions: stop egress after concurrent stop", "lesson_id", lessonID, "egress_id", egressID, "error", err)
+		}
+		return nil, ErrCaptionsNotRunning
+	}
+
+	time.AfterFunc(c.connectTimeout, func() {
+		c.mu.Lock()
+		stale := !s.connected && c.sessions[s.ID] == s
+		c.mu.Unlock()
+		if !stale {
+			return
+		}
+
+		slog.Warn("captions: egress did not connect", "lesson_id", lessonID, "egress_id", egressID)
+		metrics.CaptionSessions.WithLabelValues("connect_timeout").Inc()
+		c.drop(s)
+
+		// egress мог запуститься и подключиться позже — сессии уже нет, он бы работал впустую
+		ctx, cancel := context.WithTimeout(context.Background(), captionStopTimeout)
+		defer cancel()
+		if err := node.StopEgress(ctx, room, egressID); err != nil {
+			slog.Warn("captions: stop stale egress", "lesson_id", lessonID, "egress_id", egressID, "error", err)
+		}
+	})
+
+	metrics.CaptionSessions.WithLabelValues("started").Inc()
+	return &out, nil
+}
+
+// Stop — остановить субтитры урока; Ingest завершится, когда egress закроет соединение
+func (c *Captions) Stop(ctx context.Context, lessonID int64) error {
+	c.mu.Lock()
+	s, ok := c.lessons[lessonID]
+	var egressID string
+	if ok {
+		egressID = s.EgressID
+	}
+	c.mu.Unlock()
+	if !ok {
+		return ErrCaptionsNotRunning
+	}
+
+	c.drop(s)
+	// egress ещё запускается (Start не вернулся) — Start увидит, что сессии нет, и остановит его
+	if egressID == "" {
+		return nil
+	}
+	return s.node.StopEgress(ctx, s.room, egressID)
+}
+
+// Running — идущая сессия урока
+func (c *Captions) Running(lessonID int64) (*CaptionSession, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.lessons[lessonID]
+	if !ok {
+		return nil, false
+	}
+	out := *s
+	return &out, true
+}
+
+// Pending — сессия id ждёт подключения egress
+func (c *Captions) Pending(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.sessions[id]
+	return ok && !s.connected
+}
+
+// Ingest — звук сессии id: в STT, результаты — в комнату и в расшифровку.
+// Возвращается, когда звук закончился (egress остановлен / дорожка снята).
+func (c *Captions) Ingest(ctx context.Context, id string, src AudioSource) error {
+	c.mu.Lock()
+	s, ok := c.sessions[id]
+	if !ok || s.connected {
+		c.mu.Unlock()
+		return ErrCaptionSession
+	}
+	s.connected = true
+	egressID := s.EgressID
+	c.mu.Unlock()
+	defer c.drop(s)
+
+	log := slog.With("component", "captions", "lesson_id", s.LessonID, "egress_id", egressID)
+
+	stream, err := c.provider.Stream(ctx, EgressAudioFormat, s.Language)
+	if err != nil {
+		metrics.CaptionSessions.WithLabelValues("stt_failed").Inc()
+		return err
+	}
+
+	t0 := time.Now().UTC()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for capt := range stream.Results() {
+			c.publish(log, s, t0, capt)
+		}
+	}()
+
+	var readErr error
+	for {
+		pcm, err := src.ReadAudio()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				readErr = err
+			}
+			break
+		}
+		if err := stream.Write(pcm); err != nil {
+			readErr = err
+			break
+		}
+	}
+
+	if err := stream.Close(); err != nil {
+		log.Warn("captions: close stt stream", "error", err)
+	}
+	<-done
+
+	metrics.CaptionSessions.WithLabelValues("ended").Inc()
+	log.Info("captions: session ended", "duration", time.Since(t0).Round(time.Second).String())
+	return readErr
+}
+
+// publish — фрагмент в комнату; финальный — ещё и в расшифровку
+func (c *Captions) publish(log *slog.Logger, s *CaptionSession, t0 time.Time, capt Caption) {
+	text := strings.TrimSpace(capt.Text)
+	if text == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), captionPublishTimeout)
+	defer cancel()
+
+	msg := CaptionMessage{
+		Type:      "caption",
+		LessonID:  s.LessonID,
+		Speaker:   s.Speaker,
+		Language:  s.Language,
+		Text:      text,
+		Final:     capt.Final,
+		StartedAt: t0.Add(capt.Start),
+		EndedAt:   t0.Add(capt.End),
+	}
+	if raw, err := json.Marshal(msg); err == nil {
+		// субтитр не дошёл — не страшно, следующий придёт через секунды
+		if err := s.node.SendData(ctx, s.room, CaptionsTopic, raw); err != nil {
+			log.Warn("captions: broadcast failed", "error", err)
+		}
+	}
+
+	if !capt.Final {
+		return
+	}
+	metrics.CaptionSegments.Inc()
+	if err := c.save(ctx, &db.TranscriptSegment{
+		LessonID:  s.LessonID,
+		Speaker:   s.Speaker,
+		Language:  s.Language,
+		StartedAt: msg.StartedAt,
+		EndedAt:   msg.EndedAt,
+		Text:      text,
+	}); err != nil {
+		log.Error("captions: save transcript segment", "error", err)
+	}
+}
+
+// drop — забыть сессию (повторно — ничего)
+func (c *Captions) drop(s *CaptionSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sessions[s.ID] == s {
+		delete(c.sessions, s.ID)
+	}
+	if c.lessons[s.LessonID] == s {
+		delete(c.lessons, s.LessonID)
+	}
+}
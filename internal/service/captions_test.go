@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"streaming/internal/db"
+)
+
+// fakeNode — узел LiveKit в памяти: запоминает рассылки и остановленные egress
+type fakeNode struct {
+	mu      sync.Mutex
+	sent    []CaptionMessage
+	stopped []string
+
+	// starting — если задан, StartTrackWebsocket ждёт его закрытия
+	starting chan struct{}
+	started  chan struct{}
+}
+
+func (n *fakeNode) AudioTrack(ctx context.Context, room, identity string) (string, error) {
+	return "TR_" + identity, nil
+}
+
+func (n *fakeNode) StartTrackWebsocket(ctx context.Context, room, trackID, wsURL string) (string, error) {
+	if n.starting != nil {
+		close(n.started)
+		<-n.starting
+	}
+	return "EG_" + trackID, nil
+}
+
+func (n *fakeNode) StopEgress(ctx context.Context, room, egressID string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.stopped = append(n.stopped, egressID)
+	return nil
+}
+
+func (n *fakeNode) SendData(ctx context.Context, room, topic string, data []byte, identities ...string) error {
+	var msg CaptionMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+func (n *fakeNode) stoppedEgress() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.stopped...)
+}
+
+// fakeAudio — chunks кусков звука, затем err (nil => io.EOF)
+type fakeAudio struct {
+	chunks [][]byte
+	err    error
+}
+
+func (a *fakeAudio) ReadAudio() ([]byte, error) {
+	if len(a.chunks) == 0 {
+		if a.err != nil {
+			return nil, a.err
+		}
+		return nil, io.EOF
+	}
+	pcm := a.chunks[0]
+	a.chunks = a.chunks[1:]
+	return pcm, nil
+}
+
+// seconds — звук egress'а по полсекунды
+func seconds(n int) *fakeAudio {
+	half := make([]byte, EgressAudioFormat.BytesPerSecond()/2)
+	a := &fakeAudio{}
+	for range 2 * n {
+		a.chunks = append(a.chunks, half)
+	}
+	return a
+}
+
+// newTestCaptions — субтитры без БД: финальные фрагменты складываются в saved
+func newTestCaptions(phrases ...string) (*Captions, *[]db.TranscriptSegment) {
+	c := NewCaptions(nil, FakeSTT{Phrases: phrases, Every: time.Second}, "ws://captions.test/")
+	var mu sync.Mutex
+	saved := []db.TranscriptSegment{}
+	c.save = func(ctx context.Context, seg *db.TranscriptSegment) error {
+		mu.Lock()
+		defer mu.Unlock()
+		saved = append(saved, *seg)
+		return nil
+	}
+	return c, &saved
+}
+
+func TestCaptionsIngest(t *testing.T) {
+	c, saved := newTestCaptions("first phrase", "second phrase")
+	node := &fakeNode{}
+	ctx := context.Background()
+
+	s, err := c.Start(ctx, node, 7, "room", "teacher", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.EgressID != "EG_TR_teacher" || !c.Pending(s.ID) {
+		t.Fatalf("session = %+v, pending = %v", s, c.Pending(s.ID))
+	}
+
+	if err := c.Ingest(ctx, s.ID, seconds(2)); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+
+	var finals []string
+	for _, m := range node.sent {
+		if m.Type != "caption" || m.LessonID != 7 || m.Speaker != "teacher" || m.Language != "en" {
+			t.Errorf("message = %+v", m)
+		}
+		if m.Final {
+			finals = append(finals, m.Text)
+		}
+	}
+	if len(node.sent) != 4 || len(finals) != 2 || finals[0] != "first phrase" || finals[1] != "second phrase" {
+		t.Fatalf("sent = %+v", node.sent)
+	}
+
+	// в расшифровку — только финальные
+	if len(*saved) != 2 || (*saved)[0].Text != "first phrase" || (*saved)[1].LessonID != 7 {
+		t.Fatalf("saved = %+v", *saved)
+	}
+
+	// звук кончился — сессии нет, второй раз к ней не подключиться
+	if _, ok := c.Running(7); ok {
+		t.Fatal("session still running after ingest")
+	}
+	if err := c.Ingest(ctx, s.ID, seconds(1)); !errors.Is(err, ErrCaptionSession) {
+		t.Fatalf("second ingest = %v, want %v", err, ErrCaptionSession)
+	}
+}
+
+func TestCaptionsIngestReadError(t *testing.T) {
+	c, saved := newTestCaptions("tail")
+	ctx := context.Background()
+
+	s, err := c.Start(ctx, &fakeNode{}, 7, "room", "teacher", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken := errors.New("websocket reset")
+	audio := &fakeAudio{chunks: [][]byte{make([]byte, 1000)}, err: broken}
+	if err := c.Ingest(ctx, s.ID, audio); !errors.Is(err, broken) {
+		t.Fatalf("ingest = %v, want %v", err, broken)
+	}
+
+	// уже распознанный хвост не теряется
+	if len(*saved) != 1 || (*saved)[0].Text != "tail" {
+		t.Fatalf("saved = %+v", *saved)
+	}
+	if _, ok := c.Running(7); ok {
+		t.Fatal("session still running after read error")
+	}
+}
+
+func TestCaptionsIngestUnknownSession(t *testing.T) {
+	c, _ := newTestCaptions()
+
+	if err := c.Ingest(context.Background(), "nope", seconds(1)); !errors.Is(err, ErrCaptionSession) {
+		t.Fatalf("ingest = %v, want %v", err, ErrCaptionSession)
+	}
+}
+
+func TestCaptionsStartTwice(t *testing.T) {
+	c, _ := newTestCaptions()
+	ctx := context.Background()
+
+	if _, err := c.Start(ctx, &fakeNode{}, 7, "room", "teacher", "en"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Start(ctx, &fakeNode{}, 7, "room", "teacher", "en"); !errors.Is(err, ErrCaptionsRunning) {
+		t.Fatalf("second start = %v, want %v", err, ErrCaptionsRunning)
+	}
+}
+
+func TestCaptionsStop(t *testing.T) {
+	c, _ := newTestCaptions()
+	node := &fakeNode{}
+	ctx := context.Background()
+
+	if _, err := c.Start(ctx, node, 7, "room", "teacher", "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Stop(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := node.stoppedEgress(); len(got) != 1 || got[0] != "EG_TR_teacher" {
+		t.Fatalf("stopped = %v", got)
+	}
+	if err := c.Stop(ctx, 7); !errors.Is(err, ErrCaptionsNotRunning) {
+		t.Fatalf("second stop = %v, want %v", err, ErrCaptionsNotRunning)
+	}
+}
+
+func TestCaptionsStopWhileStarting(t *testing.T) {
+	c, _ := newTestCaptions()
+	node := &fakeNode{starting: make(chan struct{}), started: make(chan struct{})}
+	ctx := context.Background()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.Start(ctx, node, 7, "room", "teacher", "en")
+		errc <- err
+	}()
+	<-node.started
+
+	// egress ещё запускается: Stop забывает сессию, остановить пока нечего
+	if err := c.Stop(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	close(node.starting)
+
+	if err := <-errc; !errors.Is(err, ErrCaptionsNotRunning) {
+		t.Fatalf("start = %v, want %v", err, ErrCaptionsNotRunning)
+	}
+	if got := node.stoppedEgress(); len(got) != 1 || got[0] != "EG_TR_teacher" {
+		t.Fatalf("egress started after stop was not stopped: %v", got)
+	}
+	if _, ok := c.Running(7); ok {
+		t.Fatal("session running after stop")
+	}
+}
+
+func TestCaptionsConnectTimeoutStopsEgress(t *testing.T) {
+	c, _ := newTestCaptions()
+	c.connectTimeout = 10 * time.Millisecond
+	node := &fakeNode{}
+
+	s, err := c.Start(context.Background(), node, 7, "room", "teacher", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(node.stoppedEgress()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("egress that never connected was not stopped")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if got := node.stoppedEgress(); got[0] != s.EgressID {
+		t.Fatalf("stopped = %v, want %s", got, s.EgressID)
+	}
+	if c.Pending(s.ID) {
+		t.Fatal("stale session still pending")
+	}
+}
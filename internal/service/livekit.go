@@ -1,12 +1,20 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"strings"
 	"time"
 
 	lkauth "github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/metrics"
+	"streaming/internal/tracing"
 )
 
 type LiveKitService struct {
@@ -16,16 +24,29 @@ type LiveKitService struct {
 	HTTPPort   int
 	Secure     bool
 	PublicHost string
+
+	// APIURL — RoomService для сервера (пусто => PublicHost/HTTPPort)
+	APIURL string
+
+	// twirp клиенты RoomService / Egress на один http.Client:
+	// keep-alive соединения к узлу переиспользуются между вызовами
+	rooms  livekit.RoomService
+	egress livekit.Egress
 }
 
-func NewLiveKitService(apiKey, apiSecret string, httpPort int, secure bool, publicHost string) *LiveKitService {
-	return &LiveKitService{
+func NewLiveKitService(apiKey, apiSecret string, httpPort int, secure bool, publicHost, apiURL string) *LiveKitService {
+	s := &LiveKitService{
 		APIKey:     apiKey,
 		APISecret:  apiSecret,
 		HTTPPort:   httpPort,
 		Secure:     secure,
 		PublicHost: strings.TrimSpace(publicHost),
+		APIURL:     strings.TrimRight(strings.TrimSpace(apiURL), "/"),
 	}
+	hc := &http.Client{Timeout: roomServiceTimeout}
+	s.rooms = livekit.NewRoomServiceProtobufClient(s.apiBaseURL(), hc)
+	s.egress = livekit.NewEgressProtobufClient(s.apiBaseURL(), hc)
+	return s
 }
 
 func (s *LiveKitService) WSURLFromRequestHost(hostHeader string) string {
@@ -63,12 +84,23 @@ func extractHostname(host string) string {
 func boolPtr(v bool) *bool { return &v }
 
 // JoinToken issues token with role-based permissions.
-// role: "teacher" | "student"
-func (s *LiveKitService) JoinToken(room, identity, displayName, role string) (string, error) {
-	role = strings.ToLower(strings.TrimSpace(role))
+// md.Role: "teacher" | "student"; ttl — срок жизни токена (TOKEN_TTL_*)
+func (s *LiveKitService) JoinToken(ctx context.Context, room, identity, displayName string, md ParticipantMetadata, ttl time.Duration) (string, error) {
+	start := time.Now()
+	defer func() { metrics.TokenIssueDuration.Observe(time.Since(start).Seconds()) }()
+
+	role := strings.ToLower(strings.TrimSpace(md.Role))
 	if role == "" {
 		role = "student"
 	}
+	md.Role = role
+
+	_, span := tracing.Start(ctx, "livekit.JoinToken",
+		attribute.String("room", room),
+		attribute.String("role", role),
+		attribute.String("ttl", ttl.String()),
+	)
+	defer span.End()
 
 	at := lkauth.NewAccessToken(s.APIKey, s.APISecret)
 	at.SetIdentity(identity)
@@ -100,10 +132,31 @@ func (s *LiveKitService) JoinToken(room, identity, displayName, role string) (st
 	}
 
 	at.AddGrant(grant)
-	at.SetValidFor(time.Hour)
+	at.SetValidFor(ttl)
+
+	raw, err := md.JSON()
+	if err != nil {
+		return "", tracing.Fail(span, err)
+	}
+	at.SetMetadata(raw)
+
+	token, err := at.ToJWT()
+	return token, tracing.Fail(span, err)
+}
 
-	// metadata: role
-	_ = at.SetMetadata(fmt.Sprintf(`{"role":"%s"}`, role))
+// ErrInvalidToken — токен не наш (другой ключ/подпись) или уже истёк
+var ErrInvalidToken = errors.New("invalid or expired livekit token")
 
-	return at.ToJWT()
+// VerifyToken проверяет подпись и срок join-токена этого узла (для продления).
+func (s *LiveKitService) VerifyToken(raw string) (*lkauth.ClaimGrants, error) {
+	v, err := lkauth.ParseAPIToken(raw)
+	if err != nil || v.APIKey() != s.APIKey {
+		return nil, ErrInvalidToken
+	}
+
+	_, grants, err := v.Verify(s.APISecret)
+	if err != nil || grants.Video == nil || !grants.Video.RoomJoin {
+		return nil, ErrInvalidToken
+	}
+	return grants, nil
 }
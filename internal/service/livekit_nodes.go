@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrNoCapacity — все подходящие узлы заполнены
+var ErrNoCapacity = errors.New("all LiveKit nodes are at capacity")
+
+// LiveKitNode — LiveKit сервер в реестре: регион + лимит участников.
+type LiveKitNode struct {
+	ID       string
+	Region   string
+	Capacity int // 0 => без ограничения
+
+	*LiveKitService
+}
+
+// LiveKitNodes — реестр узлов и стратегия размещения комнат.
+type LiveKitNodes struct {
+	nodes    []*LiveKitNode
+	byID     map[string]*LiveKitNode
+	strategy string // region | least_loaded
+}
+
+func NewLiveKitNodes(nodes []*LiveKitNode, strategy string) *LiveKitNodes {
+	r := &LiveKitNodes{
+		nodes:    nodes,
+		byID:     make(map[string]*LiveKitNode, len(nodes)),
+		strategy: strategy,
+	}
+	for _, n := range nodes {
+		r.byID[n.ID] = n
+	}
+	return r
+}
+
+// Get — узел по id (урок уже "приколот" к нему)
+func (r *LiveKitNodes) Get(id string) (*LiveKitNode, bool) {
+	n, ok := r.byID[id]
+	return n, ok
+}
+
+// All — все узлы (для фоновых задач, которые обходят каждый сервер)
+func (r *LiveKitNodes) All() []*LiveKitNode {
+	return r.nodes
+}
+
+// Place выбирает узел для новой комнаты.
+//
+//	region:       сначала узлы региона regionHint, затем (если там всё занято) остальные
+//	least_loaded: наименее загруженный узел среди всех
+//
+// load — активные участники по id узла.
+func (r *LiveKitNodes) Place(regionHint string, load map[string]int) (*LiveKitNode, error) {
+	regionHint = strings.ToLower(strings.TrimSpace(regionHint))
+
+	if r.strategy == "region" && regionHint != "" {
+		var local []*LiveKitNode
+		for _, n := range r.nodes {
+			if n.Region == regionHint {
+				local = append(local, n)
+			}
+		}
+		if n := leastLoaded(local, load); n != nil {
+			return n, nil
+		}
+	}
+
+	if n := leastLoaded(r.nodes, load); n != nil {
+		return n, nil
+	}
+	return nil, ErrNoCapacity
+}
+
+// leastLoaded — узел с минимальной долей занятости (used/capacity).
+// Узлы без лимита сравниваются по числу участников, поэтому при смешанной
+// конфигурации лучше задать capacity всем узлам.
+// nil, если у всех кандидатов нет свободных мест.
+func leastLoaded(nodes []*LiveKitNode, load map[string]int) *LiveKitNode {
+	type cand struct {
+		node  *LiveKitNode
+		ratio float64
+	}
+
+	var cands []cand
+	for _, n := range nodes {
+		used := load[n.ID]
+		if n.Capacity > 0 && used >= n.Capacity {
+			continue
+		}
+
+		ratio := float64(used)
+		if n.Capacity > 0 {
+			ratio = float64(used) / float64(n.Capacity)
+		}
+		cands = append(cands, cand{node: n, ratio: ratio})
+	}
+	if len(cands) == 0 {
+		return nil
+	}
+
+	// стабильно: при равной загрузке — порядок из конфига
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].ratio < cands[j].ratio })
+	return cands[0].node
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// =======================
+// Participant metadata (ParticipantInfo.metadata / токен)
+// =======================
+
+// ParticipantMetadata — то, что фронтенд читает из participant.metadata.
+// ⚠️ Поля только добавляем: старые клиенты должны разбирать новые токены.
+type ParticipantMetadata struct {
+	Role       string `json:"role"` // teacher | student
+	UserID     string `json:"user_id,omitempty"`
+	LessonID   int64  `json:"lesson_id,omitempty"`
+	AvatarURL  string `json:"avatar_url,omitempty"`
+	Locale     string `json:"locale,omitempty"`
+	HandRaised bool   `json:"hand_raised"`
+	Tenant     string `json:"tenant,omitempty"`
+}
+
+func (m ParticipantMetadata) JSON() (string, error) {
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+// ParseParticipantMetadata — пустая строка => нулевые метаданные (не ошибка)
+func ParseParticipantMetadata(raw string) (ParticipantMetadata, error) {
+	var m ParticipantMetadata
+	if strings.TrimSpace(raw) == "" {
+		return m, nil
+	}
+	err := json.Unmarshal([]byte(raw), &m)
+	return m, err
+}
+
+// =======================
+// Room metadata (Room.metadata)
+// =======================
+
+// RoomMetadata — ставится при старте урока, меняется учителем.
+type RoomMetadata struct {
+	Title          string     `json:"title,omitempty"`
+	Teacher        string     `json:"teacher"`
+	LessonID       int64      `json:"lesson_id"`
+	Tenant         string     `json:"tenant,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	ScheduledStart *time.Time `json:"scheduled_start,omitempty"`
+	ScheduledEnd   *time.Time `json:"scheduled_end,omitempty"`
+
+	// настройки комнаты из rooms (у комнат "на лету" — нулевые)
+	MaxParticipants int    `json:"max_participants,omitempty"`
+	MaxStudents     int    `json:"max_students,omitempty"`
+	MaxTeachers     int    `json:"max_teachers,omitempty"`
+	WaitingRoom     bool   `json:"waiting_room,omitempty"`
+	Recording       string `json:"recording,omitempty"`
+}
+
+func (m RoomMetadata) JSON() (string, error) {
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+func ParseRoomMetadata(raw string) (RoomMetadata, error) {
+	var m RoomMetadata
+	if strings.TrimSpace(raw) == "" {
+		return m, nil
+	}
+	err := json.Unmarshal([]byte(raw), &m)
+	return m, err
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"streaming/internal/db"
+	"streaming/internal/metrics"
+	"streaming/internal/notify"
+)
+
+const (
+	notifyBatch = 50
+	// столько сообщение "занято" отправкой; упали посреди — через lease его заберут снова
+	notifyLease       = 2 * time.Minute
+	notifySendTimeout = 30 * time.Second
+	notifyMaxBackoff  = time.Hour
+	// учитель переподключился (в БД это новый урок) — ученикам второй раз не пишем
+	lessonStartedQuiet = 30 * time.Minute
+)
+
+// notificationQueue — подписки, расписание и очередь сообщений (таблицы notification_*)
+type notificationQueue interface {
+	RoomSubscribers(ctx context.Context, tenantID int64, room, event string) ([]db.NotificationPrefs, error)
+	Enqueue(ctx context.Context, list []db.Notification, quiet time.Duration) (int, error)
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]db.Notification, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string, retryAt *time.Time) error
+	ClaimDueReminders(ctx context.Context, before time.Duration, limit int) ([]db.ScheduledLesson, error)
+	ReleaseReminder(ctx context.Context, id int64) error
+}
+
+// sqlNotificationQueue — notificationQueue в Postgres
+type sqlNotificationQueue struct {
+	db *sql.DB
+}
+
+func (q sqlNotificationQueue) RoomSubscribers(ctx context.Context, tenantID int64, room, event string) ([]db.NotificationPrefs, error) {
+	return db.RoomSubscribers(ctx, q.db, tenantID, room, event)
+}
+
+func (q sqlNotificationQueue) Enqueue(ctx context.Context, list []db.Notification, quiet time.Duration) (int, error) {
+	return db.EnqueueNotifications(ctx, q.db, list, quiet)
+}
+
+func (q sqlNotificationQueue) Claim(ctx context.Context, limit int, lease time.Duration) ([]db.Notification, error) {
+	return db.ClaimNotifications(ctx, q.db, limit, lease)
+}
+
+func (q sqlNotificationQueue) MarkSent(ctx context.Context, id int64) error {
+	return db.MarkNotificationSent(ctx, q.db, id)
+}
+
+func (q sqlNotificationQueue) MarkFailed(ctx context.Context, id int64, reason string, retryAt *time.Time) error {
+	return db.MarkNotificationFailed(ctx, q.db, id, reason, retryAt)
+}
+
+func (q sqlNotificationQueue) ClaimDueReminders(ctx context.Context, before time.Duration, limit int) ([]db.ScheduledLesson, error) {
+	return db.ClaimDueReminders(ctx, q.db, before, limit)
+}
+
+func (q sqlNotificationQueue) ReleaseReminder(ctx context.Context, id int64) error {
+	return db.ReleaseReminder(ctx, q.db, id)
+}
+
+// NotifierOptions — NOTIFY_* из конфига
+type NotifierOptions struct {
+	JoinURL        string
+	Interval       time.Duration
+	ReminderBefore time.Duration
+	MaxAttempts    int
+	RetryBase      time.Duration
+}
+
+// Notifier — уведомления ученикам: события кладутся в очередь (таблица notifications),
+// фоновый Run рассылает их по каналам с повторами и ставит напоминания по расписанию.
+type Notifier struct {
+	queue     notificationQueue
+	channels  map[string]notify.Channel
+	templates *notify.Templates
+	opts      NotifierOptions
+
+	// разбудить Run, не дожидаясь тика (урок уже начался)
+	kick chan struct{}
+}
+
+func NewNotifier(dbConn *sql.DB, channels []notify.Channel, templates *notify.Templates, opts NotifierOptions) *Notifier {
+	n := &Notifier{
+		queue:     sqlNotificationQueue{db: dbConn},
+		channels:  make(map[string]notify.Channel, len(channels)),
+		templates: templates,
+		opts:      opts,
+		kick:      make(chan struct{}, 1),
+	}
+	for _, ch := range channels {
+		n.channels[ch.Name()] = ch
+	}
+	return n
+}
+
+// Enabled — канал настроен на сервере
+func (n *Notifier) Enabled(channel string) bool {
+	_, ok := n.channels[channel]
+	return ok
+}
+
+// LessonStarted — учитель начал урок: сообщение ученикам класса комнаты
+func (n *Notifier) LessonStarted(ctx context.Context, tenantID, lessonID int64, room, title, teacher string) (int, error) {
+	added, err := n.enqueue(ctx, tenantID, notify.TemplateData{
+		Event:   db.EventLessonStarted,
+		Room:    room,
+		Title:   title,
+		Teacher: teacher,
+	}, fmt.Sprintf("lesson:%d", lessonID), lessonStartedQuiet)
+	if added > 0 {
+		select {
+		case n.kick <- struct{}{}:
+		default:
+		}
+	}
+	return added, err
+}
+
+// Run — очередь и напоминания каждые Interval до отмены ctx
+func (n *Notifier) Run(ctx context.Context) {
+	log := slog.With("component", "notifier")
+	log.Info("notifier started",
+		"interval", n.opts.Interval.String(),
+		"reminder_before", n.opts.ReminderBefore.String(),
+		"channels", len(n.channels),
+	)
+
+	t := time.NewTicker(n.opts.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n.remind(ctx, log)
+		case <-n.kick:
+		}
+
+		// разбираем, пока очередь не опустеет (или не выключат сервер)
+		for ctx.Err() == nil {
+			if n.deliver(ctx, log) < notifyBatch {
+				break
+			}
+		}
+	}
+}
+
+// remind — напоминания об уроках, до которых осталось меньше ReminderBefore
+func (n *Notifier) remind(ctx context.Context, log *slog.Logger) {
+	due, err := n.queue.ClaimDueReminders(ctx, n.opts.ReminderBefore, notifyBatch)
+	if err != nil {
+		log.Error("claim reminders", "error", err)
+		return
+	}
+
+	for _, s := range due {
+		_, err := n.enqueue(ctx, s.TenantID, notify.TemplateData{
+			Event:    db.EventLessonReminder,
+			Room:     s.Room,
+			Title:    s.Title,
+			Teacher:  s.Teacher,
+			StartsAt: s.StartsAt,
+		}, fmt.Sprintf("schedule:%d", s.ID), 0)
+		if err == nil {
+			continue
+		}
+
+		log.Error("enqueue reminder", "scheduled_id", s.ID, "error", err)
+		// не потеряем: следующий проход возьмёт урок снова (dedup_key не даст задвоить)
+		if err := n.queue.ReleaseReminder(context.WithoutCancel(ctx), s.ID); err != nil {
+			log.Error("release reminder", "scheduled_id", s.ID, "error", err)
+		}
+	}
+}
+
+// enqueue — сообщение каждому подписанному ученику комнаты в каждый его канал
+func (n *Notifier) enqueue(ctx context.Context, tenantID int64, data notify.TemplateData, source string, quiet time.Duration) (int, error) {
+	subs, err := n.queue.RoomSubscribers(ctx, tenantID, data.Room, data.Event)
+	if err != nil {
+		return 0, err
+	}
+	if len(subs) == 0 {
+		return 0, nil
+	}
+	data.Link = n.joinLink(data.Room)
+
+	var list []db.Notification
+	for _, p := range subs {
+		data.Student = p.StudentName
+		subject, body, err := n.templates.Render(data)
+		if err != nil {
+			return 0, fmt.Errorf("render %s: %w", data.Event, err)
+		}
+
+		for _, ch := range p.Channels {
+			addr := address(&p, ch)
+			if addr == "" || !n.Enabled(ch) {
+				continue
+			}
+			list = append(list, db.Notification{
+				TenantID:  tenantID,
+				Recipient: p.StudentName,
+				Channel:   ch,
+				Address:   addr,
+				Event:     data.Event,
+				Room:      data.Room,
+				Subject:   subject,
+				Body:      body,
+				Link:      data.Link,
+				DedupKey:  fmt.Sprintf("%s:%s:%d:%s:%s", data.Event, source, tenantID, p.StudentName, ch),
+			})
+		}
+	}
+
+	return n.queue.Enqueue(ctx, list, quiet)
+}
+
+// deliver — одна пачка из очереди; возвращает, сколько было взято
+func (n *Notifier) deliver(ctx context.Context, log *slog.Logger) int {
+	batch, err := n.queue.Claim(ctx, notifyBatch, notifyLease)
+	if err != nil {
+		log.Error("claim notifications", "error", err)
+		return 0
+	}
+
+	for _, m := range batch {
+		err := n.send(ctx, m)
+		// результат записываем и при остановке сервера — иначе сообщение уйдёт повторно
+		wctx := context.WithoutCancel(ctx)
+
+		if err == nil {
+			metrics.Notifications.WithLabelValues(m.Channel, "sent").Inc()
+			if err := n.queue.MarkSent(wctx, m.ID); err != nil {
+				log.Error("mark notification sent", "notification_id", m.ID, "error", err)
+			}
+			continue
+		}
+
+		var retryAt *time.Time
+		outcome := "failed"
+		if !errors.Is(err, notify.ErrPermanent) && m.Attempts < n.opts.MaxAttempts {
+			at := time.Now().Add(n.backoff(m.Attempts))
+			retryAt, outcome = &at, "retry"
+		}
+		metrics.Notifications.WithLabelValues(m.Channel, outcome).Inc()
+		log.Warn("notification not delivered",
+			"notification_id", m.ID,
+			"channel", m.Channel,
+			"attempt", m.Attempts,
+			"outcome", outcome,
+			"error", err,
+		)
+		if err := n.queue.MarkFailed(wctx, m.ID, err.Error(), retryAt); err != nil {
+			log.Error("mark notification failed", "notification_id", m.ID, "error", err)
+		}
+	}
+	return len(batch)
+}
+
+func (n *Notifier) send(ctx context.Context, m db.Notification) error {
+	ch, ok := n.channels[m.Channel]
+	if !ok {
+		return fmt.Errorf("%w: channel %s is not configured", notify.ErrPermanent, m.Channel)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifySendTimeout)
+	defer cancel()
+	return ch.Send(ctx, notify.Message{To: m.Address, Subject: m.Subject, Text: m.Body, Link: m.Link})
+}
+
+// backoff — RetryBase, x2, x4... не больше часа (attempt с 1)
+func (n *Notifier) backoff(attempt int) time.Duration {
+	d := n.opts.RetryBase
+	for i := 1; i < attempt && d < notifyMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, notifyMaxBackoff)
+}
+
+func (n *Notifier) joinLink(room string) string {
+	if n.opts.JoinURL == "" {
+		return ""
+	}
+	return n.opts.JoinURL + "?room=" + url.QueryEscape(room)
+}
+
+// address — куда слать в этом канале
+func address(p *db.NotificationPrefs, channel string) string {
+	switch channel {
+	case notify.ChannelEmail:
+		return p.Email
+	case notify.ChannelTelegram:
+		return p.TelegramChatID
+	case notify.ChannelWebPush:
+		return string(p.PushSubscription)
+	}
+	return ""
+}
+
+// PushKey — публичный VAPID-ключ для браузера ("" — web push не настроен)
+func (n *Notifier) PushKey() string {
+	if wp, ok := n.channels[notify.ChannelWebPush].(*notify.WebPush); ok {
+		return wp.PublicKey()
+	}
+	return ""
+}
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"streaming/internal/db"
+	"streaming/internal/notify"
+)
+
+// memQueue — notificationQueue в памяти, по правилам SQL из db/notifications.go
+type memQueue struct {
+	mu   sync.Mutex
+	subs []db.NotificationPrefs
+	rows []db.Notification
+}
+
+func (q *memQueue) RoomSubscribers(ctx context.Context, tenantID int64, room, event string) ([]db.NotificationPrefs, error) {
+	var out []db.NotificationPrefs
+	for _, p := range q.subs {
+		if p.Wants(event) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// Enqueue — dedup_key уникален; за quiet тому же ученику о том же в той же комнате не пишем
+func (q *memQueue) Enqueue(ctx context.Context, list []db.Notification, quiet time.Duration) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	added := 0
+	now := time.Now()
+next:
+	for _, n := range list {
+		for _, r := range q.rows {
+			if r.DedupKey == n.DedupKey {
+				continue next
+			}
+			if r.TenantID == n.TenantID && r.Room == n.Room && r.Recipient == n.Recipient &&
+				r.Channel == n.Channel && r.Event == n.Event && r.CreatedAt.After(now.Add(-quiet)) {
+				continue next
+			}
+		}
+		n.ID = int64(len(q.rows) + 1)
+		n.Status = "pending"
+		n.NextAttemptAt = now
+		n.CreatedAt = now
+		q.rows = append(q.rows, n)
+		added++
+	}
+	return added, nil
+}
+
+func (q *memQueue) Claim(ctx context.Context, limit int, lease time.Duration) ([]db.Notification, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	out := []db.Notification{}
+	for i := range q.rows {
+		r := &q.rows[i]
+		if len(out) == limit || r.Status != "pending" || r.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		r.Attempts++
+		r.NextAttemptAt = time.Now().Add(lease)
+		out = append(out, *r)
+	}
+	return out, nil
+}
+
+func (q *memQueue) MarkSent(ctx context.Context, id int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	r := &q.rows[id-1]
+	r.Status, r.LastError = "sent", ""
+	return nil
+}
+
+func (q *memQueue) MarkFailed(ctx context.Context, id int64, reason string, retryAt *time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	r := &q.rows[id-1]
+	r.LastError = reason
+	if retryAt == nil {
+		r.Status = "failed"
+		return nil
+	}
+	r.NextAttemptAt = *retryAt
+	return nil
+}
+
+func (q *memQueue) ClaimDueReminders(ctx context.Context, before time.Duration, limit int) ([]db.ScheduledLesson, error) {
+	return nil, nil
+}
+
+func (q *memQueue) ReleaseReminder(ctx context.Context, id int64) error {
+	return nil
+}
+
+// row — копия сообщения id
+func (q *memQueue) row(id int64) db.Notification {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.rows[id-1]
+}
+
+// due — сообщение id можно слать сразу (время повтора "наступило")
+func (q *memQueue) due(id int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rows[id-1].NextAttemptAt = time.Now()
+}
+
+// syncBuffer — Out для SMTPStub (пишут горутины соединений)
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+// freeAddr — свободный порт на localhost (после Close на нём никто не слушает)
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// startSMTPStub — SMTPStub до конца теста; письма — в возвращённый буфер
+func startSMTPStub(t *testing.T) (string, *syncBuffer) {
+	t.Helper()
+	out := &syncBuffer{}
+	stub := &notify.SMTPStub{Addr: freeAddr(t), Out: out}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := stub.ListenAndServe(ctx); err != nil {
+			t.Errorf("smtp stub: %v", err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// ждём, пока заглушка начнёт принимать соединения
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", stub.Addr)
+		if err == nil {
+			conn.Close()
+			return stub.Addr, out
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("smtp stub did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestNotifier(t *testing.T, smtpAddr string, opts NotifierOptions) (*Notifier, *memQueue) {
+	t.Helper()
+	templates, err := notify.LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := notify.SMTP{Addr: smtpAddr, From: "school@example.com", Timeout: 5 * time.Second}
+
+	n := NewNotifier(nil, []notify.Channel{email}, templates, opts)
+	q := &memQueue{}
+	n.queue = q
+	return n, q
+}
+
+func student(name, email string) db.NotificationPrefs {
+	return db.NotificationPrefs{
+		StudentName:   name,
+		Email:         email,
+		Channels:      []string{notify.ChannelEmail},
+		LessonStarted: true,
+		Reminders:     true,
+	}
+}
+
+func TestNotifierDeliversViaSMTP(t *testing.T) {
+	addr, mail := startSMTPStub(t)
+	n, q := newTestNotifier(t, addr, NotifierOptions{JoinURL: "https://school.test/join", MaxAttempts: 3, RetryBase: time.Minute})
+	q.subs = []db.NotificationPrefs{student("ann", "ann@example.com"), student("bob", "bob@example.com")}
+	ctx := context.Background()
+
+	added, err := n.LessonStarted(ctx, 1, 10, "math", "Algebra", "Ms. Smith")
+	if err != nil || added != 2 {
+		t.Fatalf("lesson started: added=%d err=%v", added, err)
+	}
+
+	if got := n.deliver(ctx, slog.Default()); got != 2 {
+		t.Fatalf("delivered batch = %d, want 2", got)
+	}
+	for id := int64(1); id <= 2; id++ {
+		if r := q.row(id); r.Status != "sent" || r.Attempts != 1 {
+			t.Errorf("notification %d: status=%s attempts=%d", id, r.Status, r.Attempts)
+		}
+	}
+
+	out := mail.String()
+	for _, want := range []string{"RCPT TO: <ann@example.com>", "RCPT TO: <bob@example.com>", "https://school.test/join?room=math"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("smtp stub output has no %q:\n%s", want, out)
+		}
+	}
+
+	// отправленное второй раз не берётся
+	if got := n.deliver(ctx, slog.Default()); got != 0 {
+		t.Fatalf("second deliver took %d", got)
+	}
+}
+
+func TestNotifierRetriesWithBackoff(t *testing.T) {
+	// никто не слушает: ошибка соединения — временная
+	n, q := newTestNotifier(t, freeAddr(t), NotifierOptions{MaxAttempts: 3, RetryBase: time.Minute})
+	q.subs = []db.NotificationPrefs{student("ann", "ann@example.com")}
+	ctx := context.Background()
+
+	if _, err := n.LessonStarted(ctx, 1, 10, "math", "", "teacher"); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt, wait := range []time.Duration{time.Minute, 2 * time.Minute} {
+		before := time.Now()
+		if got := n.deliver(ctx, slog.Default()); got != 1 {
+			t.Fatalf("attempt %d: deliver took %d", attempt+1, got)
+		}
+
+		r := q.row(1)
+		if r.Status != "pending" || r.Attempts != attempt+1 || r.LastError == "" {
+			t.Fatalf("attempt %d: status=%s attempts=%d error=%q", attempt+1, r.Status, r.Attempts, r.LastError)
+		}
+		if d := r.NextAttemptAt.Sub(before); d < wait || d > wait+5*time.Second {
+			t.Fatalf("attempt %d: retry in %v, want %v", attempt+1, d, wait)
+		}
+
+		// до времени повтора сообщение не берётся
+		if got := n.deliver(ctx, slog.Default()); got != 0 {
+			t.Fatalf("attempt %d: retried before backoff", attempt+1)
+		}
+		q.due(1)
+	}
+
+	// последняя попытка — больше не пробуем
+	n.deliver(ctx, slog.Default())
+	if r := q.row(1); r.Status != "failed" || r.Attempts != 3 {
+		t.Fatalf("after max attempts: status=%s attempts=%d", r.Status, r.Attempts)
+	}
+}
+
+func TestNotifierPermanentFailure(t *testing.T) {
+	addr, mail := startSMTPStub(t)
+	n, q := newTestNotifier(t, addr, NotifierOptions{MaxAttempts: 5, RetryBase: time.Minute})
+	q.subs = []db.NotificationPrefs{student("ann", "not an address")}
+	ctx := context.Background()
+
+	if _, err := n.LessonStarted(ctx, 1, 10, "math", "", "teacher"); err != nil {
+		t.Fatal(err)
+	}
+	n.deliver(ctx, slog.Default())
+
+	// адрес неверный — повтор не поможет
+	if r := q.row(1); r.Status != "failed" || r.Attempts != 1 {
+		t.Fatalf("status=%s attempts=%d, want failed after one attempt", r.Status, r.Attempts)
+	}
+	if out := mail.String(); out != "" {
+		t.Fatalf("smtp stub got mail:\n%s", out)
+	}
+}
+
+func TestNotifierBackoff(t *testing.T) {
+	n := &Notifier{opts: NotifierOptions{RetryBase: 30 * time.Second}}
+
+	for attempt, want := range map[int]time.Duration{
+		1:   30 * time.Second,
+		2:   time.Minute,
+		3:   2 * time.Minute,
+		7:   32 * time.Minute,
+		8:   notifyMaxBackoff,
+		100: notifyMaxBackoff,
+	} {
+		if got := n.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestNotifierLessonStartedDedup(t *testing.T) {
+	n, q := newTestNotifier(t, freeAddr(t), NotifierOptions{MaxAttempts: 3, RetryBase: time.Minute})
+	q.subs = []db.NotificationPrefs{student("ann", "ann@example.com")}
+	ctx := context.Background()
+
+	if added, _ := n.LessonStarted(ctx, 1, 10, "math", "", "teacher"); added != 1 {
+		t.Fatalf("first start: added %d", added)
+	}
+	// тот же урок второй раз (повторный вебхук) — тот же dedup_key
+	if added, _ := n.LessonStarted(ctx, 1, 10, "math", "", "teacher"); added != 0 {
+		t.Fatalf("same lesson again: added %d", added)
+	}
+	// учитель переподключился — новый урок, но в пределах lessonStartedQuiet
+	if added, _ := n.LessonStarted(ctx, 1, 11, "math", "", "teacher"); added != 0 {
+		t.Fatalf("reconnect within quiet period: added %d", added)
+	}
+	// другая комната и другая школа — отдельные сообщения
+	if added, _ := n.LessonStarted(ctx, 1, 12, "physics", "", "teacher"); added != 1 {
+		t.Fatalf("other room: added %d", added)
+	}
+	if added, _ := n.LessonStarted(ctx, 2, 10, "math", "", "teacher"); added != 1 {
+		t.Fatalf("other tenant: added %d", added)
+	}
+
+	if len(q.rows) != 3 {
+		t.Fatalf("queued %d notifications, want 3", len(q.rows))
+	}
+}
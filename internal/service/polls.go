@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"streaming/internal/db"
+)
+
+// PollTopic — topic data-сообщений опросов (клиент подписывается на него)
+const PollTopic = "poll"
+
+// Типы сообщений опроса
+const (
+	PollStarted = "poll_started" // всем: вопрос и варианты (без правильного ответа)
+	PollResults = "poll_results" // учителю: живые итоги после каждого ответа
+	PollClosed  = "poll_closed"  // всем: итоги и правильный ответ
+)
+
+// PollMessage — data-сообщение опроса
+type PollMessage struct {
+	Type     string     `json:"type"`
+	PollID   int64      `json:"poll_id"`
+	LessonID int64      `json:"lesson_id"`
+	Question string     `json:"question,omitempty"`
+	Kind     string     `json:"kind,omitempty"`
+	Options  []string   `json:"options,omitempty"`
+	ClosesAt *time.Time `json:"closes_at,omitempty"`
+
+	// итоги (poll_results / poll_closed)
+	Counts   []int   `json:"counts,omitempty"`
+	Answered int     `json:"answered,omitempty"`
+	Correct  []int64 `json:"correct,omitempty"` // только в poll_closed
+}
+
+// NewPollMessage — сообщение по опросу; results == nil => без итогов
+func NewPollMessage(typ string, p *db.Poll, results *db.PollResults) PollMessage {
+	m := PollMessage{
+		Type:     typ,
+		PollID:   p.ID,
+		LessonID: p.LessonID,
+	}
+	if typ == PollStarted {
+		m.Question = p.Question
+		m.Kind = p.Kind
+		m.Options = p.Options
+		m.ClosesAt = p.ClosesAt
+	}
+	if results != nil {
+		m.Counts = results.Counts
+		m.Answered = results.Answered
+	}
+	if typ == PollClosed {
+		m.Correct = p.Correct
+	}
+	return m
+}
+
+// SendPoll — разослать сообщение опроса в комнату (identities пусто => всем)
+func (s *LiveKitService) SendPoll(ctx context.Context, room string, m PollMessage, identities ...string) error {
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.SendData(ctx, room, PollTopic, raw, identities...)
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"streaming/internal/db"
+	"streaming/internal/metrics"
+)
+
+// reaperActor — кто закрыл урок (lesson_events.actor)
+const reaperActor = "reaper"
+
+// Reaper — фоновая сверка открытых уроков/участников с реальными комнатами LiveKit.
+// Учитель закрыл вкладку, сервер упал посреди урока, webhook не дошёл — урок
+// остаётся "идущим" в БД. Reaper закрывает такие уроки временем последней
+// активности (а не временем обнаружения), чтобы не раздувать длительность.
+type Reaper struct {
+	db       *sql.DB
+	nodes    *LiveKitNodes
+	interval time.Duration
+	grace    time.Duration
+
+	// когда урок / участника последний раз видели живыми в LiveKit
+	// (только в памяти: после рестарта опираемся на данные БД)
+	lessonSeen map[int64]time.Time
+	partSeen   map[int64]map[string]time.Time
+}
+
+// ReaperReport — что исправил один проход
+type ReaperReport struct {
+	LessonsClosed      int // комнаты нет в LiveKit
+	LessonsSuperseded  int // в той же комнате открыт более новый урок
+	ParticipantsClosed int // участника нет в комнате
+	NodesSkipped       int // узел недоступен — ничего на нём не трогали
+}
+
+func NewReaper(dbConn *sql.DB, nodes *LiveKitNodes, interval, grace time.Duration) *Reaper {
+	return &Reaper{
+		db:         dbConn,
+		nodes:      nodes,
+		interval:   interval,
+		grace:      grace,
+		lessonSeen: make(map[int64]time.Time),
+		partSeen:   make(map[int64]map[string]time.Time),
+	}
+}
+
+// Run — проходы каждые interval до отмены ctx
+func (r *Reaper) Run(ctx context.Context) {
+	log := slog.With("component", "reaper")
+	log.Info("reaper started", "interval", r.interval.String(), "grace", r.grace.String())
+
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		// проход не должен наезжать на следующий
+		passCtx, cancel := context.WithTimeout(ctx, r.interval)
+		rep, err := r.Reconcile(passCtx, time.Now())
+		cancel()
+
+		switch {
+		case err != nil:
+			metrics.ReaperRuns.WithLabelValues("error").Inc()
+			log.Error("reaper pass failed", "error", err)
+		case rep.NodesSkipped > 0:
+			metrics.ReaperRuns.WithLabelValues("partial").Inc()
+		default:
+			metrics.ReaperRuns.WithLabelValues("ok").Inc()
+		}
+
+		if rep.LessonsClosed+rep.LessonsSuperseded+rep.ParticipantsClosed > 0 {
+			log.Info("reaper fixed orphans",
+				"lessons_closed", rep.LessonsClosed,
+				"lessons_superseded", rep.LessonsSuperseded,
+				"participants_closed", rep.ParticipantsClosed,
+				"nodes_skipped", rep.NodesSkipped,
+			)
+		}
+	}
+}
+
+// Reconcile — один проход сверки (now — "текущее" время прохода)
+func (r *Reaper) Reconcile(ctx context.Context, now time.Time) (ReaperReport, error) {
+	var rep ReaperReport
+	log := slog.With("component", "reaper")
+
+	lessons, err := db.OpenLessons(ctx, r.db)
+	if err != nil {
+		return rep, err
+	}
+
+	open := make(map[int64]bool, len(lessons))
+	byNode := make(map[string][]db.OpenLesson)
+	for _, l := range lessons {
+		open[l.ID] = true
+
+		// брошенный урок: учитель начал новый в той же комнате, старый так и не закрылся
+		if l.Superseded {
+			if r.closeLesson(ctx, log, l, r.lastActivity(l), "superseded") {
+				rep.LessonsSuperseded++
+			}
+			continue
+		}
+		byNode[l.LiveKitNode] = append(byNode[l.LiveKitNode], l)
+	}
+
+	for nodeID, ls := range byNode {
+		node, ok := r.nodes.Get(nodeID)
+		if !ok {
+			// узел убрали из конфига — проверить нечем, ничего не закрываем
+			log.Warn("reaper: lessons pinned to unknown livekit node", "livekit_node", nodeID, "lessons", len(ls))
+			rep.NodesSkipped++
+			continue
+		}
+
+		rooms, err := node.ActiveRooms(ctx)
+		if err != nil {
+			// LiveKit недоступен — не путаем "сервер лежит" с "комнаты нет"
+			log.Warn("reaper: list livekit rooms failed", "livekit_node", nodeID, "error", err)
+			rep.NodesSkipped++
+			continue
+		}
+
+		for _, l := range ls {
+			lkRoom := l.LiveKitRoom()
+			if !rooms[lkRoom] {
+				at := r.lastActivity(l)
+				if now.Sub(at) < r.grace {
+					continue
+				}
+				if r.closeLesson(ctx, log, l, at, "room_gone") {
+					rep.LessonsClosed++
+				}
+				continue
+			}
+
+			r.lessonSeen[l.ID] = now
+			n, err := r.reconcileParticipants(ctx, log, node, l, lkRoom, now)
+			rep.ParticipantsClosed += n
+			if err != nil {
+				log.Warn("reaper: participants not reconciled", "lesson_id", l.ID, "error", err)
+			}
+		}
+	}
+
+	// забываем закрытые уроки
+	for id := range r.lessonSeen {
+		if !open[id] {
+			delete(r.lessonSeen, id)
+		}
+	}
+	for id := range r.partSeen {
+		if !open[id] {
+			delete(r.partSeen, id)
+		}
+	}
+
+	return rep, nil
+}
+
+// reconcileParticipants — закрыть участников, которых нет в живой комнате дольше grace
+func (r *Reaper) reconcileParticipants(
+	ctx context.Context,
+	log *slog.Logger,
+	node *LiveKitNode,
+	l db.OpenLesson,
+	lkRoom string,
+	now time.Time,
+) (int, error) {
+	present, err := node.RoomParticipants(ctx, lkRoom)
+	if errors.Is(err, ErrRoomNotFound) {
+		// комната закрылась между ListRooms и ListParticipants — разберёмся в следующий проход
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	seen := r.partSeen[l.ID]
+	if seen == nil {
+		seen = make(map[string]time.Time)
+		r.partSeen[l.ID] = seen
+	}
+
+	parts, err := db.OpenParticipants(ctx, r.db, l.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	closed := 0
+	for _, p := range parts {
+		if present[p.Name] {
+			seen[p.Name] = now
+			continue
+		}
+
+		// получил токен, но ещё подключается — ждём grace от входа
+		at := p.JoinedAt
+		if t, ok := seen[p.Name]; ok && t.After(at) {
+			at = t
+		}
+		if now.Sub(at) < r.grace {
+			continue
+		}
+
+		if err := db.CloseParticipantAt(ctx, r.db, l.ID, p.Name, at); err != nil {
+			return closed, err
+		}
+		delete(seen, p.Name)
+		closed++
+		metrics.ReaperFixes.WithLabelValues("participant_gone").Inc()
+		log.Info("reaper: participant closed",
+			"lesson_id", l.ID,
+			"tenant", l.TenantSlug,
+			"room", l.Room,
+			"identity", p.Name,
+			"left_at", at.UTC(),
+		)
+	}
+	return closed, nil
+}
+
+// closeLesson — закрыть урок временем at; false => не закрыли (уже закрыт / ошибка)
+func (r *Reaper) closeLesson(ctx context.Context, log *slog.Logger, l db.OpenLesson, at time.Time, kind string) bool {
+	ok, err := db.EndLessonAt(ctx, r.db, l.ID, at, reaperActor)
+	if err != nil {
+		log.Error("reaper: close lesson failed", "lesson_id", l.ID, "error", err)
+		return false
+	}
+	delete(r.lessonSeen, l.ID)
+	delete(r.partSeen, l.ID)
+	if !ok {
+		return false
+	}
+
+	metrics.ReaperFixes.WithLabelValues("lesson_" + kind).Inc()
+	log.Info("reaper: lesson closed",
+		"reason", kind,
+		"lesson_id", l.ID,
+		"tenant", l.TenantSlug,
+		"room", l.Room,
+		"livekit_node", l.LiveKitNode,
+		"started_at", l.StartedAt.UTC(),
+		"ended_at", at.UTC(),
+	)
+	return true
+}
+
+// lastActivity — последняя известная активность урока: БД или когда видели комнату
+func (r *Reaper) lastActivity(l db.OpenLesson) time.Time {
+	at := l.LastActivity
+	if t, ok := r.lessonSeen[l.ID]; ok && t.After(at) {
+		at = t
+	}
+	return at
+}
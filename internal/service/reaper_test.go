@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"google.golang.org/protobuf/proto"
+
+	"streaming/internal/db"
+	"streaming/internal/db/dbtest"
+)
+
+// fakeRoomService — RoomService узла LiveKit: живые комнаты и их участники
+type fakeRoomService struct {
+	mu    sync.Mutex
+	rooms map[string][]string // комната => identity
+}
+
+func (f *fakeRoomService) set(room string, identities ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rooms[room] = identities
+}
+
+func (f *fakeRoomService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var res proto.Message
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/ListRooms"):
+		out := &livekit.ListRoomsResponse{}
+		for name := range f.rooms {
+			out.Rooms = append(out.Rooms, &livekit.Room{Name: name})
+		}
+		res = out
+	case strings.HasSuffix(r.URL.Path, "/ListParticipants"):
+		req := &livekit.ListParticipantsRequest{}
+		body, err := io.ReadAll(r.Body)
+		if err == nil {
+			err = proto.Unmarshal(body, req)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		out := &livekit.ListParticipantsResponse{}
+		for _, id := range f.rooms[req.Room] {
+			out.Participants = append(out.Participants, &livekit.ParticipantInfo{Identity: id})
+		}
+		res = out
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	b, err := proto.Marshal(res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/protobuf")
+	_, _ = w.Write(b)
+}
+
+func endedAt(t *testing.T, conn *sql.DB, lessonID int64) *time.Time {
+	t.Helper()
+	var ended sql.NullTime
+	if err := conn.QueryRow(`SELECT ended_at FROM lessons WHERE id = $1`, lessonID).Scan(&ended); err != nil {
+		t.Fatal(err)
+	}
+	if !ended.Valid {
+		return nil
+	}
+	return &ended.Time
+}
+
+func TestReaperSupersededAndGrace(t *testing.T) {
+	conn := dbtest.Open(t)
+	ctx := context.Background()
+
+	lk := &fakeRoomService{rooms: map[string][]string{}}
+	srv := httptest.NewServer(lk)
+	defer srv.Close()
+	nodes := NewLiveKitNodes([]*LiveKitNode{{
+		ID:             "node-1",
+		LiveKitService: NewLiveKitService("key", "secret-secret-secret-secret-1234", 0, false, "", srv.URL),
+	}}, "least_loaded")
+
+	start := func(room, teacher, node string) *db.Lesson {
+		t.Helper()
+		l, _, err := db.StartLesson(ctx, conn, 1, room, teacher, node, db.Capacity{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+
+	// math: учитель начал новый урок, старый брошен. physics живёт в LiveKit.
+	// history приколот к узлу, которого больше нет в конфиге
+	abandoned := start("math", "alice", "node-1")
+	math := start("math", "bob", "node-1")
+	physics := start("physics", "carl", "node-1")
+	history := start("history", "dana", "retired")
+	for _, name := range []string{"ann", "eve"} {
+		if err := db.JoinParticipant(ctx, conn, 1, physics.ID, "physics", name, "student", db.Capacity{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lk.set("physics", "carl")
+
+	r := NewReaper(conn, nodes, time.Minute, time.Minute)
+
+	// сразу: брошенный урок закрыт, остальные ещё в пределах grace
+	rep, err := r.Reconcile(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep != (ReaperReport{LessonsSuperseded: 1, NodesSkipped: 1}) {
+		t.Fatalf("first pass = %+v, want 1 superseded, 1 node skipped", rep)
+	}
+	if endedAt(t, conn, abandoned.ID) == nil || endedAt(t, conn, math.ID) != nil {
+		t.Fatal("first pass must close only the superseded lesson")
+	}
+
+	// ann подключилась, eve так и не появилась
+	lk.set("physics", "carl", "ann")
+	later := time.Now().Add(2 * time.Minute)
+	rep, err = r.Reconcile(ctx, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep != (ReaperReport{LessonsClosed: 1, ParticipantsClosed: 1, NodesSkipped: 1}) {
+		t.Fatalf("after grace = %+v, want math and eve closed, 1 node skipped", rep)
+	}
+
+	// время конца — последняя активность, а не время прохода
+	if ended := endedAt(t, conn, math.ID); ended == nil || !ended.Before(later.Add(-time.Minute)) {
+		t.Fatalf("math ended at %v, want its last activity (before %v)", ended, later.Add(-time.Minute))
+	}
+	if endedAt(t, conn, physics.ID) != nil || endedAt(t, conn, history.ID) != nil {
+		t.Fatal("live lesson or lesson on an unknown node was closed")
+	}
+
+	open, err := db.OpenParticipants(ctx, conn, physics.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, p := range open {
+		names[p.Name] = true
+	}
+	if len(names) != 2 || !names["carl"] || !names["ann"] {
+		t.Fatalf("open participants = %+v, want carl and ann", open)
+	}
+}
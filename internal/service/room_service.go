@@ -0,0 +1,420 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	lkauth "github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/twitchtv/twirp"
+	"go.opentelemetry.io/otel/attribute"
+
+	"streaming/internal/tracing"
+)
+
+// ErrRoomNotFound — комнаты нет на LiveKit узле (ещё никто не подключился / уже закрыта)
+var ErrRoomNotFound = errors.New("livekit room not found")
+
+// ErrParticipantNotConnected — участника сейчас нет в комнате
+var ErrParticipantNotConnected = errors.New("participant is not connected")
+
+// ErrNoAudioTrack — участник в комнате, но микрофон не опубликован
+var ErrNoAudioTrack = errors.New("participant has no microphone track")
+
+// roomServiceTimeout — RoomService вызывается из HTTP-запросов, не держим их долго
+const roomServiceTimeout = 5 * time.Second
+
+// apiBaseURL — адрес RoomService: APIURL или http(s)://PublicHost:HTTPPort
+func (s *LiveKitService) apiBaseURL() string {
+	if s.APIURL != "" {
+		return s.APIURL
+	}
+
+	scheme := "http"
+	if s.Secure {
+		scheme = "https"
+	}
+	host := s.PublicHost
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, host, s.HTTPPort)
+}
+
+// roomClient — twirp клиент RoomService и ctx с admin-токеном на комнату
+// (клиент общий на узел, токен — свой на каждый вызов)
+func (s *LiveKitService) roomClient(ctx context.Context, room string) (livekit.RoomService, context.Context, error) {
+	at := lkauth.NewAccessToken(s.APIKey, s.APISecret)
+	at.AddGrant(&lkauth.VideoGrant{
+		Room:       room,
+		RoomAdmin:  true,
+		RoomCreate: true,
+		RoomList:   true,
+	})
+	at.SetValidFor(time.Minute)
+
+	token, err := at.ToJWT()
+	if err != nil {
+		return nil, ctx, err
+	}
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer "+token)
+	ctx, err = twirp.WithHTTPRequestHeaders(ctx, h)
+	if err != nil {
+		return nil, ctx, err
+	}
+
+	return s.rooms, ctx, nil
+}
+
+// =======================
+// Room metadata
+// =======================
+
+// hiddenParticipantSeats — egress (запись, субтитры) входит в комнату скрытым участником
+const hiddenParticipantSeats = 2
+
+// CreateRoom создаёт комнату заранее (до подключения учителя) с метаданными урока.
+// Если комната уже есть — обновляет её метаданные.
+func (s *LiveKitService) CreateRoom(ctx context.Context, room string, md RoomMetadata) error {
+	ctx, span := tracing.Start(ctx, "livekit.CreateRoom", attribute.String("room", room))
+	defer span.End()
+
+	raw, err := md.JSON()
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	client, ctx, err := s.roomClient(ctx, room)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	// MaxParticipants (0 => без ограничения) LiveKit проверяет сам при подключении —
+	// страховка на случай токена в обход join; места под скрытых участников (egress) оставляем
+	limit := uint32(0)
+	if md.MaxParticipants > 0 {
+		limit = uint32(md.MaxParticipants + hiddenParticipantSeats)
+	}
+	if _, err := client.CreateRoom(ctx, &livekit.CreateRoomRequest{
+		Name:            room,
+		Metadata:        raw,
+		MaxParticipants: limit,
+	}); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	// CreateRoom для существующей комнаты метаданные не меняет
+	_, err = client.UpdateRoomMetadata(ctx, &livekit.UpdateRoomMetadataRequest{Room: room, Metadata: raw})
+	return tracing.Fail(span, err)
+}
+
+// RoomMetadata — текущие метаданные комнаты
+func (s *LiveKitService) RoomMetadata(ctx context.Context, room string) (RoomMetadata, error) {
+	ctx, span := tracing.Start(ctx, "livekit.RoomMetadata", attribute.String("room", room))
+	defer span.End()
+
+	client, ctx, err := s.roomClient(ctx, room)
+	if err != nil {
+		return RoomMetadata{}, tracing.Fail(span, err)
+	}
+
+	res, err := client.ListRooms(ctx, &livekit.ListRoomsRequest{Names: []string{room}})
+	if err != nil {
+		return RoomMetadata{}, tracing.Fail(span, err)
+	}
+	if len(res.Rooms) == 0 {
+		return RoomMetadata{}, ErrRoomNotFound
+	}
+
+	md, err := ParseRoomMetadata(res.Rooms[0].Metadata)
+	return md, tracing.Fail(span, err)
+}
+
+// UpdateRoomMetadata — разослать всем в комнате новые метаданные
+func (s *LiveKitService) UpdateRoomMetadata(ctx context.Context, room string, md RoomMetadata) error {
+	ctx, span := tracing.Start(ctx, "livekit.UpdateRoomMetadata", attribute.String("room", room))
+	defer span.End()
+
+	raw, err := md.JSON()
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	client, ctx, err := s.roomClient(ctx, room)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	_, err = client.UpdateRoomMetadata(ctx, &livekit.UpdateRoomMetadataRequest{Room: room, Metadata: raw})
+	if isTwirpNotFound(err) {
+		return ErrRoomNotFound
+	}
+	return tracing.Fail(span, err)
+}
+
+// =======================
+// Participant metadata
+// =======================
+
+// ParticipantMetadata — метаданные подключённого участника
+func (s *LiveKitService) ParticipantMetadata(ctx context.Context, room, identity string) (ParticipantMetadata, error) {
+	ctx, span := tracing.Start(ctx, "livekit.ParticipantMetadata", attribute.String("room", room))
+	defer span.End()
+
+	client, ctx, err := s.roomClient(ctx, room)
+	if err != nil {
+		return ParticipantMetadata{}, tracing.Fail(span, err)
+	}
+
+	p, err := client.GetParticipant(ctx, &livekit.RoomParticipantIdentity{Room: room, Identity: identity})
+	if isTwirpNotFound(err) {
+		return ParticipantMetadata{}, ErrParticipantNotConnected
+	}
+	if err != nil {
+		return ParticipantMetadata{}, tracing.Fail(span, err)
+	}
+
+	md, err := ParseParticipantMetadata(p.Metadata)
+	return md, tracing.Fail(span, err)
+}
+
+// UpdateParticipantMetadata — новые метаданные участника (LiveKit рассылает их всем)
+func (s *LiveKitService) UpdateParticipantMetadata(ctx context.Context, room, identity string, md ParticipantMetadata) error {
+	ctx, span := tracing.Start(ctx, "livekit.UpdateParticipantMetadata", attribute.String("room", room))
+	defer span.End()
+
+	raw, err := md.JSON()
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	client, ctx, err := s.roomClient(ctx, room)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	_, err = client.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
+		Room:     room,
+		Identity: identity,
+		Metadata: raw,
+	})
+	if isTwirpNotFound(err) {
+		return ErrParticipantNotConnected
+	}
+	return tracing.Fail(span, err)
+}
+
+// RemoveParticipant — отключить участника сейчас (kick / ban); ErrParticipantNotConnected — его нет
+func (s *LiveKitService) RemoveParticipant(ctx context.Context, room, identity string) error {
+	ctx, span := tracing.Start(ctx, "livekit.RemoveParticipant", attribute.String("room", room))
+	defer span.End()
+
+	client, ctx, err := s.roomClient(ctx, room)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	_, err = client.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{Room: room, Identity: identity})
+	if isTwirpNotFound(err) {
+		return ErrParticipantNotConnected
+	}
+	return tracing.Fail(span, err)
+}
+
+// =======================
+// Data messages
+// =======================
+
+// SendData — надёжное (reliable) data-сообщение в комнату по topic;
+// identities пусто => всем участникам.
+func (s *LiveKitService) SendData(ctx context.Context, room, topic string, data []byte, identities ...string) error {
+	ctx, span := tracing.Start(ctx, "livekit.SendData",
+		attribute.String("room", room),
+		attribute.String("topic", topic),
+	)
+	defer span.End()
+
+	client, ctx, err := s.roomClient(ctx, room)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	_, err = client.SendData(ctx, &livekit.SendDataRequest{
+		Room:                  room,
+		Data:                  data,
+		Kind:                  livekit.DataPacket_RELIABLE,
+		Topic:                 &topic,
+		DestinationIdentities: identities,
+		// сервер отбрасывает повторы по nonce
+		Nonce: nonce(),
+	})
+	if isTwirpNotFound(err) {
+		return ErrRoomNotFound
+	}
+	return tracing.Fail(span, err)
+}
+
+func nonce() []byte {
+	id := uuid.New()
+	return id[:]
+}
+
+// =======================
+// Rooms / participants (сверка с БД)
+// =======================
+
+// ActiveRooms — имена комнат, которые сейчас живут на узле
+func (s *LiveKitService) ActiveRooms(ctx context.Context) (map[string]bool, error) {
+	ctx, span := tracing.Start(ctx, "livekit.ActiveRooms")
+	defer span.End()
+
+	client, ctx, err := s.roomClient(ctx, "")
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+
+	res, err := client.ListRooms(ctx, &livekit.ListRoomsRequest{})
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+
+	rooms := make(map[string]bool, len(res.Rooms))
+	for _, r := range res.Rooms {
+		rooms[r.Name] = true
+	}
+	return rooms, nil
+}
+
+// RoomParticipants — identity подключённых участников; комнаты нет => ErrRoomNotFound
+func (s *LiveKitService) RoomParticipants(ctx context.Context, room string) (map[string]bool, error) {
+	ctx, span := tracing.Start(ctx, "livekit.RoomParticipants", attribute.String("room", room))
+	defer span.End()
+
+	client, ctx, err := s.roomClient(ctx, room)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+
+	res, err := client.ListParticipants(ctx, &livekit.ListParticipantsRequest{Room: room})
+	if isTwirpNotFound(err) {
+		return nil, ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+
+	ids := make(map[string]bool, len(res.Participants))
+	for _, p := range res.Participants {
+		ids[p.Identity] = true
+	}
+	return ids, nil
+}
+
+// AudioTrack — sid опубликованного микрофона участника
+func (s *LiveKitService) AudioTrack(ctx context.Context, room, identity string) (string, error) {
+	ctx, span := tracing.Start(ctx, "livekit.AudioTrack", attribute.String("room", room))
+	defer span.End()
+
+	client, ctx, err := s.roomClient(ctx, room)
+	if err != nil {
+		return "", tracing.Fail(span, err)
+	}
+
+	p, err := client.GetParticipant(ctx, &livekit.RoomParticipantIdentity{Room: room, Identity: identity})
+	if isTwirpNotFound(err) {
+		return "", ErrParticipantNotConnected
+	}
+	if err != nil {
+		return "", tracing.Fail(span, err)
+	}
+
+	for _, t := range p.Tracks {
+		if t.Type == livekit.TrackType_AUDIO && t.Source == livekit.TrackSource_MICROPHONE {
+			return t.Sid, nil
+		}
+	}
+	return "", ErrNoAudioTrack
+}
+
+// =======================
+// Egress
+// =======================
+
+// egressClient — twirp клиент Egress (тот же адрес, что RoomService) с токеном на запись комнаты
+func (s *LiveKitService) egressClient(ctx context.Context, room string) (livekit.Egress, context.Context, error) {
+	at := lkauth.NewAccessToken(s.APIKey, s.APISecret)
+	at.AddGrant(&lkauth.VideoGrant{Room: room, RoomRecord: true})
+	at.SetValidFor(time.Minute)
+
+	token, err := at.ToJWT()
+	if err != nil {
+		return nil, ctx, err
+	}
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer "+token)
+	ctx, err = twirp.WithHTTPRequestHeaders(ctx, h)
+	if err != nil {
+		return nil, ctx, err
+	}
+
+	return s.egress, ctx, nil
+}
+
+// StartTrackWebsocket — Track Egress: egress-сервис подписывается на дорожку как скрытый
+// участник и шлёт её звук (raw PCM) на wsURL. Возвращает egress id.
+func (s *LiveKitService) StartTrackWebsocket(ctx context.Context, room, trackID, wsURL string) (string, error) {
+	ctx, span := tracing.Start(ctx, "livekit.StartTrackWebsocket",
+		attribute.String("room", room),
+		attribute.String("track_id", trackID),
+	)
+	defer span.End()
+
+	client, ctx, err := s.egressClient(ctx, room)
+	if err != nil {
+		return "", tracing.Fail(span, err)
+	}
+
+	info, err := client.StartTrackEgress(ctx, &livekit.TrackEgressRequest{
+		RoomName: room,
+		TrackId:  trackID,
+		Output:   &livekit.TrackEgressRequest_WebsocketUrl{WebsocketUrl: wsURL},
+	})
+	if err != nil {
+		return "", tracing.Fail(span, err)
+	}
+	return info.EgressId, nil
+}
+
+// StopEgress — остановить egress (уже закончился => не ошибка)
+func (s *LiveKitService) StopEgress(ctx context.Context, room, egressID string) error {
+	ctx, span := tracing.Start(ctx, "livekit.StopEgress", attribute.String("egress_id", egressID))
+	defer span.End()
+
+	client, ctx, err := s.egressClient(ctx, room)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	_, err = client.StopEgress(ctx, &livekit.StopEgressRequest{EgressId: egressID})
+	// уже закончился (ушёл учитель / закрылась комната)
+	if isTwirpNotFound(err) || isTwirpCode(err, twirp.FailedPrecondition) {
+		return nil
+	}
+	return tracing.Fail(span, err)
+}
+
+func isTwirpCode(err error, code twirp.ErrorCode) bool {
+	var te twirp.Error
+	return errors.As(err, &te) && te.Code() == code
+}
+
+func isTwirpNotFound(err error) bool {
+	return isTwirpCode(err, twirp.NotFound)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRoomClientSharedWithTokenPerCall(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		auth []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auth = append(auth, r.Header.Get("Authorization"))
+		mu.Unlock()
+		// пустой ListRoomsResponse — комнаты нет
+		w.Header().Set("Content-Type", "application/protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	s := NewLiveKitService("key", "secret-secret-secret-secret-1234", 0, false, "", srv.URL)
+
+	first, _, err := s.roomClient(context.Background(), "math")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := s.roomClient(context.Background(), "physics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatal("roomClient built a new twirp client per call")
+	}
+
+	for _, room := range []string{"math", "physics"} {
+		if _, err := s.RoomMetadata(context.Background(), room); !errors.Is(err, ErrRoomNotFound) {
+			t.Fatalf("%s: %v, want %v", room, err, ErrRoomNotFound)
+		}
+	}
+	// admin-токен — свой на каждую комнату
+	if len(auth) != 2 || !strings.HasPrefix(auth[0], "Bearer ") || auth[0] == auth[1] {
+		t.Fatalf("authorization headers = %q", auth)
+	}
+}
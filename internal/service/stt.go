@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSTTClosed — запись в уже закрытый поток распознавания
+var ErrSTTClosed = errors.New("stt stream closed")
+
+// AudioFormat — PCM s16le, который получает провайдер
+type AudioFormat struct {
+	SampleRate int // Hz
+	Channels   int
+}
+
+// BytesPerSecond — объём секунды звука (2 байта на отсчёт)
+func (f AudioFormat) BytesPerSecond() int {
+	return f.SampleRate * f.Channels * 2
+}
+
+// Caption — распознанный фрагмент; Start/End — смещение от начала потока
+type Caption struct {
+	Text  string
+	Final bool // false => промежуточный вариант, его заменит следующий
+	Start time.Duration
+	End   time.Duration
+}
+
+// STTProvider — распознавание речи (облачный сервис, свой сервер...)
+type STTProvider interface {
+	Stream(ctx context.Context, format AudioFormat, language string) (STTStream, error)
+}
+
+// STTStream — один поток распознавания.
+// Write — звук по мере поступления; Close — конец звука: провайдер отдаёт
+// последние результаты и закрывает Results.
+type STTStream interface {
+	Write(pcm []byte) error
+	Results() <-chan Caption
+	Close() error
+}
+
+// =======================
+// Fake provider
+// =======================
+
+// FakeSTT — провайдер для тестов и демо: на каждые Every секунд звука
+// (любого, хоть тишины) выдаёт промежуточный и финальный фрагмент по очереди из Phrases.
+type FakeSTT struct {
+	Phrases []string
+	Every   time.Duration
+}
+
+func (f FakeSTT) Stream(_ context.Context, format AudioFormat, language string) (STTStream, error) {
+	if format.BytesPerSecond() <= 0 {
+		return nil, fmt.Errorf("fake stt: invalid audio format %+v", format)
+	}
+
+	every := f.Every
+	if every <= 0 {
+		every = 3 * time.Second
+	}
+	phrases := f.Phrases
+	if len(phrases) == 0 {
+		phrases = []string{"[" + language + "] проверка субтитров"}
+	}
+
+	return &fakeSTTStream{
+		phrases: phrases,
+		bps:     int64(format.BytesPerSecond()),
+		every:   int64(every.Seconds() * float64(format.BytesPerSecond())),
+		out:     make(chan Caption, 16),
+	}, nil
+}
+
+type fakeSTTStream struct {
+	phrases []string
+	bps     int64
+	every   int64 // байт звука на фрагмент
+
+	mu      sync.Mutex
+	total   int64 // байт получено
+	done    int64 // байт уже "распознано" финальными фрагментами
+	partial bool  // промежуточный для текущего фрагмента уже отдан
+	next    int
+	closed  bool
+	out     chan Caption
+}
+
+func (s *fakeSTTStream) Write(pcm []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSTTClosed
+	}
+
+	s.total += int64(len(pcm))
+	for s.total-s.done >= s.every {
+		s.emit(s.done+s.every, true)
+	}
+	if !s.partial && s.total-s.done >= s.every/2 {
+		s.emit(s.total, false)
+	}
+	return nil
+}
+
+func (s *fakeSTTStream) Results() <-chan Caption {
+	return s.out
+}
+
+func (s *fakeSTTStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	// хвост короче фрагмента — тоже фраза (учитель договорил и выключил микрофон)
+	if s.total > s.done {
+		s.emit(s.total, true)
+	}
+	close(s.out)
+	return nil
+}
+
+// emit — фрагмент [done, end); промежуточный — первая половина слов фразы
+func (s *fakeSTTStream) emit(end int64, final bool) {
+	text := s.phrases[s.next%len(s.phrases)]
+	c := Caption{
+		Final: final,
+		Start: s.offset(s.done),
+		End:   s.offset(end),
+	}
+
+	if final {
+		c.Text = text
+		s.done = end
+		s.next++
+		s.partial = false
+	} else {
+		words := strings.Fields(text)
+		c.Text = strings.Join(words[:(len(words)+1)/2], " ")
+		s.partial = true
+	}
+
+	s.out <- c
+}
+
+func (s *fakeSTTStream) offset(bytes int64) time.Duration {
+	return time.Duration(bytes * int64(time.Second) / s.bps)
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sttFormat — 1 секунда звука = 2000 байт
+var sttFormat = AudioFormat{SampleRate: 1000, Channels: 1}
+
+func fakeStream(t *testing.T, f FakeSTT) STTStream {
+	t.Helper()
+	stream, err := f.Stream(context.Background(), sttFormat, "ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stream
+}
+
+// drain — все результаты после Close
+func drain(t *testing.T, stream STTStream) []Caption {
+	t.Helper()
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var out []Caption
+	for c := range stream.Results() {
+		out = append(out, c)
+	}
+	return out
+}
+
+func TestFakeSTTPartialThenFinal(t *testing.T) {
+	stream := fakeStream(t, FakeSTT{Phrases: []string{"раз два три четыре"}, Every: time.Second})
+
+	// полфрагмента — промежуточный, ещё полфрагмента — финальный
+	for range 2 {
+		if err := stream.Write(make([]byte, 1000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := drain(t, stream)
+
+	want := []Caption{
+		{Text: "раз два", Final: false, Start: 0, End: 500 * time.Millisecond},
+		{Text: "раз два три четыре", Final: true, Start: 0, End: time.Second},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("captions = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("caption %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFakeSTTCyclesPhrases(t *testing.T) {
+	stream := fakeStream(t, FakeSTT{Phrases: []string{"a", "b"}, Every: time.Second})
+
+	// три фрагмента одной записью — три финальных подряд
+	if err := stream.Write(make([]byte, 3*2000)); err != nil {
+		t.Fatal(err)
+	}
+
+	var finals []string
+	var last time.Duration
+	for _, c := range drain(t, stream) {
+		if !c.Final {
+			continue
+		}
+		if c.Start != last {
+			t.Errorf("caption %q starts at %v, want %v", c.Text, c.Start, last)
+		}
+		last = c.End
+		finals = append(finals, c.Text)
+	}
+	if got := strings.Join(finals, ","); got != "a,b,a" {
+		t.Fatalf("finals = %q, want a,b,a", got)
+	}
+	if last != 3*time.Second {
+		t.Fatalf("last caption ends at %v, want 3s", last)
+	}
+}
+
+func TestFakeSTTCloseFlushesTail(t *testing.T) {
+	stream := fakeStream(t, FakeSTT{Phrases: []string{"хвост"}, Every: time.Second})
+
+	if err := stream.Write(make([]byte, 200)); err != nil {
+		t.Fatal(err)
+	}
+	got := drain(t, stream)
+
+	if len(got) != 1 || !got[0].Final || got[0].Text != "хвост" || got[0].End != 100*time.Millisecond {
+		t.Fatalf("captions = %+v, want one final of 100ms", got)
+	}
+}
+
+func TestFakeSTTWriteAfterClose(t *testing.T) {
+	stream := fakeStream(t, FakeSTT{})
+	drain(t, stream)
+
+	if err := stream.Write([]byte{0, 0}); !errors.Is(err, ErrSTTClosed) {
+		t.Fatalf("write after close = %v, want %v", err, ErrSTTClosed)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("second close = %v", err)
+	}
+}
+
+func TestFakeSTTDefaults(t *testing.T) {
+	stream := fakeStream(t, FakeSTT{})
+
+	// по умолчанию фрагмент — 3 секунды, фраза — с языком
+	if err := stream.Write(make([]byte, 3*2000)); err != nil {
+		t.Fatal(err)
+	}
+	got := drain(t, stream)
+
+	last := got[len(got)-1]
+	if !last.Final || last.End != 3*time.Second || !strings.HasPrefix(last.Text, "[ru]") {
+		t.Fatalf("captions = %+v, want a 3s final in [ru]", got)
+	}
+}
+
+func TestFakeSTTInvalidFormat(t *testing.T) {
+	if _, err := (FakeSTT{}).Stream(context.Background(), AudioFormat{}, "ru"); err == nil {
+		t.Fatal("stream with zero sample rate: want error")
+	}
+}
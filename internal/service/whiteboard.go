@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"streaming/internal/db"
+)
+
+// WhiteboardTopic — topic data-сообщений доски
+const WhiteboardTopic = "whiteboard"
+
+// Логический размер доски: клиенты масштабируют координаты под свой экран
+const (
+	WhiteboardWidth  = 1600
+	WhiteboardHeight = 900
+)
+
+const (
+	// сжимаем журнал, когда после снимка накопилось столько операций
+	whiteboardCompactEvery = 200
+
+	whiteboardMaxElements = 5000
+	whiteboardMaxPoints   = 4000 // координат (x,y — две) в одном штрихе
+	whiteboardMaxText     = 1000
+	whiteboardMaxIDLen    = 64
+)
+
+// Операции доски
+const (
+	WhiteboardAdd    = "add"    // новый элемент (id занят => ничего)
+	WhiteboardUpdate = "update" // элемент целиком по id (перемещение, цвет...)
+	WhiteboardErase  = "erase"  // удалить элементы по ids
+	WhiteboardClear  = "clear"  // очистить доску
+)
+
+var (
+	hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+	// ErrInvalidWhiteboardOp — операция не прошла проверку
+	ErrInvalidWhiteboardOp = errors.New("invalid whiteboard operation")
+)
+
+// WhiteboardElement — штрих или фигура
+type WhiteboardElement struct {
+	ID     string    `json:"id"`
+	Kind   string    `json:"kind"`             // stroke | line | rect | ellipse | text
+	Points []float64 `json:"points,omitempty"` // x0,y0,x1,y1... (stroke, line)
+	X      float64   `json:"x,omitempty"`      // rect / ellipse / text
+	Y      float64   `json:"y,omitempty"`
+	W      float64   `json:"w,omitempty"`
+	H      float64   `json:"h,omitempty"`
+	Text   string    `json:"text,omitempty"`
+	Color  string    `json:"color"`          // #rrggbb
+	Fill   string    `json:"fill,omitempty"` // #rrggbb, пусто => без заливки
+	Width  float64   `json:"width"`          // толщина линии / размер шрифта для text
+	Author string    `json:"author,omitempty"`
+}
+
+// WhiteboardOp — операция, которую присылает клиент
+type WhiteboardOp struct {
+	Type    string             `json:"type"`
+	Element *WhiteboardElement `json:"element,omitempty"`
+	IDs     []string           `json:"ids,omitempty"`
+}
+
+// Validate — проверка формы операции (права проверяет handler)
+func (op *WhiteboardOp) Validate() error {
+	switch op.Type {
+	case WhiteboardAdd, WhiteboardUpdate:
+		if op.Element == nil {
+			return fmt.Errorf("%w: %s needs element", ErrInvalidWhiteboardOp, op.Type)
+		}
+		return op.Element.validate()
+	case WhiteboardErase:
+		if len(op.IDs) == 0 {
+			return fmt.Errorf("%w: erase needs ids", ErrInvalidWhiteboardOp)
+		}
+	case WhiteboardClear:
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidWhiteboardOp, op.Type)
+	}
+	return nil
+}
+
+func (e *WhiteboardElement) validate() error {
+	if e.ID == "" || len(e.ID) > whiteboardMaxIDLen {
+		return fmt.Errorf("%w: element id is required (max %d)", ErrInvalidWhiteboardOp, whiteboardMaxIDLen)
+	}
+	if !hexColor.MatchString(e.Color) || (e.Fill != "" && !hexColor.MatchString(e.Fill)) {
+		return fmt.Errorf("%w: colors must be #rrggbb", ErrInvalidWhiteboardOp)
+	}
+	if e.Width <= 0 || e.Width > 200 {
+		return fmt.Errorf("%w: width must be 0..200", ErrInvalidWhiteboardOp)
+	}
+
+	switch e.Kind {
+	case "stroke", "line":
+		n := len(e.Points)
+		if n < 4 || n%2 != 0 || n > whiteboardMaxPoints || (e.Kind == "line" && n != 4) {
+			return fmt.Errorf("%w: %s needs x,y point pairs", ErrInvalidWhiteboardOp, e.Kind)
+		}
+	case "rect", "ellipse":
+		if e.W <= 0 || e.H <= 0 {
+			return fmt.Errorf("%w: %s needs positive w and h", ErrInvalidWhiteboardOp, e.Kind)
+		}
+	case "text":
+		if e.Text == "" || len([]rune(e.Text)) > whiteboardMaxText {
+			return fmt.Errorf("%w: text is required (max %d)", ErrInvalidWhiteboardOp, whiteboardMaxText)
+		}
+	default:
+		return fmt.Errorf("%w: unknown element kind %q", ErrInvalidWhiteboardOp, e.Kind)
+	}
+	return nil
+}
+
+// WhiteboardDoc — состояние доски (порядок элементов = порядок отрисовки)
+type WhiteboardDoc struct {
+	Width    int                 `json:"width"`
+	Height   int                 `json:"height"`
+	Elements []WhiteboardElement `json:"elements"`
+}
+
+// NewWhiteboardDoc — пустая доска
+func NewWhiteboardDoc() *WhiteboardDoc {
+	return &WhiteboardDoc{Width: WhiteboardWidth, Height: WhiteboardHeight, Elements: []WhiteboardElement{}}
+}
+
+// Apply — применить операцию автора (повтор той же операции ничего не меняет)
+func (d *WhiteboardDoc) Apply(op WhiteboardOp, author string) {
+	switch op.Type {
+	case WhiteboardAdd, WhiteboardUpdate:
+		e := *op.Element
+		e.Author = author
+		for i := range d.Elements {
+			if d.Elements[i].ID != e.ID {
+				continue
+			}
+			// add с занятым id (повтор / чужой элемент) ничего не меняет;
+			// update сохраняет автора — того, кто элемент создал
+			if op.Type == WhiteboardUpdate {
+				e.Author = d.Elements[i].Author
+				d.Elements[i] = e
+			}
+			return
+		}
+		// update несуществующего (стёрли раньше) — игнорируем
+		if op.Type == WhiteboardAdd && len(d.Elements) < whiteboardMaxElements {
+			d.Elements = append(d.Elements, e)
+		}
+	case WhiteboardErase:
+		drop := make(map[string]bool, len(op.IDs))
+		for _, id := range op.IDs {
+			drop[id] = true
+		}
+		kept := d.Elements[:0]
+		for _, e := range d.Elements {
+			if !drop[e.ID] {
+				kept = append(kept, e)
+			}
+		}
+		d.Elements = kept
+	case WhiteboardClear:
+		d.Elements = []WhiteboardElement{}
+	}
+}
+
+// =======================
+// Load / compact
+// =======================
+
+// LoadWhiteboard — собрать доску урока: снимок + журнал.
+// Если журнал длинный — сохраняет новый снимок (compact), чтобы следующие загрузки были дешёвыми.
+func LoadWhiteboard(ctx context.Context, dbConn *sql.DB, lessonID int64, compact bool) (*WhiteboardDoc, int64, error) {
+	st, err := db.GetWhiteboard(ctx, dbConn, lessonID, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	doc := NewWhiteboardDoc()
+	if len(st.Snapshot) > 0 && string(st.Snapshot) != "{}" {
+		if err := json.Unmarshal(st.Snapshot, doc); err != nil {
+			return nil, 0, fmt.Errorf("whiteboard snapshot: %w", err)
+		}
+	}
+
+	seq := st.SnapshotSeq
+	for _, row := range st.Ops {
+		var op WhiteboardOp
+		// в журнал попадают только проверенные операции; битую пропускаем, а не теряем всю доску
+		if err := json.Unmarshal(row.Op, &op); err == nil && op.Validate() == nil {
+			doc.Apply(op, row.Author)
+		}
+		seq = row.Seq
+	}
+
+	if len(st.Ops) > 0 && (compact || len(st.Ops) >= whiteboardCompactEvery) {
+		raw, err := json.Marshal(doc)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := db.CompactWhiteboard(ctx, dbConn, lessonID, seq, raw); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return doc, seq, nil
+}
+
+// CompactWhiteboardIfDue — сжать журнал, если диапазон [first, last] перешёл границу
+// очередных whiteboardCompactEvery операций
+func CompactWhiteboardIfDue(ctx context.Context, dbConn *sql.DB, lessonID, first, last int64) error {
+	if first/whiteboardCompactEvery == last/whiteboardCompactEvery && first%whiteboardCompactEvery != 0 {
+		return nil
+	}
+	_, _, err := LoadWhiteboard(ctx, dbConn, lessonID, true)
+	return err
+}
+
+// =======================
+// Relay
+// =======================
+
+// WhiteboardMessage — data-сообщение: новые операции с их seq
+type WhiteboardMessage struct {
+	Type     string            `json:"type"` // ops
+	LessonID int64             `json:"lesson_id"`
+	Ops      []db.WhiteboardOp `json:"ops"`
+}
+
+// SendWhiteboard — разослать операции всем в комнате (автор пропускает свои по author)
+func (s *LiveKitService) SendWhiteboard(ctx context.Context, room string, m WhiteboardMessage) error {
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.SendData(ctx, room, WhiteboardTopic, raw)
+}
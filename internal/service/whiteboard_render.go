@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// =======================
+// SVG
+// =======================
+
+// RenderSVG — доска как SVG (векторная выгрузка, текст — шрифтом браузера)
+func (d *WhiteboardDoc) RenderSVG() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		d.Width, d.Height, d.Width, d.Height)
+	b.WriteString(`<rect width="100%" height="100%" fill="#ffffff"/>`)
+
+	for _, e := range d.Elements {
+		fill := e.Fill
+		if fill == "" {
+			fill = "none"
+		}
+		switch e.Kind {
+		case "stroke", "line":
+			fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%s" stroke-linecap="round" stroke-linejoin="round"/>`,
+				svgPoints(e.Points), e.Color, svgNum(e.Width))
+		case "rect":
+			fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s" stroke="%s" stroke-width="%s"/>`,
+				svgNum(e.X), svgNum(e.Y), svgNum(e.W), svgNum(e.H), fill, e.Color, svgNum(e.Width))
+		case "ellipse":
+			fmt.Fprintf(&b, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s" fill="%s" stroke="%s" stroke-width="%s"/>`,
+				svgNum(e.X+e.W/2), svgNum(e.Y+e.H/2), svgNum(e.W/2), svgNum(e.H/2), fill, e.Color, svgNum(e.Width))
+		case "text":
+			fmt.Fprintf(&b, `<text x="%s" y="%s" fill="%s" font-size="%s" font-family="sans-serif" dominant-baseline="hanging">`,
+				svgNum(e.X), svgNum(e.Y), e.Color, svgNum(e.Width))
+			_ = xml.EscapeText(&b, []byte(e.Text))
+			b.WriteString(`</text>`)
+		}
+	}
+
+	b.WriteString(`</svg>`)
+	return b.Bytes()
+}
+
+func svgPoints(pts []float64) string {
+	parts := make([]string, 0, len(pts)/2)
+	for i := 0; i+1 < len(pts); i += 2 {
+		parts = append(parts, svgNum(pts[i])+","+svgNum(pts[i+1]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func svgNum(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// =======================
+// PNG
+// =======================
+
+// RenderPNG — доска как PNG (растр для печати / вложений).
+// Текст — встроенным моноширинным шрифтом, без масштабирования.
+func (d *WhiteboardDoc) RenderPNG(w io.Writer) error {
+	img := image.NewRGBA(image.Rect(0, 0, d.Width, d.Height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	r := &rasterizer{dst: img}
+	for _, e := range d.Elements {
+		stroke := parseHexColor(e.Color)
+		switch e.Kind {
+		case "stroke", "line":
+			r.polyline(e.Points, e.Width, stroke, false)
+		case "rect":
+			pts := []float64{e.X, e.Y, e.X + e.W, e.Y, e.X + e.W, e.Y + e.H, e.X, e.Y + e.H}
+			if e.Fill != "" {
+				r.polygon(pts, parseHexColor(e.Fill))
+			}
+			r.polyline(pts, e.Width, stroke, true)
+		case "ellipse":
+			pts := ellipsePoints(e.X+e.W/2, e.Y+e.H/2, e.W/2, e.H/2, 72)
+			if e.Fill != "" {
+				r.polygon(pts, parseHexColor(e.Fill))
+			}
+			r.polyline(pts, e.Width, stroke, true)
+		case "text":
+			dr := font.Drawer{
+				Dst:  img,
+				Src:  image.NewUniform(stroke),
+				Face: basicfont.Face7x13,
+			}
+			for i, line := range strings.Split(e.Text, "\n") {
+				dr.Dot = fixed.P(int(e.X), int(e.Y)+basicfont.Face7x13.Ascent+i*basicfont.Face7x13.Height)
+				dr.DrawString(line)
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// rasterizer — заливка выпуклых фигур по одной (в рамке фигуры, не всего холста)
+type rasterizer struct {
+	dst *image.RGBA
+	z   vector.Rasterizer
+}
+
+// polygon — залить многоугольник (x,y пары)
+func (r *rasterizer) polygon(pts []float64, c color.Color) {
+	if len(pts) < 6 {
+		return
+	}
+
+	minX, minY, maxX, maxY := pts[0], pts[1], pts[0], pts[1]
+	for i := 0; i+1 < len(pts); i += 2 {
+		minX, maxX = math.Min(minX, pts[i]), math.Max(maxX, pts[i])
+		minY, maxY = math.Min(minY, pts[i+1]), math.Max(maxY, pts[i+1])
+	}
+	box := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX))+1, int(math.Ceil(maxY))+1).
+		Intersect(r.dst.Bounds())
+	if box.Empty() {
+		return
+	}
+
+	// координаты — относительно рамки
+	ox, oy := float32(box.Min.X), float32(box.Min.Y)
+	r.z.Reset(box.Dx(), box.Dy())
+	r.z.MoveTo(float32(pts[0])-ox, float32(pts[1])-oy)
+	for i := 2; i+1 < len(pts); i += 2 {
+		r.z.LineTo(float32(pts[i])-ox, float32(pts[i+1])-oy)
+	}
+	r.z.ClosePath()
+	r.z.Draw(r.dst, box, image.NewUniform(c), image.Point{})
+}
+
+// polyline — линия толщины width с круглыми концами/стыками
+func (r *rasterizer) polyline(pts []float64, width float64, c color.Color, closed bool) {
+	if closed && len(pts) >= 4 {
+		pts = append(pts[:len(pts):len(pts)], pts[0], pts[1])
+	}
+	for i := 0; i+3 < len(pts); i += 2 {
+		r.polygon(capsule(pts[i], pts[i+1], pts[i+2], pts[i+3], width/2), c)
+	}
+}
+
+// capsule — отрезок с полукругами на концах (выпуклый многоугольник)
+func capsule(x0, y0, x1, y1, rad float64) []float64 {
+	const half = 8 // точек на полукруг
+	theta := math.Atan2(y1-y0, x1-x0)
+
+	pts := make([]float64, 0, 4*(half+1))
+	for i := 0; i <= half; i++ {
+		a := theta - math.Pi/2 + math.Pi*float64(i)/half
+		pts = append(pts, x1+rad*math.Cos(a), y1+rad*math.Sin(a))
+	}
+	for i := 0; i <= half; i++ {
+		a := theta + math.Pi/2 + math.Pi*float64(i)/half
+		pts = append(pts, x0+rad*math.Cos(a), y0+rad*math.Sin(a))
+	}
+	return pts
+}
+
+func ellipsePoints(cx, cy, rx, ry float64, n int) []float64 {
+	pts := make([]float64, 0, 2*n)
+	for i := 0; i < n; i++ {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		pts = append(pts, cx+rx*math.Cos(a), cy+ry*math.Sin(a))
+	}
+	return pts
+}
+
+// parseHexColor — #rrggbb (проверен при записи); иначе чёрный
+func parseHexColor(s string) color.RGBA {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(s) != 7 {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
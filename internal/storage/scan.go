@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Результат проверки файла
+const (
+	ScanClean    = "clean"
+	ScanInfected = "infected"
+	ScanSkipped  = "skipped" // проверка выключена
+)
+
+// ScanResult — вердикт антивируса
+type ScanResult struct {
+	Status string
+	Threat string // имя сигнатуры, если infected
+}
+
+// Scanner — хук проверки загрузки перед сохранением.
+// Ошибка = проверить не удалось (файл не принимаем).
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (ScanResult, error)
+}
+
+// NoopScanner — проверка выключена
+type NoopScanner struct{}
+
+func (NoopScanner) Scan(context.Context, io.Reader) (ScanResult, error) {
+	return ScanResult{Status: ScanSkipped}, nil
+}
+
+// ClamAV — clamd по TCP (команда INSTREAM)
+type ClamAV struct {
+	Addr    string // host:port (обычно :3310)
+	Timeout time.Duration
+}
+
+// clamd по умолчанию режет поток на 25 MB (StreamMaxLength) — держите его >= UPLOAD_MAX_MB
+const clamChunk = 64 << 10
+
+func (c ClamAV) Scan(ctx context.Context, r io.Reader) (ScanResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("clamd dial: %w", err)
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return ScanResult{}, fmt.Errorf("clamd: %w", err)
+	}
+
+	buf := make([]byte, clamChunk)
+	var size [4]byte
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := conn.Write(size[:]); err != nil {
+				return ScanResult{}, fmt.Errorf("clamd: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return ScanResult{}, fmt.Errorf("clamd: %w", err)
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return ScanResult{}, rerr
+		}
+	}
+	// конец потока — чанк нулевой длины
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return ScanResult{}, fmt.Errorf("clamd: %w", err)
+	}
+
+	reply, err := io.ReadAll(io.LimitReader(conn, 4096))
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("clamd: %w", err)
+	}
+	return parseClamReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseClamReply — "stream: OK" | "stream: Eicar-Signature FOUND" | "... ERROR"
+func parseClamReply(reply string) (ScanResult, error) {
+	msg := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case msg == "OK":
+		return ScanResult{Status: ScanClean}, nil
+	case strings.HasSuffix(msg, " FOUND"):
+		return ScanResult{Status: ScanInfected, Threat: strings.TrimSuffix(msg, " FOUND")}, nil
+	default:
+		return ScanResult{}, fmt.Errorf("clamd: %s", reply)
+	}
+}
@@ -0,0 +1,57 @@
+// Package storage — хранилище файлов уроков (локальный диск / S3-совместимое)
+// и проверка загрузок (лимиты, MIME, антивирус).
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrNotFound — объекта нет в хранилище
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage — куда кладём файлы. key — путь вида "t1/l42/<uuid>" (без ведущего "/").
+type Storage interface {
+	// Put сохраняет ровно size байт из r
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Policy — что можно загружать (перечитывается по SIGHUP)
+type Policy struct {
+	MaxSize      int64
+	AllowedTypes []string // точные MIME ("application/pdf") или группы ("image/*")
+}
+
+// Allowed — разрешён ли MIME (без параметров: "text/plain; charset=utf-8" => "text/plain")
+func (p Policy) Allowed(mime string) bool {
+	mime, _, _ = strings.Cut(strings.ToLower(mime), ";")
+	mime = strings.TrimSpace(mime)
+
+	for _, t := range p.AllowedTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == mime {
+			return true
+		}
+		if group, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(mime, group+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// validKey — ключ без выхода за пределы хранилища ("..", абсолютные пути)
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "streaming"
+
+// Options — настройки экспорта (из config.Tracing)
+type Options struct {
+	Exporter     string  // none | stdout | otlp
+	OTLPEndpoint string  // host:port, пусто => OTEL_EXPORTER_OTLP_ENDPOINT / localhost:4318
+	OTLPInsecure bool    // http вместо https для OTLP
+	SampleRatio  float64 // 0..1
+	ServiceName  string
+}
+
+// Setup настраивает глобальный TracerProvider.
+// Возвращает shutdown, который нужно вызвать при остановке (сбрасывает буфер спанов).
+func Setup(ctx context.Context, o Options) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	var exp sdktrace.SpanExporter
+	var err error
+
+	switch strings.ToLower(strings.TrimSpace(o.Exporter)) {
+	case "", "none":
+		// спаны всё равно создаются (trace_id в ответах), но никуда не уходят
+	case "stdout":
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		opts := []otlptracehttp.Option{}
+		if o.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(o.OTLPEndpoint))
+		}
+		if o.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return noop, fmt.Errorf("unknown tracing exporter %q (use none, stdout or otlp)", o.Exporter)
+	}
+	if err != nil {
+		return noop, err
+	}
+
+	name := o.ServiceName
+	if name == "" {
+		name = instrumentationName
+	}
+
+	tpOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(name),
+		)),
+	}
+	if exp != nil {
+		tpOpts = append(tpOpts, sdktrace.WithBatcher(exp))
+	}
+
+	tp := sdktrace.NewTracerProvider(tpOpts...)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return tp.Shutdown, nil
+}
+
+// Start открывает span от глобального провайдера.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Fail помечает span ошибкой и возвращает ту же ошибку:
+//
+//	return 0, tracing.Fail(span, err)
+func Fail(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// TraceID — id трассы из контекста ("" если трассировки нет)
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
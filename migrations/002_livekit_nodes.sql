@@ -0,0 +1,6 @@
+-- LiveKit узел, к которому "приколот" урок (комната живёт на одном сервере)
+ALTER TABLE lessons
+    ADD COLUMN livekit_node TEXT NOT NULL DEFAULT 'default';
+
+CREATE INDEX idx_lessons_active_room ON lessons(room_name) WHERE ended_at IS NULL;
+CREATE INDEX idx_lessons_active_node ON lessons(livekit_node) WHERE ended_at IS NULL;
@@ -0,0 +1,29 @@
+-- Школы (tenants). Ключи хранятся только как sha256 hex.
+CREATE TABLE tenants (
+    id               BIGSERIAL PRIMARY KEY,
+    slug             TEXT NOT NULL UNIQUE CHECK (slug ~ '^[a-z0-9-]{2,32}$'),
+    name             TEXT NOT NULL,
+    api_key_hash     TEXT UNIQUE,
+    teacher_key_hash TEXT,
+    created_at       TIMESTAMPTZ DEFAULT now()
+);
+
+-- tenant по умолчанию: существующие уроки + глобальный API_KEY_SECRET
+INSERT INTO tenants (id, slug, name) VALUES (1, 'default', 'Default');
+SELECT setval('tenants_id_seq', 1);
+
+ALTER TABLE lessons
+    ADD COLUMN tenant_id BIGINT NOT NULL DEFAULT 1 REFERENCES tenants(id);
+
+DROP INDEX IF EXISTS idx_lessons_active_room;
+CREATE INDEX idx_lessons_active_room ON lessons(tenant_id, room_name) WHERE ended_at IS NULL;
+CREATE INDEX idx_lessons_tenant ON lessons(tenant_id, started_at);
+
+-- администраторы школы (супер-админ — ADMIN_USERNAME/ADMIN_PASSWORD из конфига)
+CREATE TABLE tenant_admins (
+    id            BIGSERIAL PRIMARY KEY,
+    tenant_id     BIGINT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    username      TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    created_at    TIMESTAMPTZ DEFAULT now()
+);
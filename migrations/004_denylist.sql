@@ -0,0 +1,14 @@
+-- Кого нельзя пускать обратно: kick — на один урок, ban — на комнату.
+-- Проверяется при join и при продлении токена (/livekit/refresh).
+CREATE TABLE participant_denylist (
+    id               BIGSERIAL PRIMARY KEY,
+    tenant_id        BIGINT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    room_name        TEXT NOT NULL,
+    participant_name TEXT NOT NULL,
+    lesson_id        BIGINT REFERENCES lessons(id) ON DELETE CASCADE, -- NULL => бан на комнату
+    reason           TEXT NOT NULL DEFAULT '',
+    created_by       TEXT NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_denylist_lookup ON participant_denylist(tenant_id, room_name, participant_name);
@@ -0,0 +1,40 @@
+-- Классы и списки учеников. Комната, привязанная к классу, пускает только
+-- учеников из списка (+ admin override, + guest_allowance гостей на урок).
+CREATE TABLE classes (
+    id              BIGSERIAL PRIMARY KEY,
+    tenant_id       BIGINT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    guest_allowance INTEGER NOT NULL DEFAULT 0 CHECK (guest_allowance >= 0),
+    created_at      TIMESTAMPTZ DEFAULT now(),
+    UNIQUE (tenant_id, name)
+);
+
+-- student_name — то же имя, с которым ученик входит в комнату (identity)
+CREATE TABLE enrollments (
+    id           BIGSERIAL PRIMARY KEY,
+    class_id     BIGINT NOT NULL REFERENCES classes(id) ON DELETE CASCADE,
+    student_name TEXT NOT NULL,
+    user_id      TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ DEFAULT now(),
+    UNIQUE (class_id, student_name)
+);
+
+-- комната школы => класс (одна комната — один класс)
+CREATE TABLE class_rooms (
+    tenant_id  BIGINT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    room_name  TEXT NOT NULL,
+    class_id   BIGINT NOT NULL REFERENCES classes(id) ON DELETE CASCADE,
+    PRIMARY KEY (tenant_id, room_name)
+);
+
+-- разовый пропуск от администратора (не из списка, но пустить)
+CREATE TABLE roster_overrides (
+    id           BIGSERIAL PRIMARY KEY,
+    class_id     BIGINT NOT NULL REFERENCES classes(id) ON DELETE CASCADE,
+    student_name TEXT NOT NULL,
+    created_by   TEXT NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    created_at   TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_roster_overrides_lookup ON roster_overrides(class_id, student_name, expires_at);
@@ -0,0 +1,6 @@
+-- расписание урока (учитель передаёт при старте) — для аналитики опозданий
+ALTER TABLE lessons
+    ADD COLUMN scheduled_start TIMESTAMPTZ,
+    ADD COLUMN scheduled_end   TIMESTAMPTZ;
+
+CREATE INDEX idx_lp_lesson_times ON lesson_participants(lesson_id, joined_at);
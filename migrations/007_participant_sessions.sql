@@ -0,0 +1,20 @@
+-- Сегменты присутствия: одна строка на каждый вход/выход (переподключения —
+-- отдельные сегменты). lesson_participants хранит только последнее состояние.
+CREATE TABLE participant_sessions (
+    id               BIGSERIAL PRIMARY KEY,
+    lesson_id        BIGINT NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    participant_name TEXT NOT NULL,
+    role             TEXT NOT NULL CHECK (role IN ('teacher','student')),
+    joined_at        TIMESTAMPTZ NOT NULL,
+    left_at          TIMESTAMPTZ
+);
+
+CREATE INDEX idx_ps_lesson ON participant_sessions(lesson_id, participant_name);
+CREATE INDEX idx_ps_participant ON participant_sessions(participant_name, joined_at);
+CREATE INDEX idx_ps_open ON participant_sessions(lesson_id) WHERE left_at IS NULL;
+
+-- история до миграции: по сегменту на участника
+INSERT INTO participant_sessions (lesson_id, participant_name, role, joined_at, left_at)
+SELECT lp.lesson_id, lp.participant_name, lp.role, lp.joined_at, COALESCE(lp.left_at, l.ended_at)
+FROM lesson_participants lp
+JOIN lessons l ON l.id = lp.lesson_id;
@@ -0,0 +1,45 @@
+-- Биллинг: закрытый расчётный период (месяц) школы, ведомости по учителям
+-- и строки по урокам. Правила (ставки, округление, минимум) сохраняются
+-- вместе с периодом — пересчёт конфига не меняет закрытые периоды.
+CREATE TABLE billing_periods (
+    id            BIGSERIAL PRIMARY KEY,
+    tenant_id     BIGINT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    period_start  DATE NOT NULL,             -- первое число месяца (в поясе tz)
+    tz            TEXT NOT NULL DEFAULT 'UTC',
+    starts_at     TIMESTAMPTZ NOT NULL,
+    ends_at       TIMESTAMPTZ NOT NULL,      -- не включительно
+    currency      TEXT NOT NULL,
+    rules         JSONB NOT NULL,
+    closed_by     TEXT NOT NULL DEFAULT '',
+    closed_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (tenant_id, period_start)
+);
+
+CREATE TABLE billing_statements (
+    id                  BIGSERIAL PRIMARY KEY,
+    period_id           BIGINT NOT NULL REFERENCES billing_periods(id) ON DELETE CASCADE,
+    teacher_name        TEXT NOT NULL,
+    lessons             INTEGER NOT NULL,
+    teacher_minutes     BIGINT NOT NULL,     -- фактически (после округления)
+    billable_minutes    BIGINT NOT NULL,     -- с учётом минимума
+    participant_minutes BIGINT NOT NULL,
+    teacher_amount      BIGINT NOT NULL,     -- минорные единицы валюты
+    participant_cost    BIGINT NOT NULL,
+    UNIQUE (period_id, teacher_name)
+);
+
+CREATE TABLE billing_lines (
+    id                  BIGSERIAL PRIMARY KEY,
+    statement_id        BIGINT NOT NULL REFERENCES billing_statements(id) ON DELETE CASCADE,
+    lesson_id           BIGINT NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    room_name           TEXT NOT NULL,
+    started_at          TIMESTAMPTZ NOT NULL,
+    ended_at            TIMESTAMPTZ NOT NULL,
+    teacher_minutes     BIGINT NOT NULL,
+    billable_minutes    BIGINT NOT NULL,
+    participant_minutes BIGINT NOT NULL,
+    participants        INTEGER NOT NULL,
+    amount              BIGINT NOT NULL
+);
+
+CREATE INDEX idx_billing_lines_statement ON billing_lines(statement_id);
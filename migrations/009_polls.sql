@@ -0,0 +1,26 @@
+-- Опросы / мини-тесты во время урока. Варианты — массив строк, ответы — индексы.
+-- correct пусто => опрос без правильного ответа (не оценивается).
+CREATE TABLE polls (
+    id          BIGSERIAL PRIMARY KEY,
+    lesson_id   BIGINT NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    question    TEXT NOT NULL,
+    kind        TEXT NOT NULL CHECK (kind IN ('single','multiple')),
+    options     TEXT[] NOT NULL,
+    correct     INTEGER[] NOT NULL DEFAULT '{}',
+    created_by  TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    closes_at   TIMESTAMPTZ,                 -- таймер; NULL => до закрытия учителем
+    closed_at   TIMESTAMPTZ
+);
+
+CREATE INDEX idx_polls_lesson ON polls(lesson_id, created_at);
+
+-- один ответ ученика на опрос (до закрытия можно передумать)
+CREATE TABLE poll_answers (
+    poll_id          BIGINT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    participant_name TEXT NOT NULL,
+    choices          INTEGER[] NOT NULL,
+    is_correct       BOOLEAN,                -- NULL => опрос не оценивается
+    answered_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (poll_id, participant_name)
+);
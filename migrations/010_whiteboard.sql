@@ -0,0 +1,19 @@
+-- Доска урока: журнал операций (seq по порядку) + сжатый снимок.
+-- Снимок = документ после применения операций до snapshot_seq включительно;
+-- операции до snapshot_seq удаляются при сжатии.
+CREATE TABLE whiteboards (
+    lesson_id     BIGINT PRIMARY KEY REFERENCES lessons(id) ON DELETE CASCADE,
+    head_seq      BIGINT NOT NULL DEFAULT 0,   -- последний выданный seq
+    snapshot_seq  BIGINT NOT NULL DEFAULT 0,
+    snapshot      JSONB NOT NULL DEFAULT '{}',
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE whiteboard_ops (
+    lesson_id   BIGINT NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    seq         BIGINT NOT NULL,
+    author      TEXT NOT NULL,
+    op          JSONB NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (lesson_id, seq)
+);
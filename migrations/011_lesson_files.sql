@@ -0,0 +1,19 @@
+-- Файлы урока: материалы учителя (material) и домашние задания учеников (homework).
+-- Содержимое лежит в хранилище (STORAGE_BACKEND) по storage_key; здесь — метаданные.
+CREATE TABLE lesson_files (
+    id             BIGSERIAL PRIMARY KEY,
+    lesson_id      BIGINT NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    uploader       TEXT NOT NULL,
+    uploader_role  TEXT NOT NULL CHECK (uploader_role IN ('teacher','student')),
+    kind           TEXT NOT NULL CHECK (kind IN ('material','homework')),
+    filename       TEXT NOT NULL,
+    content_type   TEXT NOT NULL,
+    size_bytes     BIGINT NOT NULL,
+    sha256         TEXT NOT NULL,
+    storage_key    TEXT NOT NULL UNIQUE,
+    scan_status    TEXT NOT NULL CHECK (scan_status IN ('clean','skipped')),
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    deleted_at     TIMESTAMPTZ                  -- удалён (объект в хранилище стирается следом)
+);
+
+CREATE INDEX idx_lesson_files_lesson ON lesson_files(lesson_id, created_at) WHERE deleted_at IS NULL;
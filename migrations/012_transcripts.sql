@@ -0,0 +1,13 @@
+-- Расшифровка урока: финальные фрагменты живых субтитров (речь учителя).
+CREATE TABLE transcript_segments (
+    id          BIGSERIAL PRIMARY KEY,
+    lesson_id   BIGINT NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    speaker     TEXT NOT NULL,
+    language    TEXT NOT NULL,
+    started_at  TIMESTAMPTZ NOT NULL,
+    ended_at    TIMESTAMPTZ NOT NULL,
+    text        TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_transcript_segments_lesson ON transcript_segments(lesson_id, started_at);
@@ -0,0 +1,57 @@
+-- Уведомления ученикам: настройки, расписание (напоминания) и очередь отправки.
+
+-- настройки ученика школы (student_name — identity, как в enrollments)
+CREATE TABLE notification_prefs (
+    tenant_id          BIGINT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    student_name       TEXT NOT NULL,
+    email              TEXT NOT NULL DEFAULT '',
+    telegram_chat_id   TEXT NOT NULL DEFAULT '',
+    push_subscription  JSONB,                               -- Web Push: {endpoint, keys: {p256dh, auth}}
+    channels           TEXT[] NOT NULL DEFAULT '{}',        -- включённые: email | telegram | webpush
+    lesson_started     BOOLEAN NOT NULL DEFAULT true,
+    reminders          BOOLEAN NOT NULL DEFAULT true,
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (tenant_id, student_name)
+);
+
+-- запланированные уроки: за NOTIFY_REMINDER_BEFORE до начала — напоминание
+CREATE TABLE scheduled_lessons (
+    id            BIGSERIAL PRIMARY KEY,
+    tenant_id     BIGINT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    room_name     TEXT NOT NULL,
+    title         TEXT NOT NULL DEFAULT '',
+    teacher_name  TEXT NOT NULL DEFAULT '',
+    starts_at     TIMESTAMPTZ NOT NULL,
+    ends_at       TIMESTAMPTZ,
+    reminded_at   TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_scheduled_lessons_due ON scheduled_lessons(starts_at) WHERE reminded_at IS NULL;
+CREATE INDEX idx_scheduled_lessons_room ON scheduled_lessons(tenant_id, room_name, starts_at);
+
+-- очередь: одна строка = одно сообщение в один канал
+CREATE TABLE notifications (
+    id               BIGSERIAL PRIMARY KEY,
+    tenant_id        BIGINT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    recipient        TEXT NOT NULL,
+    channel          TEXT NOT NULL CHECK (channel IN ('email','telegram','webpush')),
+    address          TEXT NOT NULL,                         -- email / chat id / push subscription JSON
+    event            TEXT NOT NULL,                         -- lesson_started | lesson_reminder
+    room_name        TEXT NOT NULL DEFAULT '',
+    subject          TEXT NOT NULL,
+    body             TEXT NOT NULL,
+    link             TEXT NOT NULL DEFAULT '',
+    dedup_key        TEXT NOT NULL UNIQUE,                  -- повторный триггер не шлёт дважды
+    status           TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending','sent','failed')),
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error       TEXT NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at          TIMESTAMPTZ
+);
+
+CREATE INDEX idx_notifications_due ON notifications(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_notifications_tenant ON notifications(tenant_id, created_at);
+-- учитель переподключился (новый урок в той же комнате) — ученикам второй раз не пишем
+CREATE INDEX idx_notifications_recent ON notifications(tenant_id, room_name, recipient, created_at);
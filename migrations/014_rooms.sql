@@ -0,0 +1,24 @@
+-- Комнаты как сущности: раньше комната была просто строкой room_name из запроса,
+-- и опечатка в ссылке создавала "фантомный" класс.
+-- room_name в lessons / class_rooms / scheduled_lessons = rooms.slug.
+
+CREATE TABLE rooms (
+    id                BIGSERIAL PRIMARY KEY,
+    tenant_id         BIGINT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    slug              TEXT NOT NULL,
+    title             TEXT NOT NULL DEFAULT '',
+    owner_teacher     TEXT NOT NULL DEFAULT '',
+    max_participants  INTEGER NOT NULL DEFAULT 0 CHECK (max_participants >= 0),  -- 0 => без ограничения
+    waiting_room      BOOLEAN NOT NULL DEFAULT false,
+    recording         TEXT NOT NULL DEFAULT 'off' CHECK (recording IN ('off','allowed','always')),
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (tenant_id, slug)
+);
+
+-- уже использованные комнаты становятся настоящими (иначе с ROOMS_ADHOC=false в них не войти)
+INSERT INTO rooms (tenant_id, slug)
+SELECT DISTINCT tenant_id, room_name FROM lessons
+UNION
+SELECT DISTINCT tenant_id, room_name FROM class_rooms
+ON CONFLICT (tenant_id, slug) DO NOTHING;
@@ -0,0 +1,22 @@
+-- Места в комнате по ролям: max_participants — всего, max_students / max_teachers — по ролям
+-- (0 => без ограничения). Считаются живые участники (left_at IS NULL) открытых уроков комнаты.
+
+ALTER TABLE rooms
+    ADD COLUMN max_students   INTEGER NOT NULL DEFAULT 0 CHECK (max_students >= 0),
+    ADD COLUMN max_teachers   INTEGER NOT NULL DEFAULT 0 CHECK (max_teachers >= 0),
+    ADD COLUMN overflow_queue BOOLEAN NOT NULL DEFAULT false;  -- мест нет => очередь, а не отказ
+
+-- Очередь на вход в заполненную комнату: порядок по id, клиент держит место
+-- повторными join (seen_at); не повторял дольше минуты — выбывает.
+CREATE TABLE room_queue (
+    id                BIGSERIAL PRIMARY KEY,
+    tenant_id         BIGINT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    room_name         TEXT NOT NULL,
+    participant_name  TEXT NOT NULL,
+    role              TEXT NOT NULL CHECK (role IN ('teacher','student')),
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    seen_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (tenant_id, room_name, participant_name)
+);
+
+CREATE INDEX idx_room_queue_room ON room_queue(tenant_id, room_name, role, id);
@@ -0,0 +1,4 @@
+-- Строки закрытого периода — неизменяемая запись: удаление урока не должно
+-- стирать уже выставленные суммы (раньше ON DELETE CASCADE). lesson_id остаётся
+-- как есть, для сверки, без внешнего ключа.
+ALTER TABLE billing_lines DROP CONSTRAINT IF EXISTS billing_lines_lesson_id_fkey;
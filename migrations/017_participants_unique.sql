@@ -0,0 +1,22 @@
+-- Участник урока — одна строка: JoinParticipant / StartLesson делают upsert
+-- ON CONFLICT (lesson_id, participant_name), а уникального ключа в 001 не было.
+-- Дубли от прежних повторных входов сливаем в последнюю запись (последнее состояние).
+DELETE FROM lesson_participants lp
+USING lesson_participants newer
+WHERE newer.lesson_id = lp.lesson_id
+  AND newer.participant_name = lp.participant_name
+  AND newer.id > lp.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_lp_lesson_participant
+    ON lesson_participants(lesson_id, participant_name);
+
+-- журнал событий урока (db.LogEvent); раньше таблица заводилась вручную
+CREATE TABLE IF NOT EXISTS lesson_events (
+    id           BIGSERIAL PRIMARY KEY,
+    lesson_id    BIGINT NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    event_type   TEXT NOT NULL,                 -- lesson_started | lesson_ended | join | leave | kick | ban
+    actor_name   TEXT NOT NULL DEFAULT '',
+    occurred_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_lesson_events_lesson ON lesson_events(lesson_id, occurred_at);
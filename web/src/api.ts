@@ -1,3 +1,20 @@
+import type { ApiErrorBody, ErrorCode } from "./errorCodes";
+
+// ошибка API: code — из каталога (errorCodes.ts), message — уже на языке браузера
+export class ApiError extends Error {
+  readonly code: ErrorCode | undefined;
+  readonly status: number;
+  readonly details: Record<string, unknown> | undefined;
+
+  constructor(status: number, body?: Partial<ApiErrorBody>) {
+    super(body?.message || `API request failed (${status})`);
+    this.name = "ApiError";
+    this.status = status;
+    this.code = body?.code;
+    this.details = body?.details;
+  }
+}
+
 export type JoinResponse = {
   room: string;
   name: string;
@@ -33,8 +50,7 @@ export async function fetchJoin(
   const data = await res.json().catch(() => ({}));
 
   if (!res.ok) {
-    const msg = (data as any)?.error || `Join API failed (${res.status})`;
-    throw new Error(msg);
+    throw new ApiError(res.status, (data as { error?: ApiErrorBody })?.error);
   }
 
   return data as JoinResponse;
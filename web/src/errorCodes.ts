@@ -0,0 +1,391 @@
+// Code generated by `server error-codes`; DO NOT EDIT.
+// Источник: internal/apierr/catalog.go
+
+export const ErrorCodes = {
+  ADMIN_CREATE_FAILED: 400,
+  BILLING_OPEN_LESSONS: 409,
+  BILLING_PERIOD_CLOSED: 409,
+  BILLING_PERIOD_NOT_FOUND: 404,
+  BILLING_PERIOD_NOT_OVER: 400,
+  CAPTIONS_DISABLED: 503,
+  CAPTIONS_NOT_RUNNING: 404,
+  CAPTIONS_RUNNING: 409,
+  CHANNEL_DISABLED: 400,
+  CLASS_CREATE_FAILED: 400,
+  CLASS_NOT_FOUND: 404,
+  DB_ERROR: 500,
+  FILE_FORBIDDEN: 403,
+  FILE_INFECTED: 422,
+  FILE_NOT_FOUND: 404,
+  FILE_TOO_LARGE: 413,
+  FILE_TYPE_NOT_ALLOWED: 415,
+  FORBIDDEN: 403,
+  HOST_AUTH_REQUIRED: 401,
+  INVALID_ANSWER: 400,
+  INVALID_CSV: 400,
+  INVALID_DATE: 400,
+  INVALID_JSON: 400,
+  INVALID_POLL: 400,
+  INVALID_REQUEST: 400,
+  INVALID_TIMEZONE: 400,
+  INVALID_TOKEN: 401,
+  INVALID_WHITEBOARD_OP: 400,
+  KEY_GENERATION_FAILED: 500,
+  LESSON_ENDED: 403,
+  LESSON_NOT_FOUND: 404,
+  LESSON_START_FAILED: 500,
+  LIVEKIT_NODE_UNAVAILABLE: 503,
+  LIVEKIT_NO_CAPACITY: 503,
+  LIVEKIT_TOKEN_ERROR: 500,
+  LIVEKIT_UNAVAILABLE: 503,
+  METADATA_FORBIDDEN: 403,
+  NOTIFICATION_NOT_FOUND: 404,
+  NOT_A_PARTICIPANT: 403,
+  NOT_ON_ROSTER: 403,
+  NO_ACTIVE_LESSON: 400,
+  NO_AUDIO_TRACK: 409,
+  PARTICIPANT_DENIED: 403,
+  PARTICIPANT_NOT_CONNECTED: 404,
+  POLL_CLOSED: 409,
+  POLL_NOT_FOUND: 404,
+  RATE_LIMITED: 429,
+  RENDER_FAILED: 500,
+  ROOM_EXISTS: 409,
+  ROOM_FULL: 409,
+  ROOM_NOT_FOUND: 404,
+  SCAN_UNAVAILABLE: 503,
+  SCHEDULED_LESSON_NOT_FOUND: 404,
+  STORAGE_UNAVAILABLE: 503,
+  TEACHER_KEY_REQUIRED: 403,
+  TEACHER_ONLY: 403,
+  TENANT_CREATE_FAILED: 400,
+  TENANT_NOT_FOUND: 400,
+  UNAUTHORIZED: 401,
+  UPLOAD_FAILED: 500,
+  WEBPUSH_DISABLED: 404,
+  WHITEBOARD_FORBIDDEN: 403,
+} as const;
+
+export type ErrorCode = keyof typeof ErrorCodes;
+export type ErrorLang = "en" | "ru" | "tk";
+
+export const ErrorMessages: Record<ErrorCode, Record<ErrorLang, string>> = {
+  ADMIN_CREATE_FAILED: {
+    en: "Admin could not be created (username taken?)",
+    ru: "Не удалось создать администратора (логин уже занят?)",
+    tk: "Administratory döredip bolmady (login eýýäm bar?)",
+  },
+  BILLING_OPEN_LESSONS: {
+    en: "Some lessons of this month are still open; end them before closing the period",
+    ru: "Часть уроков месяца ещё не закрыта; завершите их до закрытия периода",
+    tk: "Aýyň käbir sapaklary entek açyk; döwri ýapmazdan öň olary tamamlaň",
+  },
+  BILLING_PERIOD_CLOSED: {
+    en: "This month is already closed",
+    ru: "Этот месяц уже закрыт",
+    tk: "Bu aý eýýäm ýapyldy",
+  },
+  BILLING_PERIOD_NOT_FOUND: {
+    en: "Billing period not found",
+    ru: "Расчётный период не найден",
+    tk: "Hasaplaşyk döwri tapylmady",
+  },
+  BILLING_PERIOD_NOT_OVER: {
+    en: "The month is not over yet",
+    ru: "Месяц ещё не закончился",
+    tk: "Aý entek gutarmady",
+  },
+  CAPTIONS_DISABLED: {
+    en: "Captions are not available on this server",
+    ru: "Субтитры на этом сервере не настроены",
+    tk: "Bu serwerde subtitrler sazlanmady",
+  },
+  CAPTIONS_NOT_RUNNING: {
+    en: "Captions are not on for this lesson",
+    ru: "Субтитры для этого урока не включены",
+    tk: "Bu sapak üçin subtitrler açyk däl",
+  },
+  CAPTIONS_RUNNING: {
+    en: "Captions are already on for this lesson",
+    ru: "Субтитры для этого урока уже включены",
+    tk: "Bu sapak üçin subtitrler eýýäm açyk",
+  },
+  CHANNEL_DISABLED: {
+    en: "This notification channel is not available on this server",
+    ru: "Этот канал уведомлений на сервере не настроен",
+    tk: "Bu habarnama kanaly serwerde sazlanmady",
+  },
+  CLASS_CREATE_FAILED: {
+    en: "Class could not be created (name taken?)",
+    ru: "Не удалось создать класс (название уже занято?)",
+    tk: "Synpy döredip bolmady (ady eýýäm bar?)",
+  },
+  CLASS_NOT_FOUND: {
+    en: "Class not found",
+    ru: "Класс не найден",
+    tk: "Synp tapylmady",
+  },
+  DB_ERROR: {
+    en: "Internal error, try again later",
+    ru: "Внутренняя ошибка, попробуйте позже",
+    tk: "Içki ýalňyşlyk, soňrak synanyşyň",
+  },
+  FILE_FORBIDDEN: {
+    en: "Only the author or the teacher can delete this file",
+    ru: "Удалить файл может только автор или учитель",
+    tk: "Faýly diňe awtor ýa-da mugallym pozup biler",
+  },
+  FILE_INFECTED: {
+    en: "The file did not pass the virus check",
+    ru: "Файл не прошёл проверку на вирусы",
+    tk: "Faýl wirus barlagyndan geçmedi",
+  },
+  FILE_NOT_FOUND: {
+    en: "File not found",
+    ru: "Файл не найден",
+    tk: "Faýl tapylmady",
+  },
+  FILE_TOO_LARGE: {
+    en: "The file is too large",
+    ru: "Файл слишком большой",
+    tk: "Faýl gaty uly",
+  },
+  FILE_TYPE_NOT_ALLOWED: {
+    en: "Files of this type are not allowed",
+    ru: "Файлы такого типа загружать нельзя",
+    tk: "Bu görnüşdäki faýllary ýüklemek bolmaýar",
+  },
+  FORBIDDEN: {
+    en: "Access denied",
+    ru: "Доступ запрещён",
+    tk: "Giriş gadagan",
+  },
+  HOST_AUTH_REQUIRED: {
+    en: "Site login required",
+    ru: "Требуется вход на сайт",
+    tk: "Saýta girmek gerek",
+  },
+  INVALID_ANSWER: {
+    en: "Invalid answer",
+    ru: "Некорректный ответ",
+    tk: "Jogap nädogry",
+  },
+  INVALID_CSV: {
+    en: "The CSV file has errors",
+    ru: "В CSV-файле есть ошибки",
+    tk: "CSV faýlynda ýalňyşlyklar bar",
+  },
+  INVALID_DATE: {
+    en: "Invalid date or date range",
+    ru: "Некорректная дата или период",
+    tk: "Sene ýa-da döwür nädogry",
+  },
+  INVALID_JSON: {
+    en: "Invalid JSON body",
+    ru: "Некорректный JSON в запросе",
+    tk: "Haýyşdaky JSON nädogry",
+  },
+  INVALID_POLL: {
+    en: "Invalid poll",
+    ru: "Некорректный опрос",
+    tk: "Sorag-jogap nädogry",
+  },
+  INVALID_REQUEST: {
+    en: "Invalid request parameters",
+    ru: "Некорректные параметры запроса",
+    tk: "Haýyşyň parametrleri nädogry",
+  },
+  INVALID_TIMEZONE: {
+    en: "Unknown time zone",
+    ru: "Неизвестный часовой пояс",
+    tk: "Näbelli sagat guşaklygy",
+  },
+  INVALID_TOKEN: {
+    en: "Token is invalid or expired, join again",
+    ru: "Токен недействителен или истёк, войдите заново",
+    tk: "Token nädogry ýa-da möhleti geçdi, täzeden giriň",
+  },
+  INVALID_WHITEBOARD_OP: {
+    en: "Invalid whiteboard operation",
+    ru: "Некорректное действие на доске",
+    tk: "Tagtadaky hereket nädogry",
+  },
+  KEY_GENERATION_FAILED: {
+    en: "Failed to generate a key",
+    ru: "Не удалось создать ключ",
+    tk: "Açary döredip bolmady",
+  },
+  LESSON_ENDED: {
+    en: "The lesson has ended",
+    ru: "Урок закончился",
+    tk: "Sapak tamamlandy",
+  },
+  LESSON_NOT_FOUND: {
+    en: "Lesson not found",
+    ru: "Урок не найден",
+    tk: "Sapak tapylmady",
+  },
+  LESSON_START_FAILED: {
+    en: "Failed to start the lesson, try again",
+    ru: "Не удалось начать урок, попробуйте ещё раз",
+    tk: "Sapagy başlap bolmady, täzeden synanyşyň",
+  },
+  LIVEKIT_NODE_UNAVAILABLE: {
+    en: "The video server for this lesson is unavailable",
+    ru: "Видеосервер этого урока недоступен",
+    tk: "Bu sapagyň wideo serweri elýeterli däl",
+  },
+  LIVEKIT_NO_CAPACITY: {
+    en: "All video servers are full, try again later",
+    ru: "Все видеосерверы заняты, попробуйте позже",
+    tk: "Ähli wideo serwerler doly, soňrak synanyşyň",
+  },
+  LIVEKIT_TOKEN_ERROR: {
+    en: "Failed to issue a video token, try again",
+    ru: "Не удалось выдать токен для видео, попробуйте ещё раз",
+    tk: "Wideo tokenini berip bolmady, täzeden synanyşyň",
+  },
+  LIVEKIT_UNAVAILABLE: {
+    en: "The video server did not respond, try again later",
+    ru: "Видеосервер не ответил, попробуйте позже",
+    tk: "Wideo serwer jogap bermedi, soňrak synanyşyň",
+  },
+  METADATA_FORBIDDEN: {
+    en: "Participants can only update their own data",
+    ru: "Участник может менять только свои данные",
+    tk: "Gatnaşyjy diňe öz maglumatlaryny üýtgedip biler",
+  },
+  NOTIFICATION_NOT_FOUND: {
+    en: "Notification not found or already sent",
+    ru: "Уведомление не найдено или уже отправлено",
+    tk: "Habarnama tapylmady ýa-da eýýäm iberildi",
+  },
+  NOT_A_PARTICIPANT: {
+    en: "You are not in this lesson, join again",
+    ru: "Вы не участник этого урока, войдите заново",
+    tk: "Siz bu sapagyň gatnaşyjysy däl, täzeden giriň",
+  },
+  NOT_ON_ROSTER: {
+    en: "You are not on the class list for this room",
+    ru: "Вас нет в списке класса этой комнаты",
+    tk: "Siz bu otagyň synp sanawynda ýok",
+  },
+  NO_ACTIVE_LESSON: {
+    en: "The lesson has not started yet",
+    ru: "Урок ещё не начался",
+    tk: "Sapak entek başlamady",
+  },
+  NO_AUDIO_TRACK: {
+    en: "The teacher's microphone is not on",
+    ru: "Микрофон учителя не включён",
+    tk: "Mugallymyň mikrofony açyk däl",
+  },
+  PARTICIPANT_DENIED: {
+    en: "You were removed from this room",
+    ru: "Вас удалили из этой комнаты",
+    tk: "Siz bu otagdan aýryldyňyz",
+  },
+  PARTICIPANT_NOT_CONNECTED: {
+    en: "The participant is not in the room",
+    ru: "Участника нет в комнате",
+    tk: "Gatnaşyjy otagda ýok",
+  },
+  POLL_CLOSED: {
+    en: "The poll is closed",
+    ru: "Опрос уже закрыт",
+    tk: "Sorag-jogap ýapyldy",
+  },
+  POLL_NOT_FOUND: {
+    en: "Poll not found",
+    ru: "Опрос не найден",
+    tk: "Sorag-jogap tapylmady",
+  },
+  RATE_LIMITED: {
+    en: "Too many requests, try again later",
+    ru: "Слишком много запросов, попробуйте позже",
+    tk: "Haýyş gaty köp, soňrak synanyşyň",
+  },
+  RENDER_FAILED: {
+    en: "Failed to render the whiteboard",
+    ru: "Не удалось нарисовать доску",
+    tk: "Tagtany çekip bolmady",
+  },
+  ROOM_EXISTS: {
+    en: "A room with this slug already exists",
+    ru: "Комната с таким slug уже есть",
+    tk: "Şeýle slug bilen otag eýýäm bar",
+  },
+  ROOM_FULL: {
+    en: "The room is full",
+    ru: "В комнате нет свободных мест",
+    tk: "Otagda boş ýer ýok",
+  },
+  ROOM_NOT_FOUND: {
+    en: "Room not found, check the link",
+    ru: "Комната не найдена, проверьте ссылку",
+    tk: "Otag tapylmady, salgyny barlaň",
+  },
+  SCAN_UNAVAILABLE: {
+    en: "File check is unavailable, try again later",
+    ru: "Проверка файлов недоступна, попробуйте позже",
+    tk: "Faýl barlagy elýeterli däl, soňrak synanyşyň",
+  },
+  SCHEDULED_LESSON_NOT_FOUND: {
+    en: "Scheduled lesson not found",
+    ru: "Урок в расписании не найден",
+    tk: "Meýilnamadaky sapak tapylmady",
+  },
+  STORAGE_UNAVAILABLE: {
+    en: "File storage is unavailable, try again later",
+    ru: "Хранилище файлов недоступно, попробуйте позже",
+    tk: "Faýl ammary elýeterli däl, soňrak synanyşyň",
+  },
+  TEACHER_KEY_REQUIRED: {
+    en: "Teacher key required",
+    ru: "Нужен ключ учителя",
+    tk: "Mugallymyň açary gerek",
+  },
+  TEACHER_ONLY: {
+    en: "Only the teacher can do this",
+    ru: "Это может сделать только учитель",
+    tk: "Muny diňe mugallym edip biler",
+  },
+  TENANT_CREATE_FAILED: {
+    en: "School could not be created (slug taken?)",
+    ru: "Не удалось создать школу (slug уже занят?)",
+    tk: "Mekdebi döredip bolmady (slug eýýäm bar?)",
+  },
+  TENANT_NOT_FOUND: {
+    en: "Unknown school",
+    ru: "Неизвестная школа",
+    tk: "Näbelli mekdep",
+  },
+  UNAUTHORIZED: {
+    en: "Authentication required",
+    ru: "Требуется авторизация",
+    tk: "Ygtyýarlandyrma gerek",
+  },
+  UPLOAD_FAILED: {
+    en: "Failed to process the file",
+    ru: "Не удалось обработать файл",
+    tk: "Faýly işläp bolmady",
+  },
+  WEBPUSH_DISABLED: {
+    en: "Browser notifications are not available on this server",
+    ru: "Уведомления в браузере на этом сервере не настроены",
+    tk: "Bu serwerde brauzer habarnamalary sazlanmady",
+  },
+  WHITEBOARD_FORBIDDEN: {
+    en: "Students can only draw; editing and erasing is for the teacher",
+    ru: "Ученики могут только рисовать; правка и стирание — у учителя",
+    tk: "Okuwçylar diňe çekip bilýär; üýtgetmek we pozmak mugallymda",
+  },
+};
+
+// тело ответа с ошибкой: { error: ApiErrorBody }
+export type ApiErrorBody = {
+  code: ErrorCode;
+  message: string;
+  trace_id?: string;
+  details?: Record<string, unknown>;
+};